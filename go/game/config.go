@@ -2,32 +2,90 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"net" // New import for net.SplitHostPort
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
 )
 
 // Config holds all the necessary configuration for the game-service.
 type Config struct {
-	ListenAddr                string        // Address for the HTTP server (e.g., ":8082" or "0.0.0.0:8082")
-	ServiceRegistrationPort   int           // The numeric port to register with the cluster (extracted from ListenAddr)
-	RedisAddrs                []string      // Redis server address (e.g., "127.0.0.1:7000")
-	TickInterval              time.Duration // Duration for the game tick (e.g., 50ms)
-	PersistenceInterval       time.Duration // Duration for periodic MongoDB persistence (e.g., 1m)
-	RedisOnlineTTL            time.Duration // TTL for 'online:<uuid>' keys in Redis (e.g., 15s)
-	GameServiceInstanceID     int           // Unique identifier for this game service instance (e.g., 0, 1, 2)
-	TotalGameServiceInstances int           // Total number of active game service instances (e.g., 1, 3)
-	PlayerServiceURL          string        // The url to the used player-service
+	ListenAddr                string                   // Address for the HTTP server (e.g., ":8082" or "0.0.0.0:8082")
+	ServiceRegistrationPort   int                      // The numeric port to register with the cluster (extracted from ListenAddr)
+	RedisAddrs                []string                 // Redis server address (e.g., "127.0.0.1:7000")
+	TickInterval              time.Duration            // Duration for the game tick (e.g., 50ms)
+	PersistenceInterval       time.Duration            // Duration for periodic MongoDB persistence (e.g., 1m)
+	RedisOnlineTTL            time.Duration            // TTL for 'online:<uuid>' keys in Redis (e.g., 15s)
+	RedisSessionTTL           time.Duration            // TTL for 'session:<uuid>' keys, refreshed by heartbeats; bounds leaked sessions (e.g., 1h)
+	SessionReapInterval       time.Duration            // How often to end sessions whose online key expired (e.g., 10s)
+	PartitionLeaseTTL         time.Duration            // How long a partition lease lasts without renewal (e.g., 5s)
+	MaxCatchUpTicks           int                      // Most ticks credited at once after a delay; older elapsed time is dropped (e.g., 200)
+	PersistFlushInterval      time.Duration            // How often online players' playtime is written to the player service (e.g., 1m)
+	PersistBatchSize          int                      // Players per bulk playtime write (e.g., 500)
+	PersistMaxRetries         int                      // Retries for a failed bulk playtime write (e.g., 3)
+	TeamStreamInterval        time.Duration            // How often live team totals are pushed to stream clients (e.g., 1s)
+	LeaderboardUpdateInterval time.Duration            // How often the tick path writes online players' totals to the leaderboards (e.g., 1s)
+	LeaderboardSeedInterval   time.Duration            // How often the leaderboards are re-seeded from MongoDB (e.g., 1h)
+	SeasonCheckInterval       time.Duration            // How often the leader checks whether a season should start or end (e.g., 30s)
+	GameServiceInstanceID     int                      // Unique identifier for this game service instance (e.g., 0, 1, 2)
+	TotalGameServiceInstances int                      // Total number of active game service instances (e.g., 1, 3)
+	PlayerServiceURL          string                   // The url to the used player-service
+	ProxyServiceType          string                   // Service type proxies register under; their departures invalidate presence (e.g., "proxy")
+	AFKTimeout                time.Duration            // How long without input before a player counts as AFK; 0 disables AFK detection (e.g., 5m)
+	AFKPolicy                 string                   // How AFK players accrue playtime: one of the AFKPolicy* constants
+	AFKScale                  float64                  // Fraction of their usual playtime AFK players accrue under AFKPolicyScale (e.g., 0.25)
+	AuthAPIKeys               []api.Credential         // Credentials accepted as static API keys; AuthAPIKeys or AuthHMACKeys are required unless AuthDisabled
+	AuthHMACKeys              []api.Credential         // Credentials accepted for HMAC-signed requests
+	AuthDisabled              bool                     // Serve every route unauthenticated; only for local development
+	CORSAllowedOrigins        []string                 // Origins browsers may call the service and open team streams from; "*" allows any
+	PlayerServiceKeyID        string                   // Key ID signing requests to the player-service; empty sends them unsigned
+	PlayerServiceSecret       string                   // Secret signing requests to the player-service
+	RateLimit                 api.RateLimit            // Requests per caller and route; a zero Rate (the default) disables rate limiting
+	RateLimitCallers          map[string]api.RateLimit // Quotas per credential ID (or "ip:<address>"), overriding RateLimit
+	RateLimitRoutes           map[string]api.RateLimit // Limits per route path template, overriding RateLimit
+	RateLimitAuthFailures     api.RateLimit            // Failed authentications allowed per IP address; a zero Rate allows any number
+	RateLimitRedis            bool                     // Keep rate limit buckets in Redis so limits hold across instances
+	TrustForwardedFor         bool                     // Rate limit anonymous callers by X-Forwarded-For; only behind a proxy that sets it
+	LogLevel                  string                   // Minimum level logged: debug, info, warn or error
+	LogFormat                 string                   // Log output format: json or text
+	Tracing                   api.TracingConfig        // Trace exporter (otlp, stdout, file or none) and sampling
+}
+
+// AFK policies, i.e. how the game tick credits players who are AFK. AFK time is counted under every policy.
+const (
+	AFKPolicyNone  = "none"  // AFK players accrue playtime as usual
+	AFKPolicySkip  = "skip"  // AFK players accrue no playtime
+	AFKPolicyScale = "scale" // AFK players accrue AFKScale of their usual playtime
+)
+
+// AFKMultiplier returns the factor the configured AFK policy applies to AFK players' playtime.
+func (c *Config) AFKMultiplier() float64 {
+	switch c.AFKPolicy {
+	case AFKPolicySkip:
+		return 0
+	case AFKPolicyScale:
+		return c.AFKScale
+	default:
+		return 1
+	}
 }
 
 // LoadConfig loads configuration from environment variables, applying defaults if not set.
 // It returns a Config struct or an error if any required variable is missing or invalid.
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
-		ListenAddr:       os.Getenv("GAME_SERVICE_LISTEN_ADDR"),
-		PlayerServiceURL: os.Getenv("PLAYERS_SERVICE_URL"),
+		ListenAddr:          os.Getenv("GAME_SERVICE_LISTEN_ADDR"),
+		PlayerServiceURL:    os.Getenv("PLAYERS_SERVICE_URL"),
+		ProxyServiceType:    os.Getenv("PROXY_SERVICE_TYPE"),
+		AFKPolicy:           os.Getenv("GAME_SERVICE_AFK_POLICY"),
+		PlayerServiceKeyID:  os.Getenv("PLAYERS_SERVICE_KEY_ID"),
+		PlayerServiceSecret: os.Getenv("PLAYERS_SERVICE_SECRET"),
+		LogLevel:            os.Getenv("LOG_LEVEL"),
+		LogFormat:           os.Getenv("LOG_FORMAT"),
 	}
 
 	var err error
@@ -50,6 +108,10 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.TickInterval < time.Millisecond {
+		return nil, fmt.Errorf("GAME_SERVICE_TICK_INTERVAL must be at least 1ms (got %v)", cfg.TickInterval)
+	}
+
 	cfg.PersistenceInterval, err = getDuration("GAME_SERVICE_PERSISTENCE_INTERVAL", 30*time.Second)
 	if err != nil {
 		return nil, err
@@ -60,6 +122,72 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	cfg.RedisSessionTTL, err = getDuration("REDIS_SESSION_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RedisSessionTTL <= cfg.RedisOnlineTTL {
+		return nil, fmt.Errorf("REDIS_SESSION_TTL (%v) must be longer than REDIS_ONLINE_TTL (%v)", cfg.RedisSessionTTL, cfg.RedisOnlineTTL)
+	}
+
+	cfg.SessionReapInterval, err = getDuration("GAME_SERVICE_SESSION_REAP_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SessionReapInterval <= 0 {
+		return nil, fmt.Errorf("GAME_SERVICE_SESSION_REAP_INTERVAL must be positive (got %v)", cfg.SessionReapInterval)
+	}
+
+	cfg.PartitionLeaseTTL, err = getDuration("GAME_SERVICE_PARTITION_LEASE_TTL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PartitionLeaseTTL <= cfg.TickInterval {
+		return nil, fmt.Errorf("GAME_SERVICE_PARTITION_LEASE_TTL (%v) must be longer than GAME_SERVICE_TICK_INTERVAL (%v)", cfg.PartitionLeaseTTL, cfg.TickInterval)
+	}
+
+	cfg.PersistFlushInterval, err = getDuration("GAME_SERVICE_PERSIST_FLUSH_INTERVAL", 1*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PersistFlushInterval <= 0 {
+		return nil, fmt.Errorf("GAME_SERVICE_PERSIST_FLUSH_INTERVAL must be positive (got %v)", cfg.PersistFlushInterval)
+	}
+
+	cfg.TeamStreamInterval, err = getDuration("GAME_SERVICE_TEAM_STREAM_INTERVAL", 1*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TeamStreamInterval <= 0 {
+		return nil, fmt.Errorf("GAME_SERVICE_TEAM_STREAM_INTERVAL must be positive (got %v)", cfg.TeamStreamInterval)
+	}
+
+	cfg.LeaderboardUpdateInterval, err = getDuration("GAME_SERVICE_LEADERBOARD_UPDATE_INTERVAL", 1*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.LeaderboardSeedInterval, err = getDuration("GAME_SERVICE_LEADERBOARD_SEED_INTERVAL", 1*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.SeasonCheckInterval, err = getDuration("GAME_SERVICE_SEASON_CHECK_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SeasonCheckInterval <= 0 {
+		return nil, fmt.Errorf("GAME_SERVICE_SEASON_CHECK_INTERVAL must be positive (got %v)", cfg.SeasonCheckInterval)
+	}
+
+	cfg.AFKTimeout, err = getDuration("GAME_SERVICE_AFK_TIMEOUT", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AFKTimeout < 0 {
+		return nil, fmt.Errorf("GAME_SERVICE_AFK_TIMEOUT must be non-negative (got %v)", cfg.AFKTimeout)
+	}
+
 	// --- Load Int fields ---
 	getInt := func(envKey string, defaultVal int) (int, error) {
 		valStr := os.Getenv(envKey)
@@ -73,6 +201,30 @@ func LoadConfig() (*Config, error) {
 		return i, nil
 	}
 
+	cfg.MaxCatchUpTicks, err = getInt("GAME_SERVICE_MAX_CATCHUP_TICKS", 200)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxCatchUpTicks < 1 {
+		return nil, fmt.Errorf("GAME_SERVICE_MAX_CATCHUP_TICKS must be at least 1 (got %d)", cfg.MaxCatchUpTicks)
+	}
+
+	cfg.PersistBatchSize, err = getInt("GAME_SERVICE_PERSIST_BATCH_SIZE", 500)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PersistBatchSize < 1 || cfg.PersistBatchSize > 1000 {
+		return nil, fmt.Errorf("GAME_SERVICE_PERSIST_BATCH_SIZE must be between 1 and 1000 (got %d)", cfg.PersistBatchSize)
+	}
+
+	cfg.PersistMaxRetries, err = getInt("GAME_SERVICE_PERSIST_MAX_RETRIES", 3)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PersistMaxRetries < 0 {
+		return nil, fmt.Errorf("GAME_SERVICE_PERSIST_MAX_RETRIES must be non-negative (got %d)", cfg.PersistMaxRetries)
+	}
+
 	cfg.GameServiceInstanceID, err = getInt("GAME_SERVICE_INSTANCE_ID", 0) // Default to 0 for single instance
 	if err != nil {
 		return nil, err
@@ -83,6 +235,18 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	// --- Load Float fields ---
+	cfg.AFKScale = 0.25
+	if valStr := os.Getenv("GAME_SERVICE_AFK_SCALE"); valStr != "" {
+		cfg.AFKScale, err = strconv.ParseFloat(valStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float format for GAME_SERVICE_AFK_SCALE: %w", err)
+		}
+	}
+	if cfg.AFKScale < 0 || cfg.AFKScale > 1 {
+		return nil, fmt.Errorf("GAME_SERVICE_AFK_SCALE must be between 0 and 1 (got %v)", cfg.AFKScale)
+	}
+
 	// --- Load Redis Cluster Addresses ---
 	redisAddrsStr := os.Getenv("REDIS_ADDRS") // New environment variable name, plural for clarity
 	if redisAddrsStr == "" {
@@ -133,6 +297,81 @@ func LoadConfig() (*Config, error) {
 	if cfg.PlayerServiceURL == "" {
 		cfg.PlayerServiceURL = "http://localhost:8081" // Corrected URL scheme
 	}
+	if cfg.ProxyServiceType == "" {
+		cfg.ProxyServiceType = "proxy"
+	}
+	switch cfg.AFKPolicy {
+	case "":
+		cfg.AFKPolicy = AFKPolicyScale
+	case AFKPolicyNone, AFKPolicySkip, AFKPolicyScale:
+	default:
+		return nil, fmt.Errorf("GAME_SERVICE_AFK_POLICY must be one of %q, %q or %q (got %q)", AFKPolicyNone, AFKPolicySkip, AFKPolicyScale, cfg.AFKPolicy)
+	}
+
+	// --- Load service credentials ---
+	cfg.AuthAPIKeys, err = api.ParseCredentials(os.Getenv("SERVICE_AUTH_API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_AUTH_API_KEYS: %w", err)
+	}
+	cfg.AuthHMACKeys, err = api.ParseCredentials(os.Getenv("SERVICE_AUTH_HMAC_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_AUTH_HMAC_KEYS: %w", err)
+	}
+	cfg.AuthDisabled = os.Getenv("SERVICE_AUTH_DISABLED") == "true"
+	if len(cfg.AuthAPIKeys) == 0 && len(cfg.AuthHMACKeys) == 0 && !cfg.AuthDisabled {
+		return nil, fmt.Errorf("no service credentials configured: set SERVICE_AUTH_API_KEYS or SERVICE_AUTH_HMAC_KEYS, or SERVICE_AUTH_DISABLED=true to serve every route unauthenticated")
+	}
+	if (cfg.PlayerServiceKeyID == "") != (cfg.PlayerServiceSecret == "") {
+		return nil, fmt.Errorf("PLAYERS_SERVICE_KEY_ID and PLAYERS_SERVICE_SECRET must be set together")
+	}
+	cfg.CORSAllowedOrigins = api.ParseCORSOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
+
+	// --- Load rate limits ---
+	// Off unless configured; callers with the internal scope are only limited by RATE_LIMIT_CALLERS
+	if valStr := os.Getenv("RATE_LIMIT_RATE"); valStr != "" {
+		cfg.RateLimit.Rate, err = strconv.ParseFloat(valStr, 64)
+		if err != nil || cfg.RateLimit.Rate < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_RATE %q: must be a non-negative number", valStr)
+		}
+	}
+	cfg.RateLimit.Burst = max(1, int(math.Ceil(2*cfg.RateLimit.Rate)))
+	if valStr := os.Getenv("RATE_LIMIT_BURST"); valStr != "" {
+		cfg.RateLimit.Burst, err = strconv.Atoi(valStr)
+		if err != nil || cfg.RateLimit.Burst < 1 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_BURST %q: must be a positive integer", valStr)
+		}
+	}
+	cfg.RateLimitAuthFailures = api.RateLimit{Rate: 1, Burst: 20}
+	if valStr := os.Getenv("RATE_LIMIT_AUTH_FAILURES"); valStr == "0" {
+		cfg.RateLimitAuthFailures = api.RateLimit{}
+	} else if valStr != "" {
+		cfg.RateLimitAuthFailures, err = api.ParseRateLimit(valStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_FAILURES: %w", err)
+		}
+	}
+	cfg.RateLimitCallers, err = api.ParseRateLimits(os.Getenv("RATE_LIMIT_CALLERS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_CALLERS: %w", err)
+	}
+	cfg.RateLimitRoutes, err = api.ParseRateLimits(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ROUTES: %w", err)
+	}
+	cfg.RateLimitRedis = os.Getenv("RATE_LIMIT_REDIS") == "true"
+	cfg.TrustForwardedFor = os.Getenv("TRUST_FORWARDED_FOR") == "true"
+
+	// --- Load tracing ---
+	cfg.Tracing = api.TracingConfig{Exporter: os.Getenv("TRACING_EXPORTER"), File: os.Getenv("TRACING_FILE"), SampleRatio: 1}
+	if cfg.Tracing.File == "" {
+		cfg.Tracing.File = "traces.jsonl"
+	}
+	if valStr := os.Getenv("TRACING_SAMPLE_RATIO"); valStr != "" {
+		cfg.Tracing.SampleRatio, err = strconv.ParseFloat(valStr, 64)
+		if err != nil || cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+			return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO %q: must be a number between 0 and 1", valStr)
+		}
+	}
 
 	// --- Final validation for instance IDs (important even with defaults) ---
 	if cfg.TotalGameServiceInstances <= 0 {
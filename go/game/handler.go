@@ -3,17 +3,18 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/Ftotnem/Backend/go/shared/api"     // Import your shared API module as 'api'
+	"github.com/Ftotnem/Backend/go/shared/events"  // Publishes player lifecycle events
+	"github.com/Ftotnem/Backend/go/shared/models"  // For the player profile passed to ban checks
 	"github.com/Ftotnem/Backend/go/shared/service" // Import the shared service client
 	"github.com/gorilla/mux"                       // Still needed for mux.Vars
 	"go.minekube.com/gate/pkg/util/uuid"           // Required for parsing UUIDs
-	"go.mongodb.org/mongo-driver/mongo"            // To check for ErrNoDocuments
 )
 
 // GameService holds dependencies for HTTP handlers (like the RedisClient and PlayerServiceClient)
@@ -21,6 +22,8 @@ type GameService struct {
 	redisClient         *RedisClient
 	playerServiceClient *service.PlayerServiceClient // New: Client for Player Data Service
 	config              *Config                      // To access config values like RedisOnlineTTL if needed by handlers
+	events              *events.Publisher            // Publishes player lifecycle events
+	proxyCommands       *events.Publisher            // Delivers commands (e.g. kicks) to every proxy
 }
 
 // BanRequest is the structure for the request body for banning/unbanning.
@@ -28,20 +31,122 @@ type BanRequest struct {
 	UUID        string `json:"uuid"`
 	DurationSec int64  `json:"duration_seconds"` // Duration in seconds. 0 for permanent, -1 to unban.
 	Reason      string `json:"reason,omitempty"`
+	IssuedBy    string `json:"issued_by"` // Staff member or system issuing the ban; recorded in the punishment history
+}
+
+// UnbanRequest is the structure for the request body of /game/unban.
+type UnbanRequest struct {
+	UUID      string `json:"uuid"`
+	RevokedBy string `json:"revoked_by"` // Staff member or system lifting the ban; recorded in the punishment history
+	Reason    string `json:"reason,omitempty"`
+}
+
+// GrantBoosterRequest is the structure for the request body of /game/boosters/grant.
+type GrantBoosterRequest struct {
+	UUID        string  `json:"uuid"`
+	Type        string  `json:"type"`
+	Value       float64 `json:"value"`            // Multiplier applied to the player's per-tick increment
+	DurationSec int64   `json:"duration_seconds"` // Duration in seconds. 0 for a booster that never expires.
+	Source      string  `json:"source,omitempty"`
+}
+
+// RevokeBoosterRequest is the structure for the request body of /game/boosters/revoke.
+type RevokeBoosterRequest struct {
+	UUID      string `json:"uuid"`
+	BoosterID string `json:"booster_id"`
 }
 
 // NewGameService creates a new GameService instance.
-func NewGameService(rc *RedisClient, psc *service.PlayerServiceClient, cfg *Config) *GameService {
+func NewGameService(rc *RedisClient, psc *service.PlayerServiceClient, cfg *Config, publisher, proxyCommands *events.Publisher) *GameService {
 	return &GameService{
 		redisClient:         rc,
 		playerServiceClient: psc, // Assign the new client
 		config:              cfg,
+		events:              publisher,
+		proxyCommands:       proxyCommands,
 	}
 }
 
 // OnlineStatusRequest is the structure for the request body of /game/online and /game/offline.
+// SessionID is the ID /game/online returned: it is required on /game/offline, and on /game/online
+// marks a repeated request for a session that is already live rather than a new login.
+// ProxyID and Server say where the player is connected, for presence lookups.
 type OnlineStatusRequest struct {
-	UUID string `json:"uuid"`
+	UUID      string `json:"uuid"`
+	SessionID string `json:"session_id,omitempty"`
+	ProxyID   string `json:"proxy_id,omitempty"` // Registrar ID of the proxy the player joined through
+	Server    string `json:"server,omitempty"`   // Backend server the player is on, if already known
+}
+
+// OnlineResponse is returned by /game/online with the ID of the player's session.
+type OnlineResponse struct {
+	Message   string `json:"message"`
+	UUID      string `json:"uuid"`
+	SessionID string `json:"session_id"`
+}
+
+// BanDeniedResponse is returned with 403 Forbidden when a banned player tries to come online.
+type BanDeniedResponse struct {
+	Message     string     `json:"message"`
+	UUID        string     `json:"uuid"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Nil for permanent bans
+	IsPermanent bool       `json:"is_permanent"`
+}
+
+// HeartbeatRequest is the structure for the request body of /game/heartbeat.
+// Either UUID and SessionID (single player) or Sessions (batch, e.g. every player on a proxy) may be set.
+// ProxyID is the sending proxy and applies to every session in the request.
+type HeartbeatRequest struct {
+	ProxyID   string          `json:"proxy_id,omitempty"`
+	UUID      string          `json:"uuid,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Server    string          `json:"server,omitempty"`
+	Sessions  []PlayerSession `json:"sessions,omitempty"`
+}
+
+// PlayerSession identifies one player's session in a batch heartbeat, along with the backend server they are on.
+type PlayerSession struct {
+	UUID      string `json:"uuid"`
+	SessionID string `json:"session_id"`
+	Server    string `json:"server,omitempty"`
+}
+
+// HeartbeatResponse reports which players had their presence refreshed.
+// Expired lists players whose presence had already lapsed; the proxy should send /game/online for them again.
+// Superseded lists players who have logged in again since their session was issued; the proxy should drop them.
+type HeartbeatResponse struct {
+	Refreshed  int      `json:"refreshed"`
+	Expired    []string `json:"expired"`
+	Superseded []string `json:"superseded"`
+}
+
+// maxActivityClockSkew is how far in the future a reported input time may be before HandleActivity
+// rejects it. Times within it are clamped to the server's clock.
+const maxActivityClockSkew = 30 * time.Second
+
+// ActivityRequest is the structure for the request body of /game/activity.
+// Either UUID, SessionID and LastInputAt (single player) or Players (batch) may be set.
+type ActivityRequest struct {
+	UUID        string           `json:"uuid,omitempty"`
+	SessionID   string           `json:"session_id,omitempty"`
+	LastInputAt time.Time        `json:"last_input_at,omitempty"`
+	Players     []PlayerActivity `json:"players,omitempty"`
+}
+
+// PlayerActivity is the time of a player's last input (movement, chat, commands, ...) as seen by their proxy.
+type PlayerActivity struct {
+	UUID        string    `json:"uuid"`
+	SessionID   string    `json:"session_id"`
+	LastInputAt time.Time `json:"last_input_at"`
+}
+
+// ActivityResponse reports which players had their activity recorded.
+// Expired and Superseded mean the same as in HeartbeatResponse.
+type ActivityResponse struct {
+	Recorded   int      `json:"recorded"`
+	Expired    []string `json:"expired"`
+	Superseded []string `json:"superseded"`
 }
 
 // PlaytimeResponse is the structure for the JSON response for playtime requests.
@@ -85,7 +190,7 @@ func (gs *GameService) handleGetPlaytime(w http.ResponseWriter, r *http.Request)
 		if err == ErrRedisKeyNotFound { // Assuming you have a custom error for key not found
 			api.WriteError(w, http.StatusNotFound, "Playtime data not found for player")
 		} else {
-			log.Printf("Error getting total playtime for %s from Redis: %v", playerUUID.String(), err)
+			api.Logger(ctx).Error("Failed to get total playtime from Redis", "uuid", playerUUID.String(), "error", err)
 			api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve total playtime")
 		}
 		return
@@ -119,7 +224,7 @@ func (gs *GameService) handleGetDeltaPlaytime(w http.ResponseWriter, r *http.Req
 			// Return 0.0 with 200 OK if delta playtime is not found, as per Java client expectation
 			api.WriteJSON(w, http.StatusOK, DeltaPlaytimeResponse{Deltatime: 0.0})
 		} else {
-			log.Printf("Error getting delta playtime for %s from Redis: %v", playerUUID.String(), err)
+			api.Logger(ctx).Error("Failed to get delta playtime from Redis", "uuid", playerUUID.String(), "error", err)
 			api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve delta playtime")
 		}
 		return
@@ -130,9 +235,11 @@ func (gs *GameService) handleGetDeltaPlaytime(w http.ResponseWriter, r *http.Req
 
 // --- NEW HANDLER METHODS END ---
 
-// HandleOnline handles requests to mark a player as online.
+// HandleOnline handles requests to mark a player as online and returns the session ID that
+// /game/offline and /game/heartbeat must echo. A repeated request carrying the current session ID
+// only refreshes presence; one carrying a superseded session ID is rejected with 409 Conflict.
 // POST /game/online
-// Body: { "uuid": "<player_uuid>" }
+// Body: { "uuid": "<player_uuid>", "session_id": "<session_id, if re-sending for a live session>", "proxy_id": "...", "server": "..." }
 func (gs *GameService) HandleOnline(w http.ResponseWriter, r *http.Request) {
 	var req OnlineStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -149,61 +256,120 @@ func (gs *GameService) HandleOnline(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second) // Increased timeout for external service call
 	defer cancel()
 
+	epoch, rejected := gs.rejectDuringRollover(ctx, w)
+	if rejected {
+		return
+	}
+
 	// Check if player's playtime data already exists in Redis
 	playtimeExists, deltaPlaytimeExists, err := gs.redisClient.CheckPlaytimeKeysExist(ctx, playerUUID.String())
 	if err != nil {
-		log.Printf("Error checking playtime keys for %s in Redis: %v", playerUUID.String(), err)
+		api.Logger(ctx).Error("Failed to check playtime keys in Redis", "uuid", playerUUID.String(), "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to check player data status")
 		return
 	}
+	hasSession := playtimeExists && deltaPlaytimeExists
+
+	// Fetch the profile up front: it is needed for the ban check and to load a new session.
+	profile, profileErr := gs.playerServiceClient.GetProfile(ctx, playerUUID)
+	if profileErr != nil && !errors.Is(profileErr, api.ErrNotFound) {
+		if !hasSession {
+			// Other errors getting profile from Player Data Service
+			api.Logger(ctx).Error("Failed to get player profile from Player Data Service", "uuid", playerUUID.String(), "error", profileErr)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve player profile for playtime sync")
+			return
+		}
+		// The session is already loaded, so only the profile ban check is skipped
+		api.Logger(ctx).Warn("Could not fetch profile for ban check, relying on Redis ban state", "uuid", playerUUID.String(), "error", profileErr)
+	}
 
-	if !playtimeExists || !deltaPlaytimeExists {
-		log.Printf("Player %s is coming online for the first time this session. Loading/Initializing playtime data.", playerUUID.String())
+	// Banned players must never be marked online
+	denial, err := gs.checkBan(ctx, playerUUID, profile)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to check ban status", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to check player ban status")
+		return
+	}
+	if denial != nil {
+		api.WriteJSON(w, http.StatusForbidden, denial)
+		api.Logger(ctx).Info("Denied online for banned player", "uuid", playerUUID.String(), "permanent", denial.IsPermanent, "reason", denial.Reason)
+		return
+	}
 
-		// Attempt to get player profile from Player Data Service
-		profile, err := gs.playerServiceClient.GetProfile(ctx, playerUUID)
-		if err != nil {
-			if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusNotFound {
-				// Profile not found in MongoDB (player data service). Initialize with defaults.
-				log.Printf("Profile for %s not found in Player Data Service. Initializing default playtime values in Redis.", playerUUID.String())
-				err = gs.redisClient.SetPlayerPlaytime(ctx, playerUUID.String(), 0.0) // Default total playtime
-				if err != nil {
-					log.Printf("Error setting default total playtime for %s: %v", playerUUID.String(), err)
-					api.WriteError(w, http.StatusInternalServerError, "Failed to set default playtime")
-					return
-				}
-				err = gs.redisClient.SetDeltaPlaytime(ctx, playerUUID.String(), 1.0) // Default delta playtime
-				if err != nil {
-					log.Printf("Error setting default delta playtime for %s: %v", playerUUID.String(), err)
-					api.WriteError(w, http.StatusInternalServerError, "Failed to set default delta playtime")
-					return
-				}
-				// The client is responsible for creating the profile in MongoDB if needed.
-				// This service just syncs with Redis or initializes local state.
-			} else if mongo.IsDuplicateKeyError(err) {
-				// This case should ideally not happen for a GET operation, but as a safeguard.
-				log.Printf("WARN: Duplicate key error during GET for %s, this is unexpected: %v", playerUUID.String(), err)
-				api.WriteError(w, http.StatusInternalServerError, "Unexpected error during profile retrieval")
+	// The profile has been reset for a season that Redis has not caught up with yet
+	if profile != nil && profile.SeasonEpoch > epoch {
+		w.Header().Set("Retry-After", "5")
+		api.WriteError(w, http.StatusServiceUnavailable, "Season is changing; try again shortly")
+		api.Logger(ctx).Warn("Rejected online ahead of the season epoch", "uuid", playerUUID.String(), "profile_epoch", profile.SeasonEpoch, "epoch", epoch)
+		return
+	}
+
+	sessionID, started, err := gs.redisClient.ClaimSession(ctx, playerUUID.String(), req.SessionID)
+	if errors.Is(err, ErrSessionSuperseded) {
+		api.WriteError(w, http.StatusConflict, "Session has been superseded by a newer login")
+		api.Logger(ctx).Info("Rejected stale online", "uuid", playerUUID.String(), "session_id", req.SessionID)
+		return
+	}
+	if err != nil {
+		api.Logger(ctx).Error("Failed to claim session", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to start player session")
+		return
+	}
+	if !started {
+		// A repeated online for the live session: nothing to load, just keep presence alive
+		if err := gs.redisClient.SetOnlineStatus(ctx, playerUUID.String()); err != nil {
+			api.Logger(ctx).Error("Failed to set online status", "uuid", playerUUID.String(), "error", err)
+			api.WriteError(w, http.StatusInternalServerError, "Failed to set player online status")
+			return
+		}
+		gs.recordLocation(ctx, playerUUID.String(), req.ProxyID, req.Server)
+		api.WriteJSON(w, http.StatusOK, OnlineResponse{Message: "Player already online", UUID: playerUUID.String(), SessionID: sessionID})
+		return
+	}
+
+	// Check again now that the session is ours: an offline for the previous session may have
+	// cleared its data in the meantime, and it cannot clear anything from here on.
+	playtimeExists, deltaPlaytimeExists, err = gs.redisClient.CheckPlaytimeKeysExist(ctx, playerUUID.String())
+	if err != nil {
+		api.Logger(ctx).Error("Failed to check playtime keys in Redis", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to check player data status")
+		return
+	}
+	hasSession = playtimeExists && deltaPlaytimeExists
+
+	if !hasSession {
+		api.Logger(ctx).Info("Player is coming online for the first time this session, loading playtime data", "uuid", playerUUID.String())
+
+		if profile == nil {
+			// Profile not found in MongoDB (player data service). Initialize with defaults.
+			api.Logger(ctx).Info("Profile not found in Player Data Service, initializing default playtime in Redis", "uuid", playerUUID.String())
+			err = gs.redisClient.SetPlayerPlaytime(ctx, playerUUID.String(), 0.0, epoch) // Default total playtime
+			if err != nil {
+				api.Logger(ctx).Error("Failed to set default total playtime", "uuid", playerUUID.String(), "error", err)
+				api.WriteError(w, http.StatusInternalServerError, "Failed to set default playtime")
 				return
-			} else {
-				// Other errors getting profile from Player Data Service
-				log.Printf("Error getting player profile %s from Player Data Service: %v", playerUUID.String(), err)
-				api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve player profile for playtime sync")
+			}
+			err = gs.redisClient.SetDeltaPlaytime(ctx, playerUUID.String(), 1.0) // Default delta playtime
+			if err != nil {
+				api.Logger(ctx).Error("Failed to set default delta playtime", "uuid", playerUUID.String(), "error", err)
+				api.WriteError(w, http.StatusInternalServerError, "Failed to set default delta playtime")
 				return
 			}
+			// The client is responsible for creating the profile in MongoDB if needed.
+			// This service just syncs with Redis or initializes local state.
 		} else {
 			// Profile found in Player Data Service. Load existing values into Redis.
-			log.Printf("Profile for %s found in Player Data Service. Loading playtime: %.2f, delta: %.2f into Redis.",
-				playerUUID.String(), profile.TotalPlaytimeTicks, profile.DeltaPlaytimeTicks)
-			err = gs.redisClient.SetPlayerPlaytime(ctx, playerUUID.String(), profile.TotalPlaytimeTicks)
+			api.Logger(ctx).Info("Profile found in Player Data Service, loading playtime into Redis",
+				"uuid", playerUUID.String(), "total", profile.TotalPlaytimeTicks, "delta", profile.DeltaPlaytimeTicks)
+			err = gs.redisClient.SetPlayerPlaytime(ctx, playerUUID.String(), profile.TotalPlaytimeTicks, epoch)
 			if err != nil {
-				log.Printf("Error setting total playtime from DB for %s: %v", playerUUID.String(), err)
+				api.Logger(ctx).Error("Failed to set total playtime from DB", "uuid", playerUUID.String(), "error", err)
 				api.WriteError(w, http.StatusInternalServerError, "Failed to set playtime from DB")
 				return
 			}
 			err = gs.redisClient.SetDeltaPlaytime(ctx, playerUUID.String(), profile.DeltaPlaytimeTicks)
 			if err != nil {
-				log.Printf("Error setting delta playtime from DB for %s: %v", playerUUID.String(), err)
+				api.Logger(ctx).Error("Failed to set delta playtime from DB", "uuid", playerUUID.String(), "error", err)
 				api.WriteError(w, http.StatusInternalServerError, "Failed to set delta playtime from DB")
 				return
 			}
@@ -211,66 +377,67 @@ func (gs *GameService) HandleOnline(w http.ResponseWriter, r *http.Request) {
 			if profile.Team != "" {
 				err = gs.redisClient.SetPlayerTeam(ctx, playerUUID.String(), profile.Team)
 				if err != nil {
-					log.Printf("WARN: Failed to set player team %s for %s in Redis: %v", profile.Team, playerUUID.String(), err)
+					api.Logger(ctx).Warn("Failed to set player team in Redis", "uuid", playerUUID.String(), "team", profile.Team, "error", err)
 					// Not a critical error to prevent login, just log
 				}
 			}
+			// Mirror the player's boosters so the game tick can apply them
+			err = gs.redisClient.SetPlayerBoosters(ctx, playerUUID.String(), profile.Boosters)
+			if err != nil {
+				api.Logger(ctx).Warn("Failed to mirror boosters into Redis", "uuid", playerUUID.String(), "error", err)
+				// Not a critical error to prevent login, just log
+			}
+		}
+
+		afkTicks := 0.0
+		if profile != nil {
+			afkTicks = profile.AFKTicks
 		}
+		// Without this key the game tick still applies the AFK policy, it just stops counting AFK time
+		if err := gs.redisClient.SetAFKTicks(ctx, playerUUID.String(), afkTicks); err != nil {
+			api.Logger(ctx).Warn("Failed to load AFK ticks into Redis", "uuid", playerUUID.String(), "error", err)
+		}
+
+		// Mutes are checked in Redis when the player chats, so make sure an active one is there
+		gs.restoreMute(ctx, playerUUID)
 	} else {
 		// When handlel online happens also try to save the playtime to player-sercice
-		// 1. Get playtime and delta playtime from Redis
-		totalPlaytime, deltaPlaytime, err := gs.redisClient.GetPlayerPlaytimeAndDelta(ctx, playerUUID.String())
-		if err != nil {
-			log.Printf("Error retrieving playtime for %s from Redis: %v", playerUUID.String(), err)
-			// Don't fail here if data is missing, we still want to mark offline
+		if err := gs.persistPlayerPlaytime(ctx, playerUUID); err != nil {
+			api.Logger(ctx).Error("Failed to retrieve playtime from Redis", "uuid", playerUUID.String(), "error", err)
 			api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve player playtime from Redis")
 			return
 		}
-
-		// 2. Persist playtime to Player Data Service (MongoDB)
-		// Only attempt to update if playtime data was actually retrieved from Redis
-		if totalPlaytime > 0 || deltaPlaytime > 0 { // Check if there's *some* data to persist
-			log.Printf("Persisting playtime for %s: Total=%.2f, Delta=%.2f", playerUUID.String(), totalPlaytime, deltaPlaytime)
-			// Update total playtime in MongoDB
-			err = gs.playerServiceClient.UpdateProfilePlaytime(ctx, playerUUID, totalPlaytime)
-			if err != nil {
-				log.Printf("Error updating total playtime for %s in Player Data Service: %v", playerUUID.String(), err)
-				// Log and continue, don't block offline process for this.
-			}
-
-			// Update delta playtime in MongoDB (often reset to 0 after persistence on player data service side)
-			err = gs.playerServiceClient.UpdateProfileDeltaPlaytime(ctx, playerUUID, deltaPlaytime)
-			if err != nil {
-				log.Printf("Error updating delta playtime for %s in Player Data Service: %v", playerUUID.String(), err)
-				// Log and continue
-			}
-
-			// Update LastLoginAt in MongoDB
-			err = gs.playerServiceClient.UpdateProfileLastLogin(ctx, playerUUID)
-			if err != nil {
-				log.Printf("Error updating last login for %s in Player Data Service: %v", playerUUID.String(), err)
-				// Log and continue
-			}
-		} else {
-			log.Printf("No playtime data found in Redis for %s to persist to Player Data Service.", playerUUID.String())
-		}
 	}
 
 	// Mark player as online in Redis (always done after playtime sync)
 	err = gs.redisClient.SetOnlineStatus(ctx, playerUUID.String())
 	if err != nil {
-		log.Printf("Error setting player %s online status: %v", playerUUID.String(), err)
+		api.Logger(ctx).Error("Failed to set online status", "uuid", playerUUID.String(), "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to set player online status")
 		return
 	}
+	gs.recordLocation(ctx, playerUUID.String(), req.ProxyID, req.Server)
+
+	team := ""
+	if profile != nil {
+		team = profile.Team
+	}
+	gs.publishEvent(ctx, models.EventPlayerOnline, playerUUID.String(), models.PlayerOnlineData{
+		Team:       team,
+		NewSession: !hasSession,
+		ProxyID:    req.ProxyID,
+		Server:     req.Server,
+	})
 
-	api.WriteJSON(w, http.StatusOK, map[string]string{"message": "Player set online", "uuid": playerUUID.String()})
-	log.Printf("Player %s is now online.", playerUUID.String())
+	api.WriteJSON(w, http.StatusOK, OnlineResponse{Message: "Player set online", UUID: playerUUID.String(), SessionID: sessionID})
+	api.Logger(ctx).Info("Player is now online", "uuid", playerUUID.String(), "session_id", sessionID)
 }
 
 // HandleOffline handles requests to mark a player as offline and persist playtime.
+// Only the player's current session can be ended: a stale offline (the player has logged in again)
+// is rejected with 409 Conflict and a repeated one (the session has already ended) with 410 Gone.
 // POST /game/offline
-// Body: { "uuid": "<player_uuid>" }
+// Body: { "uuid": "<player_uuid>", "session_id": "<session_id>" }
 func (gs *GameService) HandleOffline(w http.ResponseWriter, r *http.Request) {
 	var req OnlineStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -283,65 +450,334 @@ func (gs *GameService) HandleOffline(w http.ResponseWriter, r *http.Request) {
 		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
 		return
 	}
+	if req.SessionID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Session ID is required")
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second) // Increased timeout for external service call
 	defer cancel()
 
-	// 1. Get playtime and delta playtime from Redis
-	totalPlaytime, deltaPlaytime, err := gs.redisClient.GetPlayerPlaytimeAndDelta(ctx, playerUUID.String())
+	if _, rejected := gs.rejectDuringRollover(ctx, w); rejected {
+		return
+	}
+
+	err = gs.endPlayerSession(ctx, playerUUID, req.SessionID)
+	if errors.Is(err, ErrSessionSuperseded) {
+		api.WriteError(w, http.StatusConflict, "Session has been superseded by a newer login")
+		api.Logger(ctx).Info("Ignored stale offline", "uuid", playerUUID.String(), "session_id", req.SessionID)
+		return
+	}
+	if errors.Is(err, ErrSessionEnded) {
+		api.WriteError(w, http.StatusGone, "Session has already ended")
+		api.Logger(ctx).Info("Ignored repeated offline", "uuid", playerUUID.String(), "session_id", req.SessionID)
+		return
+	}
 	if err != nil {
-		log.Printf("Error retrieving playtime for %s from Redis: %v", playerUUID.String(), err)
-		// Don't fail here if data is missing, we still want to mark offline
-		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve player playtime from Redis")
+		api.Logger(ctx).Error("Failed to end session", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to set player offline status")
 		return
 	}
 
-	// 2. Persist playtime to Player Data Service (MongoDB)
-	// Only attempt to update if playtime data was actually retrieved from Redis
-	if totalPlaytime > 0 || deltaPlaytime > 0 { // Check if there's *some* data to persist
-		log.Printf("Persisting playtime for %s: Total=%.2f, Delta=%.2f", playerUUID.String(), totalPlaytime, deltaPlaytime)
-		// Update total playtime in MongoDB
-		err = gs.playerServiceClient.UpdateProfilePlaytime(ctx, playerUUID, totalPlaytime)
+	gs.publishEvent(ctx, models.EventPlayerOffline, playerUUID.String(), models.PlayerOfflineData{Reason: models.OfflineReasonOffline})
+
+	api.WriteJSON(w, http.StatusOK, map[string]string{"message": "Player set offline", "uuid": playerUUID.String()})
+	api.Logger(ctx).Info("Player is now offline, data persisted and Redis session keys cleared", "uuid", playerUUID.String())
+}
+
+// checkBan returns a denial if the player is banned, or nil if they may come online.
+// The Redis ban key is authoritative while it exists; the profile (if any) catches bans
+// Redis has lost, and those are mirrored back into Redis for subsequent checks.
+func (gs *GameService) checkBan(ctx context.Context, playerUUID uuid.UUID, profile *models.Player) (*BanDeniedResponse, error) {
+	banned, err := gs.redisClient.IsBanned(ctx, playerUUID.String())
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		expiresAtUnix, reason, err := gs.redisClient.GetBanDetails(ctx, playerUUID.String())
+		if err == ErrRedisKeyNotFound {
+			return nil, nil // Ban expired between the two reads
+		}
 		if err != nil {
-			log.Printf("Error updating total playtime for %s in Player Data Service: %v", playerUUID.String(), err)
-			// Log and continue, don't block offline process for this.
+			return nil, err
+		}
+		denial := &BanDeniedResponse{Message: "Player is banned", UUID: playerUUID.String(), Reason: reason, IsPermanent: expiresAtUnix <= 0}
+		if !denial.IsPermanent {
+			expiresAt := time.Unix(expiresAtUnix, 0)
+			denial.ExpiresAt = &expiresAt
 		}
+		return denial, nil
+	}
 
-		// Update delta playtime in MongoDB (often reset to 0 after persistence on player data service side)
-		err = gs.playerServiceClient.UpdateProfileDeltaPlaytime(ctx, playerUUID, deltaPlaytime)
+	if profile == nil || !profile.Banned {
+		return nil, nil
+	}
+	if profile.BanExpiresAt != nil && !time.Now().Before(*profile.BanExpiresAt) {
+		return nil, nil // Ban recorded in MongoDB has run out
+	}
+
+	// The profile only records that the player is banned; the reason lives in the punishment history
+	reason := ""
+	bans, err := gs.playerServiceClient.GetPunishments(ctx, playerUUID, models.PunishmentTypeBan, true)
+	if err != nil {
+		api.Logger(ctx).Warn("Failed to get active bans for the ban reason", "uuid", playerUUID.String(), "error", err)
+	} else if len(bans) > 0 {
+		reason = bans[0].Reason
+	}
+
+	var expiresAtUnix int64 // 0 marks a permanent ban in Redis
+	if profile.BanExpiresAt != nil {
+		expiresAtUnix = profile.BanExpiresAt.Unix()
+	}
+	if _, _, err := gs.redisClient.SetBanStatus(ctx, playerUUID.String(), true, expiresAtUnix, reason); err != nil {
+		api.Logger(ctx).Warn("Failed to mirror MongoDB ban into Redis", "uuid", playerUUID.String(), "error", err)
+	}
+	return &BanDeniedResponse{
+		Message:     "Player is banned",
+		UUID:        playerUUID.String(),
+		Reason:      reason,
+		ExpiresAt:   profile.BanExpiresAt,
+		IsPermanent: profile.BanExpiresAt == nil,
+	}, nil
+}
+
+// HandleHeartbeat handles keepalive requests that refresh players' online presence.
+// Only the session a heartbeat names is refreshed, so a proxy holding a superseded session cannot keep it alive.
+// POST /game/heartbeat
+// Body: { "proxy_id": "...", "uuid": "<player_uuid>", "session_id": "<session_id>", "server": "..." }
+// or { "proxy_id": "...", "sessions": [{ "uuid": ..., "session_id": ..., "server": ... }, ...] }
+func (gs *GameService) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var req HeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rawSessions := req.Sessions
+	if req.UUID != "" {
+		rawSessions = append(rawSessions, PlayerSession{UUID: req.UUID, SessionID: req.SessionID, Server: req.Server})
+	}
+	if len(rawSessions) == 0 {
+		api.WriteError(w, http.StatusBadRequest, "At least one player session is required")
+		return
+	}
+
+	sessions := make(map[string]string, len(rawSessions))
+	servers := make(map[string]string, len(rawSessions))
+	for _, raw := range rawSessions {
+		playerUUID, err := uuid.Parse(raw.UUID)
 		if err != nil {
-			log.Printf("Error updating delta playtime for %s in Player Data Service: %v", playerUUID.String(), err)
-			// Log and continue
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Invalid UUID format: %s", raw.UUID))
+			return
+		}
+		if raw.SessionID == "" {
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Session ID is required for player %s", raw.UUID))
+			return
 		}
+		sessions[playerUUID.String()] = raw.SessionID
+		servers[playerUUID.String()] = raw.Server
+	}
 
-		// Update LastLoginAt in MongoDB
-		err = gs.playerServiceClient.UpdateProfileLastLogin(ctx, playerUUID)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	results, err := gs.redisClient.RefreshOnlineStatus(ctx, sessions)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to refresh online status", "players", len(sessions), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to refresh player online status")
+		return
+	}
+
+	resp := HeartbeatResponse{Expired: []string{}, Superseded: []string{}}
+	var locations []models.PlayerPresence
+	for playerUUID, result := range results {
+		switch result {
+		case nil:
+			resp.Refreshed++
+			if req.ProxyID != "" || servers[playerUUID] != "" {
+				locations = append(locations, models.PlayerPresence{UUID: playerUUID, ProxyID: req.ProxyID, Server: servers[playerUUID]})
+			}
+		case ErrSessionSuperseded:
+			resp.Superseded = append(resp.Superseded, playerUUID)
+		default:
+			resp.Expired = append(resp.Expired, playerUUID)
+		}
+	}
+	if err := gs.redisClient.SetPlayerLocations(ctx, locations); err != nil {
+		api.Logger(ctx).Warn("Failed to update player locations", "players", len(locations), "error", err)
+	}
+
+	api.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleActivity records when players last gave any input, which the game tick uses to detect AFK players.
+// Only the session a report names is updated, as for heartbeats. Proxies should report on join and
+// then at least once per AFK timeout while input continues; players never reported are treated as active.
+// Input times are clamped to the server's clock, and those more than maxActivityClockSkew ahead of it are rejected.
+// POST /game/activity
+// Body: { "uuid": "<player_uuid>", "session_id": "<session_id>", "last_input_at": "<RFC 3339 time>" }
+// or { "players": [{ "uuid": ..., "session_id": ..., "last_input_at": ... }, ...] }
+func (gs *GameService) HandleActivity(w http.ResponseWriter, r *http.Request) {
+	var req ActivityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	activity := req.Players
+	if req.UUID != "" {
+		activity = append(activity, PlayerActivity{UUID: req.UUID, SessionID: req.SessionID, LastInputAt: req.LastInputAt})
+	}
+	if len(activity) == 0 {
+		api.WriteError(w, http.StatusBadRequest, "At least one player is required")
+		return
+	}
+
+	now := time.Now()
+	for i, a := range activity {
+		playerUUID, err := uuid.Parse(a.UUID)
 		if err != nil {
-			log.Printf("Error updating last login for %s in Player Data Service: %v", playerUUID.String(), err)
-			// Log and continue
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Invalid UUID format: %s", a.UUID))
+			return
 		}
-	} else {
-		log.Printf("No playtime data found in Redis for %s to persist to Player Data Service.", playerUUID.String())
+		if a.SessionID == "" {
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Session ID is required for player %s", a.UUID))
+			return
+		}
+		if a.LastInputAt.IsZero() {
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Last input time is required for player %s", a.UUID))
+			return
+		}
+		if a.LastInputAt.After(now.Add(maxActivityClockSkew)) {
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Last input time for player %s is in the future", a.UUID))
+			return
+		}
+		activity[i].UUID = playerUUID.String()
 	}
 
-	// 3. Remove player-specific Redis keys (playtime, deltatime, team, online status)
-	// This ensures a fresh load from DB next session
-	err = gs.redisClient.RemovePlayerSessionData(ctx, playerUUID.String())
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	results, err := gs.redisClient.RecordActivity(ctx, activity)
 	if err != nil {
-		log.Printf("Error removing session data for player %s from Redis: %v", playerUUID.String(), err)
-		// Log and continue, attempt to mark offline anyway
+		api.Logger(ctx).Error("Failed to record player activity", "players", len(activity), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to record player activity")
+		return
+	}
+
+	resp := ActivityResponse{Expired: []string{}, Superseded: []string{}}
+	for playerUUID, result := range results {
+		switch result {
+		case nil:
+			resp.Recorded++
+		case ErrSessionSuperseded:
+			resp.Superseded = append(resp.Superseded, playerUUID)
+		default:
+			resp.Expired = append(resp.Expired, playerUUID)
+		}
 	}
+	api.WriteJSON(w, http.StatusOK, resp)
+}
 
-	// Finally, mark player as offline
-	err = gs.redisClient.SetOfflineStatus(ctx, playerUUID.String())
+// persistPlayerPlaytime copies a player's Redis playtime and delta to the Player Data Service
+// and bumps their last login. Player-service failures are logged rather than returned, so
+// callers only fail when Redis itself cannot be read.
+func (gs *GameService) persistPlayerPlaytime(ctx context.Context, playerUUID uuid.UUID) error {
+	// 1. Get playtime and delta playtime from Redis. The season epoch is read first: a reset in
+	// between then leaves the total stamped with the older epoch, which MongoDB rejects.
+	seasonEpoch, err := gs.redisClient.GetPlayerSeasonEpoch(ctx, playerUUID.String())
 	if err != nil {
-		log.Printf("Error setting player %s offline status: %v", playerUUID.String(), err)
-		api.WriteError(w, http.StatusInternalServerError, "Failed to set player offline status")
+		return err
+	}
+	totalPlaytime, deltaPlaytime, err := gs.redisClient.GetPlayerPlaytimeAndDelta(ctx, playerUUID.String())
+	if err != nil {
+		return err
+	}
+
+	// 2. Persist playtime to Player Data Service (MongoDB)
+	// Only attempt to update if playtime data was actually retrieved from Redis
+	if totalPlaytime <= 0 && deltaPlaytime <= 0 { // Check if there's *some* data to persist
+		api.Logger(ctx).Info("No playtime data in Redis to persist to Player Data Service", "uuid", playerUUID.String())
+		return nil
+	}
+
+	api.Logger(ctx).Info("Persisting playtime", "uuid", playerUUID.String(), "total", totalPlaytime, "delta", deltaPlaytime)
+	// Update total playtime in MongoDB
+	err = gs.playerServiceClient.UpdateProfilePlaytime(ctx, playerUUID, totalPlaytime, seasonEpoch)
+	if errors.Is(err, api.ErrConflict) {
+		api.Logger(ctx).Warn("Dropped total playtime from before a season reset", "uuid", playerUUID.String(), "season_epoch", seasonEpoch)
+	} else if err != nil {
+		api.Logger(ctx).Error("Failed to update total playtime in Player Data Service", "uuid", playerUUID.String(), "error", err)
+		// Log and continue, don't block offline process for this.
+	}
+
+	// Update delta playtime in MongoDB (often reset to 0 after persistence on player data service side)
+	err = gs.playerServiceClient.UpdateProfileDeltaPlaytime(ctx, playerUUID, deltaPlaytime)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to update delta playtime in Player Data Service", "uuid", playerUUID.String(), "error", err)
+		// Log and continue
+	}
+
+	// Update the AFK total in MongoDB, if one was loaded for this session
+	afkTicks, err := gs.redisClient.GetAFKTicks(ctx, playerUUID.String())
+	if err == nil {
+		if err := gs.playerServiceClient.UpdateProfileAFKTicks(ctx, playerUUID, afkTicks); err != nil {
+			api.Logger(ctx).Error("Failed to update AFK ticks in Player Data Service", "uuid", playerUUID.String(), "error", err)
+			// Log and continue
+		}
+	} else if err != ErrRedisKeyNotFound {
+		api.Logger(ctx).Error("Failed to read AFK ticks from Redis", "uuid", playerUUID.String(), "error", err)
+	}
+
+	// Update LastLoginAt in MongoDB
+	err = gs.playerServiceClient.UpdateProfileLastLogin(ctx, playerUUID)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to update last login in Player Data Service", "uuid", playerUUID.String(), "error", err)
+		// Log and continue
+	}
+	return nil
+}
+
+// publishEvent publishes a player lifecycle event. Events are published after the change has been
+// made, so a failure is logged rather than failing the request.
+func (gs *GameService) publishEvent(ctx context.Context, eventType, playerUUID string, data interface{}) {
+	// Publish even if the request is cancelled after the change was made
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	if _, err := gs.events.Publish(ctx, eventType, playerUUID, data); err != nil {
+		api.Logger(ctx).Warn("Failed to publish event", "type", eventType, "uuid", playerUUID, "error", err)
+	}
+}
+
+// recordLocation stores which proxy and backend server a player is on. Location only feeds presence
+// lookups, so a failure is logged rather than failing the request.
+func (gs *GameService) recordLocation(ctx context.Context, playerUUID, proxyID, server string) {
+	if proxyID == "" && server == "" {
 		return
 	}
+	location := models.PlayerPresence{UUID: playerUUID, ProxyID: proxyID, Server: server}
+	if err := gs.redisClient.SetPlayerLocations(ctx, []models.PlayerPresence{location}); err != nil {
+		api.Logger(ctx).Warn("Failed to record player location", "uuid", playerUUID, "error", err)
+	}
+}
 
-	api.WriteJSON(w, http.StatusOK, map[string]string{"message": "Player set offline", "uuid": playerUUID.String()})
-	log.Printf("Player %s is now offline. Data persisted and Redis session keys cleared.", playerUUID.String())
+// endPlayerSession persists a player's playtime and clears their Redis session, provided sessionID
+// is still the player's current session; otherwise it returns ErrSessionEnded or ErrSessionSuperseded.
+// It backs both HandleOffline and the SessionReaper, so an expired session is
+// wound down exactly like an explicit offline.
+func (gs *GameService) endPlayerSession(ctx context.Context, playerUUID uuid.UUID, sessionID string) error {
+	if err := gs.redisClient.CheckSession(ctx, playerUUID.String(), sessionID); err != nil {
+		return err
+	}
+
+	if err := gs.persistPlayerPlaytime(ctx, playerUUID); err != nil {
+		return fmt.Errorf("failed to retrieve player playtime from Redis: %w", err)
+	}
+
+	// Remove player-specific Redis keys (playtime, deltatime, team, online status) and mark the player offline.
+	// This ensures a fresh load from DB next session. A login since the check above keeps its session;
+	// persisting first is harmless then, since the new session carries on from the same values.
+	return gs.redisClient.EndSession(ctx, playerUUID.String(), sessionID)
 }
 
 // GetTeamTotal handles requests to retrieve the total playtime for a specific team.
@@ -362,7 +798,7 @@ func (gs *GameService) GetTeamTotal(w http.ResponseWriter, r *http.Request) {
 	totalPlaytime, err := gs.redisClient.GetTeamTotalPlaytime(ctx, teamID)
 	if err != nil {
 		// Log the detailed error for debugging, but return a generic error to the client
-		log.Printf("Error retrieving total playtime for team '%s': %v", teamID, err)
+		api.Logger(ctx).Error("Failed to retrieve team total playtime", "team", teamID, "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve team total playtime")
 		return
 	}
@@ -389,20 +825,83 @@ func (gs *GameService) GetPlayerOnlineStatus(w http.ResponseWriter, r *http.Requ
 
 	isOnline, err := gs.redisClient.IsOnline(ctx, uuid)
 	if err != nil {
-		log.Printf("Error checking online status for %s: %v", uuid, err)
+		api.Logger(ctx).Error("Failed to check online status", "uuid", uuid, "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to check player online status")
 		return
 	}
 
-	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+	response := map[string]interface{}{
 		"uuid":     uuid,
 		"isOnline": isOnline,
-	})
+	}
+	if isOnline {
+		location, err := gs.redisClient.GetPlayerPresence(ctx, uuid)
+		if err != nil {
+			api.Logger(ctx).Warn("Failed to get player location", "uuid", uuid, "error", err)
+		} else if location != nil {
+			response["proxyId"] = location.ProxyID
+			response["server"] = location.Server
+		}
+
+		afkSince, err := gs.redisClient.GetAFKSince(ctx, uuid)
+		if err != nil {
+			api.Logger(ctx).Warn("Failed to get player AFK state", "uuid", uuid, "error", err)
+		} else {
+			response["isAfk"] = afkSince != nil
+			if afkSince != nil {
+				response["afkSince"] = afkSince
+			}
+		}
+	}
+
+	api.WriteJSON(w, http.StatusOK, response)
+}
+
+// GetProxyPlayers lists the players connected through a proxy.
+// GET /game/proxies/{proxyID}/players
+func (gs *GameService) GetProxyPlayers(w http.ResponseWriter, r *http.Request) {
+	proxyID := mux.Vars(r)["proxyID"]
+	if proxyID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Proxy ID is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	players, err := gs.redisClient.GetPlayersOnProxy(ctx, proxyID)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to list players on proxy", "proxy_id", proxyID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to list players on proxy")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, players)
+}
+
+// GetServerPlayers lists the players on a backend server.
+// GET /game/servers/{server}/players
+func (gs *GameService) GetServerPlayers(w http.ResponseWriter, r *http.Request) {
+	server := mux.Vars(r)["server"]
+	if server == "" {
+		api.WriteError(w, http.StatusBadRequest, "Server name is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	players, err := gs.redisClient.GetPlayersOnServer(ctx, server)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to list players on server", "server", server, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to list players on server")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, players)
 }
 
 // HandleBanPlayer handles requests to ban a player.
 // POST /game/ban
-// Body: { "uuid": "<player_uuid>", "duration_seconds": <seconds>, "reason": "..." }
+// Body: { "uuid": "<player_uuid>", "duration_seconds": <seconds>, "reason": "...", "issued_by": "..." }
 func (gs *GameService) HandleBanPlayer(w http.ResponseWriter, r *http.Request) {
 	var req BanRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -415,6 +914,10 @@ func (gs *GameService) HandleBanPlayer(w http.ResponseWriter, r *http.Request) {
 		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
 		return
 	}
+	if req.IssuedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Issuer is required")
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
@@ -435,46 +938,63 @@ func (gs *GameService) HandleBanPlayer(w http.ResponseWriter, r *http.Request) {
 		banExpiresAt = time.Now().Add(time.Duration(req.DurationSec) * time.Second)
 	}
 
-	// Set ban status in Redis (real-time check)
-	err = gs.redisClient.SetBanStatus(ctx, playerUUID.String(), true, banExpiresAt.Unix())
+	// Set ban status in Redis (real-time check); a longer ban already in place is kept
+	effectiveExpiresAt, _, err := gs.redisClient.SetBanStatus(ctx, playerUUID.String(), true, banExpiresAt.Unix(), req.Reason)
 	if err != nil {
-		log.Printf("Error setting ban status for player %s in Redis: %v", playerUUID.String(), err)
+		api.Logger(ctx).Error("Failed to set ban status in Redis", "uuid", playerUUID.String(), "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to ban player in Redis")
 		return
 	}
 
-	// Persist ban status to MongoDB via Player Data Service
+	// Record the ban in the player's punishment history; the Player Data Service derives the profile's ban status from it
 	mongoBanExpiresAt := &banExpiresAt
 	if isPermanent {
 		mongoBanExpiresAt = nil // Nil for permanent bans in MongoDB, or a specific constant if you prefer
 	}
 
-	err = gs.playerServiceClient.UpdateProfileBanStatus(ctx, playerUUID, true, mongoBanExpiresAt)
+	punishmentID := ""
+	punishment, err := gs.playerServiceClient.IssuePunishment(ctx, playerUUID, service.IssuePunishmentRequest{
+		Type:            models.PunishmentTypeBan,
+		Reason:          req.Reason,
+		IssuedBy:        req.IssuedBy,
+		DurationSeconds: req.DurationSec,
+	})
 	if err != nil {
-		log.Printf("Error persisting ban status for player %s to MongoDB: %v", playerUUID.String(), err)
+		api.Logger(ctx).Error("Failed to record ban in Player Data Service", "uuid", playerUUID.String(), "error", err)
 		// Log and continue, Redis is the immediate source of truth for bans
 	} else {
-		log.Printf("Player %s ban status (banned: true, expires: %v) persisted to MongoDB.", playerUUID.String(), mongoBanExpiresAt)
+		punishmentID = punishment.ID
+		api.Logger(ctx).Info("Recorded ban in Player Data Service", "uuid", playerUUID.String(), "punishment_id", punishmentID, "expires_at", mongoBanExpiresAt)
 	}
 
+	gs.publishEvent(ctx, models.EventPlayerBanned, playerUUID.String(), models.PlayerBannedData{
+		Reason:       req.Reason,
+		ExpiresAt:    mongoBanExpiresAt,
+		IssuedBy:     req.IssuedBy,
+		PunishmentID: punishmentID,
+	})
+
+	// Report the ban in effect, which may be an earlier, longer one
+	isPermanent = effectiveExpiresAt <= 0
 	responseMsg := fmt.Sprintf("Player %s banned", playerUUID.String())
 	if !isPermanent {
-		responseMsg = fmt.Sprintf("Player %s banned until %v", playerUUID.String(), banExpiresAt)
+		responseMsg = fmt.Sprintf("Player %s banned until %v", playerUUID.String(), time.Unix(effectiveExpiresAt, 0))
 	}
 
 	api.WriteJSON(w, http.StatusOK, map[string]string{
-		"message":      responseMsg,
-		"uuid":         playerUUID.String(),
-		"expires_at":   strconv.FormatInt(banExpiresAt.Unix(), 10),
-		"is_permanent": strconv.FormatBool(isPermanent),
+		"message":       responseMsg,
+		"uuid":          playerUUID.String(),
+		"expires_at":    strconv.FormatInt(effectiveExpiresAt, 10),
+		"is_permanent":  strconv.FormatBool(isPermanent),
+		"punishment_id": punishmentID,
 	})
 }
 
 // HandleUnbanPlayer handles requests to unban a player.
 // POST /game/unban
-// Body: { "uuid": "<player_uuid>" }
+// Body: { "uuid": "<player_uuid>", "revoked_by": "...", "reason": "..." }
 func (gs *GameService) HandleUnbanPlayer(w http.ResponseWriter, r *http.Request) {
-	var req OnlineStatusRequest // Re-use OnlineStatusRequest as it only needs UUID
+	var req UnbanRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
 		return
@@ -485,26 +1005,189 @@ func (gs *GameService) HandleUnbanPlayer(w http.ResponseWriter, r *http.Request)
 		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
 		return
 	}
+	if req.RevokedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Revoker is required")
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	// Remove ban status from Redis
-	err = gs.redisClient.SetBanStatus(ctx, playerUUID.String(), false, 0) // banned=false means DEL
+	_, _, err = gs.redisClient.SetBanStatus(ctx, playerUUID.String(), false, 0, "") // banned=false means DEL
 	if err != nil {
-		log.Printf("Error unbanning player %s in Redis: %v", playerUUID.String(), err)
+		api.Logger(ctx).Error("Failed to unban player in Redis", "uuid", playerUUID.String(), "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to unban player in Redis")
 		return
 	}
 
-	// Persist unban status to MongoDB via Player Data Service
-	err = gs.playerServiceClient.UpdateProfileBanStatus(ctx, playerUUID, false, nil) // Set banned to false, expires to nil
+	// Revoke the player's active bans in their punishment history; the profile's ban status follows
+	revoked, err := gs.playerServiceClient.RevokePunishments(ctx, playerUUID, models.PunishmentTypeBan, req.RevokedBy, req.Reason)
 	if err != nil {
-		log.Printf("Error persisting unban status for player %s to MongoDB: %v", playerUUID.String(), err)
+		api.Logger(ctx).Error("Failed to revoke bans in Player Data Service", "uuid", playerUUID.String(), "error", err)
 		// Log and continue
 	} else {
-		log.Printf("Player %s unban status persisted to MongoDB.", playerUUID.String())
+		api.Logger(ctx).Info("Revoked active bans in Player Data Service", "uuid", playerUUID.String(), "revoked", revoked)
 	}
 
+	gs.publishEvent(ctx, models.EventPlayerUnbanned, playerUUID.String(), models.PlayerUnbannedData{RevokedBy: req.RevokedBy, Reason: req.Reason})
+
 	api.WriteJSON(w, http.StatusOK, map[string]string{"message": "Player unbanned", "uuid": playerUUID.String()})
 }
+
+// GetPlayerPunishments handles requests to list a player's punishment history, newest first.
+// GET /game/player/{uuid}/punishments?type=ban&active=true
+func (gs *GameService) GetPlayerPunishments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	playerUUID, err := uuid.Parse(vars["uuid"])
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	activeOnly := false
+	if raw := r.URL.Query().Get("active"); raw != "" {
+		activeOnly, err = strconv.ParseBool(raw)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "active must be true or false")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	punishments, err := gs.playerServiceClient.GetPunishments(ctx, playerUUID, r.URL.Query().Get("type"), activeOnly)
+	if err != nil {
+		var apiErr *api.HTTPError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			api.WriteError(w, http.StatusBadRequest, "Invalid punishment type")
+			return
+		}
+		api.Logger(ctx).Error("Failed to list punishments", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve punishments")
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, punishments)
+}
+
+// HandleGrantBooster handles requests to grant a booster to a player.
+// POST /game/boosters/grant
+// Body: { "uuid": "<player_uuid>", "type": "...", "value": <multiplier>, "duration_seconds": <seconds>, "source": "..." }
+func (gs *GameService) HandleGrantBooster(w http.ResponseWriter, r *http.Request) {
+	var req GrantBoosterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	playerUUID, err := uuid.Parse(req.UUID)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	if req.Type == "" || req.Value <= 0 || req.DurationSec < 0 {
+		api.WriteError(w, http.StatusBadRequest, "Booster requires a type, a positive value and a non-negative duration")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Persist the booster on the profile first; the player service assigns its ID
+	booster, err := gs.playerServiceClient.GrantBooster(ctx, playerUUID, req.Type, req.Value, time.Duration(req.DurationSec)*time.Second, req.Source)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) {
+			api.WriteError(w, http.StatusNotFound, "Player profile not found")
+			return
+		}
+		api.Logger(ctx).Error("Failed to grant booster", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to grant booster")
+		return
+	}
+
+	// If the player has a live session, mirror the booster so it applies from the next tick
+	playtimeExists, deltaPlaytimeExists, err := gs.redisClient.CheckPlaytimeKeysExist(ctx, playerUUID.String())
+	if err != nil {
+		api.Logger(ctx).Warn("Failed to check session after granting booster", "uuid", playerUUID.String(), "booster_id", booster.ID, "error", err)
+	} else if playtimeExists && deltaPlaytimeExists {
+		if err := gs.redisClient.AddPlayerBooster(ctx, playerUUID.String(), *booster); err != nil {
+			api.Logger(ctx).Warn("Failed to mirror booster into Redis", "uuid", playerUUID.String(), "booster_id", booster.ID, "error", err)
+		}
+	}
+
+	api.WriteJSON(w, http.StatusCreated, booster)
+	api.Logger(ctx).Info("Granted booster", "uuid", playerUUID.String(), "booster_id", booster.ID, "type", booster.Type, "value", booster.Value)
+}
+
+// HandleRevokeBooster handles requests to revoke a booster from a player.
+// POST /game/boosters/revoke
+// Body: { "uuid": "<player_uuid>", "booster_id": "<booster_id>" }
+func (gs *GameService) HandleRevokeBooster(w http.ResponseWriter, r *http.Request) {
+	var req RevokeBoosterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	playerUUID, err := uuid.Parse(req.UUID)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	if req.BoosterID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Booster ID is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Stop applying the booster immediately, even if persisting the revoke fails
+	if err := gs.redisClient.RemovePlayerBooster(ctx, playerUUID.String(), req.BoosterID); err != nil {
+		api.Logger(ctx).Error("Failed to remove booster from Redis", "uuid", playerUUID.String(), "booster_id", req.BoosterID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to revoke booster in Redis")
+		return
+	}
+
+	err = gs.playerServiceClient.RevokeBooster(ctx, playerUUID, req.BoosterID)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) {
+			api.WriteError(w, http.StatusNotFound, "Booster not found")
+			return
+		}
+		api.Logger(ctx).Error("Failed to revoke booster", "uuid", playerUUID.String(), "booster_id", req.BoosterID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to revoke booster")
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, map[string]string{"message": "Booster revoked", "uuid": playerUUID.String(), "booster_id": req.BoosterID})
+}
+
+// GetPlayerBoosters handles requests to list a player's active boosters.
+// GET /game/player/{uuid}/boosters
+func (gs *GameService) GetPlayerBoosters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	playerUUID, err := uuid.Parse(vars["uuid"])
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	boosters, err := gs.playerServiceClient.GetBoosters(ctx, playerUUID)
+	if err != nil {
+		if errors.Is(err, api.ErrNotFound) {
+			api.WriteError(w, http.StatusNotFound, "Player profile not found")
+			return
+		}
+		api.Logger(ctx).Error("Failed to list boosters", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve boosters")
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, boosters)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	cluster "github.com/Ftotnem/Backend/go/shared/cluster"
+	"github.com/Ftotnem/Backend/go/shared/service"
+	"github.com/gorilla/mux"
+	"go.minekube.com/gate/pkg/util/uuid"
+)
+
+// Leaderboard query limits.
+const (
+	defaultLeaderboardLimit  = 10
+	maxLeaderboardLimit      = 100
+	defaultLeaderboardRadius = 5
+	maxLeaderboardRadius     = 50
+	leaderboardSeedPageSize  = 1000
+	leaderboardSeedCheck     = 1 * time.Minute  // How often the seeder checks whether it leads and a seed is due
+	leaderboardSeedTTL       = 30 * time.Minute // How long a seed run's staged leaderboards outlive a run that never finishes
+)
+
+// LeaderboardSeeder loads every persisted profile's playtime into the leaderboards, so offline
+// players are ranked too. Online players are kept current by the tick path. Only the elected
+// leader seeds; a newly elected leader seeds right away.
+type LeaderboardSeeder struct {
+	redisClient         *RedisClient
+	playerServiceClient *service.PlayerServiceClient
+	elector             *cluster.LeaderElector
+	seedInterval        time.Duration
+	ctx                 context.Context
+	cancel              context.CancelFunc
+}
+
+// NewLeaderboardSeeder creates a new LeaderboardSeeder instance.
+func NewLeaderboardSeeder(redisClient *RedisClient, playerServiceClient *service.PlayerServiceClient, elector *cluster.LeaderElector, seedInterval time.Duration) *LeaderboardSeeder {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LeaderboardSeeder{
+		redisClient:         redisClient,
+		playerServiceClient: playerServiceClient,
+		elector:             elector,
+		seedInterval:        seedInterval,
+		ctx:                 ctx,
+		cancel:              cancel,
+	}
+}
+
+// Start initiates the seeding loop. This should be run in a goroutine.
+func (ls *LeaderboardSeeder) Start() {
+	api.Logger(ls.ctx).Info("Leaderboard Seeder starting", "seed_interval", ls.seedInterval)
+	ticker := time.NewTicker(leaderboardSeedCheck)
+	defer ticker.Stop()
+
+	var lastSeed time.Time
+	var lastToken int64
+	for {
+		select {
+		case <-ls.ctx.Done():
+			api.Logger(ls.ctx).Info("Leaderboard Seeder shutting down")
+			return
+		case <-ticker.C:
+			token, isLeader := ls.elector.Leadership()
+			if !isLeader {
+				continue
+			}
+			if token == lastToken && time.Since(lastSeed) < ls.seedInterval {
+				continue
+			}
+			runCtx := api.WithNewRequestID(ls.ctx)
+			if err := ls.seed(runCtx); err != nil {
+				api.Logger(runCtx).Error("Leaderboard Seeder run failed", "error", err)
+				continue
+			}
+			lastSeed, lastToken = time.Now(), token
+		}
+	}
+}
+
+// Stop gracefully stops the seeding loop.
+func (ls *LeaderboardSeeder) Stop() {
+	ls.cancel()
+}
+
+// seed pages through every profile in the player service and stages it for the leaderboards, then
+// merges the staged leaderboards into the live ones. Nothing is seeded during a season rollover, and
+// a run that a rollover has overtaken is discarded, so totals archived with the old season are never
+// brought back onto the reset leaderboards.
+func (ls *LeaderboardSeeder) seed(runCtx context.Context) error {
+	started := time.Now()
+	frozen, epoch, err := ls.redisClient.GetSeasonState(runCtx)
+	if err != nil {
+		return err
+	}
+	if frozen != "" {
+		return fmt.Errorf("season %s is ending; seeding after the rollover", frozen)
+	}
+
+	runID := uuid.New().String()
+	teams := make(map[string]struct{})
+	var after string
+	var seeded int
+	for {
+		ctx, cancel := context.WithTimeout(runCtx, 30*time.Second)
+		profiles, err := ls.playerServiceClient.ListProfilePlaytimes(ctx, after, leaderboardSeedPageSize)
+		if err == nil {
+			err = ls.redisClient.SeedLeaderboards(ctx, runID, profiles, leaderboardSeedTTL)
+		}
+		cancel()
+		if err != nil {
+			ls.discard(runCtx, runID, teams)
+			return err
+		}
+		for _, profile := range profiles {
+			if profile.Team != "" {
+				teams[profile.Team] = struct{}{}
+			}
+		}
+
+		seeded += len(profiles)
+		if len(profiles) < leaderboardSeedPageSize {
+			break
+		}
+		after = profiles[len(profiles)-1].UUID
+	}
+
+	// A rollover freezes accrual well before it resets the leaderboards, so checking for one just
+	// before committing is enough to keep the old season's totals off the new season's leaderboards
+	frozen, current, err := ls.redisClient.GetSeasonState(runCtx)
+	if err == nil && (frozen != "" || current != epoch) {
+		err = fmt.Errorf("season changed while seeding (epoch %d to %d); seeding again later", epoch, current)
+	}
+	if err != nil {
+		ls.discard(runCtx, runID, teams)
+		return err
+	}
+	if err := ls.redisClient.CommitLeaderboardSeed(runCtx, runID, teamIDs(teams)); err != nil {
+		return err
+	}
+	api.Logger(runCtx).Info("Leaderboard Seeder seeded profiles", "profiles", seeded, "season_epoch", epoch, "duration", time.Since(started))
+	return nil
+}
+
+// discard deletes a seed run's staged leaderboards; they expire anyway if this fails.
+func (ls *LeaderboardSeeder) discard(ctx context.Context, runID string, teams map[string]struct{}) {
+	if err := ls.redisClient.DiscardLeaderboardSeed(ctx, runID, teamIDs(teams)); err != nil {
+		api.Logger(ctx).Warn("Leaderboard Seeder failed to discard staged leaderboards", "run_id", runID, "error", err)
+	}
+}
+
+// teamIDs returns the keys of teams.
+func teamIDs(teams map[string]struct{}) []string {
+	ids := make([]string, 0, len(teams))
+	for teamID := range teams {
+		ids = append(ids, teamID)
+	}
+	return ids
+}
+
+// parseLeaderboardLimit reads an optional positive integer query parameter, bounded by maxValue.
+func parseLeaderboardLimit(r *http.Request, name string, defaultValue, maxValue int64) (int64, bool) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return defaultValue, true
+	}
+	value, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || value < 1 || value > maxValue {
+		return 0, false
+	}
+	return value, true
+}
+
+// HandleLeaderboardTop returns the top players of the global leaderboard, or of a team's with ?team=.
+// GET /game/leaderboard?team={team}&limit={n}
+func (gs *GameService) HandleLeaderboardTop(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLeaderboardLimit(r, "limit", defaultLeaderboardLimit, maxLeaderboardLimit)
+	if !ok {
+		api.WriteError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxLeaderboardLimit))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	entries, err := gs.redisClient.GetLeaderboardTop(ctx, r.URL.Query().Get("team"), limit)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to retrieve leaderboard", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve leaderboard")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, entries)
+}
+
+// HandleLeaderboardPlayer returns a player's rank and percentile.
+// GET /game/leaderboard/player/{uuid}?team={team}
+func (gs *GameService) HandleLeaderboardPlayer(w http.ResponseWriter, r *http.Request) {
+	playerUUID, ok := parseLeaderboardPlayer(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	standing, err := gs.redisClient.GetLeaderboardStanding(ctx, r.URL.Query().Get("team"), playerUUID.String())
+	if errors.Is(err, ErrRedisKeyNotFound) {
+		api.WriteError(w, http.StatusNotFound, "Player is not on the leaderboard")
+		return
+	}
+	if err != nil {
+		api.Logger(ctx).Error("Failed to retrieve leaderboard standing", "uuid", playerUUID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve leaderboard standing")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, standing)
+}
+
+// HandleLeaderboardNeighbours returns the players ranked just above and below a player.
+// GET /game/leaderboard/player/{uuid}/neighbours?team={team}&radius={n}
+func (gs *GameService) HandleLeaderboardNeighbours(w http.ResponseWriter, r *http.Request) {
+	playerUUID, ok := parseLeaderboardPlayer(w, r)
+	if !ok {
+		return
+	}
+	radius, ok := parseLeaderboardLimit(r, "radius", defaultLeaderboardRadius, maxLeaderboardRadius)
+	if !ok {
+		api.WriteError(w, http.StatusBadRequest, "radius must be between 1 and "+strconv.Itoa(maxLeaderboardRadius))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	entries, err := gs.redisClient.GetLeaderboardNeighbours(ctx, r.URL.Query().Get("team"), playerUUID.String(), radius)
+	if errors.Is(err, ErrRedisKeyNotFound) {
+		api.WriteError(w, http.StatusNotFound, "Player is not on the leaderboard")
+		return
+	}
+	if err != nil {
+		api.Logger(ctx).Error("Failed to retrieve leaderboard neighbours", "uuid", playerUUID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve leaderboard neighbours")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, entries)
+}
+
+// parseLeaderboardPlayer reads the {uuid} path variable, writing a 400 if it is missing or invalid.
+func parseLeaderboardPlayer(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	uuidStr := mux.Vars(r)["uuid"]
+	if uuidStr == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID is required")
+		return uuid.UUID{}, false
+	}
+	playerUUID, err := uuid.Parse(uuidStr)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return uuid.UUID{}, false
+	}
+	return playerUUID, true
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/Ftotnem/Backend/go/shared/api"
 	cluster "github.com/Ftotnem/Backend/go/shared/cluster"
+	"github.com/Ftotnem/Backend/go/shared/events"
 	"github.com/Ftotnem/Backend/go/shared/service"
 	"go.minekube.com/gate/pkg/util/uuid"
 )
@@ -20,20 +22,31 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if err := api.SetupLogging("game-service", cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	shutdownTracing, err := api.SetupTracing("game-service", cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
-	redisClient, err := NewRedisClient(cfg.RedisAddrs, cfg.RedisOnlineTTL)
+	redisClient, err := NewRedisClient(cfg.RedisAddrs, cfg.RedisOnlineTTL, cfg.RedisSessionTTL)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 	defer func() {
 		if err := redisClient.Close(); err != nil {
-			log.Printf("Error closing Redis client: %v", err)
+			slog.Error("Failed to close Redis client", "error", err)
 		} else {
-			log.Println("Redis client closed.")
+			slog.Info("Redis client closed")
 		}
 	}()
 
-	playerServiceClient := service.NewPlayerClient(cfg.PlayerServiceURL)
+	var playerClientOpts []api.ClientOption
+	if cfg.PlayerServiceKeyID != "" {
+		playerClientOpts = append(playerClientOpts, api.WithSigner(api.HMACSigner{KeyID: cfg.PlayerServiceKeyID, Secret: cfg.PlayerServiceSecret}))
+	}
+	playerServiceClient := service.NewPlayerClient(cfg.PlayerServiceURL, playerClientOpts...)
 
 	// --- NEW: Initialize Service Registrar ---
 	instanceID := uuid.New().String() // Generate a unique ID for this instance
@@ -49,6 +62,7 @@ func main() {
 		InitialMetadata: map[string]string{
 			"version": "1.0.0",
 		},
+		Logger: api.Logger,
 	}
 
 	registrar, err := cluster.NewServiceRegistrar(redisClient.client, serviceConfig)
@@ -69,35 +83,162 @@ func main() {
 	}()
 	// --- END NEW: Initialize Service Registrar ---
 
-	gameService := NewGameService(redisClient, playerServiceClient, cfg)
+	eventPublisher := events.NewPublisher(redisClient.client, serviceConfig.ServiceType)
+	proxyCommands := events.NewProxyPublisher(redisClient.client, serviceConfig.ServiceType)
+	gameService := NewGameService(redisClient, playerServiceClient, cfg, eventPublisher, proxyCommands)
 
-	log.Printf("DEBUG: Configured TickInterval before updater start: %v", cfg.TickInterval)
 	// --- Update: Initialize and Start GameUpdater with registrar ---
 	gameUpdater := NewGameUpdater(redisClient, cfg, registrar) // Pass registrar
 	go gameUpdater.Start()
 	defer gameUpdater.Stop()
 
+	// Elect a single instance to run the team total sync
+	syncerElector, err := cluster.NewLeaderElector(redisClient.client, cluster.LeaderElectionConfig{
+		Name:        "game-service:playtime-syncer",
+		CandidateID: instanceID,
+		Logger:      api.Logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create playtime syncer leader elector: %v", err)
+	}
+	syncerElector.Start()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		syncerElector.Stop(shutdownCtx)
+	}()
+
 	// --- Update: Initialize and Start PlaytimeSyncer with registrar ---
-	playtimeSyncer := NewPlaytimeSyncer(redisClient, playerServiceClient, cfg.PersistenceInterval, registrar) // Pass registrar
+	playtimeSyncer := NewPlaytimeSyncer(redisClient, playerServiceClient, cfg.PersistenceInterval, registrar, syncerElector) // Pass registrar
 	go playtimeSyncer.Start()
 	defer playtimeSyncer.Stop()
 
+	// Writes online players' playtime to the player service between online/offline events
+	playtimePersister := NewPlaytimePersister(redisClient, playerServiceClient, gameUpdater.leases, cfg)
+	go playtimePersister.Start()
+	defer playtimePersister.Stop()
+
+	// Elect a single instance to build the live team totals; every instance relays them
+	streamElector, err := cluster.NewLeaderElector(redisClient.client, cluster.LeaderElectionConfig{
+		Name:        "game-service:team-stream",
+		CandidateID: instanceID,
+		Logger:      api.Logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create team stream leader elector: %v", err)
+	}
+	streamElector.Start()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		streamElector.Stop(shutdownCtx)
+	}()
+
+	teamStream := NewTeamStream(redisClient, streamElector, cfg.TeamStreamInterval, api.NewCORSPolicy(cfg.CORSAllowedOrigins))
+	go teamStream.Start()
+	defer teamStream.Stop()
+
+	// Elect a single instance to seed the leaderboards from MongoDB
+	leaderboardElector, err := cluster.NewLeaderElector(redisClient.client, cluster.LeaderElectionConfig{
+		Name:        "game-service:leaderboard-seeder",
+		CandidateID: instanceID,
+		Logger:      api.Logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create leaderboard seeder leader elector: %v", err)
+	}
+	leaderboardElector.Start()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		leaderboardElector.Stop(shutdownCtx)
+	}()
+
+	leaderboardSeeder := NewLeaderboardSeeder(redisClient, playerServiceClient, leaderboardElector, cfg.LeaderboardSeedInterval)
+	go leaderboardSeeder.Start()
+	defer leaderboardSeeder.Stop()
+
+	// Elect a single instance to start and end seasons
+	seasonElector, err := cluster.NewLeaderElector(redisClient.client, cluster.LeaderElectionConfig{
+		Name:        "game-service:season-rollover",
+		CandidateID: instanceID,
+		Logger:      api.Logger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create season rollover leader elector: %v", err)
+	}
+	seasonElector.Start()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		seasonElector.Stop(shutdownCtx)
+	}()
+
+	seasonRollover := NewSeasonRollover(redisClient, playerServiceClient, playtimePersister, seasonElector, cfg)
+	go seasonRollover.Start()
+	defer seasonRollover.Stop()
+
+	// Ends sessions whose presence expired without an offline (e.g. crashed proxy)
+	sessionReaper := NewSessionReaper(redisClient, gameService, cfg.SessionReapInterval, registrar, cfg.ProxyServiceType)
+	go sessionReaper.Start()
+	defer sessionReaper.Stop()
+
 	baseServer := api.NewBaseServer(cfg.ListenAddr)
+	// Nonces are shared through Redis so a signed request cannot be replayed against another instance
+	baseServer.Auth = api.NewServiceAuth(cfg.AuthAPIKeys, cfg.AuthHMACKeys, api.NewRedisNonceStore(redisClient.client, "game-service"))
+	if baseServer.Auth == nil {
+		slog.Warn("SERVICE_AUTH_DISABLED is set; every route is unauthenticated")
+	}
+	baseServer.CORS = api.NewCORSPolicy(cfg.CORSAllowedOrigins)
+	var rateLimitStore api.RateLimitStore // In memory unless limits must hold across instances
+	if cfg.RateLimitRedis {
+		rateLimitStore = api.NewRedisRateLimitStore(redisClient.client, "game-service")
+	}
+	baseServer.RateLimiter = api.NewRateLimiter(api.RateLimiterConfig{
+		Store:             rateLimitStore,
+		Default:           cfg.RateLimit,
+		Callers:           cfg.RateLimitCallers,
+		Routes:            cfg.RateLimitRoutes,
+		AuthFailures:      cfg.RateLimitAuthFailures,
+		TrustForwardedFor: cfg.TrustForwardedFor,
+	})
 
-	// Register your handlers on the BaseServer's router
-	baseServer.Router.HandleFunc("/game/online", gameService.HandleOnline).Methods("POST")
-	baseServer.Router.HandleFunc("/game/offline", gameService.HandleOffline).Methods("POST")
-	baseServer.Router.HandleFunc("/game/total/{team}", gameService.GetTeamTotal).Methods("GET")
-	baseServer.Router.HandleFunc("/game/player/{uuid}/online", gameService.GetPlayerOnlineStatus).Methods("GET")
-	baseServer.Router.HandleFunc("/game/ban", gameService.HandleBanPlayer).Methods("POST")
-	baseServer.Router.HandleFunc("/game/unban", gameService.HandleUnbanPlayer).Methods("POST")
+	// Register your handlers on the BaseServer's router, each with the scope its callers need
+	baseServer.HandleFunc("/game/online", api.ScopeInternal, gameService.HandleOnline).Methods("POST")
+	baseServer.HandleFunc("/game/offline", api.ScopeInternal, gameService.HandleOffline).Methods("POST")
+	baseServer.HandleFunc("/game/heartbeat", api.ScopeInternal, gameService.HandleHeartbeat).Methods("POST")
+	baseServer.HandleFunc("/game/activity", api.ScopeInternal, gameService.HandleActivity).Methods("POST")
+	baseServer.HandleFunc("/game/total/{team}", api.ScopePublic, gameService.GetTeamTotal).Methods("GET")
+	baseServer.HandleFunc("/game/stream/teams", api.ScopePublic, teamStream.HandleSSE).Methods("GET")
+	baseServer.HandleFunc("/game/leaderboard", api.ScopePublic, gameService.HandleLeaderboardTop).Methods("GET")
+	baseServer.HandleFunc("/game/leaderboard/player/{uuid}", api.ScopePublic, gameService.HandleLeaderboardPlayer).Methods("GET")
+	baseServer.HandleFunc("/game/leaderboard/player/{uuid}/neighbours", api.ScopePublic, gameService.HandleLeaderboardNeighbours).Methods("GET")
+	baseServer.HandleFunc("/game/ws/teams", api.ScopePublic, teamStream.HandleWebSocket).Methods("GET")
+	baseServer.HandleFunc("/game/player/{uuid}/online", api.ScopeInternal, gameService.GetPlayerOnlineStatus).Methods("GET")
+	baseServer.HandleFunc("/game/proxies/{proxyID}/players", api.ScopeInternal, gameService.GetProxyPlayers).Methods("GET")
+	baseServer.HandleFunc("/game/servers/{server}/players", api.ScopeInternal, gameService.GetServerPlayers).Methods("GET")
+	baseServer.HandleFunc("/game/ban", api.ScopeAdmin, gameService.HandleBanPlayer).Methods("POST")
+	baseServer.HandleFunc("/game/unban", api.ScopeAdmin, gameService.HandleUnbanPlayer).Methods("POST")
+	baseServer.HandleFunc("/game/player/{uuid}/punishments", api.ScopeAdmin, gameService.GetPlayerPunishments).Methods("GET")
+	baseServer.HandleFunc("/game/mute", api.ScopeAdmin, gameService.HandleMutePlayer).Methods("POST")
+	baseServer.HandleFunc("/game/unmute", api.ScopeAdmin, gameService.HandleUnmutePlayer).Methods("POST")
+	baseServer.HandleFunc("/game/player/{uuid}/mute", api.ScopeInternal, gameService.GetPlayerMute).Methods("GET")
+	baseServer.HandleFunc("/game/warn", api.ScopeAdmin, gameService.HandleWarnPlayer).Methods("POST")
+	baseServer.HandleFunc("/game/kick", api.ScopeAdmin, gameService.HandleKickPlayer).Methods("POST")
+	baseServer.HandleFunc("/game/boosters/grant", api.ScopeAdmin, gameService.HandleGrantBooster).Methods("POST")
+	baseServer.HandleFunc("/game/boosters/revoke", api.ScopeAdmin, gameService.HandleRevokeBooster).Methods("POST")
+	baseServer.HandleFunc("/game/player/{uuid}/boosters", api.ScopeInternal, gameService.GetPlayerBoosters).Methods("GET")
+	baseServer.HandleFunc("/game/ticks/stats", api.ScopeInternal, gameUpdater.HandleTickStats).Methods("GET")
+	baseServer.HandleFunc("/game/persister/stats", api.ScopeInternal, playtimePersister.HandlePersisterStats).Methods("GET")
+	baseServer.HandleFunc("/game/admin/sessions/orphaned", api.ScopeAdmin, sessionReaper.HandleOrphanedSessions).Methods("GET")
+	baseServer.HandleFunc("/game/admin/sessions/recover", api.ScopeAdmin, sessionReaper.HandleRecoverSessions).Methods("POST")
 
 	// Register playtime and deltatime endpoints
-	baseServer.Router.HandleFunc("/playtime/{uuid}", gameService.handleGetPlaytime).Methods("GET")
-	baseServer.Router.HandleFunc("/deltatime/{uuid}", gameService.handleGetDeltaPlaytime).Methods("GET")
+	baseServer.HandleFunc("/playtime/{uuid}", api.ScopeInternal, gameService.handleGetPlaytime).Methods("GET")
+	baseServer.HandleFunc("/deltatime/{uuid}", api.ScopeInternal, gameService.handleGetDeltaPlaytime).Methods("GET")
 
 	go func() {
-		log.Printf("Game Service listening on %s", cfg.ListenAddr)
+		slog.Info("Game Service listening", "addr", cfg.ListenAddr)
 		if err := baseServer.Start(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Could not listen on %s: %v", cfg.ListenAddr, err)
 		}
@@ -107,7 +248,7 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	sig := <-sigChan
-	log.Printf("Received signal %s, shutting down...", sig)
+	slog.Info("Received signal, shutting down", "signal", sig.String())
 
 	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancelShutdown()
@@ -115,5 +256,8 @@ func main() {
 	if err := baseServer.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
-	log.Println("Game Service gracefully stopped.")
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Game Service gracefully stopped")
 }
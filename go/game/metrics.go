@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Game tick metrics, recorded by GameUpdater.performGameTick. They count this instance only.
+var (
+	gameTicksTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ticks_total",
+		Help: "Game tick loop iterations on this instance.",
+	})
+
+	gameTickOverrunsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_tick_overruns_total",
+		Help: "Game ticks that took longer than the tick interval.",
+	})
+
+	gameTickDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_tick_duration_seconds",
+		Help:    "Time taken by a game tick.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	})
+
+	gamePlayersTicked = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_players_ticked",
+		Help: "Players credited by the last game tick on this instance.",
+	})
+
+	gameBucketsHeld = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_buckets_held",
+		Help: "Online index buckets this instance holds a lease for.",
+	})
+)
+
+// Playtime sync metrics, recorded by PlaytimeSyncer on the elected leader.
+var (
+	playtimeSyncRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_playtime_sync_runs_total",
+		Help: "Playtime sync runs, by result: success, error or stale (leadership lost mid-run).",
+	}, []string{"result"})
+
+	playtimeSyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_playtime_sync_duration_seconds",
+		Help:    "Time taken by a playtime sync run, including the player service aggregation.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	})
+
+	playtimeSyncLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_playtime_sync_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful playtime sync run on this instance.",
+	})
+)
+
+// Team metrics, updated from every team totals frame TeamStream relays, so every instance reports them.
+var (
+	teamTotalPlaytime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_team_total_playtime_ticks",
+		Help: "Live total playtime of each team, in ticks.",
+	}, []string{"team"})
+
+	teamOnlinePlayers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_team_online_players",
+		Help: "Players online in each team.",
+	}, []string{"team"})
+)
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/Ftotnem/Backend/go/shared/service"
+	"github.com/gorilla/mux"
+	"go.minekube.com/gate/pkg/util/uuid"
+)
+
+// MuteRequest is the structure for the request body of /game/mute.
+type MuteRequest struct {
+	UUID        string `json:"uuid"`
+	DurationSec int64  `json:"duration_seconds"` // Duration in seconds. 0 for permanent.
+	Reason      string `json:"reason,omitempty"`
+	IssuedBy    string `json:"issued_by"` // Staff member or system issuing the mute; recorded in the punishment history
+}
+
+// UnmuteRequest is the structure for the request body of /game/unmute.
+type UnmuteRequest struct {
+	UUID      string `json:"uuid"`
+	RevokedBy string `json:"revoked_by"` // Staff member or system lifting the mute; recorded in the punishment history
+	Reason    string `json:"reason,omitempty"`
+}
+
+// MuteStatusResponse reports whether a player is muted, for proxies to check before relaying chat.
+type MuteStatusResponse struct {
+	UUID        string     `json:"uuid"`
+	IsMuted     bool       `json:"is_muted"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Nil for permanent mutes
+	IsPermanent bool       `json:"is_permanent"`
+}
+
+// WarnRequest is the structure for the request body of /game/warn.
+type WarnRequest struct {
+	UUID     string `json:"uuid"`
+	Reason   string `json:"reason"`
+	IssuedBy string `json:"issued_by"`
+}
+
+// WarnResponse reports a recorded warning and the player's active warnings, including it.
+type WarnResponse struct {
+	Message      string `json:"message"`
+	UUID         string `json:"uuid"`
+	PunishmentID string `json:"punishment_id"`
+	Warnings     int    `json:"warnings"`
+}
+
+// KickRequest is the structure for the request body of /game/kick.
+type KickRequest struct {
+	UUID     string `json:"uuid"`
+	Reason   string `json:"reason,omitempty"`
+	IssuedBy string `json:"issued_by"`
+}
+
+// KickResponse reports a kick that has been sent to the player's proxy.
+type KickResponse struct {
+	Message      string `json:"message"`
+	UUID         string `json:"uuid"`
+	ProxyID      string `json:"proxy_id,omitempty"` // Empty if the player's proxy is unknown; every proxy is then asked
+	PunishmentID string `json:"punishment_id,omitempty"`
+}
+
+// HandleMutePlayer handles requests to mute a player.
+// POST /game/mute
+// Body: { "uuid": "<player_uuid>", "duration_seconds": <seconds>, "reason": "...", "issued_by": "..." }
+func (gs *GameService) HandleMutePlayer(w http.ResponseWriter, r *http.Request) {
+	var req MuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	playerUUID, err := uuid.Parse(req.UUID)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	if req.IssuedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Issuer is required")
+		return
+	}
+	if req.DurationSec < 0 {
+		api.WriteError(w, http.StatusBadRequest, "Mute duration cannot be negative")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var expiresAt *time.Time // Nil for a permanent mute
+	var expiresAtUnix int64  // 0 marks a permanent mute in Redis
+	if req.DurationSec > 0 {
+		t := time.Now().Add(time.Duration(req.DurationSec) * time.Second)
+		expiresAt = &t
+		expiresAtUnix = t.Unix()
+	}
+
+	// Set mute status in Redis (real-time check by the proxies); a longer mute already in place is kept
+	effectiveExpiresAt, effectiveReason, err := gs.redisClient.SetMuteStatus(ctx, playerUUID.String(), true, expiresAtUnix, req.Reason)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to set mute status in Redis", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to mute player in Redis")
+		return
+	}
+
+	punishmentID := gs.recordPunishment(ctx, playerUUID, service.IssuePunishmentRequest{
+		Type:            models.PunishmentTypeMute,
+		Reason:          req.Reason,
+		IssuedBy:        req.IssuedBy,
+		DurationSeconds: req.DurationSec,
+	})
+
+	gs.publishEvent(ctx, models.EventPlayerMuted, playerUUID.String(), models.PlayerMutedData{
+		Reason:       req.Reason,
+		ExpiresAt:    expiresAt,
+		IssuedBy:     req.IssuedBy,
+		PunishmentID: punishmentID,
+	})
+
+	// Report the mute in effect, which may be an earlier, longer one
+	resp := MuteStatusResponse{UUID: playerUUID.String(), IsMuted: true, Reason: effectiveReason, IsPermanent: effectiveExpiresAt <= 0}
+	if !resp.IsPermanent {
+		t := time.Unix(effectiveExpiresAt, 0)
+		resp.ExpiresAt = &t
+	}
+	api.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleUnmutePlayer handles requests to unmute a player.
+// POST /game/unmute
+// Body: { "uuid": "<player_uuid>", "revoked_by": "...", "reason": "..." }
+func (gs *GameService) HandleUnmutePlayer(w http.ResponseWriter, r *http.Request) {
+	var req UnmuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	playerUUID, err := uuid.Parse(req.UUID)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	if req.RevokedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Revoker is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, _, err := gs.redisClient.SetMuteStatus(ctx, playerUUID.String(), false, 0, ""); err != nil {
+		api.Logger(ctx).Error("Failed to unmute player in Redis", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to unmute player in Redis")
+		return
+	}
+
+	revoked, err := gs.playerServiceClient.RevokePunishments(ctx, playerUUID, models.PunishmentTypeMute, req.RevokedBy, req.Reason)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to revoke mutes in Player Data Service", "uuid", playerUUID.String(), "error", err)
+		// Log and continue, Redis is the immediate source of truth for mutes
+	} else {
+		api.Logger(ctx).Info("Revoked active mutes in Player Data Service", "uuid", playerUUID.String(), "revoked", revoked)
+	}
+
+	gs.publishEvent(ctx, models.EventPlayerUnmuted, playerUUID.String(), models.PlayerUnmutedData{RevokedBy: req.RevokedBy, Reason: req.Reason})
+
+	api.WriteJSON(w, http.StatusOK, MuteStatusResponse{UUID: playerUUID.String()})
+}
+
+// GetPlayerMute handles requests to check whether a player is muted. Proxies call it before relaying chat.
+// GET /game/player/{uuid}/mute
+func (gs *GameService) GetPlayerMute(w http.ResponseWriter, r *http.Request) {
+	playerUUID, err := uuid.Parse(mux.Vars(r)["uuid"])
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	response := MuteStatusResponse{UUID: playerUUID.String()}
+	expiresAtUnix, reason, err := gs.redisClient.GetMuteDetails(ctx, playerUUID.String())
+	if err == ErrRedisKeyNotFound {
+		api.WriteJSON(w, http.StatusOK, response)
+		return
+	}
+	if err != nil {
+		api.Logger(ctx).Error("Failed to check mute status", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to check player mute status")
+		return
+	}
+
+	response.IsMuted = true
+	response.Reason = reason
+	response.IsPermanent = expiresAtUnix <= 0
+	if !response.IsPermanent {
+		expiresAt := time.Unix(expiresAtUnix, 0)
+		response.ExpiresAt = &expiresAt
+	}
+	api.WriteJSON(w, http.StatusOK, response)
+}
+
+// HandleWarnPlayer handles requests to warn a player. A warning has no effect of its own; it is
+// recorded in the punishment history, and the response carries the player's active warnings.
+// POST /game/warn
+// Body: { "uuid": "<player_uuid>", "reason": "...", "issued_by": "..." }
+func (gs *GameService) HandleWarnPlayer(w http.ResponseWriter, r *http.Request) {
+	var req WarnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	playerUUID, err := uuid.Parse(req.UUID)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	if req.IssuedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Issuer is required")
+		return
+	}
+	if req.Reason == "" {
+		api.WriteError(w, http.StatusBadRequest, "Reason is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// The history is the only place a warning lives, so failing to record it fails the request
+	punishment, err := gs.playerServiceClient.IssuePunishment(ctx, playerUUID, service.IssuePunishmentRequest{
+		Type:     models.PunishmentTypeWarn,
+		Reason:   req.Reason,
+		IssuedBy: req.IssuedBy,
+	})
+	if err != nil {
+		api.Logger(ctx).Error("Failed to record warning in Player Data Service", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to record warning")
+		return
+	}
+
+	warnings, err := gs.playerServiceClient.GetPunishments(ctx, playerUUID, models.PunishmentTypeWarn, true)
+	if err != nil {
+		api.Logger(ctx).Warn("Failed to count warnings", "uuid", playerUUID.String(), "error", err)
+	}
+
+	gs.publishEvent(ctx, models.EventPlayerWarned, playerUUID.String(), models.PlayerWarnedData{
+		Reason:       req.Reason,
+		IssuedBy:     req.IssuedBy,
+		PunishmentID: punishment.ID,
+		Warnings:     len(warnings),
+	})
+
+	api.WriteJSON(w, http.StatusOK, WarnResponse{
+		Message:      fmt.Sprintf("Player %s warned", playerUUID.String()),
+		UUID:         playerUUID.String(),
+		PunishmentID: punishment.ID,
+		Warnings:     len(warnings),
+	})
+}
+
+// HandleKickPlayer handles requests to kick an online player. The kick reaches the player's proxy as an
+// EventPlayerKicked event on the proxy stream; the proxy disconnects the player and sends /game/offline
+// as usual. The kick is only recorded in the punishment history, and announced on the player stream,
+// once it has been delivered. Players who are not online are rejected with 409 Conflict.
+// POST /game/kick
+// Body: { "uuid": "<player_uuid>", "reason": "...", "issued_by": "..." }
+func (gs *GameService) HandleKickPlayer(w http.ResponseWriter, r *http.Request) {
+	var req KickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	playerUUID, err := uuid.Parse(req.UUID)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	if req.IssuedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Issuer is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	isOnline, err := gs.redisClient.IsOnline(ctx, playerUUID.String())
+	if err != nil {
+		api.Logger(ctx).Error("Failed to check online status", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to check player online status")
+		return
+	}
+	if !isOnline {
+		api.WriteError(w, http.StatusConflict, "Player is not online")
+		return
+	}
+
+	kick := models.PlayerKickedData{Reason: req.Reason, IssuedBy: req.IssuedBy}
+	location, err := gs.redisClient.GetPlayerPresence(ctx, playerUUID.String())
+	if err != nil {
+		api.Logger(ctx).Warn("Failed to get player location for kick, asking every proxy", "uuid", playerUUID.String(), "error", err)
+	} else if location != nil {
+		kick.ProxyID = location.ProxyID
+		kick.Server = location.Server
+	}
+
+	// Unlike other events, this one is the kick itself, so failing to publish it fails the request
+	if _, err := gs.proxyCommands.Publish(ctx, models.EventPlayerKicked, playerUUID.String(), kick); err != nil {
+		api.Logger(ctx).Error("Failed to deliver kick", "uuid", playerUUID.String(), "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to deliver kick to the proxy")
+		return
+	}
+
+	kick.PunishmentID = gs.recordPunishment(ctx, playerUUID, service.IssuePunishmentRequest{
+		Type:     models.PunishmentTypeKick,
+		Reason:   req.Reason,
+		IssuedBy: req.IssuedBy,
+	})
+	gs.publishEvent(ctx, models.EventPlayerKicked, playerUUID.String(), kick)
+
+	api.WriteJSON(w, http.StatusOK, KickResponse{
+		Message:      fmt.Sprintf("Player %s kicked", playerUUID.String()),
+		UUID:         playerUUID.String(),
+		ProxyID:      kick.ProxyID,
+		PunishmentID: kick.PunishmentID,
+	})
+}
+
+// recordPunishment records a punishment whose effect is enforced elsewhere (Redis or the proxy) in the
+// player's punishment history, and returns its ID. A failure is logged and yields an empty ID.
+func (gs *GameService) recordPunishment(ctx context.Context, playerUUID uuid.UUID, req service.IssuePunishmentRequest) string {
+	punishment, err := gs.playerServiceClient.IssuePunishment(ctx, playerUUID, req)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to record punishment in Player Data Service", "uuid", playerUUID.String(), "type", req.Type, "error", err)
+		return ""
+	}
+	api.Logger(ctx).Info("Recorded punishment in Player Data Service", "uuid", playerUUID.String(), "type", req.Type, "punishment_id", punishment.ID, "expires_at", punishment.ExpiresAt)
+	return punishment.ID
+}
+
+// restoreMute mirrors a player's active mute from the punishment history into Redis if Redis has
+// none, e.g. after Redis lost its data. Failures are logged; the player can still come online.
+func (gs *GameService) restoreMute(ctx context.Context, playerUUID uuid.UUID) {
+	if _, _, err := gs.redisClient.GetMuteDetails(ctx, playerUUID.String()); err != ErrRedisKeyNotFound {
+		if err != nil {
+			api.Logger(ctx).Warn("Failed to check mute status", "uuid", playerUUID.String(), "error", err)
+		}
+		return
+	}
+
+	mutes, err := gs.playerServiceClient.GetPunishments(ctx, playerUUID, models.PunishmentTypeMute, true)
+	if err != nil {
+		api.Logger(ctx).Warn("Failed to get active mutes", "uuid", playerUUID.String(), "error", err)
+		return
+	}
+	if len(mutes) == 0 {
+		return
+	}
+
+	// Mirror the mute lasting longest; mutes are listed newest first, so ties keep the newest reason
+	longest := mutes[0]
+	for _, mute := range mutes[1:] {
+		if longest.ExpiresAt != nil && (mute.ExpiresAt == nil || mute.ExpiresAt.After(*longest.ExpiresAt)) {
+			longest = mute
+		}
+	}
+	var expiresAtUnix int64 // 0 marks a permanent mute in Redis
+	if longest.ExpiresAt != nil {
+		expiresAtUnix = longest.ExpiresAt.Unix()
+	}
+	if _, _, err := gs.redisClient.SetMuteStatus(ctx, playerUUID.String(), true, expiresAtUnix, longest.Reason); err != nil {
+		api.Logger(ctx).Warn("Failed to mirror mute into Redis", "uuid", playerUUID.String(), "error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+)
+
+// PartitionLeaseManager tracks which online index buckets this instance holds a lease for.
+// An instance only ticks players in buckets it holds, so two instances that briefly disagree
+// about the ring never credit the same player twice.
+type PartitionLeaseManager struct {
+	redisClient *RedisClient
+	holderID    string
+	ttl         time.Duration
+	margin      time.Duration // Lease time kept back so a tick started before local expiry finishes before Redis expiry
+
+	mu   sync.Mutex
+	held map[int]time.Time // bucket -> local expiry of our lease
+
+	inUse sync.RWMutex // Read-held by each HoldBuckets caller until done; leases are only released with it write-held
+}
+
+// NewPartitionLeaseManager creates a lease manager acting on behalf of holderID.
+func NewPartitionLeaseManager(redisClient *RedisClient, holderID string, ttl time.Duration) *PartitionLeaseManager {
+	return &PartitionLeaseManager{
+		redisClient: redisClient,
+		holderID:    holderID,
+		ttl:         ttl,
+		margin:      ttl / 5,
+		held:        make(map[int]time.Time),
+	}
+}
+
+// Sync releases leases for buckets this instance no longer owns, then acquires or renews
+// the leases for the desired buckets. Buckets still leased by another instance are skipped
+// until that instance releases them or its lease expires.
+func (lm *PartitionLeaseManager) Sync(ctx context.Context, desired []int) {
+	want := make(map[int]bool, len(desired))
+	for _, bucket := range desired {
+		want[bucket] = true
+	}
+
+	lm.mu.Lock()
+	var release []int
+	for bucket := range lm.held {
+		if !want[bucket] {
+			release = append(release, bucket)
+			delete(lm.held, bucket) // Stop ticking it right away, even if the release fails
+		}
+	}
+	lm.mu.Unlock()
+
+	if len(release) > 0 {
+		lm.waitForHolders()
+		if err := lm.redisClient.ReleasePartitionLeases(ctx, release, lm.holderID); err != nil {
+			api.Logger(ctx).Warn("PartitionLeaseManager failed to release leases; they will expire", "buckets", release, "ttl", lm.ttl, "error", err)
+		} else {
+			api.Logger(ctx).Info("PartitionLeaseManager released leases", "buckets", release)
+		}
+	}
+
+	if len(desired) == 0 {
+		return
+	}
+
+	// Expiry is measured from before the request, so it never outlives the lease in Redis
+	start := time.Now()
+	results, err := lm.redisClient.AcquirePartitionLeases(ctx, desired, lm.holderID, lm.ttl)
+	if err != nil {
+		api.Logger(ctx).Error("PartitionLeaseManager failed to acquire leases", "error", err)
+		return
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	var acquired, contended []int
+	for _, bucket := range desired {
+		ok, answered := results[bucket]
+		if !answered {
+			continue // Keep the existing expiry; it lapses on its own if renewals keep failing
+		}
+		if !ok {
+			delete(lm.held, bucket)
+			contended = append(contended, bucket)
+			continue
+		}
+		if _, had := lm.held[bucket]; !had {
+			acquired = append(acquired, bucket)
+		}
+		lm.held[bucket] = start.Add(lm.ttl - lm.margin)
+	}
+	if len(acquired) > 0 {
+		api.Logger(ctx).Info("PartitionLeaseManager acquired leases", "buckets", acquired)
+	}
+	if len(contended) > 0 {
+		api.Logger(ctx).Info("PartitionLeaseManager waiting on leases held elsewhere", "buckets", contended)
+	}
+}
+
+// HeldBuckets returns the buckets this instance currently holds an unexpired lease for.
+func (lm *PartitionLeaseManager) HeldBuckets() []int {
+	now := time.Now()
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	buckets := make([]int, 0, len(lm.held))
+	for bucket, expiresAt := range lm.held {
+		if now.Before(expiresAt) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	sort.Ints(buckets)
+	return buckets
+}
+
+// HoldBuckets returns the buckets this instance currently holds an unexpired lease for, like
+// HeldBuckets, and keeps their leases from being released until done is called. A tick holds its
+// buckets while crediting them, so a bucket's next owner never starts before the tick has finished.
+func (lm *PartitionLeaseManager) HoldBuckets() (buckets []int, done func()) {
+	lm.inUse.RLock()
+	return lm.HeldBuckets(), lm.inUse.RUnlock
+}
+
+// waitForHolders waits until every HoldBuckets caller that may still be using a bucket being
+// released is done. Buckets are dropped from held before this is called, so later callers never see them.
+func (lm *PartitionLeaseManager) waitForHolders() {
+	lm.inUse.Lock()
+	lm.inUse.Unlock()
+}
+
+// ReleaseAll gives up every lease this instance holds, letting the next owners take over
+// without waiting for the leases to expire.
+func (lm *PartitionLeaseManager) ReleaseAll(ctx context.Context) {
+	lm.mu.Lock()
+	buckets := make([]int, 0, len(lm.held))
+	for bucket := range lm.held {
+		buckets = append(buckets, bucket)
+	}
+	lm.held = make(map[int]time.Time)
+	lm.mu.Unlock()
+
+	if len(buckets) == 0 {
+		return
+	}
+	lm.waitForHolders()
+	if err := lm.redisClient.ReleasePartitionLeases(ctx, buckets, lm.holderID); err != nil {
+		api.Logger(ctx).Warn("PartitionLeaseManager failed to release leases; they will expire", "buckets", buckets, "ttl", lm.ttl, "error", err)
+		return
+	}
+	api.Logger(ctx).Info("PartitionLeaseManager released all leases", "count", len(buckets))
+}
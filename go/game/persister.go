@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/service"
+)
+
+// PlaytimePersister periodically writes the total playtime of online players to the player service,
+// so progress reaches MongoDB while players stay online instead of only on online/offline.
+// Each instance flushes only the buckets it holds a lease for, so every player is written by one instance.
+type PlaytimePersister struct {
+	redisClient         *RedisClient
+	playerServiceClient *service.PlayerServiceClient
+	leases              *PartitionLeaseManager
+	flushInterval       time.Duration
+	batchSize           int
+	maxRetries          int
+	ctx                 context.Context
+	cancel              context.CancelFunc
+
+	statsMux sync.RWMutex
+	stats    PersisterStats
+}
+
+// PersisterStats describes how far MongoDB lags behind Redis for this instance's players.
+type PersisterStats struct {
+	LastFlushAt       *time.Time `json:"last_flush_at,omitempty"`     // When the last flush finished
+	LastSuccessAt     *time.Time `json:"last_success_at,omitempty"`   // Start of the last flush in which every batch succeeded
+	LagSeconds        float64    `json:"lag_seconds"`                 // Age of the data as of LastSuccessAt (or since start if none yet)
+	LastFlushDuration float64    `json:"last_flush_duration_seconds"` // How long the last flush took
+	LastFlushPlayers  int        `json:"last_flush_players"`          // Players written in the last flush
+	LastFlushFailed   int        `json:"last_flush_failed_players"`   // Players whose batch failed after all retries in the last flush
+	TotalFlushed      uint64     `json:"total_flushed_players"`       // Players written since start
+	TotalRetries      uint64     `json:"total_retries"`               // Batch retries since start
+	TotalFailed       uint64     `json:"total_failed_players"`        // Players not written after all retries since start
+	startedAt         time.Time  // Lag baseline before the first successful flush
+}
+
+// NewPlaytimePersister creates a new PlaytimePersister instance.
+func NewPlaytimePersister(redisClient *RedisClient, playerServiceClient *service.PlayerServiceClient, leases *PartitionLeaseManager, cfg *Config) *PlaytimePersister {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PlaytimePersister{
+		redisClient:         redisClient,
+		playerServiceClient: playerServiceClient,
+		leases:              leases,
+		flushInterval:       cfg.PersistFlushInterval,
+		batchSize:           cfg.PersistBatchSize,
+		maxRetries:          cfg.PersistMaxRetries,
+		ctx:                 ctx,
+		cancel:              cancel,
+		stats:               PersisterStats{startedAt: time.Now()},
+	}
+}
+
+// Start initiates the persistence loop. This should be run in a goroutine.
+func (pp *PlaytimePersister) Start() {
+	api.Logger(pp.ctx).Info("Playtime Persister starting", "flush_interval", pp.flushInterval, "batch_size", pp.batchSize)
+	ticker := time.NewTicker(pp.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pp.ctx.Done():
+			api.Logger(pp.ctx).Info("Playtime Persister shutting down")
+			return
+		case <-ticker.C:
+			pp.flush(api.WithNewRequestID(pp.ctx))
+		}
+	}
+}
+
+// Stop gracefully stops the persistence loop.
+func (pp *PlaytimePersister) Stop() {
+	pp.cancel()
+}
+
+// Stats returns a snapshot of the persister's lag metrics.
+func (pp *PlaytimePersister) Stats() PersisterStats {
+	pp.statsMux.RLock()
+	defer pp.statsMux.RUnlock()
+
+	stats := pp.stats
+	since := stats.startedAt
+	if stats.LastSuccessAt != nil {
+		since = *stats.LastSuccessAt
+	}
+	stats.LagSeconds = time.Since(since).Seconds()
+	return stats
+}
+
+// HandlePersisterStats reports the persister's lag metrics.
+func (pp *PlaytimePersister) HandlePersisterStats(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, pp.Stats())
+}
+
+// flush writes the playtime of every online player in this instance's buckets, batchSize players per request.
+// It is skipped while a season rollover has frozen accrual; the rollover writes every player itself.
+func (pp *PlaytimePersister) flush(ctx context.Context) {
+	started := time.Now()
+
+	if frozen, err := pp.redisClient.GetSeasonFreeze(ctx); err != nil || frozen != "" {
+		if err != nil {
+			api.Logger(ctx).Error("Playtime Persister failed to check season freeze", "error", err)
+		}
+		pp.recordFlush(started, 0, 0, 0, false)
+		return
+	}
+
+	buckets := pp.leases.HeldBuckets()
+	if len(buckets) == 0 {
+		pp.recordFlush(started, 0, 0, 0, true)
+		return
+	}
+
+	flushed, failed, retries, err := pp.persistBuckets(ctx, buckets)
+	if err != nil {
+		api.Logger(ctx).Error("Playtime Persister flush failed", "error", err)
+		pp.recordFlush(started, 0, 0, 0, false)
+		return
+	}
+
+	if flushed > 0 || failed > 0 {
+		api.Logger(ctx).Info("Playtime Persister flushed playtime", "players", flushed, "failed", failed, "retries", retries, "duration", time.Since(started))
+	}
+	pp.recordFlush(started, flushed, failed, retries, failed == 0)
+}
+
+// FlushAll writes the playtime of every online player, whichever instance owns them.
+// A season rollover calls it so MongoDB holds the final totals before they are archived.
+func (pp *PlaytimePersister) FlushAll(ctx context.Context) error {
+	started := time.Now()
+	buckets := make([]int, OnlineIndexBuckets)
+	for i := range buckets {
+		buckets[i] = i
+	}
+
+	flushed, failed, retries, err := pp.persistBuckets(ctx, buckets)
+	if err != nil {
+		return err
+	}
+	api.Logger(ctx).Info("Playtime Persister flushed playtime for all online players", "players", flushed, "failed", failed, "retries", retries, "duration", time.Since(started))
+	if failed > 0 {
+		return fmt.Errorf("failed to write playtime for %d players", failed)
+	}
+	return nil
+}
+
+// persistBuckets writes the playtime and AFK time of every online player in the given buckets, batchSize players per request.
+// Failed batches are counted rather than returned; err is only set if Redis could not be read.
+func (pp *PlaytimePersister) persistBuckets(ctx context.Context, buckets []int) (flushed, failed, retries int, err error) {
+	uuids, err := pp.redisClient.GetOnlineUUIDsInBuckets(ctx, buckets)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get online players: %w", err)
+	}
+
+	playtimes, epochs, err := pp.redisClient.GetPlayersPlaytime(ctx, uuids)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	afkTicks, err := pp.redisClient.GetPlayersAFKTicks(ctx, uuids)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	updates := make([]service.PlaytimeUpdate, 0, len(playtimes))
+	for uuid, playtime := range playtimes {
+		update := service.PlaytimeUpdate{UUID: uuid, TicksToSet: playtime, SeasonEpoch: epochs[uuid]}
+		if ticks, ok := afkTicks[uuid]; ok {
+			update.AFKTicksToSet = &ticks
+		}
+		updates = append(updates, update)
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].UUID < updates[j].UUID })
+
+	for start := 0; start < len(updates); start += pp.batchSize {
+		end := start + pp.batchSize
+		if end > len(updates) {
+			end = len(updates)
+		}
+		batch := updates[start:end]
+
+		attempts, err := pp.sendBatch(ctx, batch)
+		retries += attempts - 1
+		if err != nil {
+			api.Logger(ctx).Error("Playtime Persister giving up on batch", "players", len(batch), "attempts", attempts, "error", err)
+			failed += len(batch)
+			continue
+		}
+		flushed += len(batch)
+	}
+	return flushed, failed, retries, nil
+}
+
+// sendBatch writes one batch, retrying with exponential backoff. It returns the number of attempts made.
+// Retries carry the request ID of the flush the batch belongs to.
+func (pp *PlaytimePersister) sendBatch(ctx context.Context, batch []service.PlaytimeUpdate) (int, error) {
+	backoff := 500 * time.Millisecond
+	var err error
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		_, err = pp.playerServiceClient.UpdateProfilesPlaytime(attemptCtx, batch)
+		cancel()
+		if err == nil || attempt > pp.maxRetries || pp.ctx.Err() != nil {
+			return attempt, err
+		}
+
+		api.Logger(ctx).Warn("Playtime Persister batch failed, retrying", "players", len(batch), "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-pp.ctx.Done():
+			return attempt, pp.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// recordFlush updates the lag metrics after a flush. Lag only resets when complete is true,
+// i.e. every online player in this instance's buckets was written.
+func (pp *PlaytimePersister) recordFlush(started time.Time, flushed, failed, retries int, complete bool) {
+	now := time.Now()
+
+	pp.statsMux.Lock()
+	defer pp.statsMux.Unlock()
+	pp.stats.LastFlushAt = &now
+	pp.stats.LastFlushDuration = now.Sub(started).Seconds()
+	pp.stats.LastFlushPlayers = flushed
+	pp.stats.TotalFlushed += uint64(flushed)
+	pp.stats.TotalRetries += uint64(retries)
+	pp.stats.LastFlushFailed = failed
+	pp.stats.TotalFailed += uint64(failed)
+	if complete {
+		pp.stats.LastSuccessAt = &started
+	}
+}
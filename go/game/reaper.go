@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	cluster "github.com/Ftotnem/Backend/go/shared/cluster"
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"go.minekube.com/gate/pkg/util/uuid"
+)
+
+// Recovery actions reported for each orphaned session.
+const (
+	RecoveryActionPersistAndClear = "persist_and_clear" // Playtime is written to the player service, then keys are deleted
+	RecoveryActionClear           = "clear"             // No playtime key to persist; leftover keys are deleted
+	RecoveryActionSkipInvalidUUID = "skip_invalid_uuid" // Key hash tag is not a UUID; left untouched
+	RecoveryActionSkipClaimed     = "skip_claimed"      // Another instance is already recovering it
+	RecoveryActionSkipOnline      = "skip_online"       // Player came back online since the scan
+)
+
+// SessionReaper periodically ends sessions whose online key has expired without an
+// offline request (e.g. a crashed proxy), persisting their playtime like HandleOffline.
+// It also sweeps immediately (after the online TTL) when a game-service instance leaves
+// the ServiceRegistrar, so sessions stranded by a crashed instance are recovered promptly.
+// When a proxy leaves the ServiceRegistrar, the presence of every player still on it is
+// invalidated and their sessions are swept right away.
+type SessionReaper struct {
+	redisClient      *RedisClient
+	gameService      *GameService
+	registrar        *cluster.ServiceRegistrar
+	proxyServiceType string
+	reapInterval     time.Duration
+	ctx              context.Context
+	cancel           context.CancelFunc
+}
+
+// RecoveryReport describes the outcome of a sweep, or what a dry run would do.
+type RecoveryReport struct {
+	DryRun   bool               `json:"dry_run"`
+	Found    int                `json:"found"`
+	Sessions []RecoveredSession `json:"sessions"`
+}
+
+// RecoveredSession is the action taken (or planned) for a single orphaned session.
+type RecoveredSession struct {
+	OrphanedSession
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewSessionReaper creates a new SessionReaper instance.
+func NewSessionReaper(redisClient *RedisClient, gameService *GameService, reapInterval time.Duration, registrar *cluster.ServiceRegistrar, proxyServiceType string) *SessionReaper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SessionReaper{
+		redisClient:      redisClient,
+		gameService:      gameService,
+		registrar:        registrar,
+		proxyServiceType: proxyServiceType,
+		reapInterval:     reapInterval,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+}
+
+// Start initiates the reaper loop. This should be run in a goroutine.
+func (sr *SessionReaper) Start() {
+	api.Logger(sr.ctx).Info("Session Reaper starting", "reap_interval", sr.reapInterval)
+	ticker := time.NewTicker(sr.reapInterval)
+	defer ticker.Stop()
+
+	departures := make(chan string, 1)
+	go sr.watchMembership(sr.registrar.GetConfig().ServiceType, departures, false)
+
+	// Unlike game-service departures, every proxy departure must be handled on its own
+	proxyDepartures := make(chan string, 16)
+	go sr.watchMembership(sr.proxyServiceType, proxyDepartures, true)
+
+	// Sessions of a departed instance keep their online key until it expires (or the proxy moves them),
+	// so wait out the online TTL before sweeping on a departure
+	var departureSweep <-chan time.Time
+
+	for {
+		select {
+		case <-sr.ctx.Done():
+			api.Logger(sr.ctx).Info("Session Reaper shutting down")
+			return
+		case <-ticker.C:
+			sr.sweep(api.WithNewRequestID(sr.ctx), false)
+		case id := <-departures:
+			api.Logger(sr.ctx).Info("Session Reaper game service left the cluster; sweeping for orphaned sessions after the online TTL", "service_id", id, "online_ttl", sr.redisClient.onlineTTL)
+			departureSweep = time.After(sr.redisClient.onlineTTL)
+		case <-departureSweep:
+			departureSweep = nil
+			sr.sweep(api.WithNewRequestID(sr.ctx), false)
+		case id := <-proxyDepartures:
+			sr.invalidateProxy(api.WithNewRequestID(sr.ctx), id)
+		}
+	}
+}
+
+// Stop gracefully stops the reaper loop.
+func (sr *SessionReaper) Stop() {
+	sr.cancel()
+}
+
+// watchMembership polls the registrar and reports instances of serviceType that have left.
+// Unless every departure must be delivered, one is dropped while another is still pending.
+func (sr *SessionReaper) watchMembership(serviceType string, departures chan<- string, deliverAll bool) {
+	ticker := time.NewTicker(sr.registrar.GetConfig().HeartbeatInterval)
+	defer ticker.Stop()
+
+	var known map[string]cluster.ServiceInfo
+	for {
+		select {
+		case <-sr.ctx.Done():
+			return
+		case <-ticker.C:
+			active, err := sr.registrar.GetActiveServices(sr.ctx, serviceType)
+			if err != nil {
+				api.Logger(sr.ctx).Warn("Session Reaper failed to get active instances", "service_type", serviceType, "error", err)
+				continue
+			}
+			for id := range known {
+				if _, ok := active[id]; ok {
+					continue
+				}
+				if deliverAll {
+					select {
+					case departures <- id:
+					case <-sr.ctx.Done():
+						return
+					}
+					continue
+				}
+				select {
+				case departures <- id:
+				default: // A departure sweep is already pending and will cover this one too
+				}
+			}
+			known = active
+		}
+	}
+}
+
+// invalidateProxy ends the presence of the players still on a departed proxy, then sweeps so their
+// sessions are persisted and cleared without waiting for the online TTL.
+func (sr *SessionReaper) invalidateProxy(ctx context.Context, proxyID string) {
+	invalidateCtx, cancel := context.WithTimeout(ctx, sr.reapInterval)
+	invalidated, err := sr.redisClient.InvalidatePresence(invalidateCtx, proxyID)
+	cancel()
+	if err != nil {
+		api.Logger(ctx).Error("Session Reaper failed to invalidate presence on departed proxy", "proxy_id", proxyID, "error", err)
+	}
+	if invalidated == 0 {
+		return
+	}
+	api.Logger(ctx).Info("Session Reaper invalidated presence of players on departed proxy", "proxy_id", proxyID, "players", invalidated)
+	sr.sweep(ctx, false)
+}
+
+// sweep finds orphaned sessions and recovers each one this instance manages to claim.
+// With dryRun set, nothing is claimed, persisted or deleted; the report shows what would happen.
+func (sr *SessionReaper) sweep(ctx context.Context, dryRun bool) *RecoveryReport {
+	ctx, cancel := context.WithTimeout(ctx, sr.reapInterval)
+	defer cancel()
+
+	report := &RecoveryReport{DryRun: dryRun, Sessions: []RecoveredSession{}}
+	if !dryRun {
+		// Ending a session writes its playtime, which a season rollover must not race with
+		if frozen, err := sr.redisClient.GetSeasonFreeze(ctx); err != nil || frozen != "" {
+			api.Logger(ctx).Info("Session Reaper skipping sweep while season rollover is in progress", "error", err)
+			return report
+		}
+	}
+	orphaned, err := sr.redisClient.GetOrphanedSessions(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("Session Reaper failed to find orphaned sessions", "error", err)
+		return report
+	}
+	report.Found = len(orphaned)
+
+	for _, session := range orphaned {
+		result := RecoveredSession{OrphanedSession: session, Action: RecoveryActionClear}
+		if session.HasPlaytime {
+			result.Action = RecoveryActionPersistAndClear
+		}
+
+		playerUUID, err := uuid.Parse(session.UUID)
+		if err != nil {
+			result.Action = RecoveryActionSkipInvalidUUID
+			report.Sessions = append(report.Sessions, result)
+			if !dryRun {
+				api.Logger(ctx).Warn("Session Reaper skipping session with invalid UUID", "uuid", session.UUID, "error", err)
+			}
+			continue
+		}
+
+		if dryRun {
+			report.Sessions = append(report.Sessions, result)
+			continue
+		}
+
+		// Another instance may be reaping the same session; only one should persist it
+		claimed, err := sr.redisClient.TryClaimSessionReap(ctx, session.UUID, sr.reapInterval)
+		if err != nil {
+			api.Logger(ctx).Warn("Session Reaper failed to claim session", "uuid", session.UUID, "error", err)
+			result.Error = err.Error()
+			report.Sessions = append(report.Sessions, result)
+			continue
+		}
+		if !claimed {
+			result.Action = RecoveryActionSkipClaimed
+			report.Sessions = append(report.Sessions, result)
+			continue
+		}
+
+		// The player may have come back online (or finished logging in) since the scan
+		online, err := sr.redisClient.IsOnline(ctx, session.UUID)
+		if err != nil {
+			api.Logger(ctx).Warn("Session Reaper failed to check whether player is back online", "uuid", session.UUID, "error", err)
+			result.Error = err.Error()
+			report.Sessions = append(report.Sessions, result)
+			continue
+		}
+		if online {
+			result.Action = RecoveryActionSkipOnline
+			report.Sessions = append(report.Sessions, result)
+			continue
+		}
+
+		err = sr.recoverSession(ctx, playerUUID, session)
+		if errors.Is(err, ErrSessionSuperseded) {
+			// The player logged in again between the online check and ending the session
+			result.Action = RecoveryActionSkipOnline
+		} else if errors.Is(err, ErrSessionEnded) {
+			api.Logger(ctx).Info("Session Reaper found the session already ended", "uuid", session.UUID)
+		} else if err != nil {
+			api.Logger(ctx).Error("Session Reaper failed to recover orphaned session", "uuid", session.UUID, "error", err)
+			result.Error = err.Error()
+		} else {
+			api.Logger(ctx).Info("Session Reaper recovered orphaned session", "uuid", session.UUID, "action", result.Action)
+		}
+		report.Sessions = append(report.Sessions, result)
+	}
+
+	if dryRun {
+		return report
+	}
+
+	// Anything still stale in the online index has no session left to end
+	pruned, err := sr.redisClient.PruneOnlineIndex(ctx, 2*sr.redisClient.onlineTTL)
+	if err != nil {
+		api.Logger(ctx).Warn("Session Reaper failed to prune online index", "error", err)
+	} else if pruned > 0 {
+		api.Logger(ctx).Info("Session Reaper pruned stale online index entries", "entries", pruned)
+	}
+	return report
+}
+
+// recoverSession ends an orphaned session. Only sessions that still have a playtime key are persisted;
+// persisting a partial session would overwrite the stored total with zero.
+func (sr *SessionReaper) recoverSession(ctx context.Context, playerUUID uuid.UUID, session OrphanedSession) error {
+	if session.HasPlaytime {
+		if err := sr.gameService.endPlayerSession(ctx, playerUUID, session.SessionID); err != nil {
+			return err
+		}
+	} else {
+		if err := sr.redisClient.EndSession(ctx, session.UUID, session.SessionID); err != nil {
+			return err
+		}
+	}
+	sr.gameService.publishEvent(ctx, models.EventPlayerOffline, session.UUID, models.PlayerOfflineData{Reason: models.OfflineReasonSessionExpired})
+	return nil
+}
+
+// HandleOrphanedSessions reports the orphaned sessions a sweep would recover, without changing anything.
+// GET /game/admin/sessions/orphaned
+func (sr *SessionReaper) HandleOrphanedSessions(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, sr.sweep(r.Context(), true))
+}
+
+// HandleRecoverSessions runs a recovery sweep immediately and reports what it did.
+// POST /game/admin/sessions/recover
+func (sr *SessionReaper) HandleRecoverSessions(w http.ResponseWriter, r *http.Request) {
+	// A recovery the caller stops waiting for still runs to the end
+	api.WriteJSON(w, http.StatusOK, sr.sweep(context.WithoutCancel(r.Context()), false))
+}
@@ -2,43 +2,101 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"hash/fnv"
+	"log/slog"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
 	"github.com/redis/go-redis/v9" // Import the go-redis library
+	"go.minekube.com/gate/pkg/util/uuid"
 )
 
 // Define a custom error for when a Redis key is not found
 var ErrRedisKeyNotFound = fmt.Errorf("redis key not found")
 
+// ErrStaleFencingToken is returned when a write is rejected because a newer leader has already written.
+var ErrStaleFencingToken = fmt.Errorf("stale fencing token")
+
+// ErrStaleSeasonEpoch is returned when a playtime write belongs to an earlier season epoch than the
+// one the stored value has since moved to, i.e. it was read or computed before a season reset.
+var ErrStaleSeasonEpoch = fmt.Errorf("stale season epoch")
+
+// ErrSessionSuperseded is returned when a session ID is no longer the player's current session,
+// i.e. the player has logged in again since it was issued.
+var ErrSessionSuperseded = fmt.Errorf("session superseded by a newer login")
+
+// ErrSessionEnded is returned when the player has no session left for a session ID to refer to.
+var ErrSessionEnded = fmt.Errorf("session already ended")
+
 // RedisClient wraps the go-redis client and provides methods for game-service operations.
 type RedisClient struct {
-	client    *redis.ClusterClient // Use redis.ClusterClient
-	onlineTTL time.Duration        // TTL for online status keys
+	client     *redis.ClusterClient // Use redis.ClusterClient
+	onlineTTL  time.Duration        // TTL for online status keys
+	sessionTTL time.Duration        // TTL for session keys, extended with every refresh
 }
 
+// TeamTotalsChannel is the pub/sub channel live team totals are fanned out on.
+const TeamTotalsChannel = "team_totals_stream"
+
 // Key constants for Redis
 const (
 	// CHANGE: Use hash tags around the UUID to ensure keys related to the same UUID
 	// hash to the same slot in a Redis Cluster.
-	OnlineKeyPrefix         = "online:{%s}:"              // Key for player online status: online:{uuid}
-	PlaytimeKeyPrefix       = "playtime:{%s}:"            // Key for total playtime: playtime:{uuid}
-	DeltaPlaytimeKeyPrefix  = "deltatime:{%s}:"           // Key for delta playtime since last persist: deltatime:{uuid}
-	BannedKeyPrefix         = "banned:{%s}:"              // Key for player ban status: banned:{uuid}
-	PlayerTeamKeyPrefix     = "team:{%s}:"                // Key for player's assigned team: team:{uuid}
-	TeamTotalPlaytimePrefix = "team_total_playtime:{%s}:" // Key for total playtime of a team: team_total_playtime:{teamID}
+	OnlineKeyPrefix           = "online:{%s}:"                    // Key for player online status: online:{uuid}
+	SessionKeyPrefix          = "session:{%s}:"                   // ID of the player's current session: session:{uuid}
+	LocationKeyPrefix         = "location:{%s}:"                  // Hash of the proxy and backend server a player is on: location:{uuid}
+	ProxyPlayersKeyPrefix     = "proxy_players:{%s}:"             // Set of players seen on a proxy; verified against location on read: proxy_players:{proxyID}
+	ServerPlayersKeyPrefix    = "server_players:{%s}:"            // Set of players seen on a backend server; verified against location on read: server_players:{server}
+	PlaytimeKeyPrefix         = "playtime:{%s}:"                  // Key for total playtime: playtime:{uuid}
+	DeltaPlaytimeKeyPrefix    = "deltatime:{%s}:"                 // Key for delta playtime since last persist: deltatime:{uuid}
+	BannedKeyPrefix           = "banned:{%s}:"                    // Key for player ban status: banned:{uuid}
+	BanReasonKeyPrefix        = "ban_reason:{%s}:"                // Key for the reason of a player's ban: ban_reason:{uuid}
+	MutedKeyPrefix            = "muted:{%s}:"                     // Key for player mute status (expiry in unix seconds, 0 = permanent): muted:{uuid}
+	MuteReasonKeyPrefix       = "mute_reason:{%s}:"               // Key for the reason of a player's mute: mute_reason:{uuid}
+	PlayerTeamKeyPrefix       = "team:{%s}:"                      // Key for player's assigned team: team:{uuid}
+	BoostersKeyPrefix         = "boosters:{%s}:"                  // Hash of active boosters (ID -> redisBooster JSON): boosters:{uuid}
+	ActivityKeyPrefix         = "activity:{%s}:"                  // Unix ms of the player's last input reported by their proxy: activity:{uuid}
+	AFKKeyPrefix              = "afk:{%s}:"                       // Unix ms the player went AFK; only present while AFK: afk:{uuid}
+	CreditedKeyPrefix         = "credited:{%s}:"                  // Unix ms up to which the tick has credited a player's session: credited:{uuid}
+	AFKTicksKeyPrefix         = "afk_ticks:{%s}:"                 // Total ticks the player has spent AFK, loaded from their profile: afk_ticks:{uuid}
+	ReapLockKeyPrefix         = "reaping:{%s}:"                   // Short-lived claim on reaping an expired session: reaping:{uuid}
+	TeamTotalPlaytimePrefix   = "team_total_playtime:{%s}:"       // Key for total playtime of a team: team_total_playtime:{teamID}
+	TeamTotalFenceKeyPrefix   = "team_total_fence:{%s}:"          // Highest leader fencing token that set a team total: team_total_fence:{teamID}
+	OnlineIndexKeyPrefix      = "online_index:{%d}:"              // Sorted set of online players (score = last heartbeat, unix ms): online_index:{bucket}
+	PartitionLeaseKeyPrefix   = "partition_lease:{%d}:"           // Instance ID holding the right to tick a bucket: partition_lease:{bucket}
+	RingKeyPrefix             = "ring:{%s}:"                      // Hash of ring epoch and members: ring:{serviceType}
+	TickCursorKeyPrefix       = "tick_cursor:{%d}:"               // Unix ms up to which a bucket has been credited: tick_cursor:{bucket}
+	GlobalLeaderboardKey      = "leaderboard:{global}:"           // Sorted set of every player's total playtime
+	TeamLeaderboardPrefix     = "leaderboard_team:{%s}:"          // Sorted set of a team's players' total playtime: leaderboard_team:{teamID}
+	LeaderboardMemberPrefix   = "leaderboard_member:{%s}:"        // Team whose leaderboard a player is on: leaderboard_member:{uuid}
+	SeasonFreezeKey           = "season_freeze:{global}:"         // ID of the season whose rollover has frozen playtime accrual
+	SeasonEpochKey            = "season_epoch:{global}:"          // Season epoch live playtime is credited to; shares the freeze's hash tag
+	PlaytimeEpochKeyPrefix    = "playtime_epoch:{%s}:"            // Season epoch a player's playtime belongs to: playtime_epoch:{uuid}
+	TeamTotalEpochKeyPrefix   = "team_total_playtime_epoch:{%s}:" // Season epoch a team's total belongs to: team_total_playtime_epoch:{teamID}
+	LeaderboardSeedPrefix     = "leaderboard_seed:{global}:%s:"   // Global leaderboard staged by one seed run: leaderboard_seed:{global}:runID
+	TeamLeaderboardSeedPrefix = "leaderboard_team_seed:{%s}:%s:"  // Team leaderboard staged by one seed run: leaderboard_team_seed:{teamID}:runID
 )
 
+// OnlineIndexBuckets is the number of online index buckets. Each bucket has its own hash tag,
+// so buckets spread across cluster slots, and buckets are the unit game-service instances own.
+// It must be identical on every instance.
+const OnlineIndexBuckets = 64
+
 // NewRedisClient initializes a new Redis client.
-// It takes the Redis address(es) and the online status TTL from the configuration.
-func NewRedisClient(addrs []string, onlineTTL time.Duration) (*RedisClient, error) {
+// It takes the Redis address(es) and the online status and session TTLs from the configuration.
+func NewRedisClient(addrs []string, onlineTTL, sessionTTL time.Duration) (*RedisClient, error) {
 	rdb := redis.NewClusterClient(&redis.ClusterOptions{
 		Addrs: addrs,
 	})
+	rdb.AddHook(api.RedisMetricsHook{})
+	rdb.AddHook(api.RedisTracingHook{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -48,11 +106,25 @@ func NewRedisClient(addrs []string, onlineTTL time.Duration) (*RedisClient, erro
 		return nil, fmt.Errorf("failed to connect to Redis Cluster: %w", err)
 	}
 
-	log.Printf("Successfully connected to Redis Cluster at %v", addrs)
+	slog.Info("Connected to Redis Cluster", "addrs", addrs)
+
+	// Preload Lua scripts on every master so the tick path can use EVALSHA directly
+	err = rdb.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		for _, script := range []*redis.Script{incrementPlaytimeScript, publishRingScript, acquireLeaseScript, releaseLeaseScript, setTickCursorScript, setTeamTotalScript, incrementTeamTotalScript, setPlaytimeScript, resetCounterScript, raiseSeasonEpochScript, claimSessionScript, refreshSessionScript, endSessionScript, setLocationScript, invalidatePresenceScript, recordActivityScript, setPunishmentScript} {
+			if err := script.Load(ctx, client).Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Lua scripts into Redis Cluster: %w", err)
+	}
 
 	return &RedisClient{
-		client:    rdb,
-		onlineTTL: onlineTTL,
+		client:     rdb,
+		onlineTTL:  onlineTTL,
+		sessionTTL: sessionTTL,
 	}, nil
 }
 
@@ -71,16 +143,340 @@ func teamKey(prefix, teamID string) string {
 	return fmt.Sprintf(prefix, teamID)
 }
 
-// SetOnlineStatus sets a player's online status in Redis with a TTL.
+// OnlineIndexBucket returns the online index bucket a player belongs to.
+func OnlineIndexBucket(uuid string) int {
+	h := fnv.New32a()
+	h.Write([]byte(uuid))
+	return int(h.Sum32() % OnlineIndexBuckets)
+}
+
+// Helper function to format online index keys with the bucket hash tag
+func onlineIndexKey(bucket int) string {
+	return fmt.Sprintf(OnlineIndexKeyPrefix, bucket)
+}
+
+// SetOnlineStatus sets a player's online status in Redis with a TTL and records them in the online index.
 func (rc *RedisClient) SetOnlineStatus(ctx context.Context, uuid string) error {
 	key := playerKey(OnlineKeyPrefix, uuid)
-	return rc.client.Set(ctx, key, "true", rc.onlineTTL).Err()
+	pipe := rc.client.Pipeline()
+	pipe.Set(ctx, key, "true", rc.onlineTTL)
+	pipe.ZAdd(ctx, onlineIndexKey(OnlineIndexBucket(uuid)), redis.Z{Score: float64(time.Now().UnixMilli()), Member: uuid})
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// SetOfflineStatus removes a player's online status from Redis.
-func (rc *RedisClient) SetOfflineStatus(ctx context.Context, uuid string) error {
-	key := playerKey(OnlineKeyPrefix, uuid)
-	return rc.client.Del(ctx, key).Err()
+// ClaimSession starts a new session for a player and returns its ID, unless sessionID is the player's
+// current session, in which case that ID is returned and started is false. Pass an empty sessionID for
+// a fresh login. A sessionID that has been superseded by a newer login yields ErrSessionSuperseded.
+// The session key expires after the session TTL unless refreshed, so a login that never completes can't leak it.
+func (rc *RedisClient) ClaimSession(ctx context.Context, uuid, sessionID string) (string, bool, error) {
+	res, err := claimSessionScript.Run(ctx, rc.client, []string{playerKey(SessionKeyPrefix, uuid)}, sessionID, newSessionID(), rc.sessionTTL.Milliseconds()).Slice()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to claim session for %s: %w", uuid, err)
+	}
+	if len(res) != 2 {
+		return "", false, fmt.Errorf("unexpected claim session reply for %s: %v", uuid, res)
+	}
+	state, _ := res[0].(int64)
+	current, _ := res[1].(string)
+	switch state {
+	case 1:
+		return current, true, nil
+	case 0:
+		return current, false, nil
+	default:
+		return "", false, ErrSessionSuperseded
+	}
+}
+
+// newSessionID returns a fresh, globally unique session ID.
+func newSessionID() string {
+	return uuid.New().String()
+}
+
+// CheckSession returns nil if sessionID is the player's current session, ErrSessionEnded if the player
+// has no session, or ErrSessionSuperseded if a newer session has replaced it.
+func (rc *RedisClient) CheckSession(ctx context.Context, uuid, sessionID string) error {
+	current, err := rc.client.Get(ctx, playerKey(SessionKeyPrefix, uuid)).Result()
+	if err == redis.Nil {
+		if sessionID == "" {
+			return nil // A session from before session IDs existed
+		}
+		return ErrSessionEnded
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get session for %s: %w", uuid, err)
+	}
+	if current != sessionID {
+		return ErrSessionSuperseded
+	}
+	return nil
+}
+
+// EndSession removes a player's online status, online index entry and every key that is only needed
+// while online, provided sessionID is still the player's current session. Otherwise nothing is
+// removed and ErrSessionEnded or ErrSessionSuperseded is returned.
+func (rc *RedisClient) EndSession(ctx context.Context, uuid, sessionID string) error {
+	keysToDelete := []string{
+		playerKey(SessionKeyPrefix, uuid),  // Must stay first; the script compares it with sessionID
+		playerKey(LocationKeyPrefix, uuid), // Must stay second; the script returns it before deleting
+		playerKey(OnlineKeyPrefix, uuid),
+		playerKey(PlaytimeKeyPrefix, uuid),
+		playerKey(DeltaPlaytimeKeyPrefix, uuid),
+		playerKey(PlayerTeamKeyPrefix, uuid),
+		playerKey(BoostersKeyPrefix, uuid),
+		playerKey(ActivityKeyPrefix, uuid),
+		playerKey(AFKKeyPrefix, uuid),
+		playerKey(AFKTicksKeyPrefix, uuid),
+		playerKey(PlaytimeEpochKeyPrefix, uuid),
+		playerKey(CreditedKeyPrefix, uuid),
+	}
+
+	res, err := endSessionScript.Run(ctx, rc.client, keysToDelete, sessionID).Slice()
+	if err != nil {
+		return fmt.Errorf("failed to delete player session keys for %s: %w", uuid, err)
+	}
+	if len(res) != 3 {
+		return fmt.Errorf("unexpected end session reply for %s: %v", uuid, res)
+	}
+	deletedCount, _ := res[0].(int64)
+	proxyID, _ := res[1].(string)
+	server, _ := res[2].(string)
+	if deletedCount < 0 {
+		return ErrSessionSuperseded
+	}
+	if deletedCount == 0 && sessionID != "" {
+		return ErrSessionEnded
+	}
+
+	if err := rc.client.ZRem(ctx, onlineIndexKey(OnlineIndexBucket(uuid)), uuid).Err(); err != nil {
+		return fmt.Errorf("failed to remove %s from the online index: %w", uuid, err)
+	}
+	rc.removeFromLocationSets(ctx, uuid, proxyID, server)
+	api.Logger(ctx).Info("Deleted Redis session keys", "uuid", uuid, "count", deletedCount)
+	return nil
+}
+
+// RefreshOnlineStatus extends the TTL of the given players' online keys. sessions maps each player's
+// UUID to the session ID the caller holds. The result maps each UUID to nil if its presence was
+// refreshed, ErrSessionEnded if it had already expired (presence is not resurrected, so the caller
+// must send a fresh online request), or ErrSessionSuperseded if the player has logged in again elsewhere.
+func (rc *RedisClient) RefreshOnlineStatus(ctx context.Context, sessions map[string]string) (map[string]error, error) {
+	uuids := make([]string, 0, len(sessions))
+	for uuid := range sessions {
+		uuids = append(uuids, uuid)
+	}
+	ttlMillis := rc.onlineTTL.Milliseconds()
+	sessionTTLMillis := rc.sessionTTL.Milliseconds()
+	cmds := rc.evalShaPipelined(ctx, refreshSessionScript, len(uuids), func(i int) ([]string, []interface{}) {
+		keys := []string{playerKey(SessionKeyPrefix, uuids[i]), playerKey(OnlineKeyPrefix, uuids[i]), playerKey(LocationKeyPrefix, uuids[i])}
+		return keys, []interface{}{sessions[uuids[i]], ttlMillis, sessionTTLMillis}
+	})
+
+	results := make(map[string]error, len(uuids))
+	indexPipe := rc.client.Pipeline()
+	score := float64(time.Now().UnixMilli())
+	for i, uuid := range uuids {
+		state, err := cmds[i].Int64()
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh online status for %s: %w", uuid, err)
+		}
+		switch state {
+		case 1:
+			results[uuid] = nil
+			indexPipe.ZAdd(ctx, onlineIndexKey(OnlineIndexBucket(uuid)), redis.Z{Score: score, Member: uuid})
+		case -1:
+			results[uuid] = ErrSessionSuperseded
+		default:
+			results[uuid] = ErrSessionEnded
+		}
+	}
+	if indexPipe.Len() > 0 {
+		if _, err := indexPipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to refresh online index for %d players: %w", indexPipe.Len(), err)
+		}
+	}
+	return results, nil
+}
+
+// SetPlayerLocations records the proxy and backend server each player is on, and moves them between
+// the per-proxy and per-server sets. Empty fields leave the stored value unchanged. The location
+// shares the online key's TTL; RefreshOnlineStatus extends both.
+func (rc *RedisClient) SetPlayerLocations(ctx context.Context, locations []models.PlayerPresence) error {
+	if len(locations) == 0 {
+		return nil
+	}
+	ttlMillis := rc.onlineTTL.Milliseconds()
+	cmds := rc.evalShaPipelined(ctx, setLocationScript, len(locations), func(i int) ([]string, []interface{}) {
+		return []string{playerKey(LocationKeyPrefix, locations[i].UUID)}, []interface{}{locations[i].ProxyID, locations[i].Server, ttlMillis}
+	})
+
+	pipe := rc.client.Pipeline()
+	for i, location := range locations {
+		res, err := cmds[i].Slice()
+		if err != nil {
+			return fmt.Errorf("failed to set location for %s: %w", location.UUID, err)
+		}
+		if len(res) != 2 {
+			return fmt.Errorf("unexpected set location reply for %s: %v", location.UUID, res)
+		}
+		previousProxy, _ := res[0].(string)
+		previousServer, _ := res[1].(string)
+
+		if location.ProxyID != "" {
+			pipe.SAdd(ctx, locationSetKey(ProxyPlayersKeyPrefix, location.ProxyID), location.UUID)
+			if previousProxy != "" && previousProxy != location.ProxyID {
+				pipe.SRem(ctx, locationSetKey(ProxyPlayersKeyPrefix, previousProxy), location.UUID)
+			}
+		}
+		if location.Server != "" {
+			pipe.SAdd(ctx, locationSetKey(ServerPlayersKeyPrefix, location.Server), location.UUID)
+			if previousServer != "" && previousServer != location.Server {
+				pipe.SRem(ctx, locationSetKey(ServerPlayersKeyPrefix, previousServer), location.UUID)
+			}
+		}
+	}
+	if pipe.Len() > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to update proxy and server sets for %d players: %w", len(locations), err)
+		}
+	}
+	return nil
+}
+
+// GetPlayerPresence returns the proxy and backend server a player is on, or nil if no location is stored.
+func (rc *RedisClient) GetPlayerPresence(ctx context.Context, uuid string) (*models.PlayerPresence, error) {
+	fields, err := rc.client.HGetAll(ctx, playerKey(LocationKeyPrefix, uuid)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location for %s: %w", uuid, err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return &models.PlayerPresence{UUID: uuid, ProxyID: fields["proxy"], Server: fields["server"]}, nil
+}
+
+// GetPlayersOnProxy returns the players currently connected through a proxy.
+func (rc *RedisClient) GetPlayersOnProxy(ctx context.Context, proxyID string) ([]models.PlayerPresence, error) {
+	return rc.getPlayersInLocationSet(ctx, locationSetKey(ProxyPlayersKeyPrefix, proxyID), func(p models.PlayerPresence) bool {
+		return p.ProxyID == proxyID
+	})
+}
+
+// GetPlayersOnServer returns the players currently on a backend server.
+func (rc *RedisClient) GetPlayersOnServer(ctx context.Context, server string) ([]models.PlayerPresence, error) {
+	return rc.getPlayersInLocationSet(ctx, locationSetKey(ServerPlayersKeyPrefix, server), func(p models.PlayerPresence) bool {
+		return p.Server == server
+	})
+}
+
+// getPlayersInLocationSet reads a proxy or server set and keeps the players whose stored location
+// still matches. Members that have moved on or gone offline are removed from the set on the way.
+func (rc *RedisClient) getPlayersInLocationSet(ctx context.Context, setKey string, matches func(models.PlayerPresence) bool) ([]models.PlayerPresence, error) {
+	uuids, err := rc.client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", setKey, err)
+	}
+	sort.Strings(uuids)
+
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(uuids))
+	for i, uuid := range uuids {
+		cmds[i] = pipe.HGetAll(ctx, playerKey(LocationKeyPrefix, uuid))
+	}
+	if len(uuids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read locations for %d players in %s: %w", len(uuids), setKey, err)
+		}
+	}
+
+	players := []models.PlayerPresence{}
+	var stale []interface{}
+	for i, uuid := range uuids {
+		fields := cmds[i].Val()
+		presence := models.PlayerPresence{UUID: uuid, ProxyID: fields["proxy"], Server: fields["server"]}
+		if len(fields) == 0 || !matches(presence) {
+			stale = append(stale, uuid)
+			continue
+		}
+		players = append(players, presence)
+	}
+	if len(stale) > 0 {
+		if err := rc.client.SRem(ctx, setKey, stale...).Err(); err != nil {
+			api.Logger(ctx).Warn("Failed to prune stale members", "key", setKey, "count", len(stale), "error", err)
+		}
+	}
+	return players, nil
+}
+
+// InvalidatePresence ends the presence of every player still on proxyID, e.g. once the proxy has left
+// the cluster. Their sessions become orphaned and are persisted and cleared by the SessionReaper.
+// It returns how many players were invalidated.
+func (rc *RedisClient) InvalidatePresence(ctx context.Context, proxyID string) (int, error) {
+	setKey := locationSetKey(ProxyPlayersKeyPrefix, proxyID)
+	uuids, err := rc.client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", setKey, err)
+	}
+
+	if len(uuids) == 0 {
+		return 0, nil
+	}
+
+	cmds := rc.evalShaPipelined(ctx, invalidatePresenceScript, len(uuids), func(i int) ([]string, []interface{}) {
+		return []string{playerKey(LocationKeyPrefix, uuids[i]), playerKey(OnlineKeyPrefix, uuids[i])}, []interface{}{proxyID}
+	})
+	invalidated := 0
+	pipe := rc.client.Pipeline()
+	for i, cmd := range cmds {
+		res, err := cmd.Slice()
+		if err != nil {
+			return invalidated, fmt.Errorf("failed to invalidate presence of %s: %w", uuids[i], err)
+		}
+		if len(res) != 2 {
+			return invalidated, fmt.Errorf("unexpected invalidate presence reply for %s: %v", uuids[i], res)
+		}
+		if removed, _ := res[0].(int64); removed == 1 {
+			invalidated++
+			if server, _ := res[1].(string); server != "" {
+				pipe.SRem(ctx, locationSetKey(ServerPlayersKeyPrefix, server), uuids[i])
+			}
+		}
+	}
+
+	// Only the members read above are dropped; a player who joined through the proxy since stays listed
+	members := make([]interface{}, len(uuids))
+	for i, uuid := range uuids {
+		members[i] = uuid
+	}
+	pipe.SRem(ctx, setKey, members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return invalidated, fmt.Errorf("failed to remove invalidated players from %s: %w", setKey, err)
+	}
+	return invalidated, nil
+}
+
+// removeFromLocationSets drops a player from a proxy and server set. Failures are only logged,
+// since readers skip members whose location no longer matches anyway.
+func (rc *RedisClient) removeFromLocationSets(ctx context.Context, uuid, proxyID, server string) {
+	pipe := rc.client.Pipeline()
+	if proxyID != "" {
+		pipe.SRem(ctx, locationSetKey(ProxyPlayersKeyPrefix, proxyID), uuid)
+	}
+	if server != "" {
+		pipe.SRem(ctx, locationSetKey(ServerPlayersKeyPrefix, server), uuid)
+	}
+	if pipe.Len() == 0 {
+		return
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		api.Logger(ctx).Warn("Failed to remove player from proxy and server sets", "uuid", uuid, "error", err)
+	}
+}
+
+// Helper function to format proxy and server set keys with the proxy ID or server name hash tag
+func locationSetKey(prefix, id string) string {
+	return fmt.Sprintf(prefix, id)
 }
 
 // IsOnline checks if a player is currently marked as online in Redis.
@@ -94,22 +490,81 @@ func (rc *RedisClient) IsOnline(ctx context.Context, uuid string) (bool, error)
 }
 
 // SetBanStatus sets or removes a player's ban status in Redis with a TTL.
-func (rc *RedisClient) SetBanStatus(ctx context.Context, uuid string, banned bool, banExpiresAt int64) error {
-	key := playerKey(BannedKeyPrefix, uuid)
+// The ban reason is kept alongside under its own key with the same TTL. Setting a ban never shortens
+// one already stored (see setTimedPunishment); the expiry and reason in effect afterwards are returned.
+func (rc *RedisClient) SetBanStatus(ctx context.Context, uuid string, banned bool, banExpiresAt int64, reason string) (int64, string, error) {
+	return rc.setTimedPunishment(ctx, playerKey(BannedKeyPrefix, uuid), playerKey(BanReasonKeyPrefix, uuid), banned, banExpiresAt, reason)
+}
 
-	if banned {
-		if banExpiresAt > 0 { // Temporary ban
-			duration := time.Until(time.Unix(banExpiresAt, 0))
-			if duration < 0 {
-				duration = 1 * time.Millisecond
-			}
-			return rc.client.Set(ctx, key, banExpiresAt, duration).Err()
-		} else { // Permanent ban (banExpiresAt == 0)
-			return rc.client.Set(ctx, key, banExpiresAt, 0).Err()
+// SetMuteStatus sets or removes a player's mute status in Redis, exactly like SetBanStatus does for bans.
+func (rc *RedisClient) SetMuteStatus(ctx context.Context, uuid string, muted bool, muteExpiresAt int64, reason string) (int64, string, error) {
+	return rc.setTimedPunishment(ctx, playerKey(MutedKeyPrefix, uuid), playerKey(MuteReasonKeyPrefix, uuid), muted, muteExpiresAt, reason)
+}
+
+// setTimedPunishment stores a punishment's expiry (unix seconds, <= 0 for permanent) under key and its
+// reason under reasonKey, both expiring with the punishment, or deletes both if active is false.
+// A stored punishment that lasts longer (a later expiry, or permanent) is kept instead. It returns
+// the expiry and reason in effect afterwards.
+func (rc *RedisClient) setTimedPunishment(ctx context.Context, key, reasonKey string, active bool, expiresAt int64, reason string) (int64, string, error) {
+	if !active {
+		return 0, "", rc.client.Del(ctx, key, reasonKey).Err()
+	}
+
+	var duration time.Duration // Permanent punishment (expiresAt <= 0) never expires
+	if expiresAt > 0 {         // Temporary punishment
+		duration = time.Until(time.Unix(expiresAt, 0))
+		if duration < time.Millisecond {
+			duration = 1 * time.Millisecond
 		}
-	} else {
-		return rc.client.Del(ctx, key).Err()
 	}
+
+	// Both keys share the {uuid} hash tag, so the script can compare and write them in one step
+	res, err := setPunishmentScript.Run(ctx, rc.client, []string{key, reasonKey}, expiresAt, reason, duration.Milliseconds()).Slice()
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	if len(res) != 2 {
+		return 0, "", fmt.Errorf("unexpected set punishment reply for %s: %v", key, res)
+	}
+	expiry, _ := res[0].(string)
+	effectiveReason, _ := res[1].(string)
+	effectiveExpiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to parse expiry of %s: %w", key, err)
+	}
+	return effectiveExpiresAt, effectiveReason, nil
+}
+
+// GetBanDetails returns the stored expiry (unix seconds, <= 0 for permanent) and reason of a player's ban.
+// Callers should check IsBanned first; ErrRedisKeyNotFound is returned if no ban is stored.
+func (rc *RedisClient) GetBanDetails(ctx context.Context, uuid string) (int64, string, error) {
+	return rc.getTimedPunishment(ctx, playerKey(BannedKeyPrefix, uuid), playerKey(BanReasonKeyPrefix, uuid))
+}
+
+// GetMuteDetails returns the stored expiry (unix seconds, <= 0 for permanent) and reason of a player's mute.
+// ErrRedisKeyNotFound is returned if the player is not muted.
+func (rc *RedisClient) GetMuteDetails(ctx context.Context, uuid string) (int64, string, error) {
+	return rc.getTimedPunishment(ctx, playerKey(MutedKeyPrefix, uuid), playerKey(MuteReasonKeyPrefix, uuid))
+}
+
+// getTimedPunishment reads back what setTimedPunishment stored under key and reasonKey.
+func (rc *RedisClient) getTimedPunishment(ctx context.Context, key, reasonKey string) (int64, string, error) {
+	pipe := rc.client.Pipeline()
+	expiresCmd := pipe.Get(ctx, key)
+	reasonCmd := pipe.Get(ctx, reasonKey)
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return 0, "", fmt.Errorf("failed to get %s: %w", key, err)
+	}
+
+	expiresAt, err := expiresCmd.Int64()
+	if err == redis.Nil {
+		return 0, "", ErrRedisKeyNotFound
+	} else if err != nil {
+		return 0, "", fmt.Errorf("failed to parse expiry in %s: %w", key, err)
+	}
+	// A missing reason (e.g. bans set before reasons were stored) is not an error
+	return expiresAt, reasonCmd.Val(), nil
 }
 
 // IsBanned checks if a player is currently marked as banned in Redis and if the ban is still active.
@@ -125,7 +580,7 @@ func (rc *RedisClient) IsBanned(ctx context.Context, uuid string) (bool, error)
 
 	expiresAt, parseErr := strconv.ParseInt(val, 10, 64)
 	if parseErr != nil {
-		log.Printf("WARNING: Ban status for %s has non-timestamp value: %s. Treating as not banned.", uuid, val)
+		api.Logger(ctx).Warn("Ban status has non-timestamp value; treating as not banned", "uuid", uuid, "value", val)
 		return false, nil
 	}
 
@@ -134,7 +589,7 @@ func (rc *RedisClient) IsBanned(ctx context.Context, uuid string) (bool, error)
 			delCtx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 			defer cancel()
 			if err := rc.client.Del(delCtx, key).Err(); err != nil {
-				log.Printf("Error deleting expired ban key %s: %v", key, err)
+				api.Logger(ctx).Error("Failed to delete expired ban key", "key", key, "error", err)
 			}
 		}()
 		return false, nil
@@ -143,19 +598,32 @@ func (rc *RedisClient) IsBanned(ctx context.Context, uuid string) (bool, error)
 	return true, nil
 }
 
-// SetPlayerTeam sets a player's assigned team in Redis.
+// SetPlayerTeam sets a player's assigned team in Redis. If the player was on another team's
+// leaderboard, they are removed from it.
 func (rc *RedisClient) SetPlayerTeam(ctx context.Context, uuid string, teamID string) error {
 	key := playerKey(PlayerTeamKeyPrefix, uuid)
-	return rc.client.Set(ctx, key, teamID, 0).Err()
+	if err := rc.client.Set(ctx, key, teamID, 0).Err(); err != nil {
+		return err
+	}
+	return rc.moveLeaderboardTeams(ctx, map[string]string{uuid: teamID})
 }
 
-func (rc *RedisClient) SetTeamTotal(ctx context.Context, teamID string, totalPlaytime float64) error {
-	redisKey := teamKey(TeamTotalPlaytimePrefix, teamID)
-	err := rc.client.Set(ctx, redisKey, totalPlaytime, 0).Err()
+// SetTeamTotal overwrites a team's total playtime with the aggregate computed by the syncer leader
+// in season epoch. The write is rejected with ErrStaleFencingToken if a leader with a newer token
+// already wrote it, or with ErrStaleSeasonEpoch if the total has been reset for a later season since.
+func (rc *RedisClient) SetTeamTotal(ctx context.Context, teamID string, totalPlaytime float64, fencingToken, epoch int64) error {
+	keys := []string{teamKey(TeamTotalPlaytimePrefix, teamID), teamKey(TeamTotalFenceKeyPrefix, teamID), teamKey(TeamTotalEpochKeyPrefix, teamID)}
+	written, err := setTeamTotalScript.Run(ctx, rc.client, keys, totalPlaytime, fencingToken, epoch).Int64()
 	if err != nil {
 		return fmt.Errorf("failed to set team total playtime for %s in Redis: %w", teamID, err)
 	}
-	log.Printf("INFO: Successfully set Redis total playtime for team '%s' to %.2f ticks.", teamID, totalPlaytime)
+	switch written {
+	case 0:
+		return fmt.Errorf("team total playtime for %s not set with fencing token %d: %w", teamID, fencingToken, ErrStaleFencingToken)
+	case -1:
+		return fmt.Errorf("team total playtime for %s not set for season epoch %d: %w", teamID, epoch, ErrStaleSeasonEpoch)
+	}
+	api.Logger(ctx).Info("Set Redis total playtime for team", "team", teamID, "total", totalPlaytime)
 	return nil
 }
 
@@ -178,103 +646,494 @@ func (rc *RedisClient) GetTeamTotalPlaytime(ctx context.Context, teamID string)
 	return val, nil
 }
 
-// And GetAllTeamTotalPlaytimes needs to use the new pattern for scanning:
-// GetAllTeamTotalPlaytimes fetches all team total playtime values from Redis.
-func (rc *RedisClient) GetAllTeamTotalPlaytimes(ctx context.Context) (map[string]float64, error) {
-	teamPlaytimes := make(map[string]float64)
-	// Adjust SCAN pattern to match the new key format
-	iter := rc.client.Scan(ctx, 0, TeamTotalPlaytimePrefix+"*", 0).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-		// Extract teamID from key: "team_total_playtime:{teamID}:"
-		// Find '{' and '}' to get the hash tag content
-		start := strings.Index(key, "{")
-		end := strings.Index(key, "}")
-		teamID := ""
-		if start != -1 && end != -1 && end > start {
-			teamID = key[start+1 : end]
-		} else {
-			log.Printf("WARN: Could not parse team ID from team total playtime key: %s", key)
+// GetAllTeamTotalPlaytimes fetches all team total playtime values from Redis.
+// Team keys are spread over the cluster by their {teamID} hash tag, so every master is scanned.
+func (rc *RedisClient) GetAllTeamTotalPlaytimes(ctx context.Context) (map[string]float64, error) {
+	teamPlaytimes := make(map[string]float64)
+	var mu sync.Mutex
+
+	err := rc.client.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		iter := client.Scan(ctx, 0, "team_total_playtime:*", 0).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			// Extract teamID from key: "team_total_playtime:{teamID}:"
+			// Find '{' and '}' to get the hash tag content
+			start := strings.Index(key, "{")
+			end := strings.Index(key, "}")
+			teamID := ""
+			if start != -1 && end != -1 && end > start {
+				teamID = key[start+1 : end]
+			} else {
+				api.Logger(ctx).Warn("Could not parse team ID from team total playtime key", "key", key)
+				continue
+			}
+			val, err := client.Get(ctx, key).Float64()
+			if err != nil {
+				api.Logger(ctx).Warn("Failed to get team total playtime", "team", teamID, "key", key, "error", err)
+				continue
+			}
+			mu.Lock()
+			teamPlaytimes[teamID] = val
+			mu.Unlock()
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("failed to scan team total playtime keys on master node %s: %w", client.Options().Addr, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return teamPlaytimes, nil
+}
+
+// GetOnlineCountsByTeam counts online players per team across every online index bucket.
+func (rc *RedisClient) GetOnlineCountsByTeam(ctx context.Context) (map[string]int, error) {
+	buckets := make([]int, OnlineIndexBuckets)
+	for i := range buckets {
+		buckets[i] = i
+	}
+	uuids, err := rc.GetOnlineUUIDsInBuckets(ctx, buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	if len(uuids) == 0 {
+		return counts, nil
+	}
+
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(uuids))
+	for i, uuid := range uuids {
+		cmds[i] = pipe.Get(ctx, playerKey(PlayerTeamKeyPrefix, uuid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get teams of %d online players: %w", len(uuids), err)
+	}
+	for _, cmd := range cmds {
+		if team, err := cmd.Result(); err == nil {
+			counts[team]++
+		}
+	}
+	return counts, nil
+}
+
+// leaderboardKey returns the global leaderboard key, or a team's leaderboard key if teamID is set.
+func leaderboardKey(teamID string) string {
+	if teamID == "" {
+		return GlobalLeaderboardKey
+	}
+	return teamKey(TeamLeaderboardPrefix, teamID)
+}
+
+// UpdateLeaderboards writes credited players' new totals to the global and team leaderboards.
+func (rc *RedisClient) UpdateLeaderboards(ctx context.Context, players []CreditedPlayer) error {
+	if len(players) == 0 {
+		return nil
+	}
+	global := make([]redis.Z, 0, len(players))
+	byTeam := make(map[string][]redis.Z)
+	for _, p := range players {
+		z := redis.Z{Score: p.Playtime, Member: p.UUID}
+		global = append(global, z)
+		byTeam[p.Team] = append(byTeam[p.Team], z)
+	}
+
+	pipe := rc.client.Pipeline()
+	pipe.ZAdd(ctx, GlobalLeaderboardKey, global...)
+	for teamID, members := range byTeam {
+		pipe.ZAdd(ctx, leaderboardKey(teamID), members...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update leaderboards for %d players: %w", len(players), err)
+	}
+	return nil
+}
+
+// moveLeaderboardTeams records which team's leaderboard each player is on, and removes players
+// whose team changed from their previous team's leaderboard. Players without a team are skipped.
+func (rc *RedisClient) moveLeaderboardTeams(ctx context.Context, teams map[string]string) error {
+	uuids := make([]string, 0, len(teams))
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.StatusCmd, 0, len(teams))
+	for uuid, teamID := range teams {
+		if teamID == "" {
+			continue
+		}
+		uuids = append(uuids, uuid)
+		cmds = append(cmds, pipe.SetArgs(ctx, playerKey(LeaderboardMemberPrefix, uuid), teamID, redis.SetArgs{Get: true}))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to record leaderboard teams of %d players: %w", len(cmds), err)
+	}
+
+	pipe = rc.client.Pipeline()
+	moved := 0
+	for i, cmd := range cmds {
+		previous, err := cmd.Result()
+		if err != nil || previous == teams[uuids[i]] {
+			continue // redis.Nil: first time on a team leaderboard
+		}
+		pipe.ZRem(ctx, leaderboardKey(previous), uuids[i])
+		moved++
+	}
+	if moved == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove %d players from their previous team leaderboards: %w", moved, err)
+	}
+	return nil
+}
+
+// leaderboardSeedKey returns the key a seed run stages the global leaderboard in, or a team's
+// leaderboard if teamID is set. It shares the hash tag of the live leaderboard it is merged into.
+func leaderboardSeedKey(teamID, runID string) string {
+	if teamID == "" {
+		return fmt.Sprintf(LeaderboardSeedPrefix, runID)
+	}
+	return fmt.Sprintf(TeamLeaderboardSeedPrefix, teamID, runID)
+}
+
+// SeedLeaderboards stages persisted profiles for the leaderboards under the seed run runID, to be
+// merged into the live leaderboards by CommitLeaderboardSeed once the run is complete. Staged
+// leaderboards expire after ttl, so a run that is never committed or discarded leaves nothing behind.
+// Players whose team has changed are removed from their previous team's leaderboard.
+func (rc *RedisClient) SeedLeaderboards(ctx context.Context, runID string, profiles []models.Player, ttl time.Duration) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+	pipe := rc.client.Pipeline()
+	teams := make(map[string]string, len(profiles))
+	staged := make(map[string]struct{})
+	for _, profile := range profiles {
+		z := redis.Z{Score: profile.TotalPlaytimeTicks, Member: profile.UUID}
+		pipe.ZAddGT(ctx, leaderboardSeedKey("", runID), z)
+		staged[""] = struct{}{}
+		if profile.Team != "" {
+			pipe.ZAddGT(ctx, leaderboardSeedKey(profile.Team, runID), z)
+			teams[profile.UUID] = profile.Team
+			staged[profile.Team] = struct{}{}
+		}
+	}
+	for teamID := range staged {
+		pipe.Expire(ctx, leaderboardSeedKey(teamID, runID), ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to stage leaderboard seed with %d profiles: %w", len(profiles), err)
+	}
+	return rc.moveLeaderboardTeams(ctx, teams)
+}
+
+// CommitLeaderboardSeed merges the leaderboards staged by the seed run runID into the live
+// global leaderboard and those of teams, then deletes them. Scores are only ever raised, so a
+// profile loaded from MongoDB never overwrites a newer total from the tick path.
+func (rc *RedisClient) CommitLeaderboardSeed(ctx context.Context, runID string, teams []string) error {
+	pipe := rc.client.Pipeline()
+	for _, teamID := range append([]string{""}, teams...) {
+		live, staged := leaderboardKey(teamID), leaderboardSeedKey(teamID, runID)
+		pipe.ZUnionStore(ctx, live, &redis.ZStore{Keys: []string{live, staged}, Aggregate: "MAX"})
+		pipe.Del(ctx, staged)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to commit leaderboard seed %s for %d teams: %w", runID, len(teams), err)
+	}
+	return nil
+}
+
+// DiscardLeaderboardSeed deletes the leaderboards staged by the seed run runID without merging them.
+func (rc *RedisClient) DiscardLeaderboardSeed(ctx context.Context, runID string, teams []string) error {
+	pipe := rc.client.Pipeline()
+	for _, teamID := range append([]string{""}, teams...) {
+		pipe.Del(ctx, leaderboardSeedKey(teamID, runID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to discard leaderboard seed %s for %d teams: %w", runID, len(teams), err)
+	}
+	return nil
+}
+
+// GetLeaderboardTop returns the limit highest-ranked players of a leaderboard (global if teamID is empty).
+func (rc *RedisClient) GetLeaderboardTop(ctx context.Context, teamID string, limit int64) ([]models.LeaderboardEntry, error) {
+	return rc.getLeaderboardRange(ctx, teamID, 0, limit-1)
+}
+
+// GetLeaderboardStanding returns a player's rank and percentile on a leaderboard (global if teamID is empty).
+// It returns ErrRedisKeyNotFound if the player is not on the leaderboard.
+func (rc *RedisClient) GetLeaderboardStanding(ctx context.Context, teamID, uuid string) (*models.LeaderboardStanding, error) {
+	key := leaderboardKey(teamID)
+	pipe := rc.client.Pipeline()
+	rankCmd := pipe.ZRevRank(ctx, key, uuid)
+	scoreCmd := pipe.ZScore(ctx, key, uuid)
+	totalCmd := pipe.ZCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		if err == redis.Nil {
+			return nil, ErrRedisKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get leaderboard standing for %s: %w", uuid, err)
+	}
+
+	rank := rankCmd.Val()
+	total := totalCmd.Val()
+	return &models.LeaderboardStanding{
+		LeaderboardEntry: models.LeaderboardEntry{Rank: rank + 1, UUID: uuid, Playtime: scoreCmd.Val()},
+		Team:             teamID,
+		Total:            total,
+		Percentile:       float64(total-rank) / float64(total) * 100,
+	}, nil
+}
+
+// GetLeaderboardNeighbours returns the players ranked up to radius places above and below a player,
+// including the player. It returns ErrRedisKeyNotFound if the player is not on the leaderboard.
+func (rc *RedisClient) GetLeaderboardNeighbours(ctx context.Context, teamID, uuid string, radius int64) ([]models.LeaderboardEntry, error) {
+	rank, err := rc.client.ZRevRank(ctx, leaderboardKey(teamID), uuid).Result()
+	if err == redis.Nil {
+		return nil, ErrRedisKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard rank for %s: %w", uuid, err)
+	}
+
+	start := rank - radius
+	if start < 0 {
+		start = 0
+	}
+	return rc.getLeaderboardRange(ctx, teamID, start, rank+radius)
+}
+
+// getLeaderboardRange returns the leaderboard entries between two 0-based ranks, inclusive.
+func (rc *RedisClient) getLeaderboardRange(ctx context.Context, teamID string, start, stop int64) ([]models.LeaderboardEntry, error) {
+	members, err := rc.client.ZRevRangeWithScores(ctx, leaderboardKey(teamID), start, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard range %d-%d: %w", start, stop, err)
+	}
+
+	entries := make([]models.LeaderboardEntry, 0, len(members))
+	for i, member := range members {
+		uuid, _ := member.Member.(string)
+		entries = append(entries, models.LeaderboardEntry{Rank: start + int64(i) + 1, UUID: uuid, Playtime: member.Score})
+	}
+	return entries, nil
+}
+
+// PublishTeamTotals broadcasts an encoded team totals frame to every game-service instance.
+func (rc *RedisClient) PublishTeamTotals(ctx context.Context, payload []byte) error {
+	if err := rc.client.Publish(ctx, TeamTotalsChannel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish team totals: %w", err)
+	}
+	return nil
+}
+
+// SubscribeTeamTotals subscribes to the team totals broadcast. The caller must close the subscription.
+func (rc *RedisClient) SubscribeTeamTotals(ctx context.Context) *redis.PubSub {
+	return rc.client.Subscribe(ctx, TeamTotalsChannel)
+}
+
+// evalShaPipelined runs script n times in a single pipeline, taking each call's keys and args
+// from callArgs. Calls that fail with NOSCRIPT (script flushed, or a node added since startup)
+// are retried individually with Run, which loads the script again.
+// Per-command errors, including redis.Nil, are left for the caller to inspect.
+func (rc *RedisClient) evalShaPipelined(ctx context.Context, script *redis.Script, n int, callArgs func(i int) ([]string, []interface{})) []*redis.Cmd {
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.Cmd, n)
+	for i := 0; i < n; i++ {
+		keys, args := callArgs(i)
+		cmds[i] = script.EvalSha(ctx, pipe, keys, args...)
+	}
+	_, _ = pipe.Exec(ctx)
+
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil && redis.HasErrorPrefix(err, "NOSCRIPT") {
+			keys, args := callArgs(i)
+			cmds[i] = script.Run(ctx, rc.client, keys, args...)
+		}
+	}
+	return cmds
+}
+
+// CreditedPlayer is a player's state right after a tick credited them.
+type CreditedPlayer struct {
+	UUID     string
+	Team     string
+	Playtime float64 // New total playtime
+	AFK      bool    // Whether the player was AFK for this credit
+}
+
+// TickCredit describes the ticks a game tick credits to the players of some buckets.
+type TickCredit struct {
+	Ticks    int           // Ticks owed (more than 1 when catching up on missed ticks)
+	Until    int64         // Unix ms the ticks credit up to; the buckets' next tick cursor
+	Interval time.Duration // Tick interval; no player is owed more than one tick per interval of their session
+	Epoch    int64         // Season epoch the ticks are credited to
+}
+
+// IncrementPlayersPlaytime credits ticks to each player via incrementPlaytimeScript,
+// pipelining one EVALSHA per player. Players idle for at least afkTimeout (0 disables AFK
+// detection) are credited afkMultiplier times their usual increment. It returns the total
+// increment per team so the caller can flush team totals once per team instead of once per
+// player, along with every credited player's new total for the leaderboards. Players whose
+// playtime has moved to a later season epoch than credit's are skipped.
+//
+// Each player is only credited for the part of the ticks their session has not been credited
+// for yet, so the same credit can be retried after an error without crediting anyone twice.
+// An error is returned if any player could not be credited.
+func (rc *RedisClient) IncrementPlayersPlaytime(ctx context.Context, uuids []string, credit TickCredit, afkTimeout time.Duration, afkMultiplier float64) (map[string]float64, []CreditedPlayer, error) {
+	now := time.Now()
+	keysFor := func(uuid string) []string {
+		return []string{
+			playerKey(PlaytimeKeyPrefix, uuid),
+			playerKey(DeltaPlaytimeKeyPrefix, uuid),
+			playerKey(PlayerTeamKeyPrefix, uuid),
+			playerKey(BoostersKeyPrefix, uuid),
+			playerKey(ActivityKeyPrefix, uuid),
+			playerKey(AFKKeyPrefix, uuid),
+			playerKey(AFKTicksKeyPrefix, uuid),
+			playerKey(PlaytimeEpochKeyPrefix, uuid),
+			playerKey(CreditedKeyPrefix, uuid),
+		}
+	}
+
+	cmds := rc.evalShaPipelined(ctx, incrementPlaytimeScript, len(uuids), func(i int) ([]string, []interface{}) {
+		return keysFor(uuids[i]), []interface{}{
+			now.Unix(), credit.Ticks, now.UnixMilli(), afkTimeout.Milliseconds(), afkMultiplier,
+			credit.Epoch, credit.Until, credit.Interval.Milliseconds(),
+		}
+	})
+
+	teamIncrements := make(map[string]float64)
+	credited := make([]CreditedPlayer, 0, len(uuids))
+	var failed int
+	for i, cmd := range cmds {
+		err := cmd.Err()
+		if err == redis.Nil {
+			continue // Session ended or incomplete, or the tick is from an earlier season; nothing to credit
+		}
+		if err != nil {
+			api.Logger(ctx).Error("Failed to increment total playtime", "uuid", uuids[i], "error", err)
+			failed++
 			continue
 		}
-		val, err := rc.client.Get(ctx, key).Float64()
+
+		result, err := cmd.StringSlice()
+		if err != nil || len(result) != 4 {
+			api.Logger(ctx).Error("Failed to parse playtime increment", "uuid", uuids[i], "error", err)
+			failed++
+			continue
+		}
+		increment, err := strconv.ParseFloat(result[1], 64)
 		if err != nil {
-			log.Printf("WARN: Failed to get team total playtime for %s (key %s): %v", teamID, key, err)
+			api.Logger(ctx).Error("Failed to parse playtime increment", "uuid", uuids[i], "increment", result[1], "error", err)
+			failed++
 			continue
 		}
-		teamPlaytimes[teamID] = val
+		teamIncrements[result[0]] += increment
+
+		if total, err := strconv.ParseFloat(result[2], 64); err == nil {
+			credited = append(credited, CreditedPlayer{UUID: uuids[i], Team: result[0], Playtime: total, AFK: result[3] == "1"})
+		}
 	}
-	if err := iter.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan team total playtime keys: %w", err)
+
+	if failed > 0 {
+		return teamIncrements, credited, fmt.Errorf("failed to increment playtime for %d of %d players", failed, len(uuids))
 	}
-	return teamPlaytimes, nil
+	return teamIncrements, credited, nil
 }
 
-// IncrementPlayerPlaytime increments a player's total playtime and their team's total playtime in Redis.
-func (rc *RedisClient) IncrementPlayerPlaytime(ctx context.Context, uuid string) error {
-	deltaKey := playerKey(DeltaPlaytimeKeyPrefix, uuid)
-	totalPlaytimeKey := playerKey(PlaytimeKeyPrefix, uuid)
-	playerTeamKey := playerKey(PlayerTeamKeyPrefix, uuid) // Renamed for clarity
-
-	// Get the team ID for the player.
-	teamIDResult, err := rc.client.Get(ctx, playerTeamKey).Result()
-	if err == redis.Nil {
-		log.Printf("WARN: Team ID key %s not found for player %s. Cannot increment team playtime.", playerTeamKey, uuid)
-		// Decide how to handle this:
-		// 1. Return an error if team association is mandatory:
-		// return fmt.Errorf("team ID not found for player %s", uuid)
-		// 2. Or, perhaps proceed only with player playtime increment if team playtime is optional:
-		//    (This would require adjusting the pipeline logic below)
-		return nil // For now, following original flow for missing data
+// IncrementTeamTotals flushes a tick's accumulated team increments, credited in season epoch,
+// with one incrementTeamTotalScript call per team. Teams whose total has moved to a later season
+// epoch are skipped. On error it also returns the increments that were not flushed, so the
+// caller can retry them without adding the others twice.
+func (rc *RedisClient) IncrementTeamTotals(ctx context.Context, teamIncrements map[string]float64, epoch int64) (map[string]float64, error) {
+	if len(teamIncrements) == 0 {
+		return nil, nil
 	}
-	if err != nil {
-		return fmt.Errorf("failed to get team ID for player %s: %w", uuid, err)
+	teams := make([]string, 0, len(teamIncrements))
+	for teamID := range teamIncrements {
+		teams = append(teams, teamID)
 	}
-	teamID := teamIDResult // Now teamID is the actual string
-
-	teamTotalPlaytimeKey := teamKey(TeamTotalPlaytimePrefix, teamID) // Using the new teamKey helper
-
-	// 1. Get the delta value as a string from Redis.
-	deltaStr, err := rc.client.Get(ctx, deltaKey).Result()
-	if err == redis.Nil {
-		log.Printf("INFO: Delta playtime key %s not found for %s. Assuming delta is 0.", deltaKey, uuid)
-		// If delta is 0, there's nothing to increment.
-		return nil
+	cmds := rc.evalShaPipelined(ctx, incrementTeamTotalScript, len(teams), func(i int) ([]string, []interface{}) {
+		return []string{teamKey(TeamTotalPlaytimePrefix, teams[i]), teamKey(TeamTotalEpochKeyPrefix, teams[i])}, []interface{}{teamIncrements[teams[i]], epoch}
+	})
+	var unflushed map[string]float64
+	var firstErr error
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			if unflushed == nil {
+				unflushed, firstErr = make(map[string]float64), err
+			}
+			unflushed[teams[i]] = teamIncrements[teams[i]]
+		}
 	}
-	if err != nil {
-		return fmt.Errorf("failed to get delta playtime for %s: %w", uuid, err)
+	if firstErr != nil {
+		return unflushed, fmt.Errorf("failed to flush team playtime increments for %d of %d teams: %w", len(unflushed), len(teams), firstErr)
 	}
+	return nil, nil
+}
 
-	// 2. Convert the string delta value to a float64.
-	deltaFloat, err := strconv.ParseFloat(deltaStr, 64)
-	if err != nil {
-		return fmt.Errorf("failed to parse delta playtime '%s' for %s as float: %w", deltaStr, uuid, err)
+// redisBooster is the compact form of a booster mirrored into Redis.
+// It is decoded by incrementPlaytimeScript, hence the unix-seconds expiry (0 = never).
+type redisBooster struct {
+	Value     float64 `json:"value"`
+	ExpiresAt int64   `json:"expires_at"`
+}
+
+// marshalRedisBooster encodes a booster for the boosters:{uuid}: hash.
+func marshalRedisBooster(b models.Booster) ([]byte, error) {
+	rb := redisBooster{Value: b.Value}
+	if b.ExpiresAt != nil {
+		rb.ExpiresAt = b.ExpiresAt.Unix()
 	}
+	return json.Marshal(rb)
+}
 
-	// Use a Redis Pipeline for atomic execution of both increments.
-	// This ensures both operations are sent to Redis as a single batch,
-	// and if they share a hash slot, they'll be processed atomically on that node.
-	pipe := rc.client.Pipeline()
-	playerIncr := pipe.IncrByFloat(ctx, totalPlaytimeKey, deltaFloat)
-	teamIncr := pipe.IncrByFloat(ctx, teamTotalPlaytimeKey, deltaFloat)
+// SetPlayerBoosters replaces a player's mirrored boosters in Redis. Expired boosters are skipped.
+func (rc *RedisClient) SetPlayerBoosters(ctx context.Context, uuid string, boosters []models.Booster) error {
+	key := playerKey(BoostersKeyPrefix, uuid)
+	now := time.Now()
 
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		// pipe.Exec already aggregates errors. Check if any command failed within the pipeline.
-		return fmt.Errorf("failed to execute playtime increments in pipeline for %s (team %s): %w", uuid, teamID, err)
+	fields := make(map[string]interface{}, len(boosters))
+	for _, b := range boosters {
+		if b.IsExpired(now) {
+			continue
+		}
+		data, err := marshalRedisBooster(b)
+		if err != nil {
+			return fmt.Errorf("failed to marshal booster %s for %s: %w", b.ID, uuid, err)
+		}
+		fields[b.ID] = data
 	}
 
-	// Although pipe.Exec aggregates, checking individual command errors can provide more specific context.
-	if playerIncr.Err() != nil {
-		return fmt.Errorf("player playtime increment failed for %s: %w", uuid, playerIncr.Err())
+	pipe := rc.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(fields) > 0 {
+		pipe.HSet(ctx, key, fields)
 	}
-	if teamIncr.Err() != nil {
-		return fmt.Errorf("team playtime increment failed for team %s: %w", teamID, teamIncr.Err())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set boosters for %s: %w", uuid, err)
 	}
-
 	return nil
 }
 
+// AddPlayerBooster mirrors a single booster into a player's Redis booster hash.
+func (rc *RedisClient) AddPlayerBooster(ctx context.Context, uuid string, booster models.Booster) error {
+	data, err := marshalRedisBooster(booster)
+	if err != nil {
+		return fmt.Errorf("failed to marshal booster %s for %s: %w", booster.ID, uuid, err)
+	}
+	key := playerKey(BoostersKeyPrefix, uuid)
+	return rc.client.HSet(ctx, key, booster.ID, data).Err()
+}
+
+// RemovePlayerBooster removes a single booster from a player's Redis booster hash.
+func (rc *RedisClient) RemovePlayerBooster(ctx context.Context, uuid string, boosterID string) error {
+	key := playerKey(BoostersKeyPrefix, uuid)
+	return rc.client.HDel(ctx, key, boosterID).Err()
+}
+
 // --- NEW RedisClient GETTER METHODS START ---
 
 // GetPlayerPlaytime retrieves a player's total playtime from Redis.
@@ -305,6 +1164,66 @@ func (rc *RedisClient) GetDeltaPlaytime(ctx context.Context, uuid string) (float
 
 // --- NEW RedisClient GETTER METHODS END ---
 
+// GetPlayersPlaytime fetches the total playtime of many players in one pipeline, along with the
+// season epoch each total belongs to. Players without a playtime key (session ended) are absent from the maps.
+func (rc *RedisClient) GetPlayersPlaytime(ctx context.Context, uuids []string) (map[string]float64, map[string]int64, error) {
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(uuids))
+	for i, uuid := range uuids {
+		// Both keys share the {uuid} hash tag, so the total and its epoch are read together
+		cmds[i] = pipe.MGet(ctx, playerKey(PlaytimeKeyPrefix, uuid), playerKey(PlaytimeEpochKeyPrefix, uuid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, fmt.Errorf("failed to get playtime for %d players: %w", len(uuids), err)
+	}
+
+	playtimes := make(map[string]float64, len(uuids))
+	epochs := make(map[string]int64, len(uuids))
+	for i, cmd := range cmds {
+		values := cmd.Val()
+		if len(values) != 2 {
+			continue
+		}
+		raw, ok := values[0].(string)
+		if !ok {
+			continue // Session ended since the index was read
+		}
+		playtime, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		playtimes[uuids[i]] = playtime
+		epochs[uuids[i]] = parseSeasonEpoch(values[1])
+	}
+	return playtimes, epochs, nil
+}
+
+// parseSeasonEpoch parses a season epoch read with MGET. A missing epoch is epoch 0, the one
+// before any season reset.
+func parseSeasonEpoch(value interface{}) int64 {
+	raw, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	epoch, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return epoch
+}
+
+// GetPlayerSeasonEpoch returns the season epoch a player's live playtime belongs to.
+func (rc *RedisClient) GetPlayerSeasonEpoch(ctx context.Context, uuid string) (int64, error) {
+	epoch, err := rc.client.Get(ctx, playerKey(PlaytimeEpochKeyPrefix, uuid)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get season epoch for %s: %w", uuid, err)
+	}
+	return epoch, nil
+}
+
 // GetPlayerPlaytimeAndDelta fetches a player's total playtime and delta playtime from Redis.
 func (rc *RedisClient) GetPlayerPlaytimeAndDelta(ctx context.Context, uuid string) (float64, float64, error) {
 	totalPlaytimeKey := playerKey(PlaytimeKeyPrefix, uuid)
@@ -314,7 +1233,7 @@ func (rc *RedisClient) GetPlayerPlaytimeAndDelta(ctx context.Context, uuid strin
 	totalCmd := pipe.Get(ctx, totalPlaytimeKey)
 	deltaCmd := pipe.Get(ctx, deltaPlaytimeKey)
 	_, err := pipe.Exec(ctx)
-	if err != nil {
+	if err != nil && err != redis.Nil { // Missing keys are handled per command below
 		return 0, 0, fmt.Errorf("failed to get player playtime and delta in pipeline: %w", err)
 	}
 
@@ -335,10 +1254,19 @@ func (rc *RedisClient) GetPlayerPlaytimeAndDelta(ctx context.Context, uuid strin
 	return totalPlaytime, deltaPlaytime, nil
 }
 
-// SetPlayerPlaytime sets a player's total playtime in Redis.
-func (rc *RedisClient) SetPlayerPlaytime(ctx context.Context, uuid string, playtime float64) error {
-	key := playerKey(PlaytimeKeyPrefix, uuid)
-	return rc.client.Set(ctx, key, playtime, 0).Err()
+// SetPlayerPlaytime sets a player's total playtime in Redis as belonging to season epoch, and
+// credits their session from now on.
+// Returns ErrStaleSeasonEpoch if the player's playtime has moved to a later season epoch.
+func (rc *RedisClient) SetPlayerPlaytime(ctx context.Context, uuid string, playtime float64, epoch int64) error {
+	keys := []string{playerKey(PlaytimeKeyPrefix, uuid), playerKey(PlaytimeEpochKeyPrefix, uuid), playerKey(CreditedKeyPrefix, uuid)}
+	written, err := setPlaytimeScript.Run(ctx, rc.client, keys, playtime, epoch, time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to set total playtime for %s: %w", uuid, err)
+	}
+	if written == 0 {
+		return fmt.Errorf("total playtime for %s not set for season epoch %d: %w", uuid, epoch, ErrStaleSeasonEpoch)
+	}
+	return nil
 }
 
 // SetDeltaPlaytime sets a player's delta playtime in Redis.
@@ -347,6 +1275,90 @@ func (rc *RedisClient) SetDeltaPlaytime(ctx context.Context, uuid string, deltaP
 	return rc.client.Set(ctx, key, deltaPlaytime, 0).Err()
 }
 
+// RecordActivity stores the latest input time the proxy reported for each player's session.
+// The result maps each UUID to nil if recorded, or ErrSessionEnded or ErrSessionSuperseded
+// if the reported session is no longer the player's current one. Input times ahead of this
+// instance's clock are stored as the current time.
+func (rc *RedisClient) RecordActivity(ctx context.Context, activity []PlayerActivity) (map[string]error, error) {
+	now := time.Now().UnixMilli()
+	cmds := rc.evalShaPipelined(ctx, recordActivityScript, len(activity), func(i int) ([]string, []interface{}) {
+		keys := []string{playerKey(SessionKeyPrefix, activity[i].UUID), playerKey(ActivityKeyPrefix, activity[i].UUID)}
+		return keys, []interface{}{activity[i].SessionID, activity[i].LastInputAt.UnixMilli(), now}
+	})
+
+	results := make(map[string]error, len(activity))
+	for i, a := range activity {
+		state, err := cmds[i].Int64()
+		if err != nil {
+			return nil, fmt.Errorf("failed to record activity for %s: %w", a.UUID, err)
+		}
+		switch state {
+		case 1:
+			results[a.UUID] = nil
+		case -1:
+			results[a.UUID] = ErrSessionSuperseded
+		default:
+			results[a.UUID] = ErrSessionEnded
+		}
+	}
+	return results, nil
+}
+
+// GetAFKSince returns when a player went AFK, or nil if they are not AFK.
+func (rc *RedisClient) GetAFKSince(ctx context.Context, uuid string) (*time.Time, error) {
+	millis, err := rc.client.Get(ctx, playerKey(AFKKeyPrefix, uuid)).Int64()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AFK state for %s: %w", uuid, err)
+	}
+	since := time.UnixMilli(millis)
+	return &since, nil
+}
+
+// SetAFKTicks sets the total ticks a player has spent AFK, from which the tick path keeps counting.
+func (rc *RedisClient) SetAFKTicks(ctx context.Context, uuid string, ticks float64) error {
+	key := playerKey(AFKTicksKeyPrefix, uuid)
+	return rc.client.Set(ctx, key, ticks, 0).Err()
+}
+
+// GetAFKTicks returns the total ticks a player has spent AFK.
+// It returns ErrRedisKeyNotFound if no AFK total is loaded for the player.
+func (rc *RedisClient) GetAFKTicks(ctx context.Context, uuid string) (float64, error) {
+	val, err := rc.client.Get(ctx, playerKey(AFKTicksKeyPrefix, uuid)).Float64()
+	if err == redis.Nil {
+		return 0, ErrRedisKeyNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get AFK ticks for %s: %w", uuid, err)
+	}
+	return val, nil
+}
+
+// GetPlayersAFKTicks fetches the AFK tick totals of many players in one pipeline.
+// Players without an AFK total loaded are absent from the map.
+func (rc *RedisClient) GetPlayersAFKTicks(ctx context.Context, uuids []string) (map[string]float64, error) {
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(uuids))
+	for i, uuid := range uuids {
+		cmds[i] = pipe.Get(ctx, playerKey(AFKTicksKeyPrefix, uuid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get AFK ticks for %d players: %w", len(uuids), err)
+	}
+
+	afkTicks := make(map[string]float64, len(uuids))
+	for i, cmd := range cmds {
+		ticks, err := cmd.Float64()
+		if err != nil {
+			continue // redis.Nil: session ended or the total was never loaded
+		}
+		afkTicks[uuids[i]] = ticks
+	}
+	return afkTicks, nil
+}
+
 // CheckPlaytimeKeysExist checks if both total playtime and delta playtime keys exist for a player.
 func (rc *RedisClient) CheckPlaytimeKeysExist(ctx context.Context, uuid string) (bool, bool, error) {
 	totalPlaytimeKey := playerKey(PlaytimeKeyPrefix, uuid)
@@ -363,70 +1375,292 @@ func (rc *RedisClient) CheckPlaytimeKeysExist(ctx context.Context, uuid string)
 	return totalExistsCmd.Val() == 1, deltaExistsCmd.Val() == 1, nil
 }
 
-// RemovePlayerSessionData removes all player-specific keys that are only needed while online.
-func (rc *RedisClient) RemovePlayerSessionData(ctx context.Context, uuid string) error {
-	keysToDelete := []string{
-		playerKey(OnlineKeyPrefix, uuid),
-		playerKey(PlaytimeKeyPrefix, uuid),
-		playerKey(DeltaPlaytimeKeyPrefix, uuid),
-		playerKey(PlayerTeamKeyPrefix, uuid),
+// PublishRingMembers records the given ring members (if they differ from the stored ones)
+// and returns the current ring epoch and stored member list.
+func (rc *RedisClient) PublishRingMembers(ctx context.Context, serviceType string, members []string) (int64, []string, error) {
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+
+	key := fmt.Sprintf(RingKeyPrefix, serviceType)
+	result, err := publishRingScript.Run(ctx, rc.client, []string{key}, strings.Join(sorted, ",")).StringSlice()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to publish ring members for %s: %w", serviceType, err)
 	}
+	return parseRing(result)
+}
 
-	deletedCount, err := rc.client.Del(ctx, keysToDelete...).Result()
+// GetRing returns the current ring epoch and member list for a service type (epoch 0 if none is stored).
+func (rc *RedisClient) GetRing(ctx context.Context, serviceType string) (int64, []string, error) {
+	key := fmt.Sprintf(RingKeyPrefix, serviceType)
+	result, err := rc.client.HMGet(ctx, key, "epoch", "members").Result()
 	if err != nil {
-		return fmt.Errorf("failed to delete player session keys for %s: %w", uuid, err)
+		return 0, nil, fmt.Errorf("failed to get ring for %s: %w", serviceType, err)
 	}
-	log.Printf("Deleted %d Redis session keys for player %s.", deletedCount, uuid)
-	return nil
+	fields := make([]string, len(result))
+	for i, v := range result {
+		if str, ok := v.(string); ok {
+			fields[i] = str
+		}
+	}
+	if fields[0] == "" {
+		return 0, nil, nil
+	}
+	return parseRing(fields)
 }
 
-// GetAllOnlineUUIDs retrieves all UUIDs currently marked as online.
-// This uses SCAN to iterate keys, which is suitable for production.
-// In redisclient.go (GetAllOnlineUUIDs)
-func (rc *RedisClient) GetAllOnlineUUIDs(ctx context.Context) ([]string, error) {
-	var allOnlineUUIDs []string
-	var mu sync.Mutex // Declare a Mutex to protect allOnlineUUIDs
+// parseRing decodes the {epoch, members} pair stored in a ring hash.
+func parseRing(fields []string) (int64, []string, error) {
+	if len(fields) != 2 {
+		return 0, nil, fmt.Errorf("malformed ring entry: %v", fields)
+	}
+	epoch, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed ring epoch %q: %w", fields[0], err)
+	}
+	var members []string
+	if fields[1] != "" {
+		members = strings.Split(fields[1], ",")
+	}
+	return epoch, members, nil
+}
 
-	scanPattern := "online:*"
+// AcquirePartitionLeases takes or renews the leases for the given buckets on behalf of holderID.
+// It returns, per bucket, whether holderID holds the lease afterwards.
+func (rc *RedisClient) AcquirePartitionLeases(ctx context.Context, buckets []int, holderID string, ttl time.Duration) (map[int]bool, error) {
+	cmds := rc.evalShaPipelined(ctx, acquireLeaseScript, len(buckets), func(i int) ([]string, []interface{}) {
+		return []string{fmt.Sprintf(PartitionLeaseKeyPrefix, buckets[i])}, []interface{}{holderID, ttl.Milliseconds()}
+	})
 
-	err := rc.client.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
-		// Defensive check: Ensure client is not nil, as discussed before.
-		if client == nil {
-			log.Printf("ERROR: ForEachMaster provided a NIL Redis client for node. Skipping this node.")
-			return nil // Return nil error to continue processing other masters, or return specific error
+	held := make(map[int]bool, len(buckets))
+	var lastErr error
+	for i, cmd := range cmds {
+		ok, err := cmd.Int64()
+		if err != nil {
+			lastErr = err
+			continue
 		}
+		held[buckets[i]] = ok == 1
+	}
+	if lastErr != nil && len(held) == 0 && len(buckets) > 0 {
+		return nil, fmt.Errorf("failed to acquire partition leases: %w", lastErr)
+	}
+	return held, nil
+}
 
-		iter := client.Scan(ctx, 0, scanPattern, 0).Iterator()
+// GetTickCursors returns the last-credited timestamp (unix ms) for each bucket that has one.
+// Buckets that have never been ticked, or whose cursor expired, are absent from the map.
+func (rc *RedisClient) GetTickCursors(ctx context.Context, buckets []int) (map[int]int64, error) {
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(buckets))
+	for i, bucket := range buckets {
+		cmds[i] = pipe.Get(ctx, fmt.Sprintf(TickCursorKeyPrefix, bucket))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get tick cursors: %w", err)
+	}
 
-		for iter.Next(ctx) {
-			key := iter.Val()
+	cursors := make(map[int]int64, len(buckets))
+	for i, cmd := range cmds {
+		cursor, err := cmd.Int64()
+		if err != nil {
+			continue // redis.Nil: no cursor yet
+		}
+		cursors[buckets[i]] = cursor
+	}
+	return cursors, nil
+}
 
-			start := strings.Index(key, "{")
-			end := strings.Index(key, "}")
-			if start != -1 && end != -1 && end > start {
-				uuid := key[start+1 : end]
+// SetTickCursors stores the last-credited timestamp (unix ms) for each bucket whose partition
+// lease holderID still holds, and returns the buckets whose lease it has lost, which are left alone.
+// Cursors expire after ttl so buckets nobody has ticked in a long time start fresh.
+func (rc *RedisClient) SetTickCursors(ctx context.Context, cursors map[int]int64, holderID string, ttl time.Duration) ([]int, error) {
+	if len(cursors) == 0 {
+		return nil, nil
+	}
+	buckets := make([]int, 0, len(cursors))
+	for bucket := range cursors {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	cmds := rc.evalShaPipelined(ctx, setTickCursorScript, len(buckets), func(i int) ([]string, []interface{}) {
+		keys := []string{fmt.Sprintf(PartitionLeaseKeyPrefix, buckets[i]), fmt.Sprintf(TickCursorKeyPrefix, buckets[i])}
+		return keys, []interface{}{holderID, cursors[buckets[i]], ttl.Milliseconds()}
+	})
 
-				// --- CRITICAL CHANGE: Use a mutex to protect the append operation ---
-				mu.Lock()                                     // Lock before modifying the shared slice
-				allOnlineUUIDs = append(allOnlineUUIDs, uuid) // Line 345 was here!
-				mu.Unlock()                                   // Unlock after modification
-				// --- END CRITICAL CHANGE ---
+	var lost []int
+	for i, cmd := range cmds {
+		set, err := cmd.Int64()
+		if err != nil {
+			return lost, fmt.Errorf("failed to set tick cursor for bucket %d: %w", buckets[i], err)
+		}
+		if set == 0 {
+			lost = append(lost, buckets[i])
+		}
+	}
+	return lost, nil
+}
 
-			} else {
-				log.Printf("WARN in GetAllOnlineUUIDs (Node %s): Could not parse UUID from key: %s", client.Options().Addr, key)
-			}
+// ReleasePartitionLeases gives up the leases holderID holds for the given buckets.
+func (rc *RedisClient) ReleasePartitionLeases(ctx context.Context, buckets []int, holderID string) error {
+	cmds := rc.evalShaPipelined(ctx, releaseLeaseScript, len(buckets), func(i int) ([]string, []interface{}) {
+		return []string{fmt.Sprintf(PartitionLeaseKeyPrefix, buckets[i])}, []interface{}{holderID}
+	})
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			return fmt.Errorf("failed to release partition lease for bucket %d: %w", buckets[i], err)
 		}
-		if err := iter.Err(); err != nil {
-			return fmt.Errorf("failed to scan on master node %s: %w", client.Options().Addr, err)
+	}
+	return nil
+}
+
+// GetOnlineUUIDsInBuckets reads the online index buckets given and returns players whose
+// last heartbeat is within the online TTL. One ZRANGEBYSCORE per bucket, pipelined.
+func (rc *RedisClient) GetOnlineUUIDsInBuckets(ctx context.Context, buckets []int) ([]string, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+	minScore := strconv.FormatInt(time.Now().Add(-rc.onlineTTL).UnixMilli(), 10)
+
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(buckets))
+	for i, bucket := range buckets {
+		cmds[i] = pipe.ZRangeByScore(ctx, onlineIndexKey(bucket), &redis.ZRangeBy{Min: minScore, Max: "+inf"})
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read %d online index buckets: %w", len(buckets), err)
+	}
+
+	var onlineUUIDs []string
+	for _, cmd := range cmds {
+		onlineUUIDs = append(onlineUUIDs, cmd.Val()...)
+	}
+	return onlineUUIDs, nil
+}
+
+// PruneOnlineIndex drops index entries whose last heartbeat is older than maxAge.
+// Live sessions are re-added on their next heartbeat, so this only clears leftovers.
+func (rc *RedisClient) PruneOnlineIndex(ctx context.Context, maxAge time.Duration) (int64, error) {
+	maxScore := "(" + strconv.FormatInt(time.Now().Add(-maxAge).UnixMilli(), 10)
+
+	pipe := rc.client.Pipeline()
+	cmds := make([]*redis.IntCmd, OnlineIndexBuckets)
+	for bucket := 0; bucket < OnlineIndexBuckets; bucket++ {
+		cmds[bucket] = pipe.ZRemRangeByScore(ctx, onlineIndexKey(bucket), "-inf", maxScore)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to prune online index: %w", err)
+	}
+
+	var removed int64
+	for _, cmd := range cmds {
+		removed += cmd.Val()
+	}
+	return removed, nil
+}
+
+// OrphanedSession describes session data left in Redis for a player without an online key,
+// e.g. after a crashed proxy or a game-service instance dying mid-offline.
+type OrphanedSession struct {
+	UUID          string   `json:"uuid"`
+	SessionID     string   `json:"session_id,omitempty"` // Empty for sessions started before session IDs existed
+	Keys          []string `json:"keys"`                 // Session key types still present (playtime, deltatime, team)
+	HasPlaytime   bool     `json:"has_playtime"`         // Without a playtime key there is nothing safe to persist
+	Playtime      float64  `json:"playtime"`
+	DeltaPlaytime float64  `json:"delta_playtime"`
+}
+
+// sessionKeyScanPatterns maps the session key types that outlive presence to their SCAN patterns.
+var sessionKeyScanPatterns = map[string]string{
+	"playtime":  "playtime:*",
+	"deltatime": "deltatime:*",
+	"team":      "team:*",
+}
+
+// GetOrphanedSessions returns players that still have session data (playtime, deltatime or team keys)
+// but no online key. It SCANs every master node, so it is meant for periodic sweeps, not the tick path.
+func (rc *RedisClient) GetOrphanedSessions(ctx context.Context) ([]OrphanedSession, error) {
+	sessionKeys := make(map[string][]string) // uuid -> key types present
+	var mu sync.Mutex
+
+	err := rc.client.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		for keyType, pattern := range sessionKeyScanPatterns {
+			iter := client.Scan(ctx, 0, pattern, 0).Iterator()
+			for iter.Next(ctx) {
+				key := iter.Val()
+				start := strings.Index(key, "{")
+				end := strings.Index(key, "}")
+				if start == -1 || end == -1 || end <= start {
+					api.Logger(ctx).Warn("GetOrphanedSessions could not parse UUID from key", "node", client.Options().Addr, "key", key)
+					continue
+				}
+				mu.Lock()
+				uuid := key[start+1 : end]
+				sessionKeys[uuid] = append(sessionKeys[uuid], keyType)
+				mu.Unlock()
+			}
+			if err := iter.Err(); err != nil {
+				return fmt.Errorf("failed to scan %s on master node %s: %w", pattern, client.Options().Addr, err)
+			}
 		}
 		return nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("error during cluster-wide scan for online UUIDs: %w", err)
+		return nil, fmt.Errorf("error during cluster-wide scan for session keys: %w", err)
+	}
+	if len(sessionKeys) == 0 {
+		return nil, nil
+	}
+
+	uuids := make([]string, 0, len(sessionKeys))
+	for uuid := range sessionKeys {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	pipe := rc.client.Pipeline()
+	existsCmds := make([]*redis.IntCmd, len(uuids))
+	playtimeCmds := make([]*redis.StringCmd, len(uuids))
+	deltaCmds := make([]*redis.StringCmd, len(uuids))
+	sessionCmds := make([]*redis.StringCmd, len(uuids))
+	for i, uuid := range uuids {
+		existsCmds[i] = pipe.Exists(ctx, playerKey(OnlineKeyPrefix, uuid))
+		playtimeCmds[i] = pipe.Get(ctx, playerKey(PlaytimeKeyPrefix, uuid))
+		deltaCmds[i] = pipe.Get(ctx, playerKey(DeltaPlaytimeKeyPrefix, uuid))
+		sessionCmds[i] = pipe.Get(ctx, playerKey(SessionKeyPrefix, uuid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to check online keys for %d sessions: %w", len(uuids), err)
+	}
+
+	var orphaned []OrphanedSession
+	for i, uuid := range uuids {
+		if existsCmds[i].Val() != 0 {
+			continue
+		}
+		keys := sessionKeys[uuid]
+		sort.Strings(keys)
+		session := OrphanedSession{UUID: uuid, SessionID: sessionCmds[i].Val(), Keys: keys}
+		if playtime, err := playtimeCmds[i].Float64(); err == nil {
+			session.HasPlaytime = true
+			session.Playtime = playtime
+		}
+		if delta, err := deltaCmds[i].Float64(); err == nil {
+			session.DeltaPlaytime = delta
+		}
+		orphaned = append(orphaned, session)
 	}
+	return orphaned, nil
+}
 
-	return allOnlineUUIDs, nil
+// TryClaimSessionReap claims the right to reap a player's expired session for ttl,
+// so only one game-service instance persists and clears it.
+func (rc *RedisClient) TryClaimSessionReap(ctx context.Context, uuid string, ttl time.Duration) (bool, error) {
+	key := playerKey(ReapLockKeyPrefix, uuid)
+	ok, err := rc.client.SetNX(ctx, key, "1", ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim session reap for %s: %w", uuid, err)
+	}
+	return ok, nil
 }
 
 // GetAllPlaytimeAndDeltaPlaytime fetches all playtime and delta playtime values from Redis.
@@ -444,13 +1678,13 @@ func (rc *RedisClient) GetAllPlaytimeAndDeltaPlaytime(ctx context.Context) (map[
 		if start != -1 && end != -1 && end > start {
 			uuid = key[start+1 : end]
 		} else {
-			log.Printf("WARN: Could not parse UUID from playtime key: %s", key)
+			api.Logger(ctx).Warn("Could not parse UUID from playtime key", "key", key)
 			continue
 		}
 
 		val, err := rc.client.Get(ctx, key).Float64()
 		if err != nil {
-			log.Printf("WARN: Failed to get playtime for %s: %v", uuid, err)
+			api.Logger(ctx).Warn("Failed to get playtime", "uuid", uuid, "error", err)
 			continue
 		}
 		playtimes[uuid] = val
@@ -469,13 +1703,13 @@ func (rc *RedisClient) GetAllPlaytimeAndDeltaPlaytime(ctx context.Context) (map[
 		if start != -1 && end != -1 && end > start {
 			uuid = key[start+1 : end]
 		} else {
-			log.Printf("WARN: Could not parse UUID from delta playtime key: %s", key)
+			api.Logger(ctx).Warn("Could not parse UUID from delta playtime key", "key", key)
 			continue
 		}
 
 		val, err := rc.client.Get(ctx, key).Float64()
 		if err != redis.Nil && err != nil { // Allow redis.Nil for keys that might not exist
-			log.Printf("WARN: Failed to get delta playtime for %s: %v", uuid, err)
+			api.Logger(ctx).Warn("Failed to get delta playtime", "uuid", uuid, "error", err)
 			continue
 		}
 		deltaPlaytimes[uuid] = val
@@ -486,3 +1720,113 @@ func (rc *RedisClient) GetAllPlaytimeAndDeltaPlaytime(ctx context.Context) (map[
 
 	return playtimes, deltaPlaytimes, nil
 }
+
+// SetSeasonFreeze freezes playtime accrual across every instance for a season rollover.
+// The freeze has no TTL: it must outlive a leader crash so the next leader can finish the rollover.
+func (rc *RedisClient) SetSeasonFreeze(ctx context.Context, seasonID string) error {
+	if err := rc.client.Set(ctx, SeasonFreezeKey, seasonID, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set season freeze for %s: %w", seasonID, err)
+	}
+	return nil
+}
+
+// GetSeasonFreeze returns the ID of the season whose rollover is in progress, or "" if accrual is not frozen.
+func (rc *RedisClient) GetSeasonFreeze(ctx context.Context) (string, error) {
+	seasonID, err := rc.client.Get(ctx, SeasonFreezeKey).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get season freeze: %w", err)
+	}
+	return seasonID, nil
+}
+
+// GetSeasonState returns the ID of the season whose rollover is in progress ("" if accrual is not
+// frozen) and the season epoch live playtime is credited to, read together.
+func (rc *RedisClient) GetSeasonState(ctx context.Context) (string, int64, error) {
+	values, err := rc.client.MGet(ctx, SeasonFreezeKey, SeasonEpochKey).Result()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get season state: %w", err)
+	}
+	frozen, _ := values[0].(string)
+	return frozen, parseSeasonEpoch(values[1]), nil
+}
+
+// RaiseSeasonEpoch moves the season epoch live playtime is credited to forward to epoch.
+// It never moves the epoch back; the epoch in effect afterwards is returned.
+func (rc *RedisClient) RaiseSeasonEpoch(ctx context.Context, epoch int64) (int64, error) {
+	current, err := raiseSeasonEpochScript.Run(ctx, rc.client, []string{SeasonEpochKey}, epoch).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to raise season epoch to %d: %w", epoch, err)
+	}
+	return current, nil
+}
+
+// ClearSeasonFreeze lifts a season rollover freeze.
+func (rc *RedisClient) ClearSeasonFreeze(ctx context.Context) error {
+	if err := rc.client.Del(ctx, SeasonFreezeKey).Err(); err != nil {
+		return fmt.Errorf("failed to clear season freeze: %w", err)
+	}
+	return nil
+}
+
+// ResetLiveCounters zeroes every online player's playtime and every team total, stamping each with
+// season epoch, and empties the leaderboards. Playtime keys are zeroed rather than deleted since
+// they mark a live session, and only existing keys are touched, so a session ended meanwhile is not
+// brought back. Counters already stamped with epoch are skipped, so repeating a reset is harmless.
+func (rc *RedisClient) ResetLiveCounters(ctx context.Context, epoch int64) error {
+	var mu sync.Mutex
+	var counters []string
+	var cleared int
+	err := rc.client.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		for _, pattern := range []string{"playtime:*", "team_total_playtime:*", "leaderboard:*", "leaderboard_team:*"} {
+			iter := client.Scan(ctx, 0, pattern, 0).Iterator()
+			var keys []string
+			for iter.Next(ctx) {
+				keys = append(keys, iter.Val())
+			}
+			if err := iter.Err(); err != nil {
+				return fmt.Errorf("failed to scan %s keys on master node %s: %w", pattern, client.Options().Addr, err)
+			}
+			if len(keys) == 0 {
+				continue
+			}
+			if !strings.HasPrefix(pattern, "leaderboard") {
+				mu.Lock()
+				counters = append(counters, keys...)
+				mu.Unlock()
+				continue
+			}
+			pipe := client.Pipeline()
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return fmt.Errorf("failed to clear %s keys on master node %s: %w", pattern, client.Options().Addr, err)
+			}
+			mu.Lock()
+			cleared += len(keys)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Each counter's epoch key is named after it, e.g. playtime:{uuid}: and playtime_epoch:{uuid}:
+	cmds := rc.evalShaPipelined(ctx, resetCounterScript, len(counters), func(i int) ([]string, []interface{}) {
+		return []string{counters[i], strings.Replace(counters[i], ":", "_epoch:", 1)}, []interface{}{epoch}
+	})
+	var reset int64
+	for i, cmd := range cmds {
+		n, err := cmd.Int64()
+		if err != nil {
+			return fmt.Errorf("failed to reset %s for season epoch %d: %w", counters[i], epoch, err)
+		}
+		reset += n
+	}
+	api.Logger(ctx).Info("Reset live counters and cleared leaderboards in Redis", "counters", reset, "season_epoch", epoch, "leaderboards", cleared)
+	return nil
+}
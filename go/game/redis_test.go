@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedisClient connects a RedisClient to an in-memory Redis, which answers as a single-node cluster.
+func newTestRedisClient(t *testing.T) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	rc, err := NewRedisClient([]string{server.Addr()}, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc, server
+}
+
+// startTestSession stores what a player's tick credit reads: a total, a delta and a team.
+func startTestSession(t *testing.T, rc *RedisClient, uuid string, playtime float64, epoch int64) {
+	t.Helper()
+	ctx := context.Background()
+	if err := rc.SetPlayerPlaytime(ctx, uuid, playtime, epoch); err != nil {
+		t.Fatalf("SetPlayerPlaytime: %v", err)
+	}
+	if err := rc.SetDeltaPlaytime(ctx, uuid, 1); err != nil {
+		t.Fatalf("SetDeltaPlaytime: %v", err)
+	}
+	if err := rc.SetPlayerTeam(ctx, uuid, "red"); err != nil {
+		t.Fatalf("SetPlayerTeam: %v", err)
+	}
+}
+
+func TestIncrementPlayersPlaytime(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+	startTestSession(t, rc, "alice", 100, 0)
+	startTestSession(t, rc, "bob", 10, 0)
+
+	expired := time.Now().Add(-time.Minute)
+	if err := rc.AddPlayerBooster(ctx, "alice", models.Booster{ID: "double", Value: 2}); err != nil {
+		t.Fatalf("AddPlayerBooster: %v", err)
+	}
+	if err := rc.AddPlayerBooster(ctx, "alice", models.Booster{ID: "old", Value: 10, ExpiresAt: &expired}); err != nil {
+		t.Fatalf("AddPlayerBooster: %v", err)
+	}
+
+	credit := TickCredit{Ticks: 1, Until: time.Now().Add(time.Second).UnixMilli(), Interval: time.Second}
+	teams, credited, err := rc.IncrementPlayersPlaytime(ctx, []string{"alice", "bob", "offline"}, credit, 0, 0)
+	if err != nil {
+		t.Fatalf("IncrementPlayersPlaytime: %v", err)
+	}
+	if teams["red"] != 3 {
+		t.Errorf("team increments = %v, want red: 3", teams)
+	}
+	if len(credited) != 2 || credited[0] != (CreditedPlayer{UUID: "alice", Team: "red", Playtime: 102}) || credited[1].Playtime != 11 {
+		t.Errorf("credited = %+v", credited)
+	}
+	if server.Exists(playerKey(PlaytimeKeyPrefix, "offline")) {
+		t.Error("crediting a player without a session created their playtime")
+	}
+	if fields, _ := server.HKeys(playerKey(BoostersKeyPrefix, "alice")); len(fields) != 1 || fields[0] != "double" {
+		t.Errorf("boosters after the tick = %v, want the expired one pruned", fields)
+	}
+}
+
+func TestOnlineIndex(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+	if err := rc.SetOnlineStatus(ctx, "alice"); err != nil {
+		t.Fatalf("SetOnlineStatus: %v", err)
+	}
+	bucket := OnlineIndexBucket("alice")
+	stale := float64(time.Now().Add(-time.Hour).UnixMilli())
+	server.ZAdd(onlineIndexKey(bucket), stale, "ghost")
+
+	uuids, err := rc.GetOnlineUUIDsInBuckets(ctx, []int{bucket})
+	if err != nil {
+		t.Fatalf("GetOnlineUUIDsInBuckets: %v", err)
+	}
+	if len(uuids) != 1 || uuids[0] != "alice" {
+		t.Errorf("online in bucket %d = %v, want only alice", bucket, uuids)
+	}
+	other := (bucket + 1) % OnlineIndexBuckets
+	if uuids, _ := rc.GetOnlineUUIDsInBuckets(ctx, []int{other}); len(uuids) != 0 {
+		t.Errorf("online in bucket %d = %v, want none", other, uuids)
+	}
+
+	if removed, err := rc.PruneOnlineIndex(ctx, time.Minute); err != nil || removed != 1 {
+		t.Errorf("PruneOnlineIndex = %d, %v; want the stale entry removed", removed, err)
+	}
+}
+
+func TestPublishRingMembers(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+
+	epoch, members, err := rc.PublishRingMembers(ctx, "game", []string{"b", "a"})
+	if err != nil || epoch != 1 || len(members) != 2 || members[0] != "a" {
+		t.Fatalf("first publish = %d, %v, %v; want epoch 1 with sorted members", epoch, members, err)
+	}
+	if epoch, _, _ := rc.PublishRingMembers(ctx, "game", []string{"a", "b"}); epoch != 1 {
+		t.Errorf("republishing the same members moved the epoch to %d", epoch)
+	}
+	if epoch, _, _ := rc.PublishRingMembers(ctx, "game", []string{"a"}); epoch != 2 {
+		t.Errorf("a member change left the epoch at %d, want 2", epoch)
+	}
+	if epoch, members, _ := rc.GetRing(ctx, "game"); epoch != 2 || len(members) != 1 {
+		t.Errorf("GetRing = %d, %v", epoch, members)
+	}
+}
+
+func TestPartitionLeases(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+
+	held, err := rc.AcquirePartitionLeases(ctx, []int{1, 2}, "a", time.Second)
+	if err != nil || !held[1] || !held[2] {
+		t.Fatalf("AcquirePartitionLeases = %v, %v; want both buckets", held, err)
+	}
+	if held, _ := rc.AcquirePartitionLeases(ctx, []int{2, 3}, "b", time.Second); held[2] || !held[3] {
+		t.Errorf("second holder got %v, want only the free bucket", held)
+	}
+
+	// Renewing extends the lease rather than letting it run out
+	server.FastForward(800 * time.Millisecond)
+	rc.AcquirePartitionLeases(ctx, []int{1}, "a", time.Second)
+	server.FastForward(800 * time.Millisecond)
+	if held, _ := rc.AcquirePartitionLeases(ctx, []int{1, 2}, "b", time.Second); held[1] || !held[2] {
+		t.Errorf("after renewing only bucket 1, b got %v; want only the expired bucket 2", held)
+	}
+
+	if err := rc.ReleasePartitionLeases(ctx, []int{1, 2}, "a"); err != nil {
+		t.Fatalf("ReleasePartitionLeases: %v", err)
+	}
+	if holder, _ := server.Get(fmt.Sprintf(PartitionLeaseKeyPrefix, 1)); holder != "" {
+		t.Errorf("bucket 1 still held by %q after release", holder)
+	}
+	if holder, _ := server.Get(fmt.Sprintf(PartitionLeaseKeyPrefix, 2)); holder != "b" {
+		t.Errorf("releasing a lease held by another instance dropped it (holder %q)", holder)
+	}
+}
+
+func TestIncrementPlayersPlaytimeCreditsEachTickOnce(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+	startTestSession(t, rc, "alice", 0, 0)
+	start := time.Now()
+
+	credit := TickCredit{Ticks: 1, Until: start.Add(time.Second).UnixMilli(), Interval: time.Second}
+	for i := 0; i < 2; i++ {
+		if _, _, err := rc.IncrementPlayersPlaytime(ctx, []string{"alice"}, credit, 0, 0); err != nil {
+			t.Fatalf("IncrementPlayersPlaytime: %v", err)
+		}
+	}
+	if playtime, _ := rc.GetPlayerPlaytime(ctx, "alice"); playtime != 1 {
+		t.Errorf("playtime after crediting the same tick twice = %v, want 1", playtime)
+	}
+
+	// Catching up credits only the ticks since the session was last credited
+	catchUp := TickCredit{Ticks: 5, Until: start.Add(3 * time.Second).UnixMilli(), Interval: time.Second}
+	teams, _, err := rc.IncrementPlayersPlaytime(ctx, []string{"alice"}, catchUp, 0, 0)
+	if err != nil {
+		t.Fatalf("IncrementPlayersPlaytime: %v", err)
+	}
+	if teams["red"] != 2 {
+		t.Errorf("catch-up credited %v ticks, want 2", teams["red"])
+	}
+}
+
+func TestSetTickCursors(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+	rc.AcquirePartitionLeases(ctx, []int{1}, "a", time.Minute)
+	rc.AcquirePartitionLeases(ctx, []int{2}, "b", time.Minute)
+
+	lost, err := rc.SetTickCursors(ctx, map[int]int64{1: 1000, 2: 1000, 3: 1000}, "a", time.Minute)
+	if err != nil {
+		t.Fatalf("SetTickCursors: %v", err)
+	}
+	if len(lost) != 2 || lost[0] != 2 || lost[1] != 3 {
+		t.Errorf("lost buckets = %v, want [2 3]", lost)
+	}
+	cursors, err := rc.GetTickCursors(ctx, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("GetTickCursors: %v", err)
+	}
+	if len(cursors) != 1 || cursors[1] != 1000 {
+		t.Errorf("cursors = %v, want only bucket 1 advanced", cursors)
+	}
+}
+
+func TestSetTeamTotalFencing(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+
+	if err := rc.SetTeamTotal(ctx, "red", 50, 2, 0); err != nil {
+		t.Fatalf("SetTeamTotal: %v", err)
+	}
+	if err := rc.SetTeamTotal(ctx, "red", 40, 1, 0); !errors.Is(err, ErrStaleFencingToken) {
+		t.Errorf("write from an older term: err = %v, want ErrStaleFencingToken", err)
+	}
+	if err := rc.SetTeamTotal(ctx, "red", 60, 2, 0); err != nil {
+		t.Errorf("repeated write in the same term: %v", err)
+	}
+	if total, _ := rc.GetTeamTotalPlaytime(ctx, "red"); total != 60 {
+		t.Errorf("team total = %v, want 60", total)
+	}
+}
+
+func TestLeaderboards(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+	for uuid, team := range map[string]string{"alice": "red", "bob": "red", "carol": "blue"} {
+		if err := rc.SetPlayerTeam(ctx, uuid, team); err != nil {
+			t.Fatalf("SetPlayerTeam: %v", err)
+		}
+	}
+	err := rc.UpdateLeaderboards(ctx, []CreditedPlayer{
+		{UUID: "alice", Team: "red", Playtime: 30},
+		{UUID: "bob", Team: "red", Playtime: 10},
+		{UUID: "carol", Team: "blue", Playtime: 20},
+	})
+	if err != nil {
+		t.Fatalf("UpdateLeaderboards: %v", err)
+	}
+
+	top, err := rc.GetLeaderboardTop(ctx, "", 2)
+	if err != nil {
+		t.Fatalf("GetLeaderboardTop: %v", err)
+	}
+	if len(top) != 2 || top[0] != (models.LeaderboardEntry{Rank: 1, UUID: "alice", Playtime: 30}) || top[1].UUID != "carol" {
+		t.Errorf("global top 2 = %+v", top)
+	}
+	standing, err := rc.GetLeaderboardStanding(ctx, "red", "bob")
+	if err != nil {
+		t.Fatalf("GetLeaderboardStanding: %v", err)
+	}
+	if standing.Rank != 2 || standing.Total != 2 || standing.Percentile != 50 {
+		t.Errorf("bob's red standing = %+v", standing)
+	}
+	if _, err := rc.GetLeaderboardStanding(ctx, "blue", "bob"); !errors.Is(err, ErrRedisKeyNotFound) {
+		t.Errorf("standing on another team's leaderboard: err = %v, want ErrRedisKeyNotFound", err)
+	}
+	if around, _ := rc.GetLeaderboardNeighbours(ctx, "", "alice", 1); len(around) != 2 || around[1].Rank != 2 {
+		t.Errorf("neighbours of the leader = %+v", around)
+	}
+
+	// Switching teams takes a player off their old team's leaderboard
+	if err := rc.SetPlayerTeam(ctx, "bob", "blue"); err != nil {
+		t.Fatalf("SetPlayerTeam: %v", err)
+	}
+	if red, _ := rc.GetLeaderboardTop(ctx, "red", 10); len(red) != 1 {
+		t.Errorf("red leaderboard after bob moved = %+v", red)
+	}
+}
+
+func TestLeaderboardSeedOnlyRaisesScores(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+	rc.UpdateLeaderboards(ctx, []CreditedPlayer{{UUID: "alice", Team: "red", Playtime: 30}})
+
+	profiles := []models.Player{{UUID: "alice", Team: "red", TotalPlaytimeTicks: 25}, {UUID: "bob", Team: "red", TotalPlaytimeTicks: 5}}
+	if err := rc.SeedLeaderboards(ctx, "run1", profiles, time.Minute); err != nil {
+		t.Fatalf("SeedLeaderboards: %v", err)
+	}
+	if top, _ := rc.GetLeaderboardTop(ctx, "red", 10); len(top) != 1 {
+		t.Errorf("staged profiles are live before the commit: %+v", top)
+	}
+	if err := rc.CommitLeaderboardSeed(ctx, "run1", []string{"red"}); err != nil {
+		t.Fatalf("CommitLeaderboardSeed: %v", err)
+	}
+	top, _ := rc.GetLeaderboardTop(ctx, "red", 10)
+	if len(top) != 2 || top[0].Playtime != 30 || top[1].UUID != "bob" {
+		t.Errorf("red leaderboard after the seed = %+v; want alice's newer total kept", top)
+	}
+}
+
+func TestResetLiveCountersFencesEarlierEpochs(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+	startTestSession(t, rc, "alice", 100, 0)
+	rc.IncrementTeamTotals(ctx, map[string]float64{"red": 100}, 0)
+	rc.UpdateLeaderboards(ctx, []CreditedPlayer{{UUID: "alice", Team: "red", Playtime: 100}})
+
+	if err := rc.ResetLiveCounters(ctx, 1); err != nil {
+		t.Fatalf("ResetLiveCounters: %v", err)
+	}
+	if epoch, err := rc.RaiseSeasonEpoch(ctx, 1); err != nil || epoch != 1 {
+		t.Fatalf("RaiseSeasonEpoch = %d, %v", epoch, err)
+	}
+	if playtime, _ := rc.GetPlayerPlaytime(ctx, "alice"); playtime != 0 {
+		t.Errorf("playtime after the reset = %v, want 0", playtime)
+	}
+	if total, _ := rc.GetTeamTotalPlaytime(ctx, "red"); total != 0 {
+		t.Errorf("team total after the reset = %v, want 0", total)
+	}
+	if top, _ := rc.GetLeaderboardTop(ctx, "", 10); len(top) != 0 {
+		t.Errorf("global leaderboard after the reset = %+v, want it empty", top)
+	}
+
+	// Writes read in the old epoch are dropped
+	stale := TickCredit{Ticks: 1, Until: time.Now().Add(time.Second).UnixMilli(), Interval: time.Second, Epoch: 0}
+	if _, credited, _ := rc.IncrementPlayersPlaytime(ctx, []string{"alice"}, stale, 0, 0); len(credited) != 0 {
+		t.Errorf("tick from the previous epoch credited %+v", credited)
+	}
+	if err := rc.SetPlayerPlaytime(ctx, "alice", 100, 0); !errors.Is(err, ErrStaleSeasonEpoch) {
+		t.Errorf("SetPlayerPlaytime from the previous epoch: err = %v, want ErrStaleSeasonEpoch", err)
+	}
+	if err := rc.SetTeamTotal(ctx, "red", 100, 1, 0); !errors.Is(err, ErrStaleSeasonEpoch) {
+		t.Errorf("SetTeamTotal from the previous epoch: err = %v, want ErrStaleSeasonEpoch", err)
+	}
+	rc.IncrementTeamTotals(ctx, map[string]float64{"red": 5}, 0)
+	if total, _ := rc.GetTeamTotalPlaytime(ctx, "red"); total != 0 {
+		t.Errorf("team total after an increment from the previous epoch = %v, want 0", total)
+	}
+
+	// Repeating the reset keeps what has been credited in the new epoch
+	current := TickCredit{Ticks: 1, Until: time.Now().Add(time.Second).UnixMilli(), Interval: time.Second, Epoch: 1}
+	rc.IncrementPlayersPlaytime(ctx, []string{"alice"}, current, 0, 0)
+	if err := rc.ResetLiveCounters(ctx, 1); err != nil {
+		t.Fatalf("ResetLiveCounters: %v", err)
+	}
+	if playtime, _ := rc.GetPlayerPlaytime(ctx, "alice"); playtime != 1 {
+		t.Errorf("playtime after a repeated reset = %v, want 1", playtime)
+	}
+}
+
+func TestSeasonState(t *testing.T) {
+	rc, _ := newTestRedisClient(t)
+	ctx := context.Background()
+
+	if err := rc.SetSeasonFreeze(ctx, "s1"); err != nil {
+		t.Fatalf("SetSeasonFreeze: %v", err)
+	}
+	rc.RaiseSeasonEpoch(ctx, 3)
+	if epoch, _ := rc.RaiseSeasonEpoch(ctx, 2); epoch != 3 {
+		t.Errorf("RaiseSeasonEpoch moved the epoch back to %d", epoch)
+	}
+	if frozen, epoch, err := rc.GetSeasonState(ctx); err != nil || frozen != "s1" || epoch != 3 {
+		t.Errorf("GetSeasonState = %q, %d, %v; want s1, 3", frozen, epoch, err)
+	}
+	rc.ClearSeasonFreeze(ctx)
+	if frozen, _, _ := rc.GetSeasonState(ctx); frozen != "" {
+		t.Errorf("freeze still set to %q after clearing it", frozen)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+
+	first, started, err := rc.ClaimSession(ctx, "alice", "")
+	if err != nil || !started || first == "" {
+		t.Fatalf("ClaimSession = %q, %v, %v; want a new session", first, started, err)
+	}
+	if id, started, err := rc.ClaimSession(ctx, "alice", first); err != nil || started || id != first {
+		t.Errorf("repeated online = %q, %v, %v; want the same session", id, started, err)
+	}
+	rc.SetOnlineStatus(ctx, "alice")
+	startTestSession(t, rc, "alice", 10, 0)
+
+	// A second login supersedes the first session
+	second, _, _ := rc.ClaimSession(ctx, "alice", "")
+	if _, _, err := rc.ClaimSession(ctx, "alice", first); !errors.Is(err, ErrSessionSuperseded) {
+		t.Errorf("claim with the old session: err = %v, want ErrSessionSuperseded", err)
+	}
+	if err := rc.CheckSession(ctx, "alice", first); !errors.Is(err, ErrSessionSuperseded) {
+		t.Errorf("CheckSession(old) = %v, want ErrSessionSuperseded", err)
+	}
+	results, err := rc.RefreshOnlineStatus(ctx, map[string]string{"alice": first})
+	if err != nil || !errors.Is(results["alice"], ErrSessionSuperseded) {
+		t.Errorf("refresh with the old session = %v, %v; want ErrSessionSuperseded", results, err)
+	}
+	activity, err := rc.RecordActivity(ctx, []PlayerActivity{{UUID: "alice", SessionID: first, LastInputAt: time.Now()}})
+	if err != nil || !errors.Is(activity["alice"], ErrSessionSuperseded) {
+		t.Errorf("activity for the old session = %v, %v; want ErrSessionSuperseded", activity, err)
+	}
+
+	// A late offline for the old session leaves the new one alone
+	if err := rc.EndSession(ctx, "alice", first); !errors.Is(err, ErrSessionSuperseded) {
+		t.Errorf("EndSession(old) = %v, want ErrSessionSuperseded", err)
+	}
+	if !server.Exists(playerKey(PlaytimeKeyPrefix, "alice")) {
+		t.Fatal("a late offline for the old session deleted the new session's playtime")
+	}
+
+	if results, _ := rc.RefreshOnlineStatus(ctx, map[string]string{"alice": second}); results["alice"] != nil {
+		t.Errorf("refresh with the current session: %v", results["alice"])
+	}
+	if err := rc.EndSession(ctx, "alice", second); err != nil {
+		t.Fatalf("EndSession: %v", err)
+	}
+	for _, key := range []string{playerKey(SessionKeyPrefix, "alice"), playerKey(OnlineKeyPrefix, "alice"), playerKey(PlaytimeKeyPrefix, "alice")} {
+		if server.Exists(key) {
+			t.Errorf("%s left behind after the session ended", key)
+		}
+	}
+	if err := rc.EndSession(ctx, "alice", second); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("repeated offline: err = %v, want ErrSessionEnded", err)
+	}
+	if results, _ := rc.RefreshOnlineStatus(ctx, map[string]string{"alice": second}); !errors.Is(results["alice"], ErrSessionEnded) {
+		t.Errorf("refresh after the session ended: %v, want ErrSessionEnded", results["alice"])
+	}
+}
+
+func TestPlayerLocations(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+	rc.SetOnlineStatus(ctx, "alice")
+
+	if err := rc.SetPlayerLocations(ctx, []models.PlayerPresence{{UUID: "alice", ProxyID: "p1", Server: "lobby"}}); err != nil {
+		t.Fatalf("SetPlayerLocations: %v", err)
+	}
+	// A heartbeat that only knows the server keeps the proxy
+	if err := rc.SetPlayerLocations(ctx, []models.PlayerPresence{{UUID: "alice", Server: "game1"}}); err != nil {
+		t.Fatalf("SetPlayerLocations: %v", err)
+	}
+	if presence, _ := rc.GetPlayerPresence(ctx, "alice"); presence == nil || *presence != (models.PlayerPresence{UUID: "alice", ProxyID: "p1", Server: "game1"}) {
+		t.Errorf("presence = %+v", presence)
+	}
+	if players, _ := rc.GetPlayersOnServer(ctx, "lobby"); len(players) != 0 {
+		t.Errorf("players on the server alice left = %+v", players)
+	}
+	if players, _ := rc.GetPlayersOnServer(ctx, "game1"); len(players) != 1 {
+		t.Errorf("players on game1 = %+v, want alice", players)
+	}
+
+	invalidated, err := rc.InvalidatePresence(ctx, "p1")
+	if err != nil || invalidated != 1 {
+		t.Fatalf("InvalidatePresence = %d, %v; want 1", invalidated, err)
+	}
+	if server.Exists(playerKey(OnlineKeyPrefix, "alice")) {
+		t.Error("online key survived the proxy leaving")
+	}
+	if players, _ := rc.GetPlayersOnProxy(ctx, "p1"); len(players) != 0 {
+		t.Errorf("players on the departed proxy = %+v", players)
+	}
+}
+
+func TestAFKAccrual(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+	sessionID, _, _ := rc.ClaimSession(ctx, "alice", "")
+	startTestSession(t, rc, "alice", 0, 0)
+	rc.SetAFKTicks(ctx, "alice", 3)
+	start := time.Now()
+
+	lastInput := start.Add(-10 * time.Minute)
+	if results, err := rc.RecordActivity(ctx, []PlayerActivity{{UUID: "alice", SessionID: sessionID, LastInputAt: lastInput}}); err != nil || results["alice"] != nil {
+		t.Fatalf("RecordActivity = %v, %v", results, err)
+	}
+	credit := TickCredit{Ticks: 1, Until: start.Add(time.Second).UnixMilli(), Interval: time.Second}
+	teams, credited, err := rc.IncrementPlayersPlaytime(ctx, []string{"alice"}, credit, time.Minute, 0.5)
+	if err != nil {
+		t.Fatalf("IncrementPlayersPlaytime: %v", err)
+	}
+	if teams["red"] != 0.5 || len(credited) != 1 || !credited[0].AFK {
+		t.Errorf("AFK tick credited %v as %+v, want half a tick while AFK", teams, credited)
+	}
+	if ticks, _ := rc.GetAFKTicks(ctx, "alice"); ticks != 4 {
+		t.Errorf("AFK ticks = %v, want 4", ticks)
+	}
+	if since, _ := rc.GetAFKSince(ctx, "alice"); since == nil || since.UnixMilli() != lastInput.Add(time.Minute).UnixMilli() {
+		t.Errorf("AFK since %v, want one timeout after the last input", since)
+	}
+
+	// Reports from the future are clamped, and older reports never move the last input back
+	rc.RecordActivity(ctx, []PlayerActivity{{UUID: "alice", SessionID: sessionID, LastInputAt: start.Add(time.Hour)}})
+	rc.RecordActivity(ctx, []PlayerActivity{{UUID: "alice", SessionID: sessionID, LastInputAt: lastInput}})
+	stored, _ := server.Get(playerKey(ActivityKeyPrefix, "alice"))
+	if millis, _ := strconv.ParseInt(stored, 10, 64); millis < start.UnixMilli() || millis > time.Now().UnixMilli() {
+		t.Errorf("stored last input = %s, want clamped to the current time", stored)
+	}
+
+	credit = TickCredit{Ticks: 1, Until: start.Add(2 * time.Second).UnixMilli(), Interval: time.Second}
+	if teams, credited, _ := rc.IncrementPlayersPlaytime(ctx, []string{"alice"}, credit, time.Minute, 0.5); teams["red"] != 1 || credited[0].AFK {
+		t.Errorf("active tick credited %v as %+v, want a full tick", teams, credited)
+	}
+	if since, _ := rc.GetAFKSince(ctx, "alice"); since != nil {
+		t.Errorf("still AFK since %v after input resumed", since)
+	}
+}
+
+func TestSetMuteStatusKeepsTheLongerPunishment(t *testing.T) {
+	rc, server := newTestRedisClient(t)
+	ctx := context.Background()
+	hour := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name       string
+		expiresAt  int64
+		reason     string
+		wantExpiry int64
+		wantReason string
+	}{
+		{"first mute", hour, "spam", hour, "spam"},
+		{"shorter mute", hour - 600, "caps", hour, "spam"},
+		{"longer mute", hour + 600, "flood", hour + 600, "flood"},
+		{"permanent mute", 0, "abuse", 0, "abuse"},
+		{"temporary after permanent", hour + 3600, "spam", 0, "abuse"},
+	}
+	for _, tt := range tests {
+		expiry, reason, err := rc.SetMuteStatus(ctx, "alice", true, tt.expiresAt, tt.reason)
+		if err != nil {
+			t.Fatalf("%s: SetMuteStatus: %v", tt.name, err)
+		}
+		if expiry != tt.wantExpiry || reason != tt.wantReason {
+			t.Errorf("%s: in effect %d %q, want %d %q", tt.name, expiry, reason, tt.wantExpiry, tt.wantReason)
+		}
+	}
+	if ttl := server.TTL(playerKey(MutedKeyPrefix, "alice")); ttl != 0 {
+		t.Errorf("permanent mute expires in %v", ttl)
+	}
+
+	if _, _, err := rc.SetMuteStatus(ctx, "alice", false, 0, ""); err != nil {
+		t.Fatalf("unmute: %v", err)
+	}
+	if _, _, err := rc.GetMuteDetails(ctx, "alice"); !errors.Is(err, ErrRedisKeyNotFound) {
+		t.Errorf("GetMuteDetails after unmute: err = %v, want ErrRedisKeyNotFound", err)
+	}
+
+	if _, _, err := rc.SetBanStatus(ctx, "bob", true, hour, "cheating"); err != nil {
+		t.Fatalf("SetBanStatus: %v", err)
+	}
+	if ttl := server.TTL(playerKey(BanReasonKeyPrefix, "bob")); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("ban reason TTL = %v, want it to expire with the ban", ttl)
+	}
+}
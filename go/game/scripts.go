@@ -0,0 +1,407 @@
+package main
+
+import "github.com/redis/go-redis/v9"
+
+// incrementPlaytimeScript credits one or more ticks of playtime to a single player atomically.
+// All keys share the player's {uuid} hash tag, so the script runs on one node.
+//
+// A player whose last reported input is at least the AFK timeout ago is AFK: their increment is
+// scaled by the AFK multiplier (0 skips them), the ticks are added to their AFK tick total, and
+// the time they went AFK is kept under the afk key until input resumes. Players without any
+// reported input are never AFK.
+//
+// The playtime belongs to the season epoch stored under playtime_epoch. A tick from an earlier epoch
+// (one that read the epoch before a season reset) is dropped, and playtime from an earlier epoch
+// than the tick's is zeroed before it is credited, so a total never crosses a season reset.
+//
+// The time the player has been credited up to is kept under credited, starting from when their
+// session began. A player is credited at most one tick per tick interval since then, so catching
+// up never credits time before they came online, and crediting the same ticks again is a no-op.
+// Sessions without a credited time get every tick.
+//
+// KEYS[1] playtime:{uuid}:   KEYS[2] deltatime:{uuid}:
+// KEYS[3] team:{uuid}:       KEYS[4] boosters:{uuid}:
+// KEYS[5] activity:{uuid}:   KEYS[6] afk:{uuid}:   KEYS[7] afk_ticks:{uuid}:
+// KEYS[8] playtime_epoch:{uuid}:   KEYS[9] credited:{uuid}:
+// ARGV[1] current unix time in seconds, used to skip and prune expired boosters
+// ARGV[2] number of ticks to credit (more than 1 when catching up on missed ticks)
+// ARGV[3] current unix time in milliseconds   ARGV[4] AFK timeout in milliseconds (0 disables AFK detection)
+// ARGV[5] multiplier applied to AFK players' increment   ARGV[6] season epoch of the tick
+// ARGV[7] unix time in milliseconds the ticks credit up to   ARGV[8] tick interval in milliseconds
+//
+// Returns {teamID, increment, newTotal, afk} with the numbers as strings (Lua numbers are
+// truncated to integers in replies) and afk "1" or "0", or nil if the session is gone or
+// incomplete, so a concurrent offline can never be resurrected by a late tick, or if the tick
+// belongs to an earlier season epoch.
+var incrementPlaytimeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+local delta = tonumber(redis.call('GET', KEYS[2]))
+local team = redis.call('GET', KEYS[3])
+if not delta or not team then
+	return false
+end
+local epoch = tonumber(ARGV[6])
+local stored = tonumber(redis.call('GET', KEYS[8])) or 0
+if stored > epoch then
+	return false
+elseif stored < epoch then
+	redis.call('SET', KEYS[1], 0)
+	redis.call('SET', KEYS[8], ARGV[6])
+end
+
+local ticks = tonumber(ARGV[2]) or 1
+local credited = tonumber(redis.call('GET', KEYS[9]))
+if credited then
+	ticks = math.min(ticks, math.floor((tonumber(ARGV[7]) - credited) / tonumber(ARGV[8])))
+	if ticks <= 0 then
+		return {team, '0', redis.call('GET', KEYS[1]), '0'}
+	end
+end
+redis.call('SET', KEYS[9], ARGV[7])
+
+local now = tonumber(ARGV[1])
+local multiplier = 1
+local boosters = redis.call('HGETALL', KEYS[4])
+for i = 1, #boosters, 2 do
+	local ok, booster = pcall(cjson.decode, boosters[i + 1])
+	if not ok or type(booster) ~= 'table' or type(booster.value) ~= 'number'
+		or (type(booster.expires_at) == 'number' and booster.expires_at > 0 and booster.expires_at <= now) then
+		redis.call('HDEL', KEYS[4], boosters[i])
+	else
+		multiplier = multiplier * booster.value
+	end
+end
+
+local afk = '0'
+local lastInput = tonumber(redis.call('GET', KEYS[5]))
+local timeout = tonumber(ARGV[4])
+if lastInput and timeout > 0 and tonumber(ARGV[3]) - lastInput >= timeout then
+	afk = '1'
+	redis.call('SET', KEYS[6], lastInput + timeout, 'NX')
+	if redis.call('EXISTS', KEYS[7]) == 1 then
+		redis.call('INCRBYFLOAT', KEYS[7], ticks)
+	end
+	multiplier = multiplier * tonumber(ARGV[5])
+else
+	redis.call('DEL', KEYS[6])
+end
+
+local increment = delta * multiplier * ticks
+local total = redis.call('INCRBYFLOAT', KEYS[1], increment)
+return {team, tostring(increment), total, afk}
+`)
+
+// publishRingScript records the game-service ring membership and bumps the ring epoch
+// whenever it changes, so every instance builds its ring from the same stored member list.
+//
+// KEYS[1] ring:{serviceType}:   ARGV[1] sorted, comma-separated member IDs
+//
+// Returns {epoch, members} as stored after the call.
+var publishRingScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'members') ~= ARGV[1] then
+	redis.call('HSET', KEYS[1], 'members', ARGV[1])
+	redis.call('HINCRBY', KEYS[1], 'epoch', 1)
+end
+return {redis.call('HGET', KEYS[1], 'epoch'), redis.call('HGET', KEYS[1], 'members')}
+`)
+
+// acquireLeaseScript takes a partition lease if it is free, or renews it if the caller already holds it.
+//
+// KEYS[1] partition_lease:{bucket}:   ARGV[1] holder ID   ARGV[2] lease TTL in milliseconds
+//
+// Returns 1 if the caller holds the lease afterwards, 0 if another instance does.
+var acquireLeaseScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if not holder then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+elseif holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// setTickCursorScript advances a bucket's tick cursor, but only for the instance holding the
+// bucket's partition lease, so an instance that has lost the bucket cannot move the cursor.
+// Both keys share the {bucket} hash tag.
+//
+// KEYS[1] partition_lease:{bucket}:   KEYS[2] tick_cursor:{bucket}:
+// ARGV[1] holder ID   ARGV[2] cursor as unix time in milliseconds   ARGV[3] TTL in milliseconds
+//
+// Returns 1 if set, 0 if the caller does not hold the lease.
+var setTickCursorScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// releaseLeaseScript deletes a partition lease only if the caller still holds it.
+//
+// KEYS[1] partition_lease:{bucket}:   ARGV[1] holder ID
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// setTeamTotalScript overwrites a team's total on behalf of a leader, unless a leader with a
+// newer fencing token has already written it, or the total has moved to a later season epoch than
+// the one the leader computed it in. The fence and epoch keys share the {teamID} hash tag.
+//
+// KEYS[1] team_total_playtime:{teamID}:   KEYS[2] team_total_fence:{teamID}:
+// KEYS[3] team_total_playtime_epoch:{teamID}:
+// ARGV[1] total playtime   ARGV[2] writer's fencing token   ARGV[3] writer's season epoch
+//
+// Returns 1 if written, 0 if the token is stale, -1 if the season epoch is stale.
+var setTeamTotalScript = redis.NewScript(`
+local fence = tonumber(redis.call('GET', KEYS[2]))
+if fence and fence > tonumber(ARGV[2]) then
+	return 0
+end
+local epoch = tonumber(redis.call('GET', KEYS[3]))
+if epoch and epoch > tonumber(ARGV[3]) then
+	return -1
+end
+redis.call('SET', KEYS[2], ARGV[2])
+redis.call('SET', KEYS[3], ARGV[3])
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+// incrementTeamTotalScript adds a tick's increment to a team's total, unless the total has moved
+// to a later season epoch than the tick's. A total from an earlier epoch is zeroed first.
+//
+// KEYS[1] team_total_playtime:{teamID}:   KEYS[2] team_total_playtime_epoch:{teamID}:
+// ARGV[1] increment                        ARGV[2] season epoch of the tick
+//
+// Returns 1 if added, 0 if the season epoch is stale.
+var incrementTeamTotalScript = redis.NewScript(`
+local epoch = tonumber(ARGV[2])
+local stored = tonumber(redis.call('GET', KEYS[2])) or 0
+if stored > epoch then
+	return 0
+elseif stored < epoch then
+	redis.call('SET', KEYS[1], 0)
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+redis.call('INCRBYFLOAT', KEYS[1], ARGV[1])
+return 1
+`)
+
+// setPlaytimeScript loads a player's total playtime for a new session, unless their playtime has
+// already moved to a later season epoch than the caller's. The session is credited from ARGV[3] on.
+//
+// KEYS[1] playtime:{uuid}:   KEYS[2] playtime_epoch:{uuid}:   KEYS[3] credited:{uuid}:
+// ARGV[1] total playtime     ARGV[2] season epoch of the caller
+// ARGV[3] current unix time in milliseconds
+//
+// Returns 1 if set, 0 if the season epoch is stale.
+var setPlaytimeScript = redis.NewScript(`
+local stored = tonumber(redis.call('GET', KEYS[2]))
+if stored and stored > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('SET', KEYS[2], ARGV[2])
+redis.call('SET', KEYS[3], ARGV[3])
+return 1
+`)
+
+// resetCounterScript zeroes a live counter (a player's playtime or a team's total) for a season reset
+// and stamps it with the new season epoch. Counters that don't exist are left alone, so a session
+// ended meanwhile is not brought back, and counters already stamped with the epoch are skipped, so
+// a repeated reset never zeroes what has been credited since.
+//
+// KEYS[1] the counter   KEYS[2] its epoch key   ARGV[1] new season epoch
+//
+// Returns 1 if reset, 0 if skipped.
+var resetCounterScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local stored = tonumber(redis.call('GET', KEYS[2]))
+if stored and stored >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], 0, 'KEEPTTL')
+redis.call('SET', KEYS[2], ARGV[1])
+return 1
+`)
+
+// raiseSeasonEpochScript moves the season epoch forward, never back.
+//
+// KEYS[1] season_epoch:{global}:   ARGV[1] season epoch
+//
+// Returns the season epoch in effect afterwards.
+var raiseSeasonEpochScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]))
+if not current or current < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1])
+	return tonumber(ARGV[1])
+end
+return current
+`)
+
+// claimSessionScript starts a player's session or recognises a repeated online for the current one.
+// Either way the session key's TTL is (re)set.
+//
+// KEYS[1] session:{uuid}:   ARGV[1] session ID echoed by the caller ("" for a new login)   ARGV[2] new session ID
+// ARGV[3] session TTL in milliseconds
+//
+// Returns {state, sessionID}: 1 and ARGV[2] if a new session was started, 0 and the current ID if
+// ARGV[1] is the current session, or -1 and the current ID if ARGV[1] has been superseded.
+var claimSessionScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if ARGV[1] ~= '' and current then
+	if current == ARGV[1] then
+		redis.call('PEXPIRE', KEYS[1], ARGV[3])
+		return {0, current}
+	end
+	return {-1, current}
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return {1, ARGV[2]}
+`)
+
+// refreshSessionScript extends a player's presence (and its location) and their session key,
+// but only for the session the caller holds.
+//
+// KEYS[1] session:{uuid}:   KEYS[2] online:{uuid}:   KEYS[3] location:{uuid}:
+// ARGV[1] session ID        ARGV[2] online TTL in milliseconds   ARGV[3] session TTL in milliseconds
+//
+// Returns 1 if refreshed, 0 if the session or its presence has already ended, -1 if superseded.
+var refreshSessionScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	return -1
+end
+if redis.call('PEXPIRE', KEYS[2], ARGV[2]) == 0 then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[3], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// endSessionScript deletes a player's session keys, but only if the given session is still current,
+// so a late offline can never clear a newer session. An empty ARGV[1] matches a session without an ID
+// (one started before session IDs existed). The location is read in the same step as it is deleted,
+// so the caller can drop the player from the proxy and server sets they were really on.
+//
+// KEYS[1] session:{uuid}:   KEYS[2] location:{uuid}:   KEYS[3..n] the player's other session keys
+// ARGV[1] session ID
+//
+// Returns {deleted, proxy, server}: the number of keys deleted, 0 if the session has already ended,
+// or -1 if superseded, and the location the player was on (empty strings where none was stored).
+var endSessionScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current and ARGV[1] ~= '' then
+	return {0, '', ''}
+end
+if (current or '') ~= ARGV[1] then
+	return {-1, '', ''}
+end
+local location = redis.call('HMGET', KEYS[2], 'proxy', 'server')
+return {redis.call('DEL', unpack(KEYS)), location[1] or '', location[2] or ''}
+`)
+
+// setLocationScript records the proxy and backend server a player is on. Empty values leave the
+// stored ones unchanged, so a heartbeat that only knows the server keeps the proxy.
+//
+// KEYS[1] location:{uuid}:   ARGV[1] proxy ID   ARGV[2] server name   ARGV[3] TTL in milliseconds
+//
+// Returns {previous proxy, previous server}, empty strings where none was stored.
+var setLocationScript = redis.NewScript(`
+local previous = redis.call('HMGET', KEYS[1], 'proxy', 'server')
+if ARGV[1] ~= '' then
+	redis.call('HSET', KEYS[1], 'proxy', ARGV[1])
+end
+if ARGV[2] ~= '' then
+	redis.call('HSET', KEYS[1], 'server', ARGV[2])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {previous[1] or '', previous[2] or ''}
+`)
+
+// invalidatePresenceScript ends a player's presence if they are still on the given proxy, leaving
+// their session data for the SessionReaper to persist and clear like any other expired session.
+//
+// KEYS[1] location:{uuid}:   KEYS[2] online:{uuid}:   ARGV[1] proxy ID
+//
+// Returns {1, server} if the presence was removed, with the backend server the player was on
+// (an empty string if none), or {0, ""} if the player has moved on.
+var invalidatePresenceScript = redis.NewScript(`
+local location = redis.call('HMGET', KEYS[1], 'proxy', 'server')
+if location[1] ~= ARGV[1] then
+	return {0, ''}
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return {1, location[2] or ''}
+`)
+
+// recordActivityScript stores the time of a player's latest input, but only for the session the caller holds.
+// Reports that arrive out of order never move the stored time backwards, and times ahead of the
+// caller's clock are clamped to it, so a skewed proxy can't keep a player active into the future.
+//
+// KEYS[1] session:{uuid}:   KEYS[2] activity:{uuid}:
+// ARGV[1] session ID        ARGV[2] last input as unix time in milliseconds
+// ARGV[3] caller's current unix time in milliseconds
+//
+// Returns 1 if recorded, 0 if the session has already ended, -1 if superseded.
+var recordActivityScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	return -1
+end
+local input = math.min(tonumber(ARGV[2]), tonumber(ARGV[3]))
+local stored = tonumber(redis.call('GET', KEYS[2]))
+if not stored or input > stored then
+	redis.call('SET', KEYS[2], string.format('%d', input))
+end
+return 1
+`)
+
+// setPunishmentScript stores a ban or mute, unless the one already stored outlasts it: the later
+// expiry is kept and a permanent punishment always wins, so a shorter punishment never shortens
+// an active one. Equal punishments take the newer reason.
+//
+// KEYS[1] expiry key (banned or muted)   KEYS[2] reason key
+// ARGV[1] expiry as unix seconds, <= 0 for permanent   ARGV[2] reason
+// ARGV[3] TTL in milliseconds, 0 for none
+//
+// Returns {expiry, reason} of the punishment in effect afterwards, with the expiry as a string.
+var setPunishmentScript = redis.NewScript(`
+local requested = tonumber(ARGV[1])
+local current = tonumber(redis.call('GET', KEYS[1]))
+if current then
+	local keep
+	if current <= 0 then
+		keep = requested > 0
+	else
+		keep = requested > 0 and requested < current
+	end
+	if keep then
+		return {string.format('%d', current), redis.call('GET', KEYS[2]) or ''}
+	end
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+return {ARGV[1], ARGV[2]}
+`)
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	cluster "github.com/Ftotnem/Backend/go/shared/cluster"
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/Ftotnem/Backend/go/shared/service"
+)
+
+// SeasonRollover starts scheduled seasons and ends active ones. Only the elected leader runs it.
+//
+// Ending a season archives every total in the player service and resets the live counters in
+// MongoDB and Redis. While that runs, a freeze flag in Redis stops every instance from crediting
+// playtime, persisting it, or starting and ending sessions. The flag is only cleared once both
+// stores are reset; if the leader dies midway, the next leader finds the flag and finishes the rollover.
+//
+// Writes already in flight when the freeze is set are fenced rather than waited for. Every reset
+// moves live totals to a new season epoch: the player service stamps each profile it resets with
+// the epoch it records on the season, Redis counters are stamped the same way, and the epoch live
+// playtime is credited to is only raised once both are reset. Every playtime write carries the
+// epoch it was read in, and both stores reject writes from an epoch earlier than the total's.
+//
+// To end a season early, move its end to now in the player service (PUT /seasons/{id}).
+type SeasonRollover struct {
+	redisClient         *RedisClient
+	playerServiceClient *service.PlayerServiceClient
+	persister           *PlaytimePersister
+	elector             *cluster.LeaderElector
+	checkInterval       time.Duration
+	ctx                 context.Context
+	cancel              context.CancelFunc
+}
+
+// NewSeasonRollover creates a new SeasonRollover instance.
+func NewSeasonRollover(redisClient *RedisClient, playerServiceClient *service.PlayerServiceClient, persister *PlaytimePersister, elector *cluster.LeaderElector, cfg *Config) *SeasonRollover {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SeasonRollover{
+		redisClient:         redisClient,
+		playerServiceClient: playerServiceClient,
+		persister:           persister,
+		elector:             elector,
+		checkInterval:       cfg.SeasonCheckInterval,
+		ctx:                 ctx,
+		cancel:              cancel,
+	}
+}
+
+// Start initiates the season check loop. This should be run in a goroutine.
+func (sr *SeasonRollover) Start() {
+	api.Logger(sr.ctx).Info("Season Rollover starting", "check_interval", sr.checkInterval)
+	ticker := time.NewTicker(sr.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sr.ctx.Done():
+			api.Logger(sr.ctx).Info("Season Rollover shutting down")
+			return
+		case <-ticker.C:
+			if !sr.elector.IsLeader() {
+				continue
+			}
+			ctx := api.WithNewRequestID(sr.ctx)
+			if err := sr.check(ctx); err != nil {
+				api.Logger(ctx).Error("Season Rollover check failed", "error", err)
+			}
+		}
+	}
+}
+
+// Stop gracefully stops the season check loop.
+func (sr *SeasonRollover) Stop() {
+	sr.cancel()
+}
+
+// check resumes an interrupted rollover, ends the current season if it is over,
+// or activates the next season once it is due.
+func (sr *SeasonRollover) check(ctx context.Context) error {
+	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	frozen, epoch, err := sr.redisClient.GetSeasonState(checkCtx)
+	if err != nil {
+		return err
+	}
+	if frozen != "" {
+		api.Logger(ctx).Info("Season Rollover resuming rollover", "season_id", frozen)
+		return sr.rollover(ctx, frozen)
+	}
+	if err := sr.reconcileEpoch(checkCtx, epoch); err != nil {
+		return err
+	}
+
+	current, err := sr.playerServiceClient.GetCurrentSeason(checkCtx)
+	if errors.Is(err, api.ErrNotFound) {
+		return sr.activateDue(checkCtx)
+	}
+	if err != nil {
+		return err
+	}
+	// A season past active was being archived without a freeze in place; finish it the same way
+	if current.Status != models.SeasonStatusActive || !time.Now().Before(current.EndsAt) {
+		return sr.rollover(ctx, current.ID)
+	}
+	return nil
+}
+
+// rollover ends a season: freeze accrual, write every online player's playtime, archive the season
+// and reset MongoDB, reset Redis, and lift the freeze. Every step is safe to repeat, so a failed
+// rollover is retried from the start on the next check while the freeze stays in place.
+func (sr *SeasonRollover) rollover(ctx context.Context, seasonID string) error {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	if err := sr.redisClient.SetSeasonFreeze(ctx, seasonID); err != nil {
+		return err
+	}
+	api.Logger(ctx).Info("Season Rollover froze playtime accrual", "season_id", seasonID)
+
+	season, err := sr.playerServiceClient.GetSeason(ctx, seasonID)
+	if err != nil {
+		return err
+	}
+	// Once MongoDB may have been reset, Redis still holds the old totals and must not be written back
+	if season.Status == models.SeasonStatusActive || season.Status == models.SeasonStatusArchiving {
+		if err := sr.persister.FlushAll(ctx); err != nil {
+			return fmt.Errorf("failed to write final playtime for season %s: %w", seasonID, err)
+		}
+	}
+
+	season, err = sr.playerServiceClient.ArchiveSeason(ctx, seasonID)
+	if err != nil {
+		return err
+	}
+	if err := sr.redisClient.ResetLiveCounters(ctx, season.ResetEpoch); err != nil {
+		return err
+	}
+	if _, err := sr.redisClient.RaiseSeasonEpoch(ctx, season.ResetEpoch); err != nil {
+		return err
+	}
+	if err := sr.redisClient.ClearSeasonFreeze(ctx); err != nil {
+		return err
+	}
+
+	api.Logger(ctx).Info("Season Rollover archived season and reset live counters", "season_id", season.ID, "name", season.Name, "season_epoch", season.ResetEpoch, "duration", time.Since(started))
+	for _, team := range season.Teams {
+		api.Logger(ctx).Info("Season Rollover final standing", "season_id", season.ID, "rank", team.Rank, "team", team.Team, "total", team.TotalPlaytimeTicks)
+	}
+	return sr.activateDue(ctx)
+}
+
+// reconcileEpoch raises the season epoch in Redis to the latest one a season has reset to, in case
+// Redis lost it. Live playtime credited to an earlier epoch would otherwise be rejected by MongoDB.
+func (sr *SeasonRollover) reconcileEpoch(ctx context.Context, epoch int64) error {
+	seasons, err := sr.playerServiceClient.ListSeasons(ctx)
+	if err != nil {
+		return err
+	}
+	var latest int64
+	for _, season := range seasons {
+		if season.Status == models.SeasonStatusArchived && season.ResetEpoch > latest {
+			latest = season.ResetEpoch
+		}
+	}
+	if latest <= epoch {
+		return nil
+	}
+	if _, err := sr.redisClient.RaiseSeasonEpoch(ctx, latest); err != nil {
+		return err
+	}
+	api.Logger(ctx).Warn("Season Rollover raised season epoch to match the player service", "from", epoch, "to", latest)
+	return nil
+}
+
+// activateDue activates the earliest scheduled season whose start has passed and whose end has not.
+func (sr *SeasonRollover) activateDue(ctx context.Context) error {
+	seasons, err := sr.playerServiceClient.ListSeasons(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, season := range seasons { // Ordered by start
+		if season.Status != models.SeasonStatusScheduled || now.Before(season.StartsAt) {
+			continue
+		}
+		if !now.Before(season.EndsAt) {
+			api.Logger(ctx).Warn("Season Rollover skipping season that ended before it could be activated", "season_id", season.ID, "ends_at", season.EndsAt)
+			continue
+		}
+		activated, err := sr.playerServiceClient.ActivateSeason(ctx, season.ID)
+		if err != nil {
+			return err
+		}
+		api.Logger(ctx).Info("Season Rollover activated season", "season_id", activated.ID, "name", activated.Name, "ends_at", activated.EndsAt)
+		return nil
+	}
+	return nil
+}
+
+// rejectDuringRollover writes a 503 and returns true while a season rollover has frozen accrual,
+// since starting or ending a session then would race the archive and reset. Otherwise it returns
+// the season epoch live playtime is credited to.
+func (gs *GameService) rejectDuringRollover(ctx context.Context, w http.ResponseWriter) (int64, bool) {
+	frozen, epoch, err := gs.redisClient.GetSeasonState(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to check season freeze", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to check season status")
+		return 0, true
+	}
+	if frozen != "" {
+		w.Header().Set("Retry-After", "5")
+		api.WriteError(w, http.StatusServiceUnavailable, fmt.Sprintf("Season %s is ending; try again shortly", frozen))
+		return 0, true
+	}
+	return epoch, false
+}
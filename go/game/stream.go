@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	cluster "github.com/Ftotnem/Backend/go/shared/cluster"
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/gorilla/websocket"
+)
+
+// streamKeepAliveInterval is how often idle stream connections get a keep-alive (SSE comment or WebSocket ping).
+const streamKeepAliveInterval = 15 * time.Second
+
+// TeamStream pushes live team totals to SSE and WebSocket clients.
+// The elected leader builds a frame every interval and publishes it on Redis pub/sub;
+// every instance relays published frames to its own connected clients.
+type TeamStream struct {
+	redisClient *RedisClient
+	elector     *cluster.LeaderElector
+	interval    time.Duration
+	upgrader    websocket.Upgrader
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	mu          sync.RWMutex
+	subscribers map[chan []byte]struct{}
+	latest      []byte // Last frame received, sent to new clients right away
+}
+
+// NewTeamStream creates a new TeamStream instance. WebSocket clients are accepted from the origins
+// cors allows, like every other cross-origin caller, or from anything that is not a browser.
+func NewTeamStream(redisClient *RedisClient, elector *cluster.LeaderElector, interval time.Duration, cors *api.CORSPolicy) *TeamStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TeamStream{
+		redisClient: redisClient,
+		elector:     elector,
+		interval:    interval,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     cors.CheckOrigin,
+		},
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[chan []byte]struct{}),
+	}
+}
+
+// Start runs the publish and relay loops. This should be run in a goroutine.
+func (ts *TeamStream) Start() {
+	api.Logger(ts.ctx).Info("Team Stream starting", "publish_interval", ts.interval)
+	go ts.publishLoop()
+	ts.relayLoop()
+}
+
+// Stop gracefully stops the stream and disconnects its clients.
+func (ts *TeamStream) Stop() {
+	ts.cancel()
+}
+
+// publishLoop builds and publishes a frame every interval while this instance is the leader.
+func (ts *TeamStream) publishLoop() {
+	ticker := time.NewTicker(ts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ts.ctx.Done():
+			return
+		case <-ticker.C:
+			if !ts.elector.IsLeader() {
+				continue
+			}
+			ctx := api.WithNewRequestID(ts.ctx)
+			if err := ts.publish(ctx); err != nil {
+				api.Logger(ctx).Error("Team Stream failed to publish team totals", "error", err)
+			}
+		}
+	}
+}
+
+// publish builds the current team totals frame and publishes it to every instance.
+func (ts *TeamStream) publish(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, ts.interval)
+	defer cancel()
+
+	totals, err := ts.redisClient.GetAllTeamTotalPlaytimes(ctx)
+	if err != nil {
+		return err
+	}
+	online, err := ts.redisClient.GetOnlineCountsByTeam(ctx)
+	if err != nil {
+		return err
+	}
+
+	update := models.TeamTotalsUpdate{
+		Teams:     make(map[string]models.TeamLiveStats, len(totals)),
+		Timestamp: time.Now().UTC(),
+	}
+	for team, total := range totals {
+		update.Teams[team] = models.TeamLiveStats{TotalPlaytime: total, Online: online[team]}
+	}
+	for team, count := range online {
+		if _, ok := update.Teams[team]; !ok {
+			update.Teams[team] = models.TeamLiveStats{Online: count}
+		}
+	}
+
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal team totals: %w", err)
+	}
+	return ts.redisClient.PublishTeamTotals(ctx, payload)
+}
+
+// relayLoop receives published frames and hands them to this instance's clients.
+// The go-redis PubSub reconnects and resubscribes on its own after connection errors.
+func (ts *TeamStream) relayLoop() {
+	pubsub := ts.redisClient.SubscribeTeamTotals(ts.ctx)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ts.ctx.Done():
+			api.Logger(ts.ctx).Info("Team Stream shutting down")
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			recordTeamMetrics(ts.ctx, []byte(msg.Payload))
+			ts.broadcast([]byte(msg.Payload))
+		}
+	}
+}
+
+// recordTeamMetrics updates the team gauges from a team totals frame.
+func recordTeamMetrics(ctx context.Context, frame []byte) {
+	var update models.TeamTotalsUpdate
+	if err := json.Unmarshal(frame, &update); err != nil {
+		api.Logger(ctx).Warn("Team Stream failed to decode team totals frame for metrics", "error", err)
+		return
+	}
+	for team, stats := range update.Teams {
+		teamTotalPlaytime.WithLabelValues(team).Set(stats.TotalPlaytime)
+		teamOnlinePlayers.WithLabelValues(team).Set(float64(stats.Online))
+	}
+}
+
+// broadcast stores the frame and sends it to every client. Clients that are still busy with
+// the previous frame skip this one; the next frame supersedes it anyway.
+func (ts *TeamStream) broadcast(frame []byte) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.latest = frame
+	for ch := range ts.subscribers {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+}
+
+// subscribe registers a client and returns its frame channel, primed with the latest frame.
+func (ts *TeamStream) subscribe() chan []byte {
+	ch := make(chan []byte, 1)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.latest != nil {
+		ch <- ts.latest
+	}
+	ts.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a client registered with subscribe.
+func (ts *TeamStream) unsubscribe(ch chan []byte) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.subscribers, ch)
+}
+
+// HandleSSE streams team totals as Server-Sent Events.
+// GET /game/stream/teams
+func (ts *TeamStream) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// The server's WriteTimeout would otherwise cut the stream off
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		api.Logger(r.Context()).Warn("Team Stream could not clear write deadline for SSE client", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	frames := ts.subscribe()
+	defer ts.unsubscribe(frames)
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ts.ctx.Done():
+			return
+		case frame := <-frames:
+			if _, err := fmt.Fprintf(w, "event: team_totals\ndata: %s\n\n", frame); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// HandleWebSocket streams team totals as WebSocket text messages.
+// GET /game/ws/teams
+func (ts *TeamStream) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := ts.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		api.Logger(r.Context()).Warn("Team Stream WebSocket upgrade failed", "error", err)
+		return // Upgrade has already written an error response
+	}
+	defer conn.Close()
+
+	frames := ts.subscribe()
+	defer ts.unsubscribe(frames)
+
+	// The stream is one-way; reading is only needed to process pongs and notice the client leaving
+	closed := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(2 * streamKeepAliveInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamKeepAliveInterval))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ts.ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+			return
+		case frame := <-frames:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		}
+	}
+}
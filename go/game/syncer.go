@@ -2,9 +2,10 @@ package main
 
 import (
 	"context" // Import fmt for string formatting
-	"log"
+	"errors"
 	"time"
 
+	"github.com/Ftotnem/Backend/go/shared/api"
 	cluster "github.com/Ftotnem/Backend/go/shared/cluster"
 	"github.com/Ftotnem/Backend/go/shared/service" // Import your shared player service client
 )
@@ -14,15 +15,15 @@ type PlaytimeSyncer struct {
 	redisClient         *RedisClient // Assuming RedisClient has Set method (e.g., from go-redis)
 	playerServiceClient *service.PlayerServiceClient
 	registrar           *cluster.ServiceRegistrar
-	syncInterval        time.Duration // How often to run the sync job (e.g., 1 minute)
+	elector             *cluster.LeaderElector // Only the elected leader runs the sync
+	syncInterval        time.Duration          // How often to run the sync job (e.g., 1 minute)
 	ctx                 context.Context
 	cancel              context.CancelFunc
 }
 
 // NewPlaytimeSyncer (example stub - update your actual definition)
 // Needs to accept the ServiceRegistrar
-func NewPlaytimeSyncer(redisClient *RedisClient, playerServiceClient *service.PlayerServiceClient, persistenceInterval time.Duration, registrar *cluster.ServiceRegistrar) *PlaytimeSyncer {
-	log.Println("PlaytimeSyncer: Initialized with ServiceRegistrar.")
+func NewPlaytimeSyncer(redisClient *RedisClient, playerServiceClient *service.PlayerServiceClient, persistenceInterval time.Duration, registrar *cluster.ServiceRegistrar, elector *cluster.LeaderElector) *PlaytimeSyncer {
 	// Create a cancellable context for the PlaytimeSyncer
 	ctx, cancel := context.WithCancel(context.Background())
 	return &PlaytimeSyncer{
@@ -30,24 +31,40 @@ func NewPlaytimeSyncer(redisClient *RedisClient, playerServiceClient *service.Pl
 		playerServiceClient: playerServiceClient,
 		syncInterval:        persistenceInterval,
 		registrar:           registrar, // Store the registrar
-		ctx:                 ctx,       // Initialize context
-		cancel:              cancel,    // Initialize cancel function
+		elector:             elector,
+		ctx:                 ctx,    // Initialize context
+		cancel:              cancel, // Initialize cancel function
 	}
 }
 
 // Start initiates the synchronization loop. This should be run in a goroutine.
 func (ps *PlaytimeSyncer) Start() {
-	log.Printf("Playtime Syncer starting with sync interval: %v", ps.syncInterval)
+	api.Logger(ps.ctx).Info("Playtime Syncer starting", "sync_interval", ps.syncInterval)
 	ticker := time.NewTicker(ps.syncInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ps.ctx.Done():
-			log.Println("Playtime Syncer shutting down.")
+			api.Logger(ps.ctx).Info("Playtime Syncer shutting down")
 			return
 		case <-ticker.C:
-			ps.triggerPlayerServiceSync()
+			// Only one instance aggregates per interval; followers take over if the leader's lease lapses
+			token, isLeader := ps.elector.Leadership()
+			if !isLeader {
+				continue
+			}
+			// Totals read mid-rollover could land in Redis after its reset
+			ctx := api.WithNewRequestID(ps.ctx)
+			frozen, epoch, err := ps.redisClient.GetSeasonState(ctx)
+			if err != nil {
+				api.Logger(ctx).Error("Playtime Syncer failed to check season freeze", "error", err)
+				continue
+			}
+			if frozen != "" {
+				continue
+			}
+			ps.triggerPlayerServiceSync(ctx, token, epoch)
 		}
 	}
 }
@@ -58,36 +75,56 @@ func (ps *PlaytimeSyncer) Stop() {
 }
 
 // triggerPlayerServiceSync calls the player service to perform the actual playtime sync
-// and then updates Redis with the returned team totals.
-func (ps *PlaytimeSyncer) triggerPlayerServiceSync() {
-	log.Println("Triggering player service to synchronize player playtimes and get team totals...")
+// and then updates Redis with the returned team totals, fenced by the leader's token and by
+// the season epoch read before the sync, so totals from before a season reset are dropped.
+func (ps *PlaytimeSyncer) triggerPlayerServiceSync(ctx context.Context, fencingToken, epoch int64) {
+	api.Logger(ctx).Info("Playtime Syncer triggering player service sync")
+	started := time.Now()
+	result := "success"
+	defer func() {
+		playtimeSyncDuration.Observe(time.Since(started).Seconds())
+		playtimeSyncRunsTotal.WithLabelValues(result).Inc()
+		if result == "success" {
+			playtimeSyncLastSuccess.SetToCurrentTime()
+		}
+	}()
 
-	syncCtx, syncCancel := context.WithTimeout(context.Background(), 30*time.Second) // Give player service ample time
+	syncCtx, syncCancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second) // Give player service ample time
 	defer syncCancel()
 
 	// This call now expects a response containing the team totals
 	resp, err := ps.playerServiceClient.SyncPlayerPlaytime(syncCtx)
 	if err != nil {
-		log.Printf("ERROR: Failed to trigger player service playtime sync or get team totals: %v", err)
+		api.Logger(syncCtx).Error("Failed to trigger player service playtime sync or get team totals", "error", err)
+		result = "error"
 		return // Exit if we couldn't get the data
 	}
 
-	log.Println("Successfully triggered player service playtime sync. Updating Redis with team totals...")
-
 	if resp.TeamTotals == nil {
-		log.Println("No team totals received from player service sync.")
+		api.Logger(syncCtx).Info("No team totals received from player service sync")
 		return
 	}
 
 	// Update Redis with the received team totals
 	for teamID, totalPlaytime := range resp.TeamTotals {
 
-		err := ps.redisClient.SetTeamTotal(syncCtx, teamID, totalPlaytime) // Set with no expiration
+		err := ps.redisClient.SetTeamTotal(syncCtx, teamID, totalPlaytime, fencingToken, epoch) // Set with no expiration
+		if errors.Is(err, ErrStaleFencingToken) {
+			api.Logger(syncCtx).Warn("Lost syncer leadership, newer totals already written", "team", teamID)
+			result = "stale"
+			return
+		}
+		if errors.Is(err, ErrStaleSeasonEpoch) {
+			api.Logger(syncCtx).Warn("Season reset during sync, dropping team totals", "team", teamID, "season_epoch", epoch)
+			result = "stale"
+			return
+		}
 		if err != nil {
-			log.Printf("ERROR: Failed to update Redis for team %s total playtime: %v", teamID, err)
+			api.Logger(syncCtx).Error("Failed to update team total playtime in Redis", "team", teamID, "error", err)
+			result = "error"
 		} else {
-			log.Printf("INFO: Successfully updated Redis total playtime for team '%s' to %.2f ticks.", teamID, totalPlaytime)
+			api.Logger(syncCtx).Info("Updated team total playtime in Redis", "team", teamID, "total", totalPlaytime)
 		}
 	}
-	log.Println("Finished updating Redis with aggregated team totals.")
+	api.Logger(syncCtx).Info("Finished updating Redis with aggregated team totals", "teams", len(resp.TeamTotals))
 }
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
-	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"sync" // For mutex to protect the consistent hash ring
 
+	"github.com/Ftotnem/Backend/go/shared/api"
 	cluster "github.com/Ftotnem/Backend/go/shared/cluster" // Your cluster package
 	"github.com/stathat/consistent"                        // Import the consistent hashing library
 )
@@ -18,16 +21,25 @@ type GameUpdater struct {
 	registrar   *cluster.ServiceRegistrar
 	ctx         context.Context
 	cancel      context.CancelFunc
+	done        chan struct{} // Closed once Start has returned and leases are released
 
 	// New fields for consistent hashing
 	consistentHash *consistent.Consistent
-	chMux          sync.RWMutex // Protects access to consistentHash
+	ringEpoch      int64        // Epoch of the Redis-stored ring consistentHash was built from
+	chMux          sync.RWMutex // Protects access to consistentHash and ringEpoch
 	myServiceID    string       // The ID of *this* game service instance
+
+	leases *PartitionLeaseManager // Leases gating which buckets this instance may tick
+	stats  TickStats              // Updated atomically by performGameTick
+
+	lastLeaderboardUpdate time.Time          // Only touched by the tick loop
+	pendingTeamIncrements map[string]float64 // Team increments a failed flush left behind; only touched by the tick loop
+	pendingTeamEpoch      int64              // Season epoch pendingTeamIncrements were credited to
+	ringChanged           chan struct{}      // Signals the lease loop to resync right after a ring change
 }
 
 // NewGameUpdater creates a new GameUpdater instance.
 func NewGameUpdater(redisClient *RedisClient, cfg *Config, registrar *cluster.ServiceRegistrar) *GameUpdater {
-	log.Println("GameUpdater: Initialized with ServiceRegistrar.")
 	ctx, cancel := context.WithCancel(context.Background())
 
 	gu := &GameUpdater{
@@ -36,29 +48,37 @@ func NewGameUpdater(redisClient *RedisClient, cfg *Config, registrar *cluster.Se
 		registrar:      registrar,
 		ctx:            ctx,
 		cancel:         cancel,
+		done:           make(chan struct{}),
 		consistentHash: consistent.New(),         // Initialize the consistent hash ring
 		myServiceID:    registrar.GetServiceID(), // Get this instance's ID
+		leases:         NewPartitionLeaseManager(redisClient, registrar.GetServiceID(), cfg.PartitionLeaseTTL),
+		ringChanged:    make(chan struct{}, 1),
 	}
-	log.Printf("DEBUG: Configured TickInterval before updater start: %v", gu.config.TickInterval)
 	return gu
 }
 
 // Start initiates the game update loop. This should be run in a goroutine.
 func (gu *GameUpdater) Start() {
-	log.Printf("Game Updater starting with tick interval: %v", gu.config.TickInterval)
+	defer close(gu.done)
+	api.Logger(gu.ctx).Info("Game Updater starting", "tick_interval", gu.config.TickInterval)
 	ticker := time.NewTicker(gu.config.TickInterval)
 	defer ticker.Stop()
 
-	// Start a goroutine to periodically update the consistent hash ring
-	gu.chMux.Lock()
-	gu.consistentHash.Add(gu.myServiceID) // Add self to the ring initially
-	gu.chMux.Unlock()
+	// Publish our view of the ring and take our leases before the first tick
+	startCtx := api.WithNewRequestID(gu.ctx)
+	gu.updateConsistentHashRing(startCtx)
+	gu.leases.Sync(startCtx, gu.ownedBuckets(startCtx))
 	go gu.updateConsistentHashLoop() // New: Goroutine to keep the ring updated
+	go gu.partitionLeaseLoop()
 
 	for {
 		select {
 		case <-gu.ctx.Done():
-			log.Println("Game Updater shutting down.")
+			api.Logger(gu.ctx).Info("Game Updater shutting down")
+			// Hand our partitions over now instead of making the next owners wait out the TTL
+			releaseCtx, cancel := context.WithTimeout(api.WithNewRequestID(context.Background()), 5*time.Second)
+			gu.leases.ReleaseAll(releaseCtx)
+			cancel()
 			return
 		case <-ticker.C:
 			gu.performGameTick()
@@ -66,40 +86,44 @@ func (gu *GameUpdater) Start() {
 	}
 }
 
-// Stop gracefully stops the game update loop.
+// Stop gracefully stops the game update loop and waits for its leases to be released.
 func (gu *GameUpdater) Stop() {
 	gu.cancel()
+	<-gu.done
 }
 
 // updateConsistentHashLoop periodically fetches active services and updates the consistent hash ring.
 func (gu *GameUpdater) updateConsistentHashLoop() {
 	// A reasonable interval for checking for new/removed services.
 	// This should be longer than your HeartbeatInterval to avoid thrashing.
-	checkInterval := gu.registrar.config.HeartbeatInterval * 2 // Example: twice the heartbeat
-	if checkInterval == 0 {                                    // Fallback if heartbeat is 0 (shouldn't happen with defaults)
+	checkInterval := gu.registrar.GetConfig().HeartbeatInterval * 2 // Example: twice the heartbeat
+	if checkInterval == 0 {                                         // Fallback if heartbeat is 0 (shouldn't happen with defaults)
 		checkInterval = 10 * time.Second
 	}
 	ticker := time.NewTicker(checkInterval)
 	defer ticker.Stop()
 
-	log.Printf("GameUpdater: Consistent Hash Updater starting with check interval: %v", checkInterval)
+	api.Logger(gu.ctx).Info("GameUpdater consistent hash updater starting", "check_interval", checkInterval)
 
 	for {
 		select {
 		case <-gu.ctx.Done():
-			log.Println("GameUpdater: Consistent Hash Updater shutting down.")
+			api.Logger(gu.ctx).Info("GameUpdater consistent hash updater shutting down")
 			return
 		case <-ticker.C:
-			gu.updateConsistentHashRing()
+			gu.updateConsistentHashRing(api.WithNewRequestID(gu.ctx))
 		}
 	}
 }
 
-// updateConsistentHashRing fetches current active game services and rebuilds the consistent hash ring.
-func (gu *GameUpdater) updateConsistentHashRing() {
-	activeServices, err := gu.registrar.GetActiveServices(gu.ctx, gu.registrar.GetConfig().ServiceType) // Use ServiceType from config
+// updateConsistentHashRing fetches current active game services and publishes them as the ring
+// stored in Redis. Every instance builds its ring from the stored members rather than its own view,
+// and the stored epoch tells instances when to rebuild.
+func (gu *GameUpdater) updateConsistentHashRing(ctx context.Context) {
+	serviceType := gu.registrar.GetConfig().ServiceType
+	activeServices, err := gu.registrar.GetActiveServices(ctx, serviceType) // Use ServiceType from config
 	if err != nil {
-		log.Printf("ERROR: GameUpdater: Failed to get active game services for consistent hash: %v", err)
+		api.Logger(ctx).Error("GameUpdater failed to get active game services for consistent hash", "error", err)
 		return
 	}
 
@@ -108,87 +132,269 @@ func (gu *GameUpdater) updateConsistentHashRing() {
 		members = append(members, id)
 	}
 
+	epoch, storedMembers, err := gu.redisClient.PublishRingMembers(ctx, serviceType, members)
+	if err != nil {
+		api.Logger(ctx).Error("GameUpdater failed to publish ring members", "error", err)
+		return
+	}
+	gu.adoptRing(ctx, epoch, storedMembers)
+}
+
+// adoptRing rebuilds the consistent hash ring if the given epoch differs from the one it was built from.
+func (gu *GameUpdater) adoptRing(ctx context.Context, epoch int64, members []string) {
 	gu.chMux.Lock()
-	defer gu.chMux.Unlock()
+	if epoch == gu.ringEpoch {
+		gu.chMux.Unlock()
+		return
+	}
+	gu.consistentHash = consistent.New() // Create a new consistent hash instance
+	for _, member := range members {
+		gu.consistentHash.Add(member)
+	}
+	gu.ringEpoch = epoch
+	gu.chMux.Unlock()
 
-	// Get current members on the ring to compare
-	currentMembers := gu.consistentHash.Members()
+	api.Logger(ctx).Info("GameUpdater consistent hash ring updated", "ring_epoch", epoch, "members", members)
 
-	// Check if members list has changed
-	if !areStringSlicesEqual(members, currentMembers) {
-		gu.consistentHash = consistent.New() // Create a new consistent hash instance
-		for _, member := range members {
-			gu.consistentHash.Add(member)
-		}
-		log.Printf("GameUpdater: Consistent Hash ring updated. Active members: %v", gu.consistentHash.Members())
+	// Release buckets we lost as soon as possible so their new owners can take over
+	select {
+	case gu.ringChanged <- struct{}{}:
+	default:
 	}
 }
 
-// Helper to compare string slices (order-independent)
-func areStringSlicesEqual(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
+// partitionLeaseLoop keeps this instance's partition leases in line with the ring.
+// It renews well within the lease TTL, and resyncs immediately when the ring changes.
+func (gu *GameUpdater) partitionLeaseLoop() {
+	ticker := time.NewTicker(gu.config.PartitionLeaseTTL / 3)
+	defer ticker.Stop()
+
+	api.Logger(gu.ctx).Info("GameUpdater partition lease loop starting", "lease_ttl", gu.config.PartitionLeaseTTL)
+
+	for {
+		ctx := api.WithNewRequestID(gu.ctx)
+		select {
+		case <-gu.ctx.Done():
+			api.Logger(gu.ctx).Info("GameUpdater partition lease loop shutting down")
+			return
+		case <-ticker.C:
+			// Pick up ring changes published by other instances
+			epoch, members, err := gu.redisClient.GetRing(ctx, gu.registrar.GetConfig().ServiceType)
+			if err != nil {
+				api.Logger(ctx).Error("GameUpdater failed to read the ring", "error", err)
+			} else if epoch != 0 {
+				gu.adoptRing(ctx, epoch, members)
+			}
+		case <-gu.ringChanged:
+		}
+		gu.leases.Sync(ctx, gu.ownedBuckets(ctx))
 	}
-	mapA := make(map[string]bool)
-	for _, x := range a {
-		mapA[x] = true
+}
+
+// ownedBuckets returns the online index buckets this instance is responsible for.
+func (gu *GameUpdater) ownedBuckets(ctx context.Context) []int {
+	gu.chMux.RLock() // Read lock to access consistentHash
+	defer gu.chMux.RUnlock()
+
+	// Check if there are any members in the consistent hash ring
+	if len(gu.consistentHash.Members()) == 0 {
+		api.Logger(ctx).Warn("GameUpdater consistent hash ring is empty; cannot determine bucket responsibility")
+		return nil
 	}
-	for _, x := range b {
-		if _, ok := mapA[x]; !ok {
-			return false
+
+	var buckets []int
+	for bucket := 0; bucket < OnlineIndexBuckets; bucket++ {
+		// Determine which service is responsible for this bucket
+		responsibleService, err := gu.consistentHash.Get(strconv.Itoa(bucket))
+		if err != nil {
+			api.Logger(ctx).Warn("GameUpdater failed to get responsible service for bucket", "bucket", bucket, "error", err)
+			continue
 		}
+		if responsibleService == gu.myServiceID {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// TickStats counts how ticks have been credited since this instance started.
+type TickStats struct {
+	Ticks         uint64 `json:"ticks"`           // Tick loop iterations
+	Overruns      uint64 `json:"overruns"`        // Iterations that took longer than the tick interval
+	CaughtUpTicks uint64 `json:"caught_up_ticks"` // Missed ticks credited late, summed over buckets
+	DroppedTicks  uint64 `json:"dropped_ticks"`   // Missed ticks beyond MaxCatchUpTicks, summed over buckets
+	AFKCredits    uint64 `json:"afk_credits"`     // Player credits made while the player was AFK, under AFKPolicy
+}
+
+// TickStats returns a snapshot of this instance's tick counters.
+func (gu *GameUpdater) TickStats() TickStats {
+	return TickStats{
+		Ticks:         atomic.LoadUint64(&gu.stats.Ticks),
+		Overruns:      atomic.LoadUint64(&gu.stats.Overruns),
+		CaughtUpTicks: atomic.LoadUint64(&gu.stats.CaughtUpTicks),
+		DroppedTicks:  atomic.LoadUint64(&gu.stats.DroppedTicks),
+		AFKCredits:    atomic.LoadUint64(&gu.stats.AFKCredits),
 	}
-	return true
+}
+
+// HandleTickStats reports this instance's tick counters.
+func (gu *GameUpdater) HandleTickStats(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, gu.TickStats())
 }
 
 // performGameTick executes the logic for a single game tick.
+// Only buckets this instance holds a lease for are read, so each player is credited by exactly one instance.
+// Nothing is credited while a season rollover has frozen accrual, and AFK players are credited
+// according to the configured AFKPolicy.
+//
+// Each bucket's tick cursor records the wall-clock time it has been credited up to, so elapsed time
+// is credited in whole ticks regardless of ticker jitter, and a bucket's new owner picks up where
+// the previous one stopped. A cursor only advances once every player in its bucket has been
+// credited, and only while this instance still holds the bucket's lease.
 func (gu *GameUpdater) performGameTick() {
-	onlineUUIDs, err := gu.redisClient.GetAllOnlineUUIDs(gu.ctx)
-	if err != nil {
-		log.Printf("Error getting online UUIDs for game tick: %v", err)
+	started := time.Now()
+	ctx := api.WithNewRequestID(gu.ctx)
+	atomic.AddUint64(&gu.stats.Ticks, 1)
+	gameTicksTotal.Inc()
+	var credited []CreditedPlayer
+	defer func() {
+		elapsed := time.Since(started)
+		gameTickDuration.Observe(elapsed.Seconds())
+		gamePlayersTicked.Set(float64(len(credited)))
+		if elapsed > gu.config.TickInterval {
+			atomic.AddUint64(&gu.stats.Overruns, 1)
+			gameTickOverrunsTotal.Inc()
+		}
+	}()
+
+	// Holding the buckets keeps a ring change from handing them over until this tick is done
+	buckets, done := gu.leases.HoldBuckets()
+	defer done()
+	gameBucketsHeld.Set(float64(len(buckets)))
+	if len(buckets) == 0 {
 		return
 	}
 
-	if len(onlineUUIDs) == 0 {
+	cursors, err := gu.redisClient.GetTickCursors(ctx, buckets)
+	if err != nil {
+		api.Logger(ctx).Error("Game tick failed to get tick cursors", "error", err)
 		return
 	}
 
-	// Filter UUIDs using consistent hashing
-	playersToUpdate := make([]string, 0, len(onlineUUIDs)/len(gu.consistentHash.Members())) // Estimate capacity
-	gu.chMux.RLock()                                                                        // Read lock to access consistentHash
-	defer gu.chMux.RUnlock()
+	// Group buckets by the ticks they are owed; normally they all owe exactly one, up to the same time
+	now := started.UnixMilli()
+	interval := gu.config.TickInterval.Milliseconds()
+	bucketsByCredit := make(map[tickGroup][]int)
+	newCursors := make(map[int]int64, len(buckets))
+	for _, bucket := range buckets {
+		cursor, ok := cursors[bucket]
+		if !ok {
+			newCursors[bucket] = now // First tick for this bucket; start counting from here
+			continue
+		}
+		elapsed := now - cursor
+		if elapsed < interval {
+			continue // Not due yet (or the previous owner's clock is ahead of ours)
+		}
 
-	// Check if there are any members in the consistent hash ring
-	if len(gu.consistentHash.Members()) == 0 {
-		log.Println("WARNING: GameUpdater: Consistent hash ring is empty. Cannot determine player responsibility.")
+		ticks := elapsed / interval
+		if ticks > int64(gu.config.MaxCatchUpTicks) {
+			atomic.AddUint64(&gu.stats.DroppedTicks, uint64(ticks-int64(gu.config.MaxCatchUpTicks)))
+			ticks = int64(gu.config.MaxCatchUpTicks)
+			newCursors[bucket] = now
+		} else {
+			newCursors[bucket] = cursor + ticks*interval // Keep the remainder for the next tick
+		}
+		if ticks > 1 {
+			atomic.AddUint64(&gu.stats.CaughtUpTicks, uint64(ticks-1))
+		}
+		group := tickGroup{ticks: int(ticks), until: newCursors[bucket]}
+		bucketsByCredit[group] = append(bucketsByCredit[group], bucket)
+	}
+
+	// A season rollover freezes accrual; cursors still advance so the frozen time is never credited later
+	frozen, epoch, err := gu.redisClient.GetSeasonState(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("Game tick failed to check season freeze", "error", err)
 		return
 	}
+	if frozen != "" {
+		bucketsByCredit = nil
+	}
 
-	for _, uuid := range onlineUUIDs {
-		// Determine which service is responsible for this UUID
-		responsibleService, err := gu.consistentHash.Get(uuid)
+	for group, groupBuckets := range bucketsByCredit {
+		credit := TickCredit{Ticks: group.ticks, Until: group.until, Interval: gu.config.TickInterval, Epoch: epoch}
+		players, err := gu.creditBuckets(ctx, groupBuckets, credit)
+		credited = append(credited, players...)
 		if err != nil {
-			log.Printf("WARNING: GameUpdater: Failed to get responsible service for UUID %s: %v", uuid, err)
-			continue
+			// Players credited before the error are not credited again when the next tick retries
+			api.Logger(ctx).Error("Game tick failed to credit buckets", "ticks", group.ticks, "buckets", groupBuckets, "error", err)
+			for _, bucket := range groupBuckets {
+				delete(newCursors, bucket) // Leave the cursor so the next tick retries
+			}
 		}
+	}
 
-		// If *this* service is responsible, add it to the list to update
-		if responsibleService == gu.myServiceID {
-			playersToUpdate = append(playersToUpdate, uuid)
+	// Leaderboards lag the tick by up to LeaderboardUpdateInterval to keep sorted set writes off most ticks
+	if len(credited) > 0 && started.Sub(gu.lastLeaderboardUpdate) >= gu.config.LeaderboardUpdateInterval {
+		if err := gu.redisClient.UpdateLeaderboards(ctx, credited); err != nil {
+			api.Logger(ctx).Error("Game tick failed to update leaderboards", "error", err)
+		} else {
+			gu.lastLeaderboardUpdate = started
 		}
 	}
 
+	// Cursors outlive a lease handoff but not an abandoned bucket
+	lost, err := gu.redisClient.SetTickCursors(ctx, newCursors, gu.myServiceID, 2*gu.config.PartitionLeaseTTL+gu.config.TickInterval*time.Duration(gu.config.MaxCatchUpTicks))
+	if err != nil {
+		api.Logger(ctx).Error("Game tick failed to advance tick cursors", "error", err)
+	}
+	if len(lost) > 0 {
+		api.Logger(ctx).Warn("Game tick lost partition leases; left their cursors to the new owner", "buckets", lost)
+	}
+}
+
+// tickGroup identifies buckets owed the same ticks, up to the same time.
+type tickGroup struct {
+	ticks int
+	until int64
+}
+
+// creditBuckets credits the given ticks to every online player in the buckets and returns the
+// players credited. Team increments that could not be flushed are kept and flushed with the next
+// credit of the same season epoch.
+func (gu *GameUpdater) creditBuckets(ctx context.Context, buckets []int, credit TickCredit) ([]CreditedPlayer, error) {
+	playersToUpdate, err := gu.redisClient.GetOnlineUUIDsInBuckets(ctx, buckets)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(playersToUpdate) == 0 {
-		//log.Println("No players assigned to this instance for update in this tick.")
-		return
+		return nil, nil
 	}
 
-	log.Printf("Performing game tick for %d players assigned to this instance.", len(playersToUpdate))
+	if credit.Ticks > 1 {
+		api.Logger(ctx).Info("Game tick catching up missed ticks", "ticks", credit.Ticks, "players", len(playersToUpdate), "buckets", buckets)
+	}
 
-	for _, uuid := range playersToUpdate {
-		// Increment both total playtime and delta playtime
-		if err := gu.redisClient.IncrementPlayerPlaytime(gu.ctx, uuid); err != nil {
-			log.Printf("Error incrementing total playtime for %s: %v", uuid, err)
+	// Credit each player atomically, then flush team totals once per team
+	teamIncrements, credited, creditErr := gu.redisClient.IncrementPlayersPlaytime(ctx, playersToUpdate, credit, gu.config.AFKTimeout, gu.config.AFKMultiplier())
+	for _, player := range credited {
+		if player.AFK {
+			atomic.AddUint64(&gu.stats.AFKCredits, 1)
 		}
 	}
+
+	if gu.pendingTeamEpoch == credit.Epoch {
+		for teamID, ticks := range gu.pendingTeamIncrements {
+			teamIncrements[teamID] += ticks
+		}
+	}
+	gu.pendingTeamIncrements, gu.pendingTeamEpoch = nil, credit.Epoch
+	unflushed, err := gu.redisClient.IncrementTeamTotals(ctx, teamIncrements, credit.Epoch)
+	if err != nil {
+		api.Logger(ctx).Error("Game tick failed to increment team totals; retrying with the next credit", "error", err)
+		gu.pendingTeamIncrements = unflushed
+	}
+	return credited, creditErr
 }
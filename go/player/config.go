@@ -2,27 +2,66 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
 )
 
 // Config holds the configuration for the Player Data Service
 type Config struct {
-	ListenAddr               string // Address for the HTTP server to listen on (e.g., ":8080")
-	MongoDBConnStr           string // MongoDB connection string
-	MongoDBDatabase          string // MongoDB database name (e.g., "minecraft_events")
-	MongoDBPlayersCollection string // MongoDB collection for players (e.g., "players")
-	MongoDBTeamCollection    string // MongoDB collection for team related info
+	ListenAddr                   string // Address for the HTTP server to listen on (e.g., ":8080")
+	MongoDBConnStr               string // MongoDB connection string
+	MongoDBDatabase              string // MongoDB database name (e.g., "minecraft_events")
+	MongoDBPlayersCollection     string // MongoDB collection for players (e.g., "players")
+	MongoDBTeamCollection        string // MongoDB collection for team related info
+	MongoDBHistoryCollection     string // MongoDB collection for team total history points
+	MongoDBSeasonCollection      string // MongoDB collection for seasons
+	MongoDBStandingsCollection   string // MongoDB collection for archived per-player season standings
+	MongoDBPunishmentsCollection string // MongoDB collection for players' punishment history
+
+	RedisAddrs []string // Redis Cluster seed addresses, used to publish domain events
+
+	HistoryRawRetention  time.Duration // How long per-sync team history points are kept before hourly downsampling (e.g., 168h)
+	HistoryHourRetention time.Duration // How long hourly team history points are kept before daily downsampling (e.g., 2160h)
+
+	AuthAPIKeys  []api.Credential // Credentials accepted as static API keys; AuthAPIKeys or AuthHMACKeys are required unless AuthDisabled
+	AuthHMACKeys []api.Credential // Credentials accepted for HMAC-signed requests (e.g. from the game service)
+	AuthDisabled bool             // Serve every route unauthenticated; only for local development
+
+	CORSAllowedOrigins []string // Origins browsers may call the service from; "*" allows any
+
+	RateLimit             api.RateLimit            // Requests per caller and route; a zero Rate (the default) disables rate limiting
+	RateLimitCallers      map[string]api.RateLimit // Quotas per credential ID (or "ip:<address>"), overriding RateLimit
+	RateLimitRoutes       map[string]api.RateLimit // Limits per route path template, overriding RateLimit
+	RateLimitAuthFailures api.RateLimit            // Failed authentications allowed per IP address; a zero Rate allows any number
+	RateLimitRedis        bool                     // Keep rate limit buckets in Redis so limits hold across instances
+	TrustForwardedFor     bool                     // Rate limit anonymous callers by X-Forwarded-For; only behind a proxy that sets it
+
+	LogLevel  string            // Minimum level logged: debug, info, warn or error
+	LogFormat string            // Log output format: json or text
+	Tracing   api.TracingConfig // Trace exporter (otlp, stdout, file or none) and sampling
 }
 
 // LoadConfig loads configuration from environment variables.
 // In a real application, you might use a dedicated config library (e.g., github.com/spf13/viper)
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
-		ListenAddr:               os.Getenv("LISTEN_ADDR"),
-		MongoDBConnStr:           os.Getenv("MONGODB_CONN_STR"),
-		MongoDBDatabase:          os.Getenv("MONGODB_DATABASE"),
-		MongoDBPlayersCollection: os.Getenv("MONGODB_PLAYERS_COLLECTION"),
-		MongoDBTeamCollection:    os.Getenv("MONGODB_TEAM_COLLECTION"),
+		ListenAddr:                   os.Getenv("LISTEN_ADDR"),
+		MongoDBConnStr:               os.Getenv("MONGODB_CONN_STR"),
+		MongoDBDatabase:              os.Getenv("MONGODB_DATABASE"),
+		MongoDBPlayersCollection:     os.Getenv("MONGODB_PLAYERS_COLLECTION"),
+		MongoDBTeamCollection:        os.Getenv("MONGODB_TEAM_COLLECTION"),
+		MongoDBHistoryCollection:     os.Getenv("MONGODB_HISTORY_COLLECTION"),
+		MongoDBSeasonCollection:      os.Getenv("MONGODB_SEASON_COLLECTION"),
+		MongoDBStandingsCollection:   os.Getenv("MONGODB_STANDINGS_COLLECTION"),
+		MongoDBPunishmentsCollection: os.Getenv("MONGODB_PUNISHMENTS_COLLECTION"),
+		LogLevel:                     os.Getenv("LOG_LEVEL"),
+		LogFormat:                    os.Getenv("LOG_FORMAT"),
 	}
 
 	// Set defaults if environment variables are not provided
@@ -41,6 +80,123 @@ func LoadConfig() (*Config, error) {
 	if cfg.MongoDBTeamCollection == "" {
 		cfg.MongoDBTeamCollection = "teams" // Default collection name
 	}
+	if cfg.MongoDBHistoryCollection == "" {
+		cfg.MongoDBHistoryCollection = "team_history" // Default collection name
+	}
+	if cfg.MongoDBSeasonCollection == "" {
+		cfg.MongoDBSeasonCollection = "seasons" // Default collection name
+	}
+	if cfg.MongoDBStandingsCollection == "" {
+		cfg.MongoDBStandingsCollection = "season_standings" // Default collection name
+	}
+	if cfg.MongoDBPunishmentsCollection == "" {
+		cfg.MongoDBPunishmentsCollection = "punishments" // Default collection name
+	}
+
+	redisAddrsStr := os.Getenv("REDIS_ADDRS")
+	if redisAddrsStr == "" {
+		// Same local Redis Cluster seeds as the Game Service; the client discovers the rest.
+		cfg.RedisAddrs = []string{
+			"127.0.0.1:7000",
+			"127.0.0.1:7001",
+			"127.0.0.1:7002",
+			"127.0.0.1:7003",
+			"127.0.0.1:7004",
+			"127.0.0.1:7005",
+		}
+	} else {
+		cfg.RedisAddrs = strings.Split(redisAddrsStr, ",")
+		for i, addr := range cfg.RedisAddrs {
+			cfg.RedisAddrs[i] = strings.TrimSpace(addr)
+		}
+	}
+
+	var err error
+	cfg.HistoryRawRetention, err = getDurationEnv("HISTORY_RAW_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cfg.HistoryHourRetention, err = getDurationEnv("HISTORY_HOUR_RETENTION", 90*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HistoryHourRetention < cfg.HistoryRawRetention {
+		return nil, fmt.Errorf("HISTORY_HOUR_RETENTION (%v) must not be shorter than HISTORY_RAW_RETENTION (%v)", cfg.HistoryHourRetention, cfg.HistoryRawRetention)
+	}
+
+	cfg.AuthAPIKeys, err = api.ParseCredentials(os.Getenv("SERVICE_AUTH_API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_AUTH_API_KEYS: %w", err)
+	}
+	cfg.AuthHMACKeys, err = api.ParseCredentials(os.Getenv("SERVICE_AUTH_HMAC_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_AUTH_HMAC_KEYS: %w", err)
+	}
+	cfg.AuthDisabled = os.Getenv("SERVICE_AUTH_DISABLED") == "true"
+	if len(cfg.AuthAPIKeys) == 0 && len(cfg.AuthHMACKeys) == 0 && !cfg.AuthDisabled {
+		return nil, fmt.Errorf("no service credentials configured: set SERVICE_AUTH_API_KEYS or SERVICE_AUTH_HMAC_KEYS, or SERVICE_AUTH_DISABLED=true to serve every route unauthenticated")
+	}
+	cfg.CORSAllowedOrigins = api.ParseCORSOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
+
+	// Rate limits
+	// Off unless configured; callers with the internal scope are only limited by RATE_LIMIT_CALLERS
+	if valStr := os.Getenv("RATE_LIMIT_RATE"); valStr != "" {
+		cfg.RateLimit.Rate, err = strconv.ParseFloat(valStr, 64)
+		if err != nil || cfg.RateLimit.Rate < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_RATE %q: must be a non-negative number", valStr)
+		}
+	}
+	cfg.RateLimit.Burst = max(1, int(math.Ceil(2*cfg.RateLimit.Rate)))
+	if valStr := os.Getenv("RATE_LIMIT_BURST"); valStr != "" {
+		cfg.RateLimit.Burst, err = strconv.Atoi(valStr)
+		if err != nil || cfg.RateLimit.Burst < 1 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_BURST %q: must be a positive integer", valStr)
+		}
+	}
+	cfg.RateLimitAuthFailures = api.RateLimit{Rate: 1, Burst: 20}
+	if valStr := os.Getenv("RATE_LIMIT_AUTH_FAILURES"); valStr == "0" {
+		cfg.RateLimitAuthFailures = api.RateLimit{}
+	} else if valStr != "" {
+		cfg.RateLimitAuthFailures, err = api.ParseRateLimit(valStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_FAILURES: %w", err)
+		}
+	}
+	cfg.RateLimitCallers, err = api.ParseRateLimits(os.Getenv("RATE_LIMIT_CALLERS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_CALLERS: %w", err)
+	}
+	cfg.RateLimitRoutes, err = api.ParseRateLimits(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_ROUTES: %w", err)
+	}
+	cfg.RateLimitRedis = os.Getenv("RATE_LIMIT_REDIS") == "true"
+	cfg.TrustForwardedFor = os.Getenv("TRUST_FORWARDED_FOR") == "true"
+
+	// Tracing
+	cfg.Tracing = api.TracingConfig{Exporter: os.Getenv("TRACING_EXPORTER"), File: os.Getenv("TRACING_FILE"), SampleRatio: 1}
+	if cfg.Tracing.File == "" {
+		cfg.Tracing.File = "traces.jsonl"
+	}
+	if valStr := os.Getenv("TRACING_SAMPLE_RATIO"); valStr != "" {
+		cfg.Tracing.SampleRatio, err = strconv.ParseFloat(valStr, 64)
+		if err != nil || cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+			return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO %q: must be a number between 0 and 1", valStr)
+		}
+	}
 
 	return cfg, nil
 }
+
+// getDurationEnv reads a duration from an environment variable, falling back to defaultVal if unset.
+func getDurationEnv(envKey string, defaultVal time.Duration) (time.Duration, error) {
+	valStr := os.Getenv(envKey)
+	if valStr == "" {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(valStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration format for %s: %w", envKey, err)
+	}
+	return d, nil
+}
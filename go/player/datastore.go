@@ -2,19 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"math/rand"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	"github.com/Ftotnem/Backend/go/shared/api"
 	"github.com/Ftotnem/Backend/go/shared/models"
 )
 
+// ErrStaleSeasonEpoch is returned when a playtime write belongs to an older season epoch than the profile,
+// which has been reset for a later season since.
+var ErrStaleSeasonEpoch = errors.New("stale season epoch")
+
+// notAfterEpoch matches profiles whose totals belong to epoch or an earlier one (including profiles
+// never reset, which have no epoch), so a write of epoch may replace them.
+func notAfterEpoch(epoch int64) bson.M {
+	return bson.M{"$not": bson.M{"$gt": epoch}}
+}
+
 // PlayerStore represents the MongoDB data store for player profiles.
 type PlayerStore struct {
 	collection   *mongo.Collection
@@ -37,7 +50,7 @@ func ConnectMongoDB(connStr string) (*mongo.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connStr))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connStr).SetMonitor(chainMongoMonitors(newMongoMetricsMonitor(), newMongoTracingMonitor())))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
@@ -47,10 +60,37 @@ func ConnectMongoDB(connStr string) (*mongo.Client, error) {
 		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	log.Println("Successfully connected to MongoDB!")
+	api.Logger(ctx).Info("Connected to MongoDB")
 	return client, nil
 }
 
+// chainMongoMonitors combines command monitors, since a client takes only one.
+func chainMongoMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
 // CreateProfile inserts a new player document (profile) into the collection.
 // It will error if a profile with the same UUID already exists.
 // This function also initializes default fields and attempts to fetch the username.
@@ -68,7 +108,7 @@ func (ps *PlayerStore) CreateProfile(ctx context.Context, playerUUID string) (*m
 		count, err := ps.teamStore.GetTeamPlayerCount(ctx, teamName)
 		if err != nil {
 			// Log warning but proceed, default to random if counts can't be fetched
-			log.Printf("WARN: Could not retrieve player count for team %s: %v. Falling back to random assignment if other teams also fail.", teamName, err)
+			api.Logger(ctx).Warn("Could not retrieve player count for team; falling back to random assignment if other teams also fail", "team", teamName, "error", err)
 			teamCounts[teamName] = -1 // Indicate an error, effectively making it undesirable
 		} else {
 			teamCounts[teamName] = count
@@ -94,11 +134,11 @@ func (ps *PlayerStore) CreateProfile(ctx context.Context, playerUUID string) (*m
 	if len(leastPopulatedTeams) > 0 {
 		// If there are teams to choose from, pick one randomly from the least populated
 		assignedTeam = leastPopulatedTeams[rand.Intn(len(leastPopulatedTeams))]
-		log.Printf("INFO: Assigned player %s to team %s (least populated).", playerUUID, assignedTeam)
+		api.Logger(ctx).Info("Assigned player to least populated team", "uuid", playerUUID, "team", assignedTeam)
 	} else {
 		// Fallback: if no team counts could be fetched (e.g., all failed), assign randomly
 		assignedTeam = allTeams[rand.Intn(len(allTeams))]
-		log.Printf("WARN: Could not determine least populated team. Assigned player %s to team %s randomly.", playerUUID, assignedTeam)
+		api.Logger(ctx).Warn("Could not determine least populated team; assigned player randomly", "uuid", playerUUID, "team", assignedTeam)
 	}
 
 	newProfile := &models.Player{
@@ -120,35 +160,35 @@ func (ps *PlayerStore) CreateProfile(ctx context.Context, playerUUID string) (*m
 		return nil, fmt.Errorf("failed to create player profile %s: %w", playerUUID, err)
 	}
 
-	log.Printf("Player profile %s created successfully with default values.", playerUUID)
+	api.Logger(ctx).Info("Created player profile with default values", "uuid", playerUUID)
 
 	// Increment the player count for the assigned team
 	go func(team string) {
-		teamCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		teamCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 		defer cancel()
 		if err := ps.teamStore.IncrementTeamPlayerCount(teamCtx, team); err != nil {
-			log.Printf("ERROR: Failed to increment player count for team %s after creating profile %s: %v", team, playerUUID, err)
+			api.Logger(teamCtx).Error("Failed to increment player count for team after creating profile", "team", team, "uuid", playerUUID, "error", err)
 		}
 	}(assignedTeam)
 
 	// Asynchronously fetch username for the newly created profile
 	go func(uuid string) {
-		mojangCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		mojangCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 		defer cancel()
 
 		username, mojangErr := ps.mojangClient.GetUsernameByUUID(mojangCtx, uuid)
 		if mojangErr != nil {
-			log.Printf("WARN: Failed to fetch username from Mojang for UUID %s: %v", uuid, mojangErr)
+			api.Logger(mojangCtx).Warn("Failed to fetch username from Mojang", "uuid", uuid, "error", mojangErr)
 			return
 		}
 
-		updateCtx, updateCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		updateCtx, updateCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 		defer updateCancel()
 
 		if updateErr := ps.UpdateProfileUsername(updateCtx, uuid, username); updateErr != nil {
-			log.Printf("WARN: Failed to update username for player profile %s in DB: %v", uuid, updateErr)
+			api.Logger(updateCtx).Warn("Failed to update username for player profile in DB", "uuid", uuid, "error", updateErr)
 		} else {
-			log.Printf("INFO: Successfully updated username for player profile %s to %s.", uuid, username)
+			api.Logger(updateCtx).Info("Updated username for player profile", "uuid", uuid, "username", username)
 			newProfile.Username = username // Update in-memory struct for immediate return (though not strictly necessary as response is already sent)
 		}
 	}(playerUUID)
@@ -169,6 +209,31 @@ func (ps *PlayerStore) GetProfileByUUID(ctx context.Context, uuid string) (*mode
 	return &profile, nil
 }
 
+// ListProfilePlaytimes returns up to limit profiles ordered by UUID, starting after afterUUID
+// (empty for the first page). Only the UUID, team and total playtime fields are loaded.
+func (ps *PlayerStore) ListProfilePlaytimes(ctx context.Context, afterUUID string, limit int64) ([]models.Player, error) {
+	filter := bson.M{}
+	if afterUUID != "" {
+		filter["_id"] = bson.M{"$gt": afterUUID}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"_id": 1, "team": 1, "total_playtime_ticks": 1})
+
+	cursor, err := ps.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list player playtimes after %q: %w", afterUUID, err)
+	}
+	defer cursor.Close(ctx)
+
+	profiles := []models.Player{}
+	if err := cursor.All(ctx, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to decode player playtimes: %w", err)
+	}
+	return profiles, nil
+}
+
 // UpdateProfileUsername updates only the Username field for a player profile.
 func (ps *PlayerStore) UpdateProfileUsername(ctx context.Context, uuid, username string) error {
 	filter := bson.M{"_id": uuid}
@@ -181,26 +246,62 @@ func (ps *PlayerStore) UpdateProfileUsername(ctx context.Context, uuid, username
 	if result.MatchedCount == 0 {
 		return fmt.Errorf("player profile %s not found for username update", uuid)
 	}
-	log.Printf("Updated username for player profile %s to %s. Matched %d, Modified %d.", uuid, username, result.MatchedCount, result.ModifiedCount)
+	api.Logger(ctx).Info("Updated username for player profile", "uuid", uuid, "username", username, "matched", result.MatchedCount, "modified", result.ModifiedCount)
 	return nil
 }
 
-// UpdateProfilePlaytime updates a player profile's total playtime.
-func (ps *PlayerStore) UpdateProfilePlaytime(ctx context.Context, uuid string, newTotalPlaytime float64) error {
-	filter := bson.M{"_id": uuid}
-	update := bson.M{"$set": bson.M{"total_playtime_ticks": newTotalPlaytime}}
+// UpdateProfilePlaytime updates a player profile's total playtime, which belongs to seasonEpoch.
+// Returns ErrStaleSeasonEpoch if the profile has been reset for a later season since.
+func (ps *PlayerStore) UpdateProfilePlaytime(ctx context.Context, uuid string, newTotalPlaytime float64, seasonEpoch int64) error {
+	filter := bson.M{"_id": uuid, "season_epoch": notAfterEpoch(seasonEpoch)}
+	update := bson.M{
+		"$set": bson.M{"total_playtime_ticks": newTotalPlaytime},
+		"$max": bson.M{"season_epoch": seasonEpoch},
+	}
 
 	result, err := ps.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("failed to set playtime for player profile %s: %w", uuid, err)
 	}
 	if result.MatchedCount == 0 {
+		if _, err := ps.GetProfileByUUID(ctx, uuid); err == nil {
+			return fmt.Errorf("%w: player profile %s has been reset since season epoch %d", ErrStaleSeasonEpoch, uuid, seasonEpoch)
+		}
 		return fmt.Errorf("player profile %s not found for playtime update", uuid)
 	}
-	log.Printf("Set total playtime for player profile %s to %.2f ticks. Matched %d, Modified %d.", uuid, newTotalPlaytime, result.MatchedCount, result.ModifiedCount)
+	api.Logger(ctx).Info("Set total playtime for player profile", "uuid", uuid, "total", newTotalPlaytime, "matched", result.MatchedCount, "modified", result.ModifiedCount)
 	return nil
 }
 
+// BulkUpdateProfilePlaytime raises the total playtime, and the AFK ticks where given, of many player
+// profiles in one unordered bulk write. Within a season epoch both only grow, so $max keeps a delayed
+// batch from overwriting a newer value written on offline; a total from an older epoch than the
+// profile's (see models.Season) is skipped, so a batch delayed past a season reset cannot restore it.
+// It returns how many of the given profiles were updated; unknown UUIDs are skipped.
+func (ps *PlayerStore) BulkUpdateProfilePlaytime(ctx context.Context, playtimes, afkTicks map[string]float64, epochs map[string]int64) (int64, error) {
+	if len(playtimes) == 0 {
+		return 0, nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(playtimes))
+	for uuid, playtime := range playtimes {
+		fields := bson.M{"total_playtime_ticks": playtime, "season_epoch": epochs[uuid]}
+		if ticks, ok := afkTicks[uuid]; ok {
+			fields["afk_ticks"] = ticks
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": uuid, "season_epoch": notAfterEpoch(epochs[uuid])}).
+			SetUpdate(bson.M{"$max": fields}))
+	}
+
+	result, err := ps.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, fmt.Errorf("failed to bulk set playtime for %d player profiles: %w", len(playtimes), err)
+	}
+	api.Logger(ctx).Info("Bulk set total playtime for player profiles", "profiles", len(playtimes), "matched", result.MatchedCount, "modified", result.ModifiedCount)
+	return result.MatchedCount, nil
+}
+
 // UpdateProfileDeltaPlaytime updates a player profile's delta playtime.
 func (ps *PlayerStore) UpdateProfileDeltaPlaytime(ctx context.Context, uuid string, newDeltaPlaytime float64) error {
 	filter := bson.M{"_id": uuid}
@@ -213,26 +314,52 @@ func (ps *PlayerStore) UpdateProfileDeltaPlaytime(ctx context.Context, uuid stri
 	if result.MatchedCount == 0 {
 		return fmt.Errorf("player profile %s not found for delta playtime update", uuid)
 	}
-	log.Printf("Set delta playtime for player profile %s to %.2f ticks. Matched %d, Modified %d.", uuid, newDeltaPlaytime, result.MatchedCount, result.ModifiedCount)
+	api.Logger(ctx).Info("Set delta playtime for player profile", "uuid", uuid, "delta", newDeltaPlaytime, "matched", result.MatchedCount, "modified", result.ModifiedCount)
 	return nil
 }
 
-// UpdateProfileBanStatus updates a player profile's ban status.
-func (ps *PlayerStore) UpdateProfileBanStatus(ctx context.Context, uuid string, banned bool, expiresAt *time.Time) error {
+// UpdateProfileAFKTicks raises the total ticks a player profile has spent AFK.
+// Like playtime it only grows, so $max keeps an older value from overwriting a newer one.
+func (ps *PlayerStore) UpdateProfileAFKTicks(ctx context.Context, uuid string, afkTicks float64) error {
 	filter := bson.M{"_id": uuid}
-	update := bson.M{"$set": bson.M{"banned": banned, "ban_expires_at": expiresAt}}
+	update := bson.M{"$max": bson.M{"afk_ticks": afkTicks}}
 
 	result, err := ps.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return fmt.Errorf("failed to update ban status for player profile %s: %w", uuid, err)
+		return fmt.Errorf("failed to set AFK ticks for player profile %s: %w", uuid, err)
 	}
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("player profile %s not found for ban status update", uuid)
+		return fmt.Errorf("player profile %s not found for AFK ticks update", uuid)
 	}
-	log.Printf("Updated ban status for player profile %s. Banned: %t, Expires: %v", uuid, banned, expiresAt)
+	api.Logger(ctx).Info("Set AFK ticks for player profile", "uuid", uuid, "afk_ticks", afkTicks, "matched", result.MatchedCount, "modified", result.ModifiedCount)
 	return nil
 }
 
+// UpdateProfileBanStatus updates a player profile's ban status, provided its ban version is still
+// version (as read with GetProfileByUUID), and bumps the version. It returns false if the version has
+// moved on or the profile is gone. The PunishmentStore derives the status from the player's active
+// bans; nothing else should set it.
+func (ps *PlayerStore) UpdateProfileBanStatus(ctx context.Context, uuid string, banned bool, expiresAt *time.Time, version int64) (bool, error) {
+	filter := bson.M{"_id": uuid, "ban_version": version}
+	if version == 0 {
+		filter["ban_version"] = bson.M{"$in": bson.A{nil, 0}} // Also matches profiles without one
+	}
+	update := bson.M{
+		"$set": bson.M{"banned": banned, "ban_expires_at": expiresAt},
+		"$inc": bson.M{"ban_version": 1},
+	}
+
+	result, err := ps.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to update ban status for player profile %s: %w", uuid, err)
+	}
+	if result.MatchedCount == 0 {
+		return false, nil
+	}
+	api.Logger(ctx).Info("Updated ban status for player profile", "uuid", uuid, "banned", banned, "expires_at", expiresAt)
+	return true, nil
+}
+
 // UpdateProfileLastLogin updates only the LastLoginAt timestamp for a player profile.
 func (ps *PlayerStore) UpdateProfileLastLogin(ctx context.Context, uuid string) error {
 	filter := bson.M{"_id": uuid}
@@ -246,6 +373,91 @@ func (ps *PlayerStore) UpdateProfileLastLogin(ctx context.Context, uuid string)
 	if result.MatchedCount == 0 {
 		return fmt.Errorf("player profile %s not found for last login update", uuid)
 	}
-	log.Printf("Updated last login for player profile %s to %v. Matched %d, Modified %d.", uuid, now, result.MatchedCount, result.ModifiedCount)
+	api.Logger(ctx).Info("Updated last login for player profile", "uuid", uuid, "last_login_at", now, "matched", result.MatchedCount, "modified", result.ModifiedCount)
 	return nil
 }
+
+// AddBooster appends a new booster to a player profile.
+// The booster ID is generated here; a nil expiresAt grants a booster that never expires.
+func (ps *PlayerStore) AddBooster(ctx context.Context, uuid, boosterType string, value float64, expiresAt *time.Time, source string) (*models.Booster, error) {
+	booster := models.Booster{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      boosterType,
+		Value:     value,
+		ExpiresAt: expiresAt,
+		Source:    source,
+	}
+
+	filter := bson.M{"_id": uuid}
+	update := bson.M{"$push": bson.M{"boosters": booster}}
+
+	result, err := ps.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add booster for player profile %s: %w", uuid, err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("player profile %s not found for booster grant", uuid)
+	}
+	api.Logger(ctx).Info("Granted booster to player profile", "uuid", uuid, "booster_id", booster.ID, "type", boosterType, "value", value, "expires_at", expiresAt)
+	return &booster, nil
+}
+
+// RemoveBooster removes a single booster from a player profile by its ID.
+func (ps *PlayerStore) RemoveBooster(ctx context.Context, uuid, boosterID string) error {
+	filter := bson.M{"_id": uuid, "boosters.id": boosterID}
+	update := bson.M{"$pull": bson.M{"boosters": bson.M{"id": boosterID}}}
+
+	result, err := ps.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to remove booster %s for player profile %s: %w", boosterID, uuid, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("booster %s not found for player profile %s", boosterID, uuid)
+	}
+	api.Logger(ctx).Info("Revoked booster from player profile", "uuid", uuid, "booster_id", boosterID)
+	return nil
+}
+
+// GetActiveBoosters prunes a player's expired boosters and returns the remaining ones.
+// Returns mongo.ErrNoDocuments if the player profile is not found.
+func (ps *PlayerStore) GetActiveBoosters(ctx context.Context, uuid string) ([]models.Booster, error) {
+	filter := bson.M{"_id": uuid}
+	update := bson.M{"$pull": bson.M{"boosters": bson.M{"expires_at": bson.M{"$lte": time.Now()}}}}
+	if _, err := ps.collection.UpdateOne(ctx, filter, update); err != nil {
+		return nil, fmt.Errorf("failed to prune expired boosters for player profile %s: %w", uuid, err)
+	}
+
+	profile, err := ps.GetProfileByUUID(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Boosters == nil {
+		return []models.Booster{}, nil
+	}
+	return profile.Boosters, nil
+}
+
+// ClearExpiredBans unbans every player profile whose latest ban has run out.
+func (ps *PlayerStore) ClearExpiredBans(ctx context.Context) (int64, error) {
+	filter := bson.M{"banned": true, "ban_expires_at": bson.M{"$lte": time.Now()}}
+	update := bson.M{"$set": bson.M{"banned": false, "ban_expires_at": nil}}
+
+	result, err := ps.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear expired bans: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// PruneExpiredBoosters removes expired boosters from every player profile.
+func (ps *PlayerStore) PruneExpiredBoosters(ctx context.Context) (int64, error) {
+	now := time.Now()
+	filter := bson.M{"boosters.expires_at": bson.M{"$lte": now}}
+	update := bson.M{"$pull": bson.M{"boosters": bson.M{"expires_at": bson.M{"$lte": now}}}}
+
+	result, err := ps.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune expired boosters: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
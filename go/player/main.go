@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/events"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -18,6 +21,13 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if err := api.SetupLogging("player-service", cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	shutdownTracing, err := api.SetupTracing("player-service", cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
 	mongoClient, err := ConnectMongoDB(cfg.MongoDBConnStr)
 	if err != nil {
@@ -25,12 +35,35 @@ func main() {
 	}
 	defer func() {
 		if err = mongoClient.Disconnect(context.Background()); err != nil {
-			log.Printf("Error disconnecting from MongoDB: %v", err)
+			slog.Error("Failed to disconnect from MongoDB", "error", err)
 		} else {
-			log.Println("Disconnected from MongoDB.")
+			slog.Info("Disconnected from MongoDB")
 		}
 	}()
 
+	redisClient := redis.NewClusterClient(&redis.ClusterOptions{Addrs: cfg.RedisAddrs})
+	redisClient.AddHook(api.RedisMetricsHook{})
+	redisClient.AddHook(api.RedisTracingHook{})
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), 5*time.Second)
+	err = redisClient.Ping(pingCtx).Err()
+	cancelPing()
+	// Redis only carries events, nonces and rate limits here, so the service can run without it
+	var eventPublisher *events.Publisher
+	if err != nil {
+		slog.Warn("Failed to connect to Redis Cluster; continuing without publishing events", "error", err)
+		redisClient.Close()
+		redisClient = nil
+	} else {
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				slog.Error("Failed to close Redis client", "error", err)
+			} else {
+				slog.Info("Closed Redis client")
+			}
+		}()
+		eventPublisher = events.NewPublisher(redisClient, "player-service")
+	}
+
 	mojangClient := NewMojangClient()
 
 	// Initialize TeamStore
@@ -42,26 +75,88 @@ func main() {
 		log.Fatalf("Failed to ensure default teams exist: %v", err)
 	}
 	playerStore := NewPlayerStore(mongoClient, cfg.MongoDBDatabase, cfg.MongoDBPlayersCollection, mojangClient, teamStore)
-	playerService := NewPlayerService(playerStore)
+	playerService := NewPlayerService(playerStore, eventPublisher)
+
+	historyStore := NewTeamHistoryStore(mongoClient, cfg.MongoDBDatabase, cfg.MongoDBHistoryCollection)
+	if err := historyStore.EnsureIndexes(context.Background()); err != nil {
+		log.Fatalf("Failed to prepare team history collection: %v", err)
+	}
 
-	teamService := NewTeamService(teamStore, playerStore) // Pass playerStore to TeamService for aggregation
+	seasonStore := NewSeasonStore(mongoClient, cfg.MongoDBDatabase, cfg.MongoDBSeasonCollection, cfg.MongoDBStandingsCollection, playerStore, teamStore)
+	if err := seasonStore.EnsureIndexes(context.Background()); err != nil {
+		log.Fatalf("Failed to prepare season standings collection: %v", err)
+	}
+	seasonService := NewSeasonService(seasonStore)
+
+	punishmentStore := NewPunishmentStore(mongoClient, cfg.MongoDBDatabase, cfg.MongoDBPunishmentsCollection, playerStore)
+	if err := punishmentStore.EnsureIndexes(context.Background()); err != nil {
+		log.Fatalf("Failed to prepare punishments collection: %v", err)
+	}
+	punishmentService := NewPunishmentService(punishmentStore)
+
+	teamService := NewTeamService(teamStore, playerStore, historyStore) // Pass playerStore to TeamService for aggregation
 
 	go startUsernameFiller(playerStore, mojangClient, 1*time.Minute)
+	go startBoosterPruner(playerStore, 1*time.Minute)
+	go startBanExpirer(playerStore, 1*time.Minute)
+	go startHistoryDownsampler(historyStore, 1*time.Hour, cfg.HistoryRawRetention, cfg.HistoryHourRetention)
 
 	baseServer := api.NewBaseServer(cfg.ListenAddr)
+	// Nonces are shared through Redis so a signed request cannot be replayed against another instance;
+	// without Redis they are only remembered by this one
+	var nonces api.NonceStore
+	if redisClient != nil {
+		nonces = api.NewRedisNonceStore(redisClient, "player-service")
+	}
+	baseServer.Auth = api.NewServiceAuth(cfg.AuthAPIKeys, cfg.AuthHMACKeys, nonces)
+	if baseServer.Auth == nil {
+		slog.Warn("SERVICE_AUTH_DISABLED is set; every route is unauthenticated")
+	}
+	baseServer.CORS = api.NewCORSPolicy(cfg.CORSAllowedOrigins)
+	var rateLimitStore api.RateLimitStore // In memory unless limits must hold across instances
+	if cfg.RateLimitRedis && redisClient != nil {
+		rateLimitStore = api.NewRedisRateLimitStore(redisClient, "player-service")
+	}
+	baseServer.RateLimiter = api.NewRateLimiter(api.RateLimiterConfig{
+		Store:             rateLimitStore,
+		Default:           cfg.RateLimit,
+		Callers:           cfg.RateLimitCallers,
+		Routes:            cfg.RateLimitRoutes,
+		AuthFailures:      cfg.RateLimitAuthFailures,
+		TrustForwardedFor: cfg.TrustForwardedFor,
+	})
+
+	// Register your handlers on the BaseServer's router, each with the scope its callers need
+	baseServer.HandleFunc("/profiles", api.ScopeInternal, playerService.CreateProfileHandler).Methods("POST")
+	baseServer.HandleFunc("/profiles/playtime", api.ScopeInternal, playerService.BulkUpdateProfilePlaytimeHandler).Methods("PUT")
+	baseServer.HandleFunc("/profiles/playtime", api.ScopeInternal, playerService.ListProfilePlaytimesHandler).Methods("GET")
+	baseServer.HandleFunc("/profiles/{uuid}", api.ScopeInternal, playerService.GetProfileHandler).Methods("GET")
+	baseServer.HandleFunc("/profiles/{uuid}/playtime", api.ScopeInternal, playerService.UpdateProfilePlaytimeHandler).Methods("PUT")
+	baseServer.HandleFunc("/profiles/{uuid}/deltaplaytime", api.ScopeInternal, playerService.UpdateProfileDeltaPlaytimeHandler).Methods("PUT")
+	baseServer.HandleFunc("/profiles/{uuid}/afk", api.ScopeInternal, playerService.UpdateProfileAFKTicksHandler).Methods("PUT")
+	baseServer.HandleFunc("/profiles/{uuid}/lastlogin", api.ScopeInternal, playerService.UpdateProfileLastLoginHandler).Methods("PUT")
+	baseServer.HandleFunc("/profiles/{uuid}/boosters", api.ScopeInternal, playerService.GetBoostersHandler).Methods("GET")
+	baseServer.HandleFunc("/profiles/{uuid}/boosters", api.ScopeInternal, playerService.GrantBoosterHandler).Methods("POST")
+	baseServer.HandleFunc("/profiles/{uuid}/boosters/{boosterID}", api.ScopeInternal, playerService.RevokeBoosterHandler).Methods("DELETE")
+	baseServer.HandleFunc("/profiles/{uuid}/punishments", api.ScopeInternal, punishmentService.IssuePunishmentHandler).Methods("POST")
+	baseServer.HandleFunc("/profiles/{uuid}/punishments", api.ScopeInternal, punishmentService.ListPunishmentsHandler).Methods("GET")
+	baseServer.HandleFunc("/profiles/{uuid}/punishments/revoke", api.ScopeInternal, punishmentService.RevokePunishmentsHandler).Methods("POST")
 
-	// Register your handlers on the BaseServer's router
-	baseServer.Router.HandleFunc("/profiles", playerService.CreateProfileHandler).Methods("POST")
-	baseServer.Router.HandleFunc("/profiles/{uuid}", playerService.GetProfileHandler).Methods("GET")
-	baseServer.Router.HandleFunc("/profiles/{uuid}/playtime", playerService.UpdateProfilePlaytimeHandler).Methods("PUT")
-	baseServer.Router.HandleFunc("/profiles/{uuid}/deltaplaytime", playerService.UpdateProfileDeltaPlaytimeHandler).Methods("PUT")
-	baseServer.Router.HandleFunc("/profiles/{uuid}/ban", playerService.UpdateProfileBanStatusHandler).Methods("PUT")
-	baseServer.Router.HandleFunc("/profiles/{uuid}/lastlogin", playerService.UpdateProfileLastLoginHandler).Methods("PUT")
+	baseServer.HandleFunc("/teams/sync-totals", api.ScopeInternal, teamService.SyncTeamTotalsHandler).Methods("POST")
+	baseServer.HandleFunc("/teams/history", api.ScopeInternal, teamService.TeamHistoryHandler).Methods("GET")
 
-	baseServer.Router.HandleFunc("/teams/sync-totals", teamService.SyncTeamTotalsHandler).Methods("POST")
+	baseServer.HandleFunc("/seasons", api.ScopeAdmin, seasonService.CreateSeasonHandler).Methods("POST")
+	baseServer.HandleFunc("/seasons", api.ScopeInternal, seasonService.ListSeasonsHandler).Methods("GET")
+	baseServer.HandleFunc("/seasons/current", api.ScopeInternal, seasonService.GetCurrentSeasonHandler).Methods("GET")
+	baseServer.HandleFunc("/seasons/{id}", api.ScopeInternal, seasonService.GetSeasonHandler).Methods("GET")
+	baseServer.HandleFunc("/seasons/{id}", api.ScopeAdmin, seasonService.UpdateSeasonHandler).Methods("PUT")
+	baseServer.HandleFunc("/seasons/{id}", api.ScopeAdmin, seasonService.DeleteSeasonHandler).Methods("DELETE")
+	baseServer.HandleFunc("/seasons/{id}/activate", api.ScopeInternal, seasonService.ActivateSeasonHandler).Methods("POST")
+	baseServer.HandleFunc("/seasons/{id}/archive", api.ScopeInternal, seasonService.ArchiveSeasonHandler).Methods("POST")
+	baseServer.HandleFunc("/seasons/{id}/standings", api.ScopeInternal, seasonService.SeasonStandingsHandler).Methods("GET")
 
 	go func() {
-		log.Printf("Player Data Service listening on %s", cfg.ListenAddr)
+		slog.Info("Player Data Service listening", "addr", cfg.ListenAddr)
 		if err := baseServer.Start(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Could not listen on %s: %v", cfg.ListenAddr, err)
 		}
@@ -71,7 +166,7 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	sig := <-sigChan
-	log.Printf("Received signal %s, shutting down...", sig)
+	slog.Info("Received signal, shutting down", "signal", sig.String())
 
 	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancelShutdown()
@@ -79,23 +174,26 @@ func main() {
 	if err := baseServer.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
-	log.Println("Player Data Service gracefully stopped.")
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Player Data Service gracefully stopped")
 }
 
 func startUsernameFiller(store *PlayerStore, mojangClient *MojangClient, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	log.Printf("Starting background username filler, checking every %v", interval)
+	slog.Info("Starting background username filler", "interval", interval)
 
 	for range ticker.C {
-		log.Println("Running username filler job...")
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(api.WithNewRequestID(context.Background()), 30*time.Second)
+		api.Logger(ctx).Debug("Running username filler job")
 
 		filter := bson.M{"username": ""}
 		cursor, err := store.collection.Find(ctx, filter)
 		if err != nil {
-			log.Printf("Error finding profiles with empty usernames: %v", err)
+			api.Logger(ctx).Error("Username filler failed to find profiles with empty usernames", "error", err)
 			cancel()
 			continue
 		}
@@ -104,7 +202,7 @@ func startUsernameFiller(store *PlayerStore, mojangClient *MojangClient, interva
 			UUID string `bson:"_id"`
 		}
 		if err := cursor.All(ctx, &profilesToUpdate); err != nil {
-			log.Printf("Error decoding profiles with empty usernames: %v", err)
+			api.Logger(ctx).Error("Username filler failed to decode profiles with empty usernames", "error", err)
 			cursor.Close(ctx)
 			cancel()
 			continue
@@ -112,29 +210,69 @@ func startUsernameFiller(store *PlayerStore, mojangClient *MojangClient, interva
 		cursor.Close(ctx)
 
 		if len(profilesToUpdate) == 0 {
-			log.Println("No profiles with empty usernames found.")
+			api.Logger(ctx).Debug("Username filler found no profiles with empty usernames")
 			cancel()
 			continue
 		}
 
-		log.Printf("Found %d profiles with empty usernames to process.", len(profilesToUpdate))
+		api.Logger(ctx).Info("Username filler found profiles with empty usernames", "profiles", len(profilesToUpdate))
 
 		for _, p := range profilesToUpdate {
 			time.Sleep(100 * time.Millisecond) // Be nice to Mojang API
 
 			username, mojangErr := mojangClient.GetUsernameByUUID(ctx, p.UUID)
 			if mojangErr != nil {
-				log.Printf("WARN: Username filler failed to fetch username for UUID %s: %v", p.UUID, mojangErr)
+				api.Logger(ctx).Warn("Username filler failed to fetch username", "uuid", p.UUID, "error", mojangErr)
 				continue
 			}
 
 			if updateErr := store.UpdateProfileUsername(ctx, p.UUID, username); updateErr != nil {
-				log.Printf("WARN: Username filler failed to update username for profile %s in DB: %v", p.UUID, updateErr)
+				api.Logger(ctx).Warn("Username filler failed to update username in DB", "uuid", p.UUID, "error", updateErr)
 			} else {
-				log.Printf("INFO: Username filler successfully updated username for profile %s to %s.", p.UUID, username)
+				api.Logger(ctx).Info("Username filler updated username", "uuid", p.UUID, "username", username)
 			}
 		}
 		cancel()
-		log.Println("Username filler job finished.")
+		api.Logger(ctx).Info("Username filler job finished")
+	}
+}
+
+func startBoosterPruner(store *PlayerStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting background booster pruner", "interval", interval)
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(api.WithNewRequestID(context.Background()), 30*time.Second)
+		pruned, err := store.PruneExpiredBoosters(ctx)
+		cancel()
+		if err != nil {
+			api.Logger(ctx).Warn("Booster pruner failed", "error", err)
+			continue
+		}
+		if pruned > 0 {
+			api.Logger(ctx).Info("Booster pruner removed expired boosters", "profiles", pruned)
+		}
+	}
+}
+
+func startBanExpirer(store *PlayerStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting background ban expirer", "interval", interval)
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(api.WithNewRequestID(context.Background()), 30*time.Second)
+		cleared, err := store.ClearExpiredBans(ctx)
+		cancel()
+		if err != nil {
+			api.Logger(ctx).Warn("Ban expirer failed", "error", err)
+			continue
+		}
+		if cleared > 0 {
+			api.Logger(ctx).Info("Ban expirer cleared expired bans", "profiles", cleared)
+		}
 	}
 }
@@ -3,25 +3,43 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo" // Import mongo to check for ErrNoDocuments
 
 	"github.com/Ftotnem/Backend/go/shared/api" // Import models for Player struct
+	"github.com/Ftotnem/Backend/go/shared/events"
+	"github.com/Ftotnem/Backend/go/shared/models"
 	"github.com/gorilla/mux"
 )
 
 // PlayerService holds dependencies for HTTP handlers (like the PlayerStore)
 type PlayerService struct {
-	store *PlayerStore
+	store  *PlayerStore
+	events *events.Publisher
 }
 
-// NewPlayerService creates a new PlayerService instance
-func NewPlayerService(store *PlayerStore) *PlayerService {
-	return &PlayerService{store: store}
+// NewPlayerService creates a new PlayerService instance. A nil publisher publishes no events.
+func NewPlayerService(store *PlayerStore, publisher *events.Publisher) *PlayerService {
+	return &PlayerService{store: store, events: publisher}
+}
+
+// publishEvent publishes a player lifecycle event. Events are published after the change has been
+// stored and are best effort: a failure is logged and does not fail the request.
+func (ps *PlayerService) publishEvent(ctx context.Context, eventType, playerUUID string, data interface{}) {
+	if ps.events == nil {
+		return
+	}
+	// Publish even if the request is cancelled after the change was made
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	if _, err := ps.events.Publish(ctx, eventType, playerUUID, data); err != nil {
+		api.Logger(ctx).Warn("Failed to publish event", "type", eventType, "uuid", playerUUID, "error", err)
+	}
 }
 
 // CreateProfileRequest defines the request body for creating a player profile.
@@ -52,13 +70,19 @@ func (ps *PlayerService) CreateProfileHandler(w http.ResponseWriter, r *http.Req
 			api.WriteError(w, http.StatusConflict, fmt.Sprintf("Profile with UUID %s already exists", req.UUID))
 			return
 		}
-		log.Printf("Error creating player profile %s: %v", req.UUID, err)
+		api.Logger(ctx).Error("Failed to create player profile", "uuid", req.UUID, "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to create player profile: "+err.Error())
 		return
 	}
 
+	ps.publishEvent(ctx, models.EventProfileCreated, createdProfile.UUID, models.ProfileCreatedData{
+		Username: createdProfile.Username,
+		Team:     createdProfile.Team,
+	})
+	ps.publishEvent(ctx, models.EventTeamAssigned, createdProfile.UUID, models.TeamAssignedData{Team: createdProfile.Team})
+
 	api.WriteJSON(w, http.StatusCreated, createdProfile) // 201 Created
-	log.Printf("Player profile %s created successfully and profile returned.", createdProfile.UUID)
+	api.Logger(ctx).Info("Created player profile", "uuid", createdProfile.UUID)
 }
 
 // GetProfileHandler handles requests to retrieve a player profile by UUID.
@@ -80,7 +104,7 @@ func (ps *PlayerService) GetProfileHandler(w http.ResponseWriter, r *http.Reques
 			api.WriteError(w, http.StatusNotFound, fmt.Sprintf("Player profile with UUID %s not found", uuid))
 			return
 		}
-		log.Printf("Error getting player profile %s: %v", uuid, err)
+		api.Logger(ctx).Error("Failed to get player profile", "uuid", uuid, "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve player profile: "+err.Error())
 		return
 	}
@@ -88,21 +112,51 @@ func (ps *PlayerService) GetProfileHandler(w http.ResponseWriter, r *http.Reques
 	// It's generally a good practice to update the last login on a successful retrieval
 	// or specific login event, rather than every GET. For now, keeping it here.
 	go func() {
-		updateCtx, updateCancel := context.WithTimeout(context.Background(), 2*time.Second)
+		updateCtx, updateCancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
 		defer updateCancel()
 		if err := ps.store.UpdateProfileLastLogin(updateCtx, uuid); err != nil {
-			log.Printf("WARN: Failed to update last login for player profile %s: %v", uuid, err)
+			api.Logger(updateCtx).Warn("Failed to update last login", "uuid", uuid, "error", err)
 		}
 	}()
 
 	api.WriteJSON(w, http.StatusOK, profile)
-	log.Printf("Player profile %s retrieved successfully.", profile.UUID)
+	api.Logger(ctx).Info("Retrieved player profile", "uuid", profile.UUID)
+}
+
+// maxProfilePlaytimePage bounds the page size of ListProfilePlaytimesHandler.
+const maxProfilePlaytimePage = 1000
+
+// ListProfilePlaytimesHandler pages through every profile's team and total playtime, ordered by UUID.
+// GET /profiles/playtime?after={uuid}&limit={n}
+func (ps *PlayerService) ListProfilePlaytimesHandler(w http.ResponseWriter, r *http.Request) {
+	limit := int64(maxProfilePlaytimePage)
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxProfilePlaytimePage {
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxProfilePlaytimePage))
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	profiles, err := ps.store.ListProfilePlaytimes(ctx, r.URL.Query().Get("after"), limit)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to list player playtimes", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to list player playtimes: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, profiles)
 }
 
 // UpdateProfilePlaytimeHandler handles requests to update a player's playtime.
 // PUT /profiles/{uuid}/playtime
 type UpdatePlaytimeRequest struct {
-	TicksToSet float64 `json:"ticksToSet"`
+	TicksToSet  float64 `json:"ticksToSet"`
+	SeasonEpoch int64   `json:"seasonEpoch"` // Season epoch the total belongs to
 }
 
 func (ps *PlayerService) UpdateProfilePlaytimeHandler(w http.ResponseWriter, r *http.Request) {
@@ -122,8 +176,12 @@ func (ps *PlayerService) UpdateProfilePlaytimeHandler(w http.ResponseWriter, r *
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	err := ps.store.UpdateProfilePlaytime(ctx, uuid, req.TicksToSet)
+	err := ps.store.UpdateProfilePlaytime(ctx, uuid, req.TicksToSet, req.SeasonEpoch)
 	if err != nil {
+		if errors.Is(err, ErrStaleSeasonEpoch) {
+			api.WriteError(w, http.StatusConflict, "Player profile has been reset for a later season")
+			return
+		}
 		if err.Error() == fmt.Sprintf("player profile %s not found for playtime update", uuid) {
 			api.WriteError(w, http.StatusNotFound, "Player profile not found")
 			return
@@ -135,6 +193,69 @@ func (ps *PlayerService) UpdateProfilePlaytimeHandler(w http.ResponseWriter, r *
 	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("Playtime updated for player profile %s", uuid)})
 }
 
+// BulkUpdatePlaytimeRequest carries total playtimes for many players at once.
+// PUT /profiles/playtime
+type BulkUpdatePlaytimeRequest struct {
+	Updates []PlaytimeUpdate `json:"updates"`
+}
+
+// PlaytimeUpdate is a single player's total playtime, and optionally AFK time, in a bulk update.
+type PlaytimeUpdate struct {
+	UUID          string   `json:"uuid"`
+	TicksToSet    float64  `json:"ticksToSet"`
+	AFKTicksToSet *float64 `json:"afkTicksToSet,omitempty"` // Nil leaves the stored AFK time unchanged
+	SeasonEpoch   int64    `json:"seasonEpoch"`             // Season epoch the total belongs to
+}
+
+// BulkUpdatePlaytimeResponse reports how many of the profiles were updated: unknown UUIDs, and
+// profiles reset for a later season than their update's, are not.
+type BulkUpdatePlaytimeResponse struct {
+	Requested int   `json:"requested"`
+	Matched   int64 `json:"matched"`
+}
+
+// maxBulkPlaytimeUpdates bounds the size of a single bulk playtime update.
+const maxBulkPlaytimeUpdates = 1000
+
+// BulkUpdateProfilePlaytimeHandler handles requests to set the total playtime of many players.
+func (ps *PlayerService) BulkUpdateProfilePlaytimeHandler(w http.ResponseWriter, r *http.Request) {
+	var req BulkUpdatePlaytimeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Updates) > maxBulkPlaytimeUpdates {
+		api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("At most %d updates are allowed per request", maxBulkPlaytimeUpdates))
+		return
+	}
+
+	playtimes := make(map[string]float64, len(req.Updates))
+	afkTicks := make(map[string]float64)
+	epochs := make(map[string]int64, len(req.Updates))
+	for _, update := range req.Updates {
+		if update.UUID == "" {
+			api.WriteError(w, http.StatusBadRequest, "Player UUID is required for every update")
+			return
+		}
+		playtimes[update.UUID] = update.TicksToSet
+		epochs[update.UUID] = update.SeasonEpoch
+		if update.AFKTicksToSet != nil {
+			afkTicks[update.UUID] = *update.AFKTicksToSet
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	matched, err := ps.store.BulkUpdateProfilePlaytime(ctx, playtimes, afkTicks, epochs)
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update playtime: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, BulkUpdatePlaytimeResponse{Requested: len(playtimes), Matched: matched})
+}
+
 // UpdateProfileDeltaPlaytimeHandler handles requests to update a player's delta playtime.
 // PUT /profiles/{uuid}/deltaplaytime
 type UpdateDeltaPlaytimeRequest struct {
@@ -171,14 +292,13 @@ func (ps *PlayerService) UpdateProfileDeltaPlaytimeHandler(w http.ResponseWriter
 	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("Delta playtime updated for player profile %s", uuid)})
 }
 
-// UpdateProfileBanStatusHandler handles requests to update a player's ban status.
-// PUT /profiles/{uuid}/ban
-type UpdateBanStatusRequest struct {
-	Banned       bool       `json:"banned"`
-	BanExpiresAt *time.Time `json:"banExpiresAt"`
+// UpdateProfileAFKTicksHandler handles requests to update the total ticks a player has spent AFK.
+// PUT /profiles/{uuid}/afk
+type UpdateAFKTicksRequest struct {
+	TicksToSet float64 `json:"ticksToSet"`
 }
 
-func (ps *PlayerService) UpdateProfileBanStatusHandler(w http.ResponseWriter, r *http.Request) {
+func (ps *PlayerService) UpdateProfileAFKTicksHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	uuid := vars["uuid"]
 	if uuid == "" {
@@ -186,7 +306,7 @@ func (ps *PlayerService) UpdateProfileBanStatusHandler(w http.ResponseWriter, r
 		return
 	}
 
-	var req UpdateBanStatusRequest
+	var req UpdateAFKTicksRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
 		return
@@ -195,17 +315,17 @@ func (ps *PlayerService) UpdateProfileBanStatusHandler(w http.ResponseWriter, r
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	err := ps.store.UpdateProfileBanStatus(ctx, uuid, req.Banned, req.BanExpiresAt)
+	err := ps.store.UpdateProfileAFKTicks(ctx, uuid, req.TicksToSet)
 	if err != nil {
-		if err.Error() == fmt.Sprintf("player profile %s not found for ban status update", uuid) {
+		if err.Error() == fmt.Sprintf("player profile %s not found for AFK ticks update", uuid) {
 			api.WriteError(w, http.StatusNotFound, "Player profile not found")
 			return
 		}
-		api.WriteError(w, http.StatusInternalServerError, "Failed to update ban status: "+err.Error())
+		api.WriteError(w, http.StatusInternalServerError, "Failed to update AFK ticks: "+err.Error())
 		return
 	}
 
-	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("Ban status updated for player profile %s", uuid)})
+	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("AFK ticks updated for player profile %s", uuid)})
 }
 
 // UpdateProfileLastLoginHandler handles requests to update only a player's last login timestamp.
@@ -233,3 +353,114 @@ func (ps *PlayerService) UpdateProfileLastLoginHandler(w http.ResponseWriter, r
 
 	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("Last login updated for player profile %s", uuid)})
 }
+
+// GrantBoosterHandler handles requests to grant a booster to a player.
+// POST /profiles/{uuid}/boosters
+type GrantBoosterRequest struct {
+	Type            string  `json:"type"`
+	Value           float64 `json:"value"`
+	DurationSeconds int64   `json:"durationSeconds"` // 0 for a booster that never expires
+	Source          string  `json:"source"`
+}
+
+func (ps *PlayerService) GrantBoosterHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uuid := vars["uuid"]
+	if uuid == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID is required")
+		return
+	}
+
+	var req GrantBoosterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Type == "" {
+		api.WriteError(w, http.StatusBadRequest, "Booster type is required")
+		return
+	}
+	if req.Value <= 0 {
+		api.WriteError(w, http.StatusBadRequest, "Booster value must be positive")
+		return
+	}
+	if req.DurationSeconds < 0 {
+		api.WriteError(w, http.StatusBadRequest, "Booster duration cannot be negative")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.DurationSeconds > 0 {
+		t := time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+		expiresAt = &t
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	booster, err := ps.store.AddBooster(ctx, uuid, req.Type, req.Value, expiresAt, req.Source)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("player profile %s not found for booster grant", uuid) {
+			api.WriteError(w, http.StatusNotFound, "Player profile not found")
+			return
+		}
+		api.WriteError(w, http.StatusInternalServerError, "Failed to grant booster: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusCreated, booster)
+}
+
+// RevokeBoosterHandler handles requests to revoke a booster from a player.
+// DELETE /profiles/{uuid}/boosters/{boosterID}
+func (ps *PlayerService) RevokeBoosterHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uuid := vars["uuid"]
+	boosterID := vars["boosterID"]
+	if uuid == "" || boosterID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID and booster ID are required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err := ps.store.RemoveBooster(ctx, uuid, boosterID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("booster %s not found for player profile %s", boosterID, uuid) {
+			api.WriteError(w, http.StatusNotFound, "Booster not found")
+			return
+		}
+		api.WriteError(w, http.StatusInternalServerError, "Failed to revoke booster: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("Booster %s revoked for player profile %s", boosterID, uuid)})
+}
+
+// GetBoostersHandler handles requests to list a player's active boosters.
+// GET /profiles/{uuid}/boosters
+func (ps *PlayerService) GetBoostersHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uuid := vars["uuid"]
+	if uuid == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	boosters, err := ps.store.GetActiveBoosters(ctx, uuid)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			api.WriteError(w, http.StatusNotFound, fmt.Sprintf("Player profile with UUID %s not found", uuid))
+			return
+		}
+		api.Logger(ctx).Error("Failed to get boosters", "uuid", uuid, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve boosters: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, boosters)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/gorilla/mux"
+)
+
+// PunishmentService handles issuing, revoking and listing player punishments.
+type PunishmentService struct {
+	store *PunishmentStore
+}
+
+// NewPunishmentService creates a new PunishmentService instance.
+func NewPunishmentService(store *PunishmentStore) *PunishmentService {
+	return &PunishmentService{
+		store: store,
+	}
+}
+
+// IssuePunishmentRequest is the request body for IssuePunishmentHandler.
+type IssuePunishmentRequest struct {
+	Type            string `json:"type"`
+	Reason          string `json:"reason"`
+	IssuedBy        string `json:"issuedBy"`
+	DurationSeconds int64  `json:"durationSeconds"` // 0 for a permanent punishment
+}
+
+// RevokePunishmentsRequest is the request body for RevokePunishmentsHandler.
+type RevokePunishmentsRequest struct {
+	Type      string `json:"type"`
+	RevokedBy string `json:"revokedBy"`
+	Reason    string `json:"reason"`
+}
+
+// RevokePunishmentsResponse reports how many active punishments were revoked.
+type RevokePunishmentsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// isPunishmentType reports whether t is one of the models.PunishmentType* constants.
+func isPunishmentType(t string) bool {
+	switch t {
+	case models.PunishmentTypeBan, models.PunishmentTypeMute, models.PunishmentTypeWarn, models.PunishmentTypeKick:
+		return true
+	default:
+		return false
+	}
+}
+
+// IssuePunishmentHandler records a new punishment for a player.
+// POST /profiles/{uuid}/punishments
+func (ps *PunishmentService) IssuePunishmentHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	if uuid == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID is required")
+		return
+	}
+
+	var req IssuePunishmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !isPunishmentType(req.Type) {
+		api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Unknown punishment type %q", req.Type))
+		return
+	}
+	if req.IssuedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Issuer is required")
+		return
+	}
+	if req.DurationSeconds < 0 {
+		api.WriteError(w, http.StatusBadRequest, "Punishment duration cannot be negative")
+		return
+	}
+
+	punishment := &models.Punishment{UUID: uuid, Type: req.Type, Reason: req.Reason, IssuedBy: req.IssuedBy}
+	if req.DurationSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+		punishment.ExpiresAt = &expiresAt
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := ps.store.IssuePunishment(ctx, punishment); err != nil {
+		api.Logger(ctx).Error("Failed to issue punishment", "uuid", uuid, "type", req.Type, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to issue punishment: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusCreated, punishment)
+}
+
+// RevokePunishmentsHandler lifts a player's active punishments of a type.
+// POST /profiles/{uuid}/punishments/revoke
+func (ps *PunishmentService) RevokePunishmentsHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	if uuid == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID is required")
+		return
+	}
+
+	var req RevokePunishmentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !isPunishmentType(req.Type) {
+		api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Unknown punishment type %q", req.Type))
+		return
+	}
+	if req.RevokedBy == "" {
+		api.WriteError(w, http.StatusBadRequest, "Revoker is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := ps.store.RevokePunishments(ctx, uuid, req.Type, req.RevokedBy, req.Reason)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to revoke punishments", "uuid", uuid, "type", req.Type, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to revoke punishments: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, RevokePunishmentsResponse{Revoked: revoked})
+}
+
+// ListPunishmentsHandler lists a player's punishment history, newest first.
+// GET /profiles/{uuid}/punishments?type=ban&active=true
+// type narrows the list to one punishment type; active=true leaves out expired and revoked punishments.
+func (ps *PunishmentService) ListPunishmentsHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	if uuid == "" {
+		api.WriteError(w, http.StatusBadRequest, "Player UUID is required")
+		return
+	}
+
+	query := r.URL.Query()
+	punishmentType := query.Get("type")
+	if punishmentType != "" && !isPunishmentType(punishmentType) {
+		api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Unknown punishment type %q", punishmentType))
+		return
+	}
+	activeOnly := false
+	if raw := query.Get("active"); raw != "" {
+		var err error
+		activeOnly, err = strconv.ParseBool(raw)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "active must be true or false")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	punishments, err := ps.store.ListPunishments(ctx, uuid, punishmentType, activeOnly)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to list punishments", "uuid", uuid, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to list punishments: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, punishments)
+}
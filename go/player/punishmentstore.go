@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
+)
+
+// PunishmentStore represents the MongoDB data store for players' punishment history.
+// It also keeps the ban fields of player profiles in step with the active bans.
+type PunishmentStore struct {
+	collection  *mongo.Collection
+	playerStore *PlayerStore
+}
+
+// NewPunishmentStore creates a new PunishmentStore instance.
+func NewPunishmentStore(client *mongo.Client, databaseName, collectionName string, playerStore *PlayerStore) *PunishmentStore {
+	return &PunishmentStore{
+		collection:  client.Database(databaseName).Collection(collectionName),
+		playerStore: playerStore,
+	}
+}
+
+// EnsureIndexes creates the index used to read a player's history, newest first.
+func (ps *PunishmentStore) EnsureIndexes(ctx context.Context) error {
+	_, err := ps.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "uuid", Value: 1}, {Key: "issued_at", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create punishment indexes: %w", err)
+	}
+	return nil
+}
+
+// punishmentFilter matches a player's punishments of a type (every type if empty),
+// or only those in force at now if activeOnly is set.
+func punishmentFilter(uuid, punishmentType string, activeOnly bool, now time.Time) bson.M {
+	filter := bson.M{"uuid": uuid}
+	if punishmentType != "" {
+		filter["type"] = punishmentType
+	}
+	if activeOnly {
+		filter["revoked_at"] = nil
+		filter["$or"] = bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": now}}}
+	}
+	return filter
+}
+
+// IssuePunishment records a new punishment. Its ID and issue time are set here, and a kick
+// expires as it is issued. Issuing a ban also updates the player's profile, if they have one.
+func (ps *PunishmentStore) IssuePunishment(ctx context.Context, punishment *models.Punishment) error {
+	punishment.ID = primitive.NewObjectID().Hex()
+	punishment.IssuedAt = time.Now()
+	if punishment.Type == models.PunishmentTypeKick {
+		punishment.ExpiresAt = &punishment.IssuedAt
+	}
+	punishment.RevokedAt = nil
+	punishment.RevokedBy = ""
+	punishment.RevokeReason = ""
+
+	if punishment.Type == models.PunishmentTypeBan {
+		// Record a ban set before punishment history was kept, so a shorter one can't replace it
+		if err := ps.backfillLegacyBan(ctx, punishment.UUID); err != nil {
+			return err
+		}
+	}
+	if _, err := ps.collection.InsertOne(ctx, punishment); err != nil {
+		return fmt.Errorf("failed to record %s for player %s: %w", punishment.Type, punishment.UUID, err)
+	}
+	api.Logger(ctx).Info("Recorded punishment", "uuid", punishment.UUID, "type", punishment.Type, "punishment_id", punishment.ID, "issued_by", punishment.IssuedBy, "expires_at", punishment.ExpiresAt, "reason", punishment.Reason)
+
+	if punishment.Type == models.PunishmentTypeBan {
+		ps.syncBanStatus(ctx, punishment.UUID)
+	}
+	return nil
+}
+
+// RevokePunishments lifts every active punishment of a type for a player and returns how many were revoked.
+// Revoking bans also updates the player's profile, if they have one.
+func (ps *PunishmentStore) RevokePunishments(ctx context.Context, uuid, punishmentType, revokedBy, reason string) (int64, error) {
+	syncBans := punishmentType == "" || punishmentType == models.PunishmentTypeBan
+	if syncBans {
+		// Record a ban set before punishment history was kept, so it is revoked with the rest
+		if err := ps.backfillLegacyBan(ctx, uuid); err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"revoked_at": now, "revoked_by": revokedBy, "revoke_reason": reason}}
+
+	result, err := ps.collection.UpdateMany(ctx, punishmentFilter(uuid, punishmentType, true, now), update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke %s punishments for player %s: %w", punishmentType, uuid, err)
+	}
+	api.Logger(ctx).Info("Revoked active punishments", "uuid", uuid, "type", punishmentType, "revoked_by", revokedBy, "count", result.ModifiedCount)
+
+	if syncBans {
+		ps.syncBanStatus(ctx, uuid)
+	}
+	return result.ModifiedCount, nil
+}
+
+// ListPunishments returns a player's punishments, newest first. An empty punishmentType lists every type;
+// activeOnly leaves out punishments that have expired or been revoked.
+func (ps *PunishmentStore) ListPunishments(ctx context.Context, uuid, punishmentType string, activeOnly bool) ([]models.Punishment, error) {
+	filter := punishmentFilter(uuid, punishmentType, activeOnly, time.Now())
+	opts := options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}})
+	cursor, err := ps.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list punishments for player %s: %w", uuid, err)
+	}
+	defer cursor.Close(ctx)
+
+	punishments := []models.Punishment{}
+	if err := cursor.All(ctx, &punishments); err != nil {
+		return nil, fmt.Errorf("failed to decode punishments for player %s: %w", uuid, err)
+	}
+	return punishments, nil
+}
+
+// maxBanSyncAttempts bounds how often syncBanStatus retries after losing a race with another sync.
+const maxBanSyncAttempts = 5
+
+// syncBanStatus derives a player profile's ban fields from their active bans: banned while any is
+// active, until the latest expiry (nil if any is permanent). The profile is only updated if no other
+// sync has updated it since its ban version was read, so concurrent syncs can't store a stale result;
+// the loser reads the bans again. A failure is logged rather than returned, since the punishment
+// itself is already recorded; a player without a profile has nothing to update.
+func (ps *PunishmentStore) syncBanStatus(ctx context.Context, uuid string) {
+	for attempt := 0; attempt < maxBanSyncAttempts; attempt++ {
+		profile, err := ps.playerStore.GetProfileByUUID(ctx, uuid)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		}
+		if err != nil {
+			api.Logger(ctx).Warn("Failed to read profile to update its ban status", "uuid", uuid, "error", err)
+			return
+		}
+		bans, err := ps.ListPunishments(ctx, uuid, models.PunishmentTypeBan, true)
+		if err != nil {
+			api.Logger(ctx).Warn("Failed to read active bans to update profile", "uuid", uuid, "error", err)
+			return
+		}
+
+		var expiresAt *time.Time
+		for _, ban := range bans {
+			if ban.ExpiresAt == nil {
+				expiresAt = nil
+				break
+			}
+			if expiresAt == nil || ban.ExpiresAt.After(*expiresAt) {
+				expiresAt = ban.ExpiresAt
+			}
+		}
+
+		updated, err := ps.playerStore.UpdateProfileBanStatus(ctx, uuid, len(bans) > 0, expiresAt, profile.BanVersion)
+		if err != nil {
+			api.Logger(ctx).Warn("Failed to update ban status of profile", "uuid", uuid, "error", err)
+			return
+		}
+		if updated {
+			return
+		}
+	}
+	api.Logger(ctx).Warn("Gave up updating ban status of profile after concurrent updates", "uuid", uuid, "attempts", maxBanSyncAttempts)
+}
+
+// backfillLegacyBan records a punishment for a ban that was set on a player's profile before
+// punishment history was kept, so it counts like any other ban from then on. Profiles whose ban
+// fields have been derived from history, and players without a profile, need nothing.
+// The record's ID is fixed per player, so backfilling twice is harmless.
+func (ps *PunishmentStore) backfillLegacyBan(ctx context.Context, uuid string) error {
+	profile, err := ps.playerStore.GetProfileByUUID(ctx, uuid)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profile %s to check for a legacy ban: %w", uuid, err)
+	}
+	now := time.Now()
+	if profile.BanVersion != 0 || !profile.Banned || (profile.BanExpiresAt != nil && !now.Before(*profile.BanExpiresAt)) {
+		return nil
+	}
+
+	legacy := models.Punishment{
+		ID:        "legacy-ban-" + uuid,
+		UUID:      uuid,
+		Type:      models.PunishmentTypeBan,
+		Reason:    "Banned before punishment history was kept",
+		IssuedBy:  "legacy",
+		IssuedAt:  now,
+		ExpiresAt: profile.BanExpiresAt,
+	}
+	if _, err := ps.collection.InsertOne(ctx, legacy); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to record legacy ban for player %s: %w", uuid, err)
+	}
+	api.Logger(ctx).Info("Recorded legacy ban", "uuid", uuid, "punishment_id", legacy.ID, "expires_at", legacy.ExpiresAt)
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Season standings page bounds.
+const (
+	defaultSeasonStandingsLimit = 100
+	maxSeasonStandingsLimit     = 1000
+)
+
+// SeasonService handles season CRUD, archival and historical standings.
+type SeasonService struct {
+	store *SeasonStore
+}
+
+// NewSeasonService creates a new SeasonService instance.
+func NewSeasonService(store *SeasonStore) *SeasonService {
+	return &SeasonService{
+		store: store,
+	}
+}
+
+// SeasonRequest is the request body for creating or updating a season.
+type SeasonRequest struct {
+	ID       string    `json:"id"` // Only used on creation
+	Name     string    `json:"name"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+}
+
+// SeasonStandingsResponse is the response body for SeasonStandingsHandler.
+type SeasonStandingsResponse struct {
+	Season  models.Season                 `json:"season"`
+	Total   int64                         `json:"total"` // Players in the (team's) standings
+	Players []models.SeasonPlayerStanding `json:"players"`
+}
+
+// writeSeasonError maps SeasonStore errors to HTTP responses.
+func writeSeasonError(ctx context.Context, w http.ResponseWriter, seasonID, action string, err error) {
+	switch {
+	case err == mongo.ErrNoDocuments:
+		api.WriteError(w, http.StatusNotFound, fmt.Sprintf("Season %s not found", seasonID))
+	case errors.Is(err, ErrSeasonConflict):
+		api.WriteError(w, http.StatusConflict, err.Error())
+	default:
+		api.Logger(ctx).Error("Season request failed", "action", action, "season_id", seasonID, "error", err)
+		api.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s season: %v", action, err))
+	}
+}
+
+// decodeSeasonRequest reads and validates a SeasonRequest, writing a 400 if it is invalid.
+func decodeSeasonRequest(w http.ResponseWriter, r *http.Request) (*SeasonRequest, bool) {
+	var req SeasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	if req.Name == "" {
+		api.WriteError(w, http.StatusBadRequest, "Season name is required")
+		return nil, false
+	}
+	if req.StartsAt.IsZero() || req.EndsAt.IsZero() {
+		api.WriteError(w, http.StatusBadRequest, "Season startsAt and endsAt are required")
+		return nil, false
+	}
+	if !req.StartsAt.Before(req.EndsAt) {
+		api.WriteError(w, http.StatusBadRequest, "Season must start before it ends")
+		return nil, false
+	}
+	req.StartsAt, req.EndsAt = req.StartsAt.UTC(), req.EndsAt.UTC()
+	return &req, true
+}
+
+// CreateSeasonHandler schedules a new season.
+// POST /seasons
+func (ss *SeasonService) CreateSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSeasonRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.ID == "" {
+		api.WriteError(w, http.StatusBadRequest, "Season ID is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	season := &models.Season{ID: req.ID, Name: req.Name, StartsAt: req.StartsAt, EndsAt: req.EndsAt}
+	if err := ss.store.CreateSeason(ctx, season); err != nil {
+		writeSeasonError(r.Context(), w, req.ID, "create", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusCreated, season)
+}
+
+// ListSeasonsHandler lists every season ordered by start time.
+// GET /seasons
+func (ss *SeasonService) ListSeasonsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	seasons, err := ss.store.ListSeasons(ctx)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to list seasons", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to list seasons: "+err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, seasons)
+}
+
+// GetCurrentSeasonHandler returns the season in progress, or 404 if there is none.
+// GET /seasons/current
+func (ss *SeasonService) GetCurrentSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	season, err := ss.store.GetCurrentSeason(ctx)
+	if err == mongo.ErrNoDocuments {
+		api.WriteError(w, http.StatusNotFound, "No season in progress")
+		return
+	}
+	if err != nil {
+		api.Logger(ctx).Error("Failed to get current season", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to retrieve current season: "+err.Error())
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, season)
+}
+
+// GetSeasonHandler returns a season, including its final team standings once archived.
+// GET /seasons/{id}
+func (ss *SeasonService) GetSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	seasonID := mux.Vars(r)["id"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	season, err := ss.store.GetSeason(ctx, seasonID)
+	if err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "retrieve", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, season)
+}
+
+// UpdateSeasonHandler changes a season's name and schedule.
+// PUT /seasons/{id}
+func (ss *SeasonService) UpdateSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	seasonID := mux.Vars(r)["id"]
+	req, ok := decodeSeasonRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	season, err := ss.store.UpdateSeason(ctx, seasonID, req.Name, req.StartsAt, req.EndsAt)
+	if err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "update", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, season)
+}
+
+// DeleteSeasonHandler removes a season that has not started.
+// DELETE /seasons/{id}
+func (ss *SeasonService) DeleteSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	seasonID := mux.Vars(r)["id"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := ss.store.DeleteSeason(ctx, seasonID); err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "delete", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("Season %s deleted", seasonID)})
+}
+
+// ActivateSeasonHandler starts a scheduled season.
+// POST /seasons/{id}/activate
+func (ss *SeasonService) ActivateSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	seasonID := mux.Vars(r)["id"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	season, err := ss.store.ActivateSeason(ctx, seasonID)
+	if err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "activate", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, season)
+}
+
+// ArchiveSeasonHandler archives a season's standings and resets the live totals in MongoDB.
+// The game service calls this during a season rollover, with playtime accrual frozen.
+// POST /seasons/{id}/archive
+func (ss *SeasonService) ArchiveSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	seasonID := mux.Vars(r)["id"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute) // Copies every profile
+	defer cancel()
+
+	season, err := ss.store.ArchiveSeason(ctx, seasonID)
+	if err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "archive", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, season)
+}
+
+// SeasonStandingsHandler returns a page of an archived season's player standings.
+// GET /seasons/{id}/standings?team={team}&offset={n}&limit={n}
+func (ss *SeasonService) SeasonStandingsHandler(w http.ResponseWriter, r *http.Request) {
+	seasonID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	var offset int64
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsed, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || parsed < 0 {
+			api.WriteError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		offset = parsed
+	}
+	limit := int64(defaultSeasonStandingsLimit)
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxSeasonStandingsLimit {
+			api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSeasonStandingsLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	season, err := ss.store.GetSeason(ctx, seasonID)
+	if err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "retrieve", err)
+		return
+	}
+	if season.Status != models.SeasonStatusArchived {
+		api.WriteError(w, http.StatusConflict, fmt.Sprintf("Season %s is %s; standings are available once it is archived", seasonID, season.Status))
+		return
+	}
+
+	players, total, err := ss.store.GetPlayerStandings(ctx, seasonID, query.Get("team"), offset, limit)
+	if err != nil {
+		writeSeasonError(r.Context(), w, seasonID, "retrieve standings for", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, SeasonStandingsResponse{Season: *season, Total: total, Players: players})
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
+)
+
+// ErrSeasonConflict is returned when a season change clashes with another season or with the season's status.
+var ErrSeasonConflict = errors.New("season conflict")
+
+// inProgressSeasonStatuses are the statuses of a season that has started but is not yet fully archived.
+// At most one season is in progress at a time.
+var inProgressSeasonStatuses = []string{models.SeasonStatusActive, models.SeasonStatusArchiving, models.SeasonStatusResetting}
+
+// resetBatchSize is how many player profiles resetLiveTotals resets per update.
+const resetBatchSize = 1000
+
+// SeasonStore represents the MongoDB data store for seasons and their archived standings.
+type SeasonStore struct {
+	seasons     *mongo.Collection
+	standings   *mongo.Collection // One document per player per archived season
+	playerStore *PlayerStore
+	teamStore   *TeamStore
+}
+
+// NewSeasonStore creates a new SeasonStore instance.
+func NewSeasonStore(client *mongo.Client, databaseName, seasonsCollection, standingsCollection string, playerStore *PlayerStore, teamStore *TeamStore) *SeasonStore {
+	db := client.Database(databaseName)
+	return &SeasonStore{
+		seasons:     db.Collection(seasonsCollection),
+		standings:   db.Collection(standingsCollection),
+		playerStore: playerStore,
+		teamStore:   teamStore,
+	}
+}
+
+// EnsureIndexes creates the indexes used to rank a season's players, overall and per team.
+func (ss *SeasonStore) EnsureIndexes(ctx context.Context) error {
+	_, err := ss.standings.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "season_id", Value: 1}, {Key: "total_playtime_ticks", Value: -1}}},
+		{Keys: bson.D{{Key: "season_id", Value: 1}, {Key: "team", Value: 1}, {Key: "total_playtime_ticks", Value: -1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create season standings indexes: %w", err)
+	}
+	return nil
+}
+
+// CreateSeason inserts a new scheduled season. It returns ErrSeasonConflict if the ID is taken
+// or the season overlaps a season that is not yet archived.
+func (ss *SeasonStore) CreateSeason(ctx context.Context, season *models.Season) error {
+	if err := ss.checkOverlap(ctx, season.ID, season.StartsAt, season.EndsAt); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	season.Status = models.SeasonStatusScheduled
+	season.CreatedAt = &now
+	season.Teams = nil
+	season.ArchivedAt = nil
+	if _, err := ss.seasons.InsertOne(ctx, season); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%w: season %s already exists", ErrSeasonConflict, season.ID)
+		}
+		return fmt.Errorf("failed to create season %s: %w", season.ID, err)
+	}
+	api.Logger(ctx).Info("Created season", "season_id", season.ID, "name", season.Name, "starts_at", season.StartsAt, "ends_at", season.EndsAt)
+	return nil
+}
+
+// checkOverlap returns ErrSeasonConflict if [startsAt, endsAt) overlaps another season that is not yet archived.
+func (ss *SeasonStore) checkOverlap(ctx context.Context, seasonID string, startsAt, endsAt time.Time) error {
+	filter := bson.M{
+		"_id":       bson.M{"$ne": seasonID},
+		"status":    bson.M{"$ne": models.SeasonStatusArchived},
+		"starts_at": bson.M{"$lt": endsAt},
+		"ends_at":   bson.M{"$gt": startsAt},
+	}
+	var other models.Season
+	err := ss.seasons.FindOne(ctx, filter).Decode(&other)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check season overlap: %w", err)
+	}
+	return fmt.Errorf("%w: overlaps season %s (%v to %v)", ErrSeasonConflict, other.ID, other.StartsAt, other.EndsAt)
+}
+
+// ListSeasons returns every season ordered by start time.
+func (ss *SeasonStore) ListSeasons(ctx context.Context) ([]models.Season, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}})
+	cursor, err := ss.seasons.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	seasons := []models.Season{}
+	if err := cursor.All(ctx, &seasons); err != nil {
+		return nil, fmt.Errorf("failed to decode seasons: %w", err)
+	}
+	return seasons, nil
+}
+
+// GetSeason retrieves a season by ID.
+// Returns mongo.ErrNoDocuments if the season is not found.
+func (ss *SeasonStore) GetSeason(ctx context.Context, seasonID string) (*models.Season, error) {
+	var season models.Season
+	if err := ss.seasons.FindOne(ctx, bson.M{"_id": seasonID}).Decode(&season); err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// GetCurrentSeason retrieves the season in progress: active, or ended but not yet fully archived.
+// Returns mongo.ErrNoDocuments if no season is in progress.
+func (ss *SeasonStore) GetCurrentSeason(ctx context.Context) (*models.Season, error) {
+	var season models.Season
+	if err := ss.seasons.FindOne(ctx, bson.M{"status": bson.M{"$in": inProgressSeasonStatuses}}).Decode(&season); err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// UpdateSeason changes a season's name and schedule. A scheduled season may change all three;
+// an active season only its name and end. Returns mongo.ErrNoDocuments if the season is not found,
+// or ErrSeasonConflict if the change is not allowed.
+func (ss *SeasonStore) UpdateSeason(ctx context.Context, seasonID, name string, startsAt, endsAt time.Time) (*models.Season, error) {
+	season, err := ss.GetSeason(ctx, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	switch season.Status {
+	case models.SeasonStatusScheduled:
+	case models.SeasonStatusActive:
+		if !startsAt.Equal(season.StartsAt) {
+			return nil, fmt.Errorf("%w: season %s has already started", ErrSeasonConflict, seasonID)
+		}
+	default:
+		return nil, fmt.Errorf("%w: season %s has ended", ErrSeasonConflict, seasonID)
+	}
+	if err := ss.checkOverlap(ctx, seasonID, startsAt, endsAt); err != nil {
+		return nil, err
+	}
+
+	// Matching on the status read above keeps a concurrent activation or archival from being overwritten
+	filter := bson.M{"_id": seasonID, "status": season.Status}
+	update := bson.M{"$set": bson.M{"name": name, "starts_at": startsAt, "ends_at": endsAt}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.Season
+	if err := ss.seasons.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: season %s changed status during the update", ErrSeasonConflict, seasonID)
+		}
+		return nil, fmt.Errorf("failed to update season %s: %w", seasonID, err)
+	}
+	api.Logger(ctx).Info("Updated season", "season_id", seasonID, "name", name, "starts_at", startsAt, "ends_at", endsAt)
+	return &updated, nil
+}
+
+// DeleteSeason removes a season that has not started. Returns mongo.ErrNoDocuments if the season
+// is not found, or ErrSeasonConflict if it has started.
+func (ss *SeasonStore) DeleteSeason(ctx context.Context, seasonID string) error {
+	result, err := ss.seasons.DeleteOne(ctx, bson.M{"_id": seasonID, "status": models.SeasonStatusScheduled})
+	if err != nil {
+		return fmt.Errorf("failed to delete season %s: %w", seasonID, err)
+	}
+	if result.DeletedCount == 0 {
+		if _, err := ss.GetSeason(ctx, seasonID); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: season %s has already started", ErrSeasonConflict, seasonID)
+	}
+	api.Logger(ctx).Info("Deleted season", "season_id", seasonID)
+	return nil
+}
+
+// ActivateSeason starts a scheduled season. Returns mongo.ErrNoDocuments if the season is not found,
+// or ErrSeasonConflict if it is not scheduled or another season is still in progress.
+func (ss *SeasonStore) ActivateSeason(ctx context.Context, seasonID string) (*models.Season, error) {
+	current, err := ss.GetCurrentSeason(ctx)
+	if err == nil {
+		return nil, fmt.Errorf("%w: season %s is still %s", ErrSeasonConflict, current.ID, current.Status)
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to check current season: %w", err)
+	}
+
+	filter := bson.M{"_id": seasonID, "status": models.SeasonStatusScheduled}
+	update := bson.M{"$set": bson.M{"status": models.SeasonStatusActive}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var season models.Season
+	if err := ss.seasons.FindOneAndUpdate(ctx, filter, update, opts).Decode(&season); err != nil {
+		if err == mongo.ErrNoDocuments {
+			if _, getErr := ss.GetSeason(ctx, seasonID); getErr != nil {
+				return nil, getErr
+			}
+			return nil, fmt.Errorf("%w: season %s is not scheduled", ErrSeasonConflict, seasonID)
+		}
+		return nil, fmt.Errorf("failed to activate season %s: %w", seasonID, err)
+	}
+	api.Logger(ctx).Info("Activated season", "season_id", season.ID, "name", season.Name)
+	return &season, nil
+}
+
+// ArchiveSeason ends a season: it copies every player's and team's total into the season's
+// standings, then resets the live totals. Each step is recorded in the season's status, so a
+// failed or interrupted archival can simply be retried; archiving an archived season is a no-op.
+// The caller must stop playtime from being written while this runs (the game service freezes
+// accrual for the rollover), since totals written in between would be lost from the standings.
+//
+// Moving to resetting gives the season the next season epoch. Live totals are reset in batches,
+// each stamping the profiles it resets with that epoch, so an interrupted reset resumes where it
+// stopped, and a write from an earlier epoch that arrives late is rejected rather than restoring
+// an archived total (see PlayerStore.UpdateProfilePlaytime).
+// Returns mongo.ErrNoDocuments if the season is not found, or ErrSeasonConflict if it never started.
+func (ss *SeasonStore) ArchiveSeason(ctx context.Context, seasonID string) (*models.Season, error) {
+	season, err := ss.GetSeason(ctx, seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch season.Status {
+	case models.SeasonStatusScheduled:
+		return nil, fmt.Errorf("%w: season %s has not started", ErrSeasonConflict, seasonID)
+	case models.SeasonStatusArchived:
+		return season, nil
+	case models.SeasonStatusActive:
+		if err := ss.setSeasonStatus(ctx, seasonID, models.SeasonStatusActive, models.SeasonStatusArchiving, nil); err != nil {
+			return nil, err
+		}
+		fallthrough
+	case models.SeasonStatusArchiving:
+		teams, err := ss.archiveStandings(ctx, seasonID)
+		if err != nil {
+			return nil, err
+		}
+		epoch, err := ss.nextResetEpoch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := ss.setSeasonStatus(ctx, seasonID, models.SeasonStatusArchiving, models.SeasonStatusResetting, bson.M{"teams": teams, "reset_epoch": epoch}); err != nil {
+			return nil, err
+		}
+		season.ResetEpoch = epoch
+		fallthrough
+	case models.SeasonStatusResetting:
+		if season.ResetEpoch == 0 {
+			// The reset began before seasons had epochs
+			epoch, err := ss.nextResetEpoch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := ss.setSeasonStatus(ctx, seasonID, models.SeasonStatusResetting, models.SeasonStatusResetting, bson.M{"reset_epoch": epoch}); err != nil {
+				return nil, err
+			}
+			season.ResetEpoch = epoch
+		}
+		if err := ss.resetLiveTotals(ctx, seasonID, season.ResetEpoch); err != nil {
+			return nil, err
+		}
+	}
+
+	api.Logger(ctx).Info("Archived season", "season_id", seasonID)
+	return ss.GetSeason(ctx, seasonID)
+}
+
+// setSeasonStatus moves a season from one status to the next, setting any extra fields along the way.
+func (ss *SeasonStore) setSeasonStatus(ctx context.Context, seasonID, from, to string, fields bson.M) error {
+	set := bson.M{"status": to}
+	for k, v := range fields {
+		set[k] = v
+	}
+	result, err := ss.seasons.UpdateOne(ctx, bson.M{"_id": seasonID, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("failed to move season %s to %s: %w", seasonID, to, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: season %s is no longer %s", ErrSeasonConflict, seasonID, from)
+	}
+	return nil
+}
+
+// archiveStandings copies every player with playtime into the season's standings and returns
+// the team standings. Standings are keyed by season and player, so rerunning it overwrites
+// rather than duplicates.
+func (ss *SeasonStore) archiveStandings(ctx context.Context, seasonID string) ([]models.SeasonTeamStanding, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"total_playtime_ticks": bson.M{"$gt": 0}}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":                  bson.M{"$concat": bson.A{seasonID, ":", "$_id"}},
+			"season_id":            seasonID,
+			"uuid":                 "$_id",
+			"username":             "$username",
+			"team":                 "$team",
+			"total_playtime_ticks": "$total_playtime_ticks",
+		}}},
+		{{Key: "$merge", Value: bson.M{"into": ss.standings.Name(), "on": "_id", "whenMatched": "replace", "whenNotMatched": "insert"}}},
+	}
+	cursor, err := ss.playerStore.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive player standings for season %s: %w", seasonID, err)
+	}
+	cursor.Close(ctx)
+
+	pipeline = mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"team": bson.M{"$ne": ""}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":                  "$team",
+			"total_playtime_ticks": bson.M{"$sum": "$total_playtime_ticks"},
+			"player_count":         bson.M{"$sum": 1},
+		}}},
+	}
+	cursor, err = ss.playerStore.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate team standings for season %s: %w", seasonID, err)
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Team               string  `bson:"_id"`
+		TotalPlaytimeTicks float64 `bson:"total_playtime_ticks"`
+		PlayerCount        int64   `bson:"player_count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode team standings for season %s: %w", seasonID, err)
+	}
+
+	teams := make([]models.SeasonTeamStanding, 0, len(results))
+	for _, result := range results {
+		teams = append(teams, models.SeasonTeamStanding{
+			Team:               result.Team,
+			TotalPlaytimeTicks: result.TotalPlaytimeTicks,
+			PlayerCount:        result.PlayerCount,
+		})
+	}
+	sort.Slice(teams, func(i, j int) bool { return teams[i].TotalPlaytimeTicks > teams[j].TotalPlaytimeTicks })
+	for i := range teams {
+		teams[i].Rank = i + 1
+	}
+	return teams, nil
+}
+
+// nextResetEpoch returns the season epoch after the latest one any season has reset to.
+func (ss *SeasonStore) nextResetEpoch(ctx context.Context) (int64, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "reset_epoch", Value: -1}})
+	var latest models.Season
+	err := ss.seasons.FindOne(ctx, bson.M{"reset_epoch": bson.M{"$gt": 0}}, opts).Decode(&latest)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("failed to find latest season epoch: %w", err)
+	}
+	return latest.ResetEpoch + 1, nil
+}
+
+// resetLiveTotals zeroes every player's total playtime, batch by batch, stamping each profile with
+// epoch, then zeroes every team's total and marks the season archived. Profiles already stamped
+// with epoch are skipped, so rerunning it picks up where an interrupted reset stopped.
+func (ss *SeasonStore) resetLiveTotals(ctx context.Context, seasonID string, epoch int64) error {
+	var reset int64
+	pending := bson.M{"season_epoch": notAfterEpoch(epoch - 1)}
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(resetBatchSize)
+	for {
+		cursor, err := ss.playerStore.collection.Find(ctx, pending, opts)
+		if err != nil {
+			return fmt.Errorf("failed to find player profiles to reset for season %s: %w", seasonID, err)
+		}
+		var batch []struct {
+			UUID string `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &batch); err != nil {
+			return fmt.Errorf("failed to decode player profiles to reset for season %s: %w", seasonID, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		ids := make([]string, len(batch))
+		for i, p := range batch {
+			ids[i] = p.UUID
+		}
+		// Matching the epoch again leaves alone any profile a newer write has stamped in between
+		result, err := ss.playerStore.collection.UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": ids}, "season_epoch": notAfterEpoch(epoch - 1)},
+			bson.M{"$set": bson.M{"total_playtime_ticks": 0.0, "season_epoch": epoch}})
+		if err != nil {
+			return fmt.Errorf("failed to reset player playtime for season %s: %w", seasonID, err)
+		}
+		reset += result.ModifiedCount
+	}
+
+	now := time.Now()
+	if _, err := ss.teamStore.collection.UpdateMany(ctx, bson.M{},
+		bson.M{"$set": bson.M{"total_playtime_ticks": 0.0, "last_updated": now}}); err != nil {
+		return fmt.Errorf("failed to reset team playtime for season %s: %w", seasonID, err)
+	}
+	if err := ss.setSeasonStatus(ctx, seasonID, models.SeasonStatusResetting, models.SeasonStatusArchived, bson.M{"archived_at": now}); err != nil {
+		return err
+	}
+	api.Logger(ctx).Info("Reset live playtime of player profiles for the end of season", "season_id", seasonID, "profiles", reset, "season_epoch", epoch)
+	return nil
+}
+
+// GetPlayerStandings returns one page of a season's archived player standings, highest playtime first,
+// optionally for a single team, along with how many players the standings hold.
+func (ss *SeasonStore) GetPlayerStandings(ctx context.Context, seasonID, team string, offset, limit int64) ([]models.SeasonPlayerStanding, int64, error) {
+	filter := bson.M{"season_id": seasonID}
+	if team != "" {
+		filter["team"] = team
+	}
+
+	total, err := ss.standings.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count standings for season %s: %w", seasonID, err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "total_playtime_ticks", Value: -1}, {Key: "uuid", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	cursor, err := ss.standings.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find standings for season %s: %w", seasonID, err)
+	}
+	defer cursor.Close(ctx)
+
+	standings := []models.SeasonPlayerStanding{}
+	if err := cursor.All(ctx, &standings); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode standings for season %s: %w", seasonID, err)
+	}
+	for i := range standings {
+		standings[i].Rank = offset + int64(i) + 1
+	}
+	return standings, total, nil
+}
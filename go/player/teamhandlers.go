@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -15,15 +15,17 @@ import (
 
 // TeamService holds dependencies for HTTP handlers related to teams.
 type TeamService struct {
-	teamStore   *TeamStore
-	playerStore *PlayerStore
+	teamStore    *TeamStore
+	playerStore  *PlayerStore
+	historyStore *TeamHistoryStore
 }
 
 // NewTeamService creates a new TeamService instance.
-func NewTeamService(teamStore *TeamStore, playerStore *PlayerStore) *TeamService {
+func NewTeamService(teamStore *TeamStore, playerStore *PlayerStore, historyStore *TeamHistoryStore) *TeamService {
 	return &TeamService{
-		teamStore:   teamStore,
-		playerStore: playerStore,
+		teamStore:    teamStore,
+		playerStore:  playerStore,
+		historyStore: historyStore,
 	}
 }
 
@@ -40,7 +42,7 @@ func (ts *TeamService) SyncTeamTotalsHandler(w http.ResponseWriter, r *http.Requ
 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second) // Longer timeout for aggregation
 	defer cancel()
 
-	log.Println("Starting team total playtime aggregation job...")
+	api.Logger(ctx).Info("Starting team total playtime aggregation job")
 
 	// Explicit MongoDB Aggregation Pipeline using primitive.E for each element
 	pipeline := mongo.Pipeline{
@@ -56,7 +58,7 @@ func (ts *TeamService) SyncTeamTotalsHandler(w http.ResponseWriter, r *http.Requ
 
 	cursor, err := ts.playerStore.collection.Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Printf("Error running aggregation for team totals: %v", err)
+		api.Logger(ctx).Error("Failed to run aggregation for team totals", "error", err)
 		api.WriteError(w, http.StatusInternalServerError, "Failed to aggregate team totals: "+err.Error())
 		return
 	}
@@ -71,7 +73,7 @@ func (ts *TeamService) SyncTeamTotalsHandler(w http.ResponseWriter, r *http.Requ
 			CalculatedTotal float64 `bson:"calculatedTotal"`
 		}
 		if err := cursor.Decode(&result); err != nil {
-			log.Printf("Error decoding aggregation result: %v", err)
+			api.Logger(ctx).Error("Failed to decode aggregation result", "error", err)
 			continue // Log and continue for other teams
 		}
 
@@ -84,22 +86,99 @@ func (ts *TeamService) SyncTeamTotalsHandler(w http.ResponseWriter, r *http.Requ
 
 		_, err := ts.teamStore.collection.UpdateOne(ctx, filter, update, opts)
 		if err != nil {
-			log.Printf("ERROR: Failed to update total playtime for team %s in MongoDB: %v", result.TeamID, err)
+			api.Logger(ctx).Error("Failed to update team total playtime in MongoDB", "team", result.TeamID, "error", err)
 			// Decide if you want to stop or continue. For an aggregation job, often continue.
 		} else {
 			teamTotalsMap[result.TeamID] = result.CalculatedTotal
-			log.Printf("INFO: Successfully updated MongoDB total playtime for team '%s' to %.2f ticks.", result.TeamID, result.CalculatedTotal)
+			api.Logger(ctx).Info("Updated team total playtime in MongoDB", "team", result.TeamID, "total", result.CalculatedTotal)
 		}
 	}
 
 	if err := cursor.Err(); err != nil {
-		log.Printf("Error after aggregation cursor iteration: %v", err)
+		api.Logger(ctx).Error("Failed after aggregation cursor iteration", "error", err)
 		// This might be an error during cursor iteration, not necessarily the aggregation itself
 	}
 
-	log.Println("Team total playtime aggregation job finished.")
+	// Keep a record of how the totals evolve; the sync itself already succeeded
+	if err := ts.historyStore.RecordSnapshot(ctx, teamTotalsMap, time.Now()); err != nil {
+		api.Logger(ctx).Error("Failed to record team history snapshot", "error", err)
+	}
+
+	api.Logger(ctx).Info("Team total playtime aggregation job finished")
 	api.WriteJSON(w, http.StatusOK, SyncTeamTotalsResponse{
 		TeamTotals: teamTotalsMap,
 		Message:    "Team totals aggregated and updated in MongoDB successfully. Redis will be updated by the Game Service.",
 	})
 }
+
+// maxHistoryPoints bounds how many buckets per team a single history query may span.
+const maxHistoryPoints = 10000
+
+// TeamHistoryResponse defines the response body for TeamHistoryHandler.
+type TeamHistoryResponse struct {
+	Resolution string                        `json:"resolution"`
+	From       time.Time                     `json:"from"`
+	To         time.Time                     `json:"to"`
+	Teams      map[string][]TeamHistoryPoint `json:"teams"` // Map of teamID to points, oldest first
+}
+
+// TeamHistoryHandler returns team totals over a time range at minute, hour or day resolution.
+// GET /teams/history?team={team}&from={RFC3339}&to={RFC3339}&resolution={minute|hour|day}
+// Defaults: every team, the last 24 hours, hourly points.
+func (ts *TeamService) TeamHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	resolution := query.Get("resolution")
+	if resolution == "" {
+		resolution = "hour"
+	}
+	unit, ok := historyQueryUnits[resolution]
+	if !ok {
+		api.WriteError(w, http.StatusBadRequest, "resolution must be one of minute, hour or day")
+		return
+	}
+
+	to := time.Now().UTC()
+	if toStr := query.Get("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromStr := query.Get("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			api.WriteError(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		api.WriteError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from)/unit > maxHistoryPoints {
+		api.WriteError(w, http.StatusBadRequest, fmt.Sprintf("Range spans more than %d %s points; use a coarser resolution", maxHistoryPoints, resolution))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	series, err := ts.historyStore.QueryHistory(ctx, query.Get("team"), from, to, resolution)
+	if err != nil {
+		api.Logger(ctx).Error("Failed to query team history", "error", err)
+		api.WriteError(w, http.StatusInternalServerError, "Failed to query team history: "+err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, TeamHistoryResponse{
+		Resolution: resolution,
+		From:       from.UTC(),
+		To:         to.UTC(),
+		Teams:      series,
+	})
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+)
+
+// Resolutions of stored team history points. Raw points are written on every sync;
+// older points are downsampled to hourly and then daily points by DownsampleHistory.
+const (
+	HistoryResolutionRaw  = "raw"
+	HistoryResolutionHour = "hour"
+	HistoryResolutionDay  = "day"
+)
+
+// historyQueryUnits maps the resolutions accepted by QueryHistory to their bucket size.
+var historyQueryUnits = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// TeamHistoryPoint is a team's total playtime at a point in time.
+type TeamHistoryPoint struct {
+	Team               string     `bson:"team" json:"-"`
+	Timestamp          time.Time  `bson:"timestamp" json:"timestamp"`
+	TotalPlaytimeTicks float64    `bson:"total_playtime_ticks" json:"total_playtime_ticks"`
+	Resolution         string     `bson:"resolution" json:"-"`
+	SampledAt          *time.Time `bson:"sampled_at,omitempty" json:"-"` // When a downsampled point's total was recorded; nil for raw points
+}
+
+// historySampledAt is when a stored point's total was recorded: its timestamp for raw points, which
+// for downsampled points is the start of their bucket rather than when their total was recorded.
+var historySampledAt = bson.M{"$ifNull": bson.A{"$sampled_at", "$timestamp"}}
+
+// TeamHistoryStore stores snapshots of team totals, one document per team per point in time.
+type TeamHistoryStore struct {
+	collection *mongo.Collection
+}
+
+// NewTeamHistoryStore creates a new TeamHistoryStore instance.
+func NewTeamHistoryStore(client *mongo.Client, databaseName, collectionName string) *TeamHistoryStore {
+	collection := client.Database(databaseName).Collection(collectionName)
+	return &TeamHistoryStore{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes creates the index used by range queries, downsampling and downsampled point upserts.
+func (hs *TeamHistoryStore) EnsureIndexes(ctx context.Context) error {
+	_, err := hs.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "team", Value: 1}, {Key: "resolution", Value: 1}, {Key: "timestamp", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create team history index: %w", err)
+	}
+	return nil
+}
+
+// RecordSnapshot writes a raw history point for every team in totals.
+func (hs *TeamHistoryStore) RecordSnapshot(ctx context.Context, totals map[string]float64, at time.Time) error {
+	if len(totals) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(totals))
+	for team, total := range totals {
+		docs = append(docs, TeamHistoryPoint{Team: team, Timestamp: at.UTC(), TotalPlaytimeTicks: total, Resolution: HistoryResolutionRaw})
+	}
+	if _, err := hs.collection.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("failed to record team history snapshot: %w", err)
+	}
+	return nil
+}
+
+// QueryHistory returns each team's totals between from and to, one point per resolution bucket
+// ("minute", "hour" or "day"). Each bucket reports the last value recorded in it, even where a
+// season reset made totals fall.
+// An empty team returns every team. Where old points have been downsampled, buckets finer than the
+// stored resolution are simply absent.
+func (hs *TeamHistoryStore) QueryHistory(ctx context.Context, team string, from, to time.Time, resolution string) (map[string][]TeamHistoryPoint, error) {
+	if _, ok := historyQueryUnits[resolution]; !ok {
+		return nil, fmt.Errorf("unsupported history resolution %q", resolution)
+	}
+
+	match := bson.M{"timestamp": bson.M{"$gte": from.UTC(), "$lte": to.UTC()}}
+	if team != "" {
+		match["team"] = team
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"sampled_at": historySampledAt}}},
+		{{Key: "$sort", Value: bson.D{{Key: "sampled_at", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "team", Value: "$team"},
+				{Key: "bucket", Value: bson.M{"$dateTrunc": bson.M{"date": "$timestamp", "unit": resolution}}},
+			}},
+			{Key: "total", Value: bson.M{"$last": "$total_playtime_ticks"}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.bucket", Value: 1}}}},
+	}
+
+	cursor, err := hs.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	series := make(map[string][]TeamHistoryPoint)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID struct {
+				Team   string    `bson:"team"`
+				Bucket time.Time `bson:"bucket"`
+			} `bson:"_id"`
+			Total float64 `bson:"total"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode team history point: %w", err)
+		}
+		series[result.ID.Team] = append(series[result.ID.Team], TeamHistoryPoint{
+			Team:               result.ID.Team,
+			Timestamp:          result.ID.Bucket,
+			TotalPlaytimeTicks: result.Total,
+			Resolution:         resolution,
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read team history: %w", err)
+	}
+	return series, nil
+}
+
+// DownsampleHistory replaces raw points older than rawRetention with hourly points, and hourly
+// points older than hourRetention with daily points. Each new point keeps the last total recorded in
+// its bucket and when it was recorded, timestamped at the bucket start. Cutoffs are aligned to bucket boundaries, so a bucket
+// is never downsampled while part of it is still retained at the finer resolution.
+func (hs *TeamHistoryStore) DownsampleHistory(ctx context.Context, now time.Time, rawRetention, hourRetention time.Duration) error {
+	rawCutoff, hourCutoff := downsampleCutoffs(now, rawRetention, hourRetention)
+	if err := hs.downsample(ctx, HistoryResolutionRaw, HistoryResolutionHour, rawCutoff); err != nil {
+		return err
+	}
+	return hs.downsample(ctx, HistoryResolutionHour, HistoryResolutionDay, hourCutoff)
+}
+
+// downsampleCutoffs returns the times before which raw points become hourly points and hourly points
+// become daily points, rounded down to the start of the hour and the (UTC) day they fall in.
+func downsampleCutoffs(now time.Time, rawRetention, hourRetention time.Duration) (time.Time, time.Time) {
+	return now.Add(-rawRetention).UTC().Truncate(time.Hour), now.Add(-hourRetention).UTC().Truncate(24 * time.Hour)
+}
+
+// downsample rolls points of one resolution older than cutoff up into the next resolution.
+func (hs *TeamHistoryStore) downsample(ctx context.Context, fromResolution, toResolution string, cutoff time.Time) error {
+	match := bson.M{"resolution": fromResolution, "timestamp": bson.M{"$lt": cutoff}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"sampled_at": historySampledAt}}},
+		{{Key: "$sort", Value: bson.D{{Key: "sampled_at", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "team", Value: "$team"},
+				{Key: "bucket", Value: bson.M{"$dateTrunc": bson.M{"date": "$timestamp", "unit": toResolution}}},
+			}},
+			{Key: "total", Value: bson.M{"$last": "$total_playtime_ticks"}},
+			{Key: "sampled_at", Value: bson.M{"$last": "$sampled_at"}},
+		}}},
+	}
+
+	cursor, err := hs.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate %s team history: %w", fromResolution, err)
+	}
+	defer cursor.Close(ctx)
+
+	var writes []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var result struct {
+			ID struct {
+				Team   string    `bson:"team"`
+				Bucket time.Time `bson:"bucket"`
+			} `bson:"_id"`
+			Total     float64   `bson:"total"`
+			SampledAt time.Time `bson:"sampled_at"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return fmt.Errorf("failed to decode %s team history bucket: %w", toResolution, err)
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"team": result.ID.Team, "resolution": toResolution, "timestamp": result.ID.Bucket}).
+			SetUpdate(mergeHistoryPoint(result.Total, result.SampledAt)).
+			SetUpsert(true))
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to read %s team history: %w", fromResolution, err)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	if _, err := hs.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to write %s team history points: %w", toResolution, err)
+	}
+	deleted, err := hs.collection.DeleteMany(ctx, match)
+	if err != nil {
+		return fmt.Errorf("failed to delete downsampled %s team history: %w", fromResolution, err)
+	}
+	api.Logger(ctx).Info("Downsampled team history", "from_resolution", fromResolution, "points", deleted.DeletedCount, "to_resolution", toResolution, "downsampled_points", len(writes))
+	return nil
+}
+
+// mergeHistoryPoint is the update that merges a downsampled total, recorded at sampledAt, into a bucket
+// an earlier run may already have written: the later of the two totals wins, whether it is higher or not,
+// since a season reset makes totals fall. Buckets written without a sampled_at lose to any total.
+func mergeHistoryPoint(total float64, sampledAt time.Time) mongo.Pipeline {
+	newer := bson.M{"$gte": bson.A{sampledAt, bson.M{"$ifNull": bson.A{"$sampled_at", time.Time{}}}}}
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"total_playtime_ticks": bson.M{"$cond": bson.A{newer, total, "$total_playtime_ticks"}},
+			"sampled_at":           bson.M{"$cond": bson.A{newer, sampledAt, "$sampled_at"}},
+		}}},
+	}
+}
+
+// startHistoryDownsampler periodically applies the team history retention policy.
+func startHistoryDownsampler(store *TeamHistoryStore, interval, rawRetention, hourRetention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	slog.Info("Starting background team history downsampler", "interval", interval, "raw_retention", rawRetention, "hour_retention", hourRetention)
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(api.WithNewRequestID(context.Background()), 5*time.Minute)
+		if err := store.DownsampleHistory(ctx, time.Now(), rawRetention, hourRetention); err != nil {
+			api.Logger(ctx).Warn("Team history downsampler failed", "error", err)
+		}
+		cancel()
+	}
+}
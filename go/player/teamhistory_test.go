@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestHistoryStore returns a store in a fresh database on the MongoDB at MONGODB_TEST_CONN_STR,
+// dropped when the test ends. Tests using it are skipped when no test database is configured.
+func newTestHistoryStore(t *testing.T) *TeamHistoryStore {
+	t.Helper()
+	connStr := os.Getenv("MONGODB_TEST_CONN_STR")
+	if connStr == "" {
+		t.Skip("MONGODB_TEST_CONN_STR is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connStr))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	database := fmt.Sprintf("teamhistory_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		client.Database(database).Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+
+	hs := NewTeamHistoryStore(client, database, "team_history")
+	if err := hs.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("EnsureIndexes: %v", err)
+	}
+	return hs
+}
+
+// storedPoints returns the stored points of one resolution, oldest first.
+func storedPoints(t *testing.T, hs *TeamHistoryStore, resolution string) []TeamHistoryPoint {
+	t.Helper()
+	ctx := context.Background()
+	cursor, err := hs.collection.Find(ctx, bson.M{"resolution": resolution}, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "team", Value: 1}}))
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	var points []TeamHistoryPoint
+	if err := cursor.All(ctx, &points); err != nil {
+		t.Fatalf("failed to decode %s points: %v", resolution, err)
+	}
+	return points
+}
+
+func recordSnapshots(t *testing.T, hs *TeamHistoryStore, team string, totals map[time.Time]float64) {
+	t.Helper()
+	for at, total := range totals {
+		if err := hs.RecordSnapshot(context.Background(), map[string]float64{team: total}, at); err != nil {
+			t.Fatalf("RecordSnapshot: %v", err)
+		}
+	}
+}
+
+type wantPoint struct {
+	timestamp time.Time
+	total     float64
+	sampledAt time.Time
+}
+
+func checkPoints(t *testing.T, what string, got []TeamHistoryPoint, want []wantPoint) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d points %+v, want %d", what, len(got), got, len(want))
+	}
+	for i, w := range want {
+		p := got[i]
+		if !p.Timestamp.Equal(w.timestamp) || p.TotalPlaytimeTicks != w.total {
+			t.Errorf("%s point %d = %v %v, want %v %v", what, i, p.Timestamp, p.TotalPlaytimeTicks, w.timestamp, w.total)
+		}
+		if !w.sampledAt.IsZero() && (p.SampledAt == nil || !p.SampledAt.Equal(w.sampledAt)) {
+			t.Errorf("%s point %d sampled at %v, want %v", what, i, p.SampledAt, w.sampledAt)
+		}
+	}
+}
+
+func TestDownsampleCutoffs(t *testing.T) {
+	now := time.Date(2025, 6, 10, 14, 35, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	rawCutoff, hourCutoff := downsampleCutoffs(now, 48*time.Hour, 30*24*time.Hour)
+	if want := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC); !rawCutoff.Equal(want) {
+		t.Errorf("raw cutoff = %v, want %v", rawCutoff, want)
+	}
+	if want := time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC); !hourCutoff.Equal(want) {
+		t.Errorf("hour cutoff = %v, want %v", hourCutoff, want)
+	}
+
+	// A bucket still partly inside the retention window is left at the finer resolution
+	rawCutoff, _ = downsampleCutoffs(time.Date(2025, 6, 10, 12, 59, 0, 0, time.UTC), time.Minute, 0)
+	if want := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC); !rawCutoff.Equal(want) {
+		t.Errorf("raw cutoff inside the current hour = %v, want %v", rawCutoff, want)
+	}
+}
+
+func TestQueryHistoryRejectsUnknownResolution(t *testing.T) {
+	hs := &TeamHistoryStore{}
+	if _, err := hs.QueryHistory(context.Background(), "red", time.Now().Add(-time.Hour), time.Now(), "week"); err == nil {
+		t.Error("QueryHistory with an unsupported resolution succeeded")
+	}
+}
+
+func TestDownsampleHistoryRawToHour(t *testing.T) {
+	hs := newTestHistoryStore(t)
+	ctx := context.Background()
+	at := func(hour, minute int) time.Time { return time.Date(2025, 6, 10, hour, minute, 0, 0, time.UTC) }
+	now := at(12, 30)
+
+	// The 10:00 bucket falls after a season reset: its later, lower total is the one kept
+	recordSnapshots(t, hs, "red", map[time.Time]float64{
+		at(9, 10): 10, at(9, 50): 20,
+		at(10, 20): 30, at(10, 40): 2,
+		at(12, 10): 5,
+	})
+
+	if err := hs.DownsampleHistory(ctx, now, time.Hour, 30*24*time.Hour); err != nil {
+		t.Fatalf("DownsampleHistory: %v", err)
+	}
+	hourly := []wantPoint{{at(9, 0), 20, at(9, 50)}, {at(10, 0), 2, at(10, 40)}}
+	checkPoints(t, "hourly", storedPoints(t, hs, HistoryResolutionHour), hourly)
+	checkPoints(t, "raw", storedPoints(t, hs, HistoryResolutionRaw), []wantPoint{{timestamp: at(12, 10), total: 5}})
+
+	// A re-run with nothing new leaves the hourly points as they are
+	if err := hs.DownsampleHistory(ctx, now, time.Hour, 30*24*time.Hour); err != nil {
+		t.Fatalf("DownsampleHistory re-run: %v", err)
+	}
+	checkPoints(t, "hourly after a re-run", storedPoints(t, hs, HistoryResolutionHour), hourly)
+
+	// Late raw points in downsampled buckets are merged into them rather than added up:
+	// the later sample replaces the bucket's total, an earlier one does not
+	recordSnapshots(t, hs, "red", map[time.Time]float64{at(9, 55): 25, at(10, 5): 99})
+	if err := hs.DownsampleHistory(ctx, now, time.Hour, 30*24*time.Hour); err != nil {
+		t.Fatalf("DownsampleHistory with late points: %v", err)
+	}
+	checkPoints(t, "hourly after late points", storedPoints(t, hs, HistoryResolutionHour), []wantPoint{
+		{at(9, 0), 25, at(9, 55)},
+		{at(10, 0), 2, at(10, 40)},
+	})
+	checkPoints(t, "raw after late points", storedPoints(t, hs, HistoryResolutionRaw), []wantPoint{{timestamp: at(12, 10), total: 5}})
+}
+
+func TestDownsampleHistoryHourToDay(t *testing.T) {
+	hs := newTestHistoryStore(t)
+	ctx := context.Background()
+	day := func(d, hour int) time.Time { return time.Date(2025, 6, d, hour, 0, 0, 0, time.UTC) }
+	now := time.Date(2025, 6, 10, 12, 30, 0, 0, time.UTC)
+
+	var hourly []interface{}
+	for at, total := range map[time.Time]float64{day(6, 5): 100, day(6, 20): 150, day(7, 3): 200, day(9, 10): 300} {
+		sampledAt := at.Add(50 * time.Minute)
+		hourly = append(hourly, TeamHistoryPoint{Team: "blue", Timestamp: at, TotalPlaytimeTicks: total, Resolution: HistoryResolutionHour, SampledAt: &sampledAt})
+	}
+	if _, err := hs.collection.InsertMany(ctx, hourly); err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+
+	daily := []wantPoint{{day(6, 0), 150, day(6, 20).Add(50 * time.Minute)}, {day(7, 0), 200, day(7, 3).Add(50 * time.Minute)}}
+	for run := 0; run < 2; run++ {
+		if err := hs.DownsampleHistory(ctx, now, time.Hour, 48*time.Hour); err != nil {
+			t.Fatalf("DownsampleHistory run %d: %v", run, err)
+		}
+		checkPoints(t, fmt.Sprintf("daily after run %d", run), storedPoints(t, hs, HistoryResolutionDay), daily)
+		checkPoints(t, fmt.Sprintf("hourly after run %d", run), storedPoints(t, hs, HistoryResolutionHour), []wantPoint{{timestamp: day(9, 10), total: 300}})
+	}
+}
+
+func TestDownsampleHistoryKeepsRawPointsWhenMergeFails(t *testing.T) {
+	hs := newTestHistoryStore(t)
+	ctx := context.Background()
+	at := time.Date(2025, 6, 10, 9, 10, 0, 0, time.UTC)
+
+	// Two teams' hourly points for the same bucket collide on this index, so the merge fails
+	_, err := hs.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "resolution", Value: 1}, {Key: "timestamp", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"resolution": HistoryResolutionHour}),
+	})
+	if err != nil {
+		t.Fatalf("failed to create the conflicting index: %v", err)
+	}
+	if err := hs.RecordSnapshot(ctx, map[string]float64{"red": 10, "blue": 20}, at); err != nil {
+		t.Fatalf("RecordSnapshot: %v", err)
+	}
+
+	if err := hs.DownsampleHistory(ctx, at.Add(3*time.Hour), time.Hour, 30*24*time.Hour); err == nil {
+		t.Fatal("DownsampleHistory succeeded although the hourly points could not be written")
+	}
+	if raw := storedPoints(t, hs, HistoryResolutionRaw); len(raw) != 2 {
+		t.Errorf("%d raw points left after a failed merge, want both", len(raw))
+	}
+}
+
+func TestQueryHistoryResolutions(t *testing.T) {
+	hs := newTestHistoryStore(t)
+	ctx := context.Background()
+	at := func(hour, minute int) time.Time { return time.Date(2025, 6, 10, hour, minute, 0, 0, time.UTC) }
+
+	sampledAt := at(8, 50)
+	if _, err := hs.collection.InsertOne(ctx, TeamHistoryPoint{Team: "red", Timestamp: at(8, 0), TotalPlaytimeTicks: 1, Resolution: HistoryResolutionHour, SampledAt: &sampledAt}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+	recordSnapshots(t, hs, "red", map[time.Time]float64{at(10, 5): 2, at(10, 40): 3, at(11, 15): 4})
+	recordSnapshots(t, hs, "blue", map[time.Time]float64{at(10, 5): 7})
+
+	cases := []struct {
+		resolution string
+		want       []wantPoint
+	}{
+		{"minute", []wantPoint{{timestamp: at(8, 0), total: 1}, {timestamp: at(10, 5), total: 2}, {timestamp: at(10, 40), total: 3}, {timestamp: at(11, 15), total: 4}}},
+		{"hour", []wantPoint{{timestamp: at(8, 0), total: 1}, {timestamp: at(10, 0), total: 3}, {timestamp: at(11, 0), total: 4}}},
+		{"day", []wantPoint{{timestamp: at(0, 0), total: 4}}},
+	}
+	for _, c := range cases {
+		series, err := hs.QueryHistory(ctx, "red", at(0, 0), at(23, 0), c.resolution)
+		if err != nil {
+			t.Fatalf("QueryHistory(%s): %v", c.resolution, err)
+		}
+		if len(series) != 1 {
+			t.Errorf("QueryHistory(%s) for red returned teams %v", c.resolution, series)
+		}
+		checkPoints(t, c.resolution, series["red"], c.want)
+	}
+
+	series, err := hs.QueryHistory(ctx, "", at(10, 0), at(10, 30), "hour")
+	if err != nil {
+		t.Fatalf("QueryHistory for every team: %v", err)
+	}
+	checkPoints(t, "red from 10:00", series["red"], []wantPoint{{timestamp: at(10, 0), total: 2}})
+	checkPoints(t, "blue from 10:00", series["blue"], []wantPoint{{timestamp: at(10, 0), total: 7}})
+}
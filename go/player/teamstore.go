@@ -4,13 +4,13 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/Ftotnem/Backend/go/shared/api"
 	"github.com/Ftotnem/Backend/go/shared/models"
 )
 
@@ -46,7 +46,7 @@ func (ts *TeamStore) EnsureTeamsExist(ctx context.Context, teams []string) error
 			return fmt.Errorf("failed to upsert team %s: %w", teamName, err)
 		}
 		if result.UpsertedID != nil {
-			log.Printf("INFO: Initialized team '%s' in database.", teamName)
+			api.Logger(ctx).Info("Initialized team in database", "team", teamName)
 		}
 	}
 	return nil
@@ -82,7 +82,7 @@ func (ts *TeamStore) IncrementTeamPlayerCount(ctx context.Context, teamName stri
 	if result.MatchedCount == 0 {
 		return fmt.Errorf("team %s not found for player count increment", teamName)
 	}
-	log.Printf("INFO: Incremented player count for team %s.", teamName)
+	api.Logger(ctx).Info("Incremented player count for team", "team", teamName)
 	return nil
 }
 
@@ -102,7 +102,7 @@ func (ts *TeamStore) DecrementTeamPlayerCount(ctx context.Context, teamName stri
 	if result.MatchedCount == 0 {
 		return fmt.Errorf("team %s not found for player count decrement", teamName)
 	}
-	log.Printf("INFO: Decremented player count for team %s.", teamName)
+	api.Logger(ctx).Info("Decremented player count for team", "team", teamName)
 	return nil
 }
 
@@ -121,7 +121,7 @@ func (ts *TeamStore) UpdateTeamTotalPlaytime(ctx context.Context, teamName strin
 	if result.MatchedCount == 0 {
 		return fmt.Errorf("team %s not found for total playtime update", teamName)
 	}
-	log.Printf("INFO: Updated total playtime for team %s by %.2f ticks.", teamName, playtimeIncrement)
+	api.Logger(ctx).Info("Updated total playtime for team", "team", teamName, "increment", playtimeIncrement)
 	return nil
 }
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// mongoTracer gives each MongoDB command a span, as a child of the span carried by the command's context.
+// Commands issued outside a traced request are not traced; see api.StartChildSpan.
+type mongoTracer struct {
+	spans sync.Map // Open spans by command request ID
+}
+
+// newMongoTracingMonitor returns a command monitor tracing every MongoDB command.
+func newMongoTracingMonitor() *event.CommandMonitor {
+	t := &mongoTracer{}
+	return &event.CommandMonitor{
+		Started: t.started,
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			t.finished(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			t.finished(e.RequestID, errors.New(e.Failure))
+		},
+	}
+}
+
+func (t *mongoTracer) started(ctx context.Context, e *event.CommandStartedEvent) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemMongoDB,
+		semconv.DBOperationName(e.CommandName),
+		semconv.DBNamespace(e.DatabaseName),
+	}
+	// Most commands name their collection as the value of the command itself, e.g. {find: "players"}
+	if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+		attrs = append(attrs, semconv.DBCollectionName(collection))
+	}
+	_, span := api.StartChildSpan(ctx, "mongo "+e.CommandName, attrs...)
+	if span.IsRecording() {
+		t.spans.Store(e.RequestID, span)
+	}
+}
+
+func (t *mongoTracer) finished(requestID int64, err error) {
+	value, ok := t.spans.LoadAndDelete(requestID)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
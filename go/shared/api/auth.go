@@ -0,0 +1,389 @@
+// go/shared/api/auth.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scope is the access a route requires. Callers are granted scopes through their Credential.
+type Scope string
+
+const (
+	ScopePublic   Scope = "public"   // No credentials needed (e.g. leaderboards and team streams for the website)
+	ScopeInternal Scope = "internal" // Calls between services and from proxies
+	ScopeAdmin    Scope = "admin"    // Moderation and operator endpoints; an admin credential may also call internal routes
+)
+
+// Headers carrying service credentials. API keys use "Authorization: Bearer <key>".
+const (
+	HeaderAuthKeyID     = "X-Auth-Key-Id"
+	HeaderAuthTimestamp = "X-Auth-Timestamp" // Unix seconds at which the request was signed
+	HeaderAuthNonce     = "X-Auth-Nonce"     // Random per request; a nonce is accepted only once
+	HeaderAuthSignature = "X-Auth-Signature" // Hex HMAC-SHA256, see signatureFor
+)
+
+// DefaultMaxClockSkew is how far a signed request's timestamp may be from the server's clock
+// when NewHMACAuthenticator is given no skew.
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// MaxSignedBodyBytes is the largest body HMACAuthenticator reads to check a signature. It is read
+// before the caller is known to hold the secret, so it must stay small.
+const MaxSignedBodyBytes = 1 << 20
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request carries no credentials it understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when a request's credentials are unknown, malformed or wrongly signed.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrReplayedRequest is returned for a signed request whose timestamp is out of range or whose nonce was used before.
+	ErrReplayedRequest = errors.New("stale or replayed request")
+	// ErrRequestTooLarge is returned for a signed request whose body exceeds MaxSignedBodyBytes.
+	ErrRequestTooLarge = errors.New("request body too large")
+)
+
+// Credential is a secret shared with a caller, and the scopes it grants.
+// For API keys the secret is the key itself; for signed requests ID names the key and the secret signs.
+type Credential struct {
+	ID     string
+	Secret string
+	Scopes []Scope
+}
+
+// ParseCredentials parses a comma-separated list of "id:secret:scope+scope" entries,
+// e.g. "game:s3cret:internal,staff-panel:0th3r:admin". An empty string yields no credentials.
+func ParseCredentials(s string) ([]Credential, error) {
+	var credentials []Credential
+	for i, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			// Don't echo the entry, it may hold a secret
+			return nil, fmt.Errorf("invalid credential #%d: expected id:secret:scopes", i+1)
+		}
+		credential := Credential{ID: parts[0], Secret: parts[1]}
+		for _, scope := range strings.Split(parts[2], "+") {
+			switch Scope(scope) {
+			case ScopeInternal, ScopeAdmin:
+				credential.Scopes = append(credential.Scopes, Scope(scope))
+			default:
+				return nil, fmt.Errorf("invalid credential %q: unknown scope %q", credential.ID, scope)
+			}
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, nil
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID     string // Credential ID
+	Scopes []Scope
+}
+
+// HasScope reports whether the principal may call routes requiring scope.
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return scope == ScopePublic
+}
+
+type principalContextKey struct{}
+
+// PrincipalFromContext returns the caller authenticated by Auth, or nil for public routes and open servers.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return p
+}
+
+// Authenticator identifies the caller of a request from one kind of credentials.
+type Authenticator interface {
+	// Authenticate returns the caller of r. It returns ErrNoCredentials if r carries none of its kind,
+	// and another error if they are present but not valid.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// APIKeyAuthenticator authenticates requests carrying a static API key as "Authorization: Bearer <key>".
+type APIKeyAuthenticator struct {
+	credentials []Credential
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator accepting the secrets of credentials as keys.
+func NewAPIKeyAuthenticator(credentials []Credential) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{credentials: credentials}
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || key == "" {
+		return nil, ErrNoCredentials
+	}
+	for _, c := range a.credentials {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(c.Secret)) == 1 {
+			return &Principal{ID: c.ID, Scopes: c.Scopes}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// NonceStore remembers the nonces of accepted signed requests so that each is accepted only once.
+type NonceStore interface {
+	// Remember records nonce until expiresAt and reports whether it had not been seen before.
+	Remember(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// NonceStoreFunc adapts a function to a NonceStore.
+type NonceStoreFunc func(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+
+// Remember implements NonceStore.
+func (f NonceStoreFunc) Remember(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	return f(ctx, nonce, expiresAt)
+}
+
+// MemoryNonceStore is a NonceStore for a single instance. Services running several instances behind
+// one address should share a store instead, or a request could be replayed against another instance.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time // Nonce -> when it may be forgotten
+	pruned time.Time            // When expired nonces were last forgotten
+}
+
+// NewMemoryNonceStore creates an empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+// Remember implements NonceStore.
+func (s *MemoryNonceStore) Remember(_ context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if until, ok := s.nonces[nonce]; ok && now.Before(until) {
+		return false, nil
+	}
+	if now.Sub(s.pruned) > time.Minute {
+		for n, until := range s.nonces {
+			if !now.Before(until) {
+				delete(s.nonces, n)
+			}
+		}
+		s.pruned = now
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
+
+// HMACAuthenticator authenticates requests signed with a shared secret (see HMACSigner). A request
+// is accepted only if its timestamp is within the clock skew and its nonce has not been seen before.
+type HMACAuthenticator struct {
+	credentials map[string]Credential // By ID
+	maxSkew     time.Duration
+	nonces      NonceStore
+}
+
+// NewHMACAuthenticator creates an HMACAuthenticator for credentials. A zero maxSkew uses
+// DefaultMaxClockSkew, and a nil nonces store uses a MemoryNonceStore.
+func NewHMACAuthenticator(credentials []Credential, maxSkew time.Duration, nonces NonceStore) *HMACAuthenticator {
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	byID := make(map[string]Credential, len(credentials))
+	for _, c := range credentials {
+		byID[c.ID] = c
+	}
+	return &HMACAuthenticator{credentials: byID, maxSkew: maxSkew, nonces: nonces}
+}
+
+// Authenticate implements Authenticator. It reads the request body, up to MaxSignedBodyBytes, to check
+// the signature and then restores it for the handler.
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	keyID := r.Header.Get(HeaderAuthKeyID)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+	credential, ok := a.credentials[keyID]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	timestamp := r.Header.Get(HeaderAuthTimestamp)
+	nonce := r.Header.Get(HeaderAuthNonce)
+	signature, err := hex.DecodeString(r.Header.Get(HeaderAuthSignature))
+	if err != nil || nonce == "" {
+		return nil, ErrInvalidCredentials
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	signedAt := time.Unix(unix, 0)
+	if skew := time.Since(signedAt); skew > a.maxSkew || skew < -a.maxSkew {
+		return nil, ErrReplayedRequest
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxSignedBodyBytes))
+		r.Body.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, ErrRequestTooLarge
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	expected := signatureFor(credential.Secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Only a correctly signed request may use up a nonce. It cannot be replayed once the timestamp is
+	// out of range, so it only has to be remembered until then.
+	fresh, err := a.nonces.Remember(r.Context(), keyID+":"+nonce, signedAt.Add(a.maxSkew))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check request nonce: %w", err)
+	}
+	if !fresh {
+		return nil, ErrReplayedRequest
+	}
+	return &Principal{ID: credential.ID, Scopes: credential.Scopes}, nil
+}
+
+// signatureFor computes the HMAC-SHA256 over a request's method, path and query, timestamp,
+// nonce and the SHA-256 of its body, one per line.
+func signatureFor(secret, method, requestURI, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, requestURI, timestamp, nonce, hex.EncodeToString(bodyHash[:]))
+	return mac.Sum(nil)
+}
+
+// Auth checks that requests to a route carry credentials granting its scope.
+// A nil *Auth authenticates nothing and leaves every route open.
+type Auth struct {
+	authenticators []Authenticator
+}
+
+// NewAuth creates an Auth trying each authenticator in turn.
+func NewAuth(authenticators ...Authenticator) *Auth {
+	return &Auth{authenticators: authenticators}
+}
+
+// NewServiceAuth creates an Auth accepting apiKeys as static API keys and hmacKeys for signed requests,
+// remembering nonces in nonces (a MemoryNonceStore if nil). It returns nil if neither has credentials.
+func NewServiceAuth(apiKeys, hmacKeys []Credential, nonces NonceStore) *Auth {
+	var authenticators []Authenticator
+	if len(apiKeys) > 0 {
+		authenticators = append(authenticators, NewAPIKeyAuthenticator(apiKeys))
+	}
+	if len(hmacKeys) > 0 {
+		authenticators = append(authenticators, NewHMACAuthenticator(hmacKeys, 0, nonces))
+	}
+	if len(authenticators) == 0 {
+		return nil
+	}
+	return NewAuth(authenticators...)
+}
+
+// Require wraps next so that it only serves callers granted scope, responding 401 Unauthorized
+// to requests without valid credentials and 403 Forbidden to callers lacking the scope.
+// The caller is available to next through PrincipalFromContext.
+func (a *Auth) Require(scope Scope, next http.Handler) http.Handler {
+	if a == nil || scope == ScopePublic {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if errors.Is(err, ErrRequestTooLarge) {
+			Logger(r.Context()).Warn("Rejected request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "error", err)
+			WriteError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if err != nil {
+			Logger(r.Context()).Warn("Rejected request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "error", err)
+			WriteError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !principal.HasScope(scope) {
+			Logger(r.Context()).Warn("Rejected request lacking scope", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "principal", principal.ID, "scope", scope)
+			WriteError(w, http.StatusForbidden, "Insufficient scope")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, principal)))
+	})
+}
+
+func (a *Auth) authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range a.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// RequestSigner adds credentials to an outgoing request. body is the request body, or nil.
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// APIKeySigner sends a static API key.
+type APIKeySigner struct {
+	Key string
+}
+
+// Sign implements RequestSigner.
+func (s APIKeySigner) Sign(req *http.Request, _ []byte) error {
+	req.Header.Set("Authorization", "Bearer "+s.Key)
+	return nil
+}
+
+// HMACSigner signs requests with a shared secret for HMACAuthenticator.
+type HMACSigner struct {
+	KeyID  string
+	Secret string
+}
+
+// Sign implements RequestSigner.
+func (s HMACSigner) Sign(req *http.Request, body []byte) error {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return fmt.Errorf("failed to generate request nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderAuthKeyID, s.KeyID)
+	req.Header.Set(HeaderAuthTimestamp, timestamp)
+	req.Header.Set(HeaderAuthNonce, nonce)
+	req.Header.Set(HeaderAuthSignature, hex.EncodeToString(signatureFor(s.Secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body)))
+	return nil
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseCredentials(t *testing.T) {
+	credentials, err := ParseCredentials(" game:s3cret:internal , staff:0th3r:admin+internal,")
+	if err != nil {
+		t.Fatalf("ParseCredentials: %v", err)
+	}
+	if len(credentials) != 2 {
+		t.Fatalf("got %d credentials, want 2", len(credentials))
+	}
+	if c := credentials[0]; c.ID != "game" || c.Secret != "s3cret" || len(c.Scopes) != 1 || c.Scopes[0] != ScopeInternal {
+		t.Errorf("first credential = %+v", c)
+	}
+	if c := credentials[1]; c.ID != "staff" || len(c.Scopes) != 2 || c.Scopes[0] != ScopeAdmin {
+		t.Errorf("second credential = %+v", c)
+	}
+
+	if credentials, err := ParseCredentials(""); err != nil || len(credentials) != 0 {
+		t.Errorf("ParseCredentials(\"\") = %v, %v; want no credentials", credentials, err)
+	}
+	for _, s := range []string{"game:s3cret", "game::internal", "game:s3cret:public", "game:s3cret:root"} {
+		if _, err := ParseCredentials(s); err == nil {
+			t.Errorf("ParseCredentials(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestPrincipalHasScope(t *testing.T) {
+	internal := &Principal{ID: "game", Scopes: []Scope{ScopeInternal}}
+	admin := &Principal{ID: "staff", Scopes: []Scope{ScopeAdmin}}
+
+	if !internal.HasScope(ScopeInternal) || !internal.HasScope(ScopePublic) || internal.HasScope(ScopeAdmin) {
+		t.Error("internal principal has the wrong scopes")
+	}
+	if !admin.HasScope(ScopeInternal) || !admin.HasScope(ScopeAdmin) {
+		t.Error("admin principal should be allowed on internal and admin routes")
+	}
+}
+
+func TestHMACSignAndVerify(t *testing.T) {
+	credentials := []Credential{{ID: "game", Secret: "s3cret", Scopes: []Scope{ScopeInternal}}}
+	auth := NewHMACAuthenticator(credentials, 0, nil)
+	body := []byte(`{"uuid":"abc"}`)
+
+	signed := func(secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/game/online?x=1", bytes.NewReader(body))
+		if err := (HMACSigner{KeyID: "game", Secret: secret}).Sign(req, body); err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return req
+	}
+
+	req := signed("s3cret")
+	principal, err := auth.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if principal.ID != "game" || !principal.HasScope(ScopeInternal) {
+		t.Errorf("principal = %+v", principal)
+	}
+	if restored, _ := io.ReadAll(req.Body); !bytes.Equal(restored, body) {
+		t.Errorf("body not restored for the handler: %q", restored)
+	}
+
+	// The same nonce is accepted only once
+	replay := httptest.NewRequest(http.MethodPost, "/game/online?x=1", bytes.NewReader(body))
+	replay.Header = req.Header.Clone()
+	if _, err := auth.Authenticate(replay); !errors.Is(err, ErrReplayedRequest) {
+		t.Errorf("replayed request: err = %v, want ErrReplayedRequest", err)
+	}
+
+	if _, err := auth.Authenticate(signed("wrong")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong secret: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	tampered := signed("s3cret")
+	tampered.Body = io.NopCloser(bytes.NewReader([]byte(`{"uuid":"xyz"}`)))
+	if _, err := auth.Authenticate(tampered); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("tampered body: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	stale := signed("s3cret")
+	stale.Header.Set(HeaderAuthTimestamp, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if _, err := auth.Authenticate(stale); !errors.Is(err, ErrReplayedRequest) {
+		t.Errorf("stale timestamp: err = %v, want ErrReplayedRequest", err)
+	}
+
+	unsigned := httptest.NewRequest(http.MethodGet, "/game/online", nil)
+	if _, err := auth.Authenticate(unsigned); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("unsigned request: err = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestHMACRejectsOversizedBody(t *testing.T) {
+	auth := NewHMACAuthenticator([]Credential{{ID: "game", Secret: "s3cret"}}, 0, nil)
+	body := bytes.Repeat([]byte("x"), MaxSignedBodyBytes+1)
+	req := httptest.NewRequest(http.MethodPost, "/game/online", bytes.NewReader(body))
+	if err := (HMACSigner{KeyID: "game", Secret: "s3cret"}).Sign(req, body); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err := auth.Authenticate(req); !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("err = %v, want ErrRequestTooLarge", err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+	ctx := context.Background()
+
+	if fresh, _ := store.Remember(ctx, "n1", time.Now().Add(time.Minute)); !fresh {
+		t.Error("first use of a nonce should be fresh")
+	}
+	if fresh, _ := store.Remember(ctx, "n1", time.Now().Add(time.Minute)); fresh {
+		t.Error("second use of a nonce should not be fresh")
+	}
+	if fresh, _ := store.Remember(ctx, "n2", time.Now().Add(-time.Second)); !fresh {
+		t.Error("first use of another nonce should be fresh")
+	}
+	if fresh, _ := store.Remember(ctx, "n2", time.Now().Add(time.Minute)); !fresh {
+		t.Error("a nonce should be accepted again once it has expired")
+	}
+}
+
+func TestRedisNonceStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisNonceStore(client, "test")
+	ctx := context.Background()
+
+	if fresh, err := store.Remember(ctx, "game:n1", time.Now().Add(time.Minute)); err != nil || !fresh {
+		t.Fatalf("first use = %v, %v; want fresh", fresh, err)
+	}
+	if fresh, _ := store.Remember(ctx, "game:n1", time.Now().Add(time.Minute)); fresh {
+		t.Error("second use of a nonce should not be fresh")
+	}
+	if fresh, _ := store.Remember(ctx, "game:n2", time.Now().Add(-time.Second)); fresh {
+		t.Error("a nonce that has already expired should not be accepted")
+	}
+
+	server.FastForward(2 * time.Minute)
+	if fresh, _ := store.Remember(ctx, "game:n1", time.Now().Add(time.Minute)); !fresh {
+		t.Error("a nonce should be forgotten once its TTL has run out")
+	}
+}
+
+func TestAuthRequire(t *testing.T) {
+	auth := NewServiceAuth(
+		[]Credential{{ID: "game", Secret: "game-key", Scopes: []Scope{ScopeInternal}}, {ID: "staff", Secret: "staff-key", Scopes: []Scope{ScopeAdmin}}},
+		nil, nil,
+	)
+	var caller string
+	handler := auth.Require(ScopeAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = PrincipalFromContext(r.Context()).ID
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"no credentials", "", http.StatusUnauthorized},
+		{"unknown key", "nope", http.StatusUnauthorized},
+		{"missing scope", "game-key", http.StatusForbidden},
+		{"admin", "staff-key", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/game/ban", nil)
+			if tt.key != "" {
+				APIKeySigner{Key: tt.key}.Sign(req, nil)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+	if caller != "staff" {
+		t.Errorf("handler saw caller %q, want staff", caller)
+	}
+
+	if NewServiceAuth(nil, nil, nil) != nil {
+		t.Error("NewServiceAuth without credentials should leave routes open")
+	}
+}
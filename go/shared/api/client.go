@@ -17,6 +17,8 @@ type HTTPError struct {
 	Message    string
 	URL        string
 	Method     string
+	Body       []byte        // Raw response body, for callers that decode structured error payloads
+	RetryAfter time.Duration // From the Retry-After header of a 429 the client did not wait out, or 0
 }
 
 func (e *HTTPError) Error() string {
@@ -33,58 +35,90 @@ var (
 )
 
 type Client struct {
-	httpClient *http.Client
-	baseURL    string
+	httpClient       *http.Client
+	baseURL          string
+	signer           RequestSigner // Adds credentials to every request; nil sends none
+	rateLimitRetries int           // How often a request answered with 429 is retried after its Retry-After
+	maxRetryAfter    time.Duration // Longest Retry-After waited out; a 429 asking for longer is returned as an error
 }
 
-func NewClient(baseURL string, timeout time.Duration) *Client {
-	return &Client{
-		httpClient: &http.Client{Timeout: timeout},
-		baseURL:    baseURL,
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithSigner makes the Client add credentials to every request with signer.
+func WithSigner(signer RequestSigner) ClientOption {
+	return func(c *Client) {
+		c.signer = signer
+	}
+}
+
+// WithRateLimitRetries sets how often a request answered with 429 Too Many Requests is retried,
+// and the longest Retry-After the Client waits out. Zero retries returns every 429 as an error.
+func WithRateLimitRetries(retries int, maxWait time.Duration) ClientOption {
+	return func(c *Client) {
+		c.rateLimitRetries = retries
+		c.maxRetryAfter = maxWait
+	}
+}
+
+func NewClient(baseURL string, timeout time.Duration, opts ...ClientOption) *Client {
+	c := &Client{
+		httpClient:       &http.Client{Timeout: timeout},
+		baseURL:          baseURL,
+		rateLimitRetries: 3,
+		maxRetryAfter:    5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
-// doRequest is a helper for common request logic
+// doRequest is a helper for common request logic. A 429 Too Many Requests is retried after the
+// wait its Retry-After header asks for, within the limits set by WithRateLimitRetries.
 func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}, result interface{}) error {
 	url := fmt.Sprintf("%s%s", c.baseURL, path)
 
-	var reqBody io.Reader
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body for %s %s: %w", method, url, err)
 		}
-		reqBody = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
-	if err != nil {
-		return fmt.Errorf("failed to create %s request for %s: %w", method, url, err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send %s request to %s: %w", method, url, err)
+	var resp *http.Response
+	var wait time.Duration
+	for attempt := 0; ; attempt++ {
+		var err error
+		resp, err = c.send(ctx, method, url, jsonData)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+		var ok bool
+		wait, ok = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok || attempt >= c.rateLimitRetries || wait > c.maxRetryAfter {
+			break
+		}
+		resp.Body.Close()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting to retry rate limited %s request to %s: %w", method, url, ctx.Err())
+		case <-time.After(wait):
+		}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		var errorResponse struct {
-			Message string `json:"message"`
+		httpErr := c.errorFrom(resp, method, url)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			httpErr.RetryAfter = wait
 		}
-		// Try to read error message from body
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr == nil && len(bodyBytes) > 0 {
-			if jsonErr := json.Unmarshal(bodyBytes, &errorResponse); jsonErr == nil && errorResponse.Message != "" {
-				return &HTTPError{StatusCode: resp.StatusCode, Message: errorResponse.Message, URL: url, Method: method}
-			}
-			// If JSON decoding fails or message is empty, just include the raw body if it's small
-			if len(bodyBytes) < 200 { // Limit size to avoid logging huge bodies
-				return &HTTPError{StatusCode: resp.StatusCode, Message: string(bodyBytes), URL: url, Method: method}
-			}
-		}
-		return &HTTPError{StatusCode: resp.StatusCode, URL: url, Method: method}
+		return httpErr
 	}
 
 	if result != nil {
@@ -95,6 +129,56 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 	return nil
 }
 
+// send makes one attempt at a request, forwarding the request ID and trace context carried by ctx
+// and signing it if the Client has a signer.
+func (c *Client) send(ctx context.Context, method, url string, jsonData []byte) (*http.Response, error) {
+	var reqBody io.Reader
+	if jsonData != nil {
+		reqBody = bytes.NewReader(jsonData)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request for %s: %w", method, url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(HeaderRequestID, id)
+	}
+	if c.signer != nil {
+		if err := c.signer.Sign(req, jsonData); err != nil {
+			return nil, fmt.Errorf("failed to sign %s request for %s: %w", method, url, err)
+		}
+	}
+
+	_, span := startClientSpan(ctx, req)
+	resp, err := c.httpClient.Do(req)
+	endClientSpan(span, resp, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s request to %s: %w", method, url, err)
+	}
+	return resp, nil
+}
+
+// errorFrom builds the HTTPError for a response with an error status.
+func (c *Client) errorFrom(resp *http.Response, method, url string) *HTTPError {
+	var errorResponse struct {
+		Message string `json:"message"`
+	}
+	// Try to read error message from body
+	bodyBytes, readErr := io.ReadAll(resp.Body)
+	if readErr == nil && len(bodyBytes) > 0 {
+		if jsonErr := json.Unmarshal(bodyBytes, &errorResponse); jsonErr == nil && errorResponse.Message != "" {
+			return &HTTPError{StatusCode: resp.StatusCode, Message: errorResponse.Message, URL: url, Method: method, Body: bodyBytes}
+		}
+		// If JSON decoding fails or message is empty, just include the raw body if it's small
+		if len(bodyBytes) < 200 { // Limit size to avoid logging huge bodies
+			return &HTTPError{StatusCode: resp.StatusCode, Message: string(bodyBytes), URL: url, Method: method, Body: bodyBytes}
+		}
+		return &HTTPError{StatusCode: resp.StatusCode, URL: url, Method: method, Body: bodyBytes}
+	}
+	return &HTTPError{StatusCode: resp.StatusCode, URL: url, Method: method}
+}
+
 func (c *Client) Get(ctx context.Context, path string, result interface{}) error {
 	return c.doRequest(ctx, "GET", path, nil, result)
 }
@@ -106,3 +190,7 @@ func (c *Client) Post(ctx context.Context, path string, body interface{}, result
 func (c *Client) Put(ctx context.Context, path string, body interface{}, result interface{}) error {
 	return c.doRequest(ctx, "PUT", path, body, result)
 }
+
+func (c *Client) Delete(ctx context.Context, path string, result interface{}) error {
+	return c.doRequest(ctx, "DELETE", path, nil, result)
+}
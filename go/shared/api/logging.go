@@ -0,0 +1,94 @@
+// go/shared/api/logging.go
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID carries the ID that correlates a request's log lines across services.
+const HeaderRequestID = "X-Request-ID"
+
+type requestIDContextKey struct{}
+
+// NewRequestID generates a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// WithNewRequestID returns a copy of ctx carrying a fresh request ID. Background jobs use it so that a
+// run's log lines, and the requests it makes to other services, can be told apart from other runs.
+func WithNewRequestID(ctx context.Context) context.Context {
+	return WithRequestID(ctx, NewRequestID())
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether a request ID received from a caller is safe to log and forward.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger returns the shared logger, tagged with the request ID and trace ID carried by ctx, if any.
+func Logger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestIDFromContext(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
+// SetupLogging makes a logger tagged with service the default for both log/slog and the log package.
+// format is "json" (the default) or "text"; level is one of "debug", "info" (the default), "warn" or "error".
+func SetupLogging(service, level, format string) error {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q: must be json or text", format)
+	}
+	slog.SetDefault(slog.New(handler).With("service", service))
+	return nil
+}
@@ -0,0 +1,61 @@
+// go/shared/api/metrics_redis.go
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Latency of Redis commands, by command. Pipelines and transactions count as one \"pipeline\" command.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command"})
+
+	redisCommandErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_command_errors_total",
+		Help: "Redis commands that failed, by command. A missing key (redis.Nil) is not a failure.",
+	}, []string{"command"})
+)
+
+// RedisMetricsHook is a go-redis hook recording the latency and failures of every command.
+// Add it with AddHook on the client; on a ClusterClient it covers every node.
+type RedisMetricsHook struct{}
+
+// DialHook implements redis.Hook.
+func (RedisMetricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook.
+func (RedisMetricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		observeRedisCommand(cmd.Name(), start, err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook.
+func (RedisMetricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		observeRedisCommand("pipeline", start, err)
+		return err
+	}
+}
+
+func observeRedisCommand(command string, start time.Time, err error) {
+	redisCommandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, redis.Nil) {
+		redisCommandErrors.WithLabelValues(command).Inc()
+	}
+}
@@ -2,25 +2,175 @@
 package api
 
 import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// RequestIDMiddleware attaches a request ID to each request's context and echoes it in the response.
+// The ID is taken from the caller's X-Request-ID header, so it follows a request across services,
+// or generated if the caller sent none.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = NewRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// LoggingMiddleware writes a structured access log line for each request once it has been served.
+// It must run after RequestIDMiddleware to pick up the request ID.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//start := time.Now()
-		next.ServeHTTP(w, r)
-		//log.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		Logger(r.Context()).Log(r.Context(), level, "HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
 	})
 }
 
-func CORSMiddleware(next http.Handler) http.Handler {
+// responseRecorder records the status and size of a response. It passes flushes and hijacks
+// through, which the team stream's SSE and WebSocket endpoints rely on.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rec.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// corsAllowedHeaders are the request headers browsers may send cross-origin: the body type, service
+// credentials, and the request ID and trace context that follow a request across services.
+var corsAllowedHeaders = strings.Join([]string{
+	"Content-Type", "Authorization",
+	HeaderAuthKeyID, HeaderAuthTimestamp, HeaderAuthNonce, HeaderAuthSignature,
+	HeaderRequestID, "traceparent", "tracestate",
+}, ", ")
+
+// CORSPolicy lets browsers call a service from an allowlist of origins.
+// A nil *CORSPolicy allows no cross-origin callers.
+type CORSPolicy struct {
+	origins   map[string]bool
+	anyOrigin bool
+}
+
+// NewCORSPolicy creates a CORSPolicy allowing origins (e.g. "https://stats.example.com").
+// "*" allows any origin. It returns nil if origins is empty.
+func NewCORSPolicy(origins []string) *CORSPolicy {
+	if len(origins) == 0 {
+		return nil
+	}
+	p := &CORSPolicy{origins: make(map[string]bool, len(origins))}
+	for _, origin := range origins {
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		p.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	return p
+}
+
+// ParseCORSOrigins parses a comma-separated list of origins for NewCORSPolicy.
+func ParseCORSOrigins(s string) []string {
+	var origins []string
+	for _, origin := range strings.Split(s, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// AllowsOrigin reports whether a browser on origin may call the service.
+func (p *CORSPolicy) AllowsOrigin(origin string) bool {
+	if p == nil || origin == "" {
+		return false
+	}
+	return p.anyOrigin || p.origins[origin]
+}
+
+// CheckOrigin is a websocket.Upgrader CheckOrigin function for the policy. Requests without an
+// Origin header don't come from a browser and are allowed.
+func (p *CORSPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || p.AllowsOrigin(origin)
+}
+
+// Middleware answers preflight requests and adds CORS headers to responses for allowed origins.
+// Responses to other origins carry no CORS headers, so browsers withhold them from the caller.
+func (p *CORSPolicy) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+		}
+		allowed := p.AllowsOrigin(origin)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Expose-Headers", HeaderRequestID+", Retry-After")
+		}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				WriteError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs makes the default logger write JSON lines to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{"kept from caller", "abc-123", false},
+		{"generated when missing", "", true},
+		{"replaced when unprintable", "bad id\n", true},
+		{"replaced when too long", strings.Repeat("x", 129), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if seen == "" || rec.Header().Get(HeaderRequestID) != seen {
+				t.Fatalf("context ID %q, response header %q; want the same non-empty ID", seen, rec.Header().Get(HeaderRequestID))
+			}
+			if tt.generate == (seen == tt.header) {
+				t.Errorf("request ID = %q for header %q", seen, tt.header)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	handler := RequestIDMiddleware(LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, http.StatusServiceUnavailable, "Season is ending")
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/game/online", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("access log is not one JSON line: %q", logs.String())
+	}
+	if line["request_id"] != "req-1" || line["path"] != "/game/online" || line["status"] != float64(http.StatusServiceUnavailable) || line["level"] != "ERROR" {
+		t.Errorf("access log = %v", line)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	logs := captureLogs(t)
+	Logger(WithRequestID(context.Background(), "req-2")).Info("tagged")
+	Logger(context.Background()).Info("untagged")
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], `"request_id":"req-2"`) {
+		t.Errorf("line without the request ID: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("line for a context without a request ID is tagged: %s", lines[1])
+	}
+
+	if id := RequestIDFromContext(WithNewRequestID(context.Background())); len(id) != 16 {
+		t.Errorf("new request ID %q, want 16 hex characters", id)
+	}
+}
+
+func TestClientForwardsRequestID(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(HeaderRequestID)
+		WriteJSON(w, http.StatusOK, map[string]string{})
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL, time.Second)
+	var result map[string]string
+	if err := client.Get(WithRequestID(context.Background(), "req-3"), "/", &result); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if received != "req-3" {
+		t.Errorf("server saw request ID %q, want req-3", received)
+	}
+}
+
+func TestSetupLogging(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	if err := SetupLogging("game-service", "debug", "text"); err != nil {
+		t.Errorf("SetupLogging: %v", err)
+	}
+	if err := SetupLogging("game-service", "loud", "json"); err == nil {
+		t.Error("an unknown level should be rejected")
+	}
+	if err := SetupLogging("game-service", "info", "xml"); err == nil {
+		t.Error("an unknown format should be rejected")
+	}
+}
+
+func TestParseCORSOrigins(t *testing.T) {
+	got := ParseCORSOrigins(" https://a.example.com, ,https://b.example.com ")
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCORSOrigins = %v, want %v", got, want)
+	}
+}
+
+func TestCORSPolicy(t *testing.T) {
+	policy := NewCORSPolicy([]string{"https://stats.example.com/"})
+	handler := policy.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/game/leaderboard", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "https://stats.example.com", false)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://stats.example.com" {
+		t.Errorf("allowed origin: status %d, Access-Control-Allow-Origin %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	rec = serve(http.MethodGet, "https://evil.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("other origins must not get CORS headers")
+	}
+	if rec := serve(http.MethodOptions, "https://stats.example.com", true); rec.Code != http.StatusNoContent {
+		t.Errorf("preflight from an allowed origin: status %d", rec.Code)
+	}
+	if rec := serve(http.MethodOptions, "https://evil.example.com", true); rec.Code != http.StatusForbidden {
+		t.Errorf("preflight from another origin: status %d, want 403", rec.Code)
+	}
+
+	if NewCORSPolicy(nil) != nil {
+		t.Error("a policy without origins should be nil")
+	}
+	var none *CORSPolicy
+	if none.AllowsOrigin("https://stats.example.com") {
+		t.Error("a nil policy should allow no origins")
+	}
+	if !NewCORSPolicy([]string{"*"}).AllowsOrigin("https://anything.example.com") {
+		t.Error(`"*" should allow any origin`)
+	}
+}
+
+func TestCORSPolicyCheckOrigin(t *testing.T) {
+	policy := NewCORSPolicy([]string{"https://stats.example.com"})
+	req := httptest.NewRequest(http.MethodGet, "/game/ws/teams", nil)
+	if !policy.CheckOrigin(req) {
+		t.Error("clients that are not browsers should be allowed")
+	}
+	req.Header.Set("Origin", "https://evil.example.com")
+	if policy.CheckOrigin(req) {
+		t.Error("browsers on other origins should be refused")
+	}
+}
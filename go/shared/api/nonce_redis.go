@@ -0,0 +1,38 @@
+// go/shared/api/nonce_redis.go
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisNonceStore remembers nonces in Redis, so a signed request accepted by one instance cannot be
+// replayed against another instance sharing it.
+type RedisNonceStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisNonceStore creates a RedisNonceStore. prefix namespaces the nonce keys
+// (e.g. "game-service"), so services sharing a Redis keep separate nonces.
+func NewRedisNonceStore(client redis.Cmdable, prefix string) *RedisNonceStore {
+	return &RedisNonceStore{client: client, prefix: prefix}
+}
+
+// Remember implements NonceStore.
+func (s *RedisNonceStore) Remember(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+	// The hash tag spreads nonces over the cluster rather than pinning them to one slot
+	key := fmt.Sprintf("auth_nonce:%s:{%s}:", s.prefix, nonce)
+	fresh, err := s.client.SetNX(ctx, key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to remember request nonce: %w", err)
+	}
+	return fresh, nil
+}
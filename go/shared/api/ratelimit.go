@@ -0,0 +1,301 @@
+// go/shared/api/ratelimit.go
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket: it holds up to Burst requests and refills at Rate requests per second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimit parses a "rate/burst" limit, e.g. "1/20".
+func ParseRateLimit(s string) (RateLimit, error) {
+	rateStr, burstStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: expected rate/burst", s)
+	}
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate in rate limit %q", s)
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst < 1 {
+		return RateLimit{}, fmt.Errorf("invalid burst in rate limit %q", s)
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// ParseRateLimits parses a comma-separated list of "key=rate/burst" entries, e.g. "proxy=500/1000,staff-panel=5/10".
+// An empty string yields no limits.
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid rate limit %q: expected key=rate/burst", entry)
+		}
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for %q: %w", key, err)
+		}
+		limits[key] = limit
+	}
+	return limits, nil
+}
+
+// RateLimitStore keeps the token buckets of a RateLimiter.
+type RateLimitStore interface {
+	// Take takes a token from the bucket under key. If the bucket is empty it returns false and how long
+	// until a token is available.
+	Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// MemoryRateLimitStore keeps token buckets in memory, so limits hold per instance.
+// Use a RedisRateLimitStore for limits that hold across instances.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time // When full buckets were last forgotten
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket will have refilled completely
+}
+
+// NewMemoryRateLimitStore creates an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.pruned) > time.Minute {
+		// A bucket that has refilled completely is the same as a new one
+		for k, b := range s.buckets {
+			if now.After(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((float64(limit.Burst) - b.tokens) / limit.Rate * float64(time.Second)))
+	if allowed {
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), nil
+}
+
+// RateLimiter limits requests per caller and route with token buckets. Authenticated callers are
+// told apart by their credential ID, everyone else by IP address. Service callers (internal or admin
+// scope) are only limited by a quota of their own in Callers, so internal traffic is never throttled
+// by the limits meant for the public. Failed authentications are limited per IP address separately.
+type RateLimiter struct {
+	store             RateLimitStore
+	defaultLimit      RateLimit
+	callerLimits      map[string]RateLimit // By credential ID, or "ip:<address>"
+	routeLimits       map[string]RateLimit // By route path template
+	authFailureLimit  RateLimit
+	trustForwardedFor bool
+
+	mu      sync.Mutex
+	blocked map[string]time.Time // IP address -> when it may try to authenticate again
+}
+
+// RateLimiterConfig configures a RateLimiter.
+type RateLimiterConfig struct {
+	Store             RateLimitStore       // Where buckets are kept; a MemoryRateLimitStore if nil
+	Default           RateLimit            // Limit per caller and route unless overridden; a zero Rate limits nothing
+	Callers           map[string]RateLimit // Quotas per caller (credential ID, or "ip:<address>"), taking precedence over Routes
+	Routes            map[string]RateLimit // Limits per route path template (e.g. "/game/online")
+	AuthFailures      RateLimit            // Failed authentications allowed per IP address; a zero Rate allows any number
+	TrustForwardedFor bool                 // Take client IPs from X-Forwarded-For; only behind a proxy that sets it
+}
+
+// NewRateLimiter creates a RateLimiter.
+func NewRateLimiter(cfg RateLimiterConfig) *RateLimiter {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{
+		store:             cfg.Store,
+		defaultLimit:      cfg.Default,
+		callerLimits:      cfg.Callers,
+		routeLimits:       cfg.Routes,
+		authFailureLimit:  cfg.AuthFailures,
+		trustForwardedFor: cfg.TrustForwardedFor,
+		blocked:           make(map[string]time.Time),
+	}
+}
+
+// Limit wraps next, the handler of the route with path template route, so that each caller gets its own
+// token bucket for it. Over-limit requests get 429 Too Many Requests with a Retry-After header.
+// A nil *RateLimiter limits nothing. Wrap it inside Auth.Require to tell authenticated callers apart.
+func (rl *RateLimiter) Limit(route string, next http.Handler) http.Handler {
+	if rl == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := rl.caller(r)
+		limit, ok := rl.callerLimits[caller]
+		if !ok {
+			if p := PrincipalFromContext(r.Context()); p != nil && p.HasScope(ScopeInternal) {
+				next.ServeHTTP(w, r) // Service traffic without a quota of its own
+				return
+			}
+			limit, ok = rl.routeLimits[route]
+		}
+		if !ok {
+			limit = rl.defaultLimit
+		}
+		if limit.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, wait, err := rl.store.Take(r.Context(), caller+"|"+route, limit)
+		if err != nil {
+			// Don't turn a store outage into an outage of every route
+			Logger(r.Context()).Warn("Rate limit check failed, allowing request", "caller", caller, "route", route, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			WriteError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitAuthFailures wraps next, a handler behind Auth.Require, so that each IP address may only fail
+// authentication as often as the AuthFailures limit allows. Once it is used up, the address gets
+// 429 Too Many Requests without its credentials being checked, until the limit has refilled.
+// A nil *RateLimiter limits nothing.
+func (rl *RateLimiter) LimitAuthFailures(next http.Handler) http.Handler {
+	if rl == nil || rl.authFailureLimit.Rate <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := rl.clientIP(r)
+		if wait := rl.blockedFor(caller); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			WriteError(w, http.StatusTooManyRequests, "Too many failed authentication attempts")
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status != http.StatusUnauthorized {
+			return
+		}
+		allowed, wait, err := rl.store.Take(r.Context(), caller+"|auth_failures", rl.authFailureLimit)
+		if err != nil {
+			Logger(r.Context()).Warn("Auth failure limit check failed", "caller", caller, "error", err)
+			return
+		}
+		if !allowed {
+			rl.block(caller, wait)
+		}
+	})
+}
+
+// blockedFor returns how long caller must wait before authenticating again, or 0.
+func (rl *RateLimiter) blockedFor(caller string) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	until, ok := rl.blocked[caller]
+	if !ok {
+		return 0
+	}
+	wait := time.Until(until)
+	if wait <= 0 {
+		delete(rl.blocked, caller)
+		return 0
+	}
+	return wait
+}
+
+// block stops caller from authenticating for wait, and forgets callers whose block has run out.
+func (rl *RateLimiter) block(caller string, wait time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	for c, until := range rl.blocked {
+		if !now.Before(until) {
+			delete(rl.blocked, c)
+		}
+	}
+	rl.blocked[caller] = now.Add(wait)
+}
+
+// caller identifies who made r: the authenticated credential ID, or "ip:<address>".
+func (rl *RateLimiter) caller(r *http.Request) string {
+	if p := PrincipalFromContext(r.Context()); p != nil {
+		return p.ID
+	}
+	return rl.clientIP(r)
+}
+
+// clientIP identifies the client r came from as "ip:<address>".
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+	if rl.trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return "ip:" + strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date. It returns false if the
+// header is missing or invalid.
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
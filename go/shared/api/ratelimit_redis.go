@@ -0,0 +1,62 @@
+// go/shared/api/ratelimit_redis.go
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeTokenScript refills and takes a token from a bucket stored as a hash of its tokens and the
+// time they were counted at. It returns {1, 0} if a token was taken, or {0, ms until one is available}.
+// KEYS[1] bucket; ARGV[1] rate per second, ARGV[2] burst, ARGV[3] now in unix ms.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+-- Once full again, the bucket is the same as a missing one
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {allowed, wait}
+`)
+
+// RedisRateLimitStore keeps token buckets in Redis, so limits hold across every instance sharing it.
+type RedisRateLimitStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisRateLimitStore creates a RedisRateLimitStore. prefix namespaces the bucket keys
+// (e.g. "game-service"), so services sharing a Redis keep separate limits.
+func NewRedisRateLimitStore(client redis.Scripter, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+// Take implements RateLimitStore.
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	bucketKey := fmt.Sprintf("ratelimit:%s:%s", s.prefix, key)
+	result, err := takeTokenScript.Run(ctx, s.client, []string{bucketKey}, limit.Rate, limit.Burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to take rate limit token for %s: %w", key, err)
+	}
+	if len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result for %s: %v", key, result)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
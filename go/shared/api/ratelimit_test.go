@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("0.5/20")
+	if err != nil {
+		t.Fatalf("ParseRateLimit: %v", err)
+	}
+	if limit.Rate != 0.5 || limit.Burst != 20 {
+		t.Errorf("limit = %+v, want 0.5/20", limit)
+	}
+	for _, s := range []string{"", "5", "x/10", "0/10", "-1/10", "5/0", "5/x"} {
+		if _, err := ParseRateLimit(s); err == nil {
+			t.Errorf("ParseRateLimit(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits(" proxy=500/1000, staff-panel=5/10,")
+	if err != nil {
+		t.Fatalf("ParseRateLimits: %v", err)
+	}
+	if len(limits) != 2 || limits["proxy"] != (RateLimit{Rate: 500, Burst: 1000}) || limits["staff-panel"] != (RateLimit{Rate: 5, Burst: 10}) {
+		t.Errorf("limits = %v", limits)
+	}
+	if limits, err := ParseRateLimits(""); err != nil || len(limits) != 0 {
+		t.Errorf("ParseRateLimits(\"\") = %v, %v; want no limits", limits, err)
+	}
+	for _, s := range []string{"proxy", "=5/10", "proxy=5"} {
+		if _, err := ParseRateLimits(s); err == nil {
+			t.Errorf("ParseRateLimits(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+// testTokenBucket checks that a store allows a full burst, then refuses with the wait until the next token.
+func testTokenBucket(t *testing.T, store RateLimitStore) {
+	ctx := context.Background()
+	limit := RateLimit{Rate: 1, Burst: 3}
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _, err := store.Take(ctx, "caller", limit); err != nil || !allowed {
+			t.Fatalf("take %d = %v, %v; want allowed", i+1, allowed, err)
+		}
+	}
+	allowed, wait, err := store.Take(ctx, "caller", limit)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if allowed {
+		t.Fatal("take beyond the burst was allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want up to one token interval", wait)
+	}
+	if allowed, _, _ := store.Take(ctx, "other", limit); !allowed {
+		t.Error("another key should have its own bucket")
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	testTokenBucket(t, NewMemoryRateLimitStore())
+
+	// Tokens refill at Rate per second
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Rate: 100, Burst: 1}
+	store.Take(context.Background(), "caller", limit)
+	time.Sleep(20 * time.Millisecond)
+	if allowed, _, _ := store.Take(context.Background(), "caller", limit); !allowed {
+		t.Error("bucket did not refill")
+	}
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	testTokenBucket(t, NewRedisRateLimitStore(client, "test"))
+	if ttl := server.TTL("ratelimit:test:caller"); ttl <= 0 {
+		t.Errorf("bucket TTL = %v, want it to expire once refilled", ttl)
+	}
+}
+
+func TestRateLimiterLimit(t *testing.T) {
+	rl := NewRateLimiter(RateLimiterConfig{
+		Default: RateLimit{Rate: 1, Burst: 1},
+		Callers: map[string]RateLimit{"proxy": {Rate: 1, Burst: 2}},
+	})
+	handler := rl.Limit("/game/online", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(principal *Principal, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/game/online", nil)
+		req.RemoteAddr = remoteAddr
+		if principal != nil {
+			req = req.WithContext(context.WithValue(req.Context(), principalContextKey{}, principal))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(nil, "10.0.0.1:1234"); rec.Code != http.StatusNoContent {
+		t.Fatalf("first anonymous request: status %d", rec.Code)
+	}
+	rec := serve(nil, "10.0.0.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second anonymous request: status %d, want 429", rec.Code)
+	}
+	if seconds, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || seconds < 1 {
+		t.Errorf("Retry-After = %q, want whole seconds", rec.Header().Get("Retry-After"))
+	}
+	if rec := serve(nil, "10.0.0.2:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("another IP address: status %d", rec.Code)
+	}
+
+	// Service callers are only limited by a quota of their own
+	game := &Principal{ID: "game", Scopes: []Scope{ScopeInternal}}
+	for i := 0; i < 5; i++ {
+		if rec := serve(game, "10.0.0.1:1234"); rec.Code != http.StatusNoContent {
+			t.Fatalf("internal caller without a quota: status %d", rec.Code)
+		}
+	}
+	proxy := &Principal{ID: "proxy", Scopes: []Scope{ScopeInternal}}
+	codes := []int{serve(proxy, "").Code, serve(proxy, "").Code, serve(proxy, "").Code}
+	if codes[0] != http.StatusNoContent || codes[1] != http.StatusNoContent || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("caller with a quota of 2: statuses %v", codes)
+	}
+}
+
+func TestRateLimiterLimitAuthFailures(t *testing.T) {
+	rl := NewRateLimiter(RateLimiterConfig{AuthFailures: RateLimit{Rate: 0.01, Burst: 2}})
+	calls := 0
+	handler := rl.LimitAuthFailures(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		WriteError(w, http.StatusUnauthorized, "Authentication required")
+	}))
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/game/ban", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Two failures fit the burst; the third uses up the limit and blocks the address
+	for i := 0; i < 3; i++ {
+		if code := serve(); code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want 401", i+1, code)
+		}
+	}
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Errorf("blocked address: status %d, want 429", code)
+	}
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3; a blocked address must not reach it", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
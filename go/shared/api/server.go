@@ -12,27 +12,49 @@ import (
 type BaseServer struct {
 	Router *mux.Router
 	Server *http.Server
+	Auth   *Auth // Checks the scope of routes registered with HandleFunc; nil leaves them open
+
+	RateLimiter *RateLimiter // Limits each caller's requests to routes registered with HandleFunc; nil limits nothing
+	CORS        *CORSPolicy  // Origins browsers may call from; nil allows none
 }
 
 func NewBaseServer(addr string) *BaseServer {
 	router := mux.NewRouter()
 
 	// Apply common middleware
+	router.Use(TracingMiddleware)
+	router.Use(RequestIDMiddleware)
 	router.Use(LoggingMiddleware)
-	router.Use(CORSMiddleware)
+	router.Use(MetricsMiddleware)
 
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      router,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
-	return &BaseServer{
+	bs := &BaseServer{
 		Router: router,
 		Server: server,
 	}
+	// CORS wraps the router rather than being router middleware, so preflight requests are answered
+	// even though routes don't match OPTIONS. bs.CORS is read per request, so it can be set later.
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs.CORS.Middleware(router).ServeHTTP(w, r)
+	})
+	// Scrapers authenticate like any other internal caller, e.g. with a bearer API key
+	bs.HandleFunc("/metrics", ScopeInternal, MetricsHandler().ServeHTTP).Methods("GET")
+	return bs
+}
+
+// HandleFunc registers a route that only serves callers granted scope by bs.Auth, within the
+// limits of bs.RateLimiter. Set both before the server starts; routes registered earlier pick them up too.
+// Failed authentications count against the caller's IP address before its credentials are checked.
+func (bs *BaseServer) HandleFunc(path string, scope Scope, handler http.HandlerFunc) *mux.Route {
+	return bs.Router.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs.RateLimiter.LimitAuthFailures(bs.Auth.Require(scope, bs.RateLimiter.Limit(path, handler))).ServeHTTP(w, r)
+	}))
 }
 
 func (bs *BaseServer) Start() error {
@@ -0,0 +1,177 @@
+// go/shared/api/tracing.go
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName identifies the spans this package and its hooks create.
+const tracerName = "github.com/Ftotnem/Backend/go/shared/api"
+
+// Tracing exporters accepted by SetupTracing.
+const (
+	TracingExporterNone   = "none"   // Spans are not recorded; trace context is still propagated
+	TracingExporterOTLP   = "otlp"   // OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables
+	TracingExporterStdout = "stdout" // Pretty-printed JSON on stdout, for local use
+	TracingExporterFile   = "file"   // JSON lines appended to TracingConfig.File, for local use
+)
+
+// TracingConfig configures SetupTracing.
+type TracingConfig struct {
+	Exporter    string  // One of the TracingExporter* constants; "" is TracingExporterNone
+	File        string  // Path the file exporter appends to
+	SampleRatio float64 // Fraction of new traces recorded; traces sampled by the caller are always recorded
+}
+
+// SetupTracing installs the global OpenTelemetry tracer provider for service and W3C trace-context
+// propagation. The returned function flushes buffered spans and must be called on shutdown.
+func SetupTracing(service string, cfg TracingConfig) (func(context.Context) error, error) {
+	// Propagate even when not recording, so this service doesn't break the traces of its callers
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch strings.ToLower(cfg.Exporter) {
+	case "", TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterOTLP:
+		var err error
+		exporter, err = otlptracehttp.New(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+	case TracingExporterStdout:
+		var err error
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter: %w", err)
+		}
+	case TracingExporterFile:
+		var err error
+		file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file %s: %w", cfg.File, err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create file trace exporter: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %q: must be otlp, stdout, file or none", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(service)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tracing resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// tracer returns the tracer of the global provider, so spans follow a provider installed after startup.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// TracingMiddleware continues the caller's trace from the W3C traceparent header, or starts a new one,
+// with a server span per request named after the route's path template.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// startClientSpan starts the span of an outgoing request and injects its trace context into the headers.
+func startClientSpan(ctx context.Context, req *http.Request) (context.Context, trace.Span) {
+	ctx, span := tracer().Start(ctx, req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return ctx, span
+}
+
+// endClientSpan records the outcome of an outgoing request on its span and ends it.
+func endClientSpan(span trace.Span, resp *http.Response, err error) {
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp.StatusCode >= http.StatusBadRequest:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	default:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	span.End()
+}
+
+// StartChildSpan starts a span for a client operation (e.g. a database command) if ctx already carries
+// a recording span. Background loops issue commands without one; those are not traced, so they don't
+// flood the exporter with single-span traces. The returned span is a no-op when nothing is traced.
+func StartChildSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, noop.Span{}
+	}
+	return tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,54 @@
+// go/shared/api/tracing_redis.go
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisTracingHook is a go-redis hook giving each command a span, as a child of the span carried by the
+// command's context. Commands issued outside a traced request are not traced; see StartChildSpan.
+// Add it with AddHook on the client; on a ClusterClient it covers every node.
+type RedisTracingHook struct{}
+
+// DialHook implements redis.Hook.
+func (RedisTracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook.
+func (RedisTracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := StartChildSpan(ctx, "redis "+cmd.Name(), semconv.DBSystemRedis, semconv.DBOperationName(cmd.Name()))
+		defer span.End()
+		err := next(ctx, cmd)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook.
+func (RedisTracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := StartChildSpan(ctx, "redis pipeline", semconv.DBSystemRedis, semconv.DBOperationName("pipeline"),
+			attribute.Int("db.redis.pipeline_length", len(cmds)))
+		defer span.End()
+		err := next(ctx, cmds)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+// recordRedisError marks span as failed if err is a failure. A missing key (redis.Nil) is not one.
+func recordRedisError(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
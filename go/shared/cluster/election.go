@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Constants for leader election keys and default timings.
+const (
+	// Redis key format for the hash holding the current leader and its token: leader:{election_name}:
+	redisLeaderKeyFormat = "leader:{%s}:"
+	// Redis key format for the never-expiring fencing token counter: leader_fence:{election_name}:
+	redisLeaderFenceKeyFormat = "leader_fence:{%s}:"
+	// Default time a leader keeps its lease without renewing
+	DefaultLeaderLeaseTTL = 15 * time.Second
+	// Default interval between lease renewals (and campaign attempts by followers)
+	DefaultLeaderRenewInterval = 5 * time.Second
+)
+
+// DefaultLogger returns the default slog logger whatever the context. Services usually replace it
+// with one that tags each line with the request ID the context carries.
+func DefaultLogger(context.Context) *slog.Logger {
+	return slog.Default()
+}
+
+// acquireLeadershipScript takes leadership if nobody holds it, or renews it for the current holder.
+// A new leader always gets a token one higher than any token issued before it.
+//
+// KEYS[1] leader:{name}:   KEYS[2] leader_fence:{name}:
+// ARGV[1] candidate ID     ARGV[2] lease TTL in milliseconds
+//
+// Returns {1, token} if the candidate leads afterwards, {0, token} otherwise.
+var acquireLeadershipScript = redis.NewScript(`
+local holder = redis.call('HGET', KEYS[1], 'holder')
+if not holder then
+	local token = redis.call('INCR', KEYS[2])
+	redis.call('HSET', KEYS[1], 'holder', ARGV[1], 'token', token)
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return {1, token}
+elseif holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return {1, tonumber(redis.call('HGET', KEYS[1], 'token'))}
+end
+return {0, tonumber(redis.call('HGET', KEYS[1], 'token')) or 0}
+`)
+
+// releaseLeadershipScript gives up leadership only if the caller still holds it.
+//
+// KEYS[1] leader:{name}:   ARGV[1] candidate ID
+var releaseLeadershipScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'holder') == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// LeaderElectionConfig holds the configuration for a leader election.
+type LeaderElectionConfig struct {
+	// Name: Identifies the election; every candidate for the same job must use the same name. Required.
+	Name string
+	// CandidateID: Unique ID of this candidate, usually the ServiceRegistrar's service ID. Required.
+	CandidateID string
+	// LeaseTTL: How long leadership lasts without renewal. Defaults to DefaultLeaderLeaseTTL.
+	LeaseTTL time.Duration
+	// RenewInterval: How often the leader renews and followers campaign. Defaults to DefaultLeaderRenewInterval.
+	// This should be well below LeaseTTL.
+	RenewInterval time.Duration
+	// Logger: Returns the logger for a context, so services can tag log lines with their own fields.
+	// Defaults to DefaultLogger.
+	Logger func(ctx context.Context) *slog.Logger
+}
+
+// LeaderElector campaigns for leadership of a named singleton job using a Redis lease.
+// Each new term carries a fencing token that strictly increases, so writes made by a
+// leader that lost its lease without noticing can be rejected by comparing tokens.
+type LeaderElector struct {
+	config      LeaderElectionConfig
+	redisClient *redis.ClusterClient
+
+	mu        sync.RWMutex
+	token     int64     // Fencing token of the current term, 0 if not leader
+	expiresAt time.Time // Local deadline for considering ourselves leader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewLeaderElector creates a new LeaderElector. Call Start to begin campaigning.
+func NewLeaderElector(redisClient *redis.ClusterClient, config LeaderElectionConfig) (*LeaderElector, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client cannot be nil")
+	}
+	if config.Name == "" {
+		return nil, fmt.Errorf("election name cannot be empty")
+	}
+	if config.CandidateID == "" {
+		return nil, fmt.Errorf("candidate ID cannot be empty")
+	}
+	if config.LeaseTTL == 0 {
+		config.LeaseTTL = DefaultLeaderLeaseTTL
+	}
+	if config.RenewInterval == 0 {
+		config.RenewInterval = DefaultLeaderRenewInterval
+	}
+	if config.Logger == nil {
+		config.Logger = DefaultLogger
+	}
+	if config.LeaseTTL <= config.RenewInterval {
+		return nil, fmt.Errorf("LeaseTTL (%s) must be greater than RenewInterval (%s)", config.LeaseTTL, config.RenewInterval)
+	}
+
+	le := &LeaderElector{
+		config:      config,
+		redisClient: redisClient,
+	}
+	le.ctx, le.cancel = context.WithCancel(context.Background())
+	return le, nil
+}
+
+// Start makes a first campaign attempt and then keeps campaigning/renewing in the background.
+func (le *LeaderElector) Start() {
+	le.campaign()
+
+	le.wg.Add(1)
+	go le.campaignLoop()
+
+	le.config.Logger(le.ctx).Info("LeaderElector joined election", "election", le.config.Name, "candidate_id", le.config.CandidateID,
+		"lease_ttl", le.config.LeaseTTL, "renew_interval", le.config.RenewInterval)
+}
+
+// campaignLoop runs in a goroutine to renew leadership, or take it over once the previous leader's lease lapses.
+func (le *LeaderElector) campaignLoop() {
+	defer le.wg.Done()
+	ticker := time.NewTicker(le.config.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-le.ctx.Done():
+			return
+		case <-ticker.C:
+			le.campaign()
+		}
+	}
+}
+
+// campaign makes one acquire/renew attempt and updates the local leadership state.
+func (le *LeaderElector) campaign() {
+	// The local deadline is measured from before the request, so it never outlives the lease in Redis
+	start := time.Now()
+	keys := []string{le.leaderKey(), le.fenceKey()}
+	result, err := acquireLeadershipScript.Run(le.ctx, le.redisClient, keys, le.config.CandidateID, le.config.LeaseTTL.Milliseconds()).Int64Slice()
+	if err != nil {
+		if le.ctx.Err() == nil {
+			le.config.Logger(le.ctx).Warn("LeaderElector campaign failed", "election", le.config.Name, "error", err)
+		}
+		return // Keep the current deadline; leadership lapses on its own if renewals keep failing
+	}
+	if len(result) != 2 {
+		le.config.Logger(le.ctx).Warn("LeaderElector received malformed campaign result", "election", le.config.Name, "result", result)
+		return
+	}
+
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	wasLeader := le.token != 0 && start.Before(le.expiresAt)
+	if result[0] == 1 {
+		if !wasLeader || le.token != result[1] {
+			le.config.Logger(le.ctx).Info("LeaderElector became leader", "election", le.config.Name, "candidate_id", le.config.CandidateID, "fencing_token", result[1])
+		}
+		le.token = result[1]
+		le.expiresAt = start.Add(le.config.LeaseTTL)
+		return
+	}
+	if wasLeader {
+		le.config.Logger(le.ctx).Warn("LeaderElector lost leadership", "election", le.config.Name, "candidate_id", le.config.CandidateID)
+	}
+	le.token = 0
+	le.expiresAt = time.Time{}
+}
+
+// Leadership reports whether this candidate currently leads, along with the fencing token of its term.
+// Pass the token along with any write the job makes, so the store can reject writes from an older term.
+func (le *LeaderElector) Leadership() (int64, bool) {
+	le.mu.RLock()
+	defer le.mu.RUnlock()
+	if le.token == 0 || !time.Now().Before(le.expiresAt) {
+		return 0, false
+	}
+	return le.token, true
+}
+
+// IsLeader reports whether this candidate currently leads.
+func (le *LeaderElector) IsLeader() bool {
+	_, ok := le.Leadership()
+	return ok
+}
+
+// Stop stops campaigning and releases leadership (if held) so another candidate can take over immediately.
+func (le *LeaderElector) Stop(ctx context.Context) {
+	le.cancel()
+	le.wg.Wait()
+
+	le.mu.Lock()
+	wasLeader := le.token != 0
+	le.token = 0
+	le.expiresAt = time.Time{}
+	le.mu.Unlock()
+
+	if !wasLeader {
+		return
+	}
+	if err := releaseLeadershipScript.Run(ctx, le.redisClient, []string{le.leaderKey()}, le.config.CandidateID).Err(); err != nil {
+		le.config.Logger(ctx).Warn("LeaderElector failed to release leadership", "election", le.config.Name, "error", err)
+		return
+	}
+	le.config.Logger(ctx).Info("LeaderElector released leadership", "election", le.config.Name, "candidate_id", le.config.CandidateID)
+}
+
+// leaderKey returns the Redis key holding the current leader of this election.
+func (le *LeaderElector) leaderKey() string {
+	return fmt.Sprintf(redisLeaderKeyFormat, le.config.Name)
+}
+
+// fenceKey returns the Redis key holding the last fencing token issued for this election.
+func (le *LeaderElector) fenceKey() string {
+	return fmt.Sprintf(redisLeaderFenceKeyFormat, le.config.Name)
+}
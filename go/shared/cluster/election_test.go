@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestElectors(t *testing.T, ids ...string) (*miniredis.Miniredis, []*LeaderElector) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{server.Addr()}})
+	t.Cleanup(func() { client.Close() })
+
+	electors := make([]*LeaderElector, len(ids))
+	for i, id := range ids {
+		le, err := NewLeaderElector(client, LeaderElectionConfig{Name: "syncer", CandidateID: id, LeaseTTL: time.Minute, RenewInterval: time.Second})
+		if err != nil {
+			t.Fatalf("NewLeaderElector: %v", err)
+		}
+		electors[i] = le
+	}
+	return server, electors
+}
+
+func TestLeaderElectorFencingTokens(t *testing.T) {
+	_, electors := newTestElectors(t, "a", "b")
+	a, b := electors[0], electors[1]
+
+	a.campaign()
+	b.campaign()
+	token, ok := a.Leadership()
+	if !ok || token != 1 {
+		t.Fatalf("first candidate: Leadership() = %d, %v; want token 1", token, ok)
+	}
+	if b.IsLeader() {
+		t.Fatal("two candidates lead at once")
+	}
+
+	// Renewing keeps the term's token
+	a.campaign()
+	if token, _ := a.Leadership(); token != 1 {
+		t.Errorf("token after renewal = %d, want 1", token)
+	}
+
+	a.Stop(context.Background())
+	b.campaign()
+	if token, ok := b.Leadership(); !ok || token != 2 {
+		t.Errorf("next leader: Leadership() = %d, %v; want token 2", token, ok)
+	}
+	if a.IsLeader() {
+		t.Error("stopped candidate still leads")
+	}
+}
+
+func TestLeaderElectorLeaseExpiry(t *testing.T) {
+	server, electors := newTestElectors(t, "a", "b")
+	a, b := electors[0], electors[1]
+
+	a.campaign()
+	server.FastForward(time.Minute)
+	b.campaign()
+	if token, ok := b.Leadership(); !ok || token != 2 {
+		t.Errorf("after the lease lapsed: Leadership() = %d, %v; want token 2", token, ok)
+	}
+
+	a.campaign()
+	if a.IsLeader() {
+		t.Error("the old leader took leadership back while the new lease is held")
+	}
+}
+
+func TestNewLeaderElectorValidation(t *testing.T) {
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"localhost:0"}})
+	t.Cleanup(func() { client.Close() })
+
+	for _, config := range []LeaderElectionConfig{
+		{CandidateID: "a"},
+		{Name: "syncer"},
+		{Name: "syncer", CandidateID: "a", LeaseTTL: time.Second, RenewInterval: time.Second},
+	} {
+		if _, err := NewLeaderElector(client, config); err == nil {
+			t.Errorf("NewLeaderElector(%+v) succeeded, want an error", config)
+		}
+	}
+	if _, err := NewLeaderElector(nil, LeaderElectionConfig{Name: "syncer", CandidateID: "a"}); err == nil {
+		t.Error("NewLeaderElector without a client succeeded")
+	}
+}
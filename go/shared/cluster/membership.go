@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -55,6 +55,9 @@ type ServiceConfig struct {
 	HeartbeatTTL time.Duration
 	// CleanupInterval: How often the background goroutine actively removes stale entries. Defaults to DefaultCleanupInterval.
 	CleanupInterval time.Duration
+	// Logger: Returns the logger for a context, so services can tag log lines with their own fields.
+	// Defaults to DefaultLogger.
+	Logger func(ctx context.Context) *slog.Logger
 }
 
 // ServiceRegistrar manages the registration and heartbeat for a service instance in Redis using a Hash.
@@ -93,6 +96,9 @@ func NewServiceRegistrar(redisClient *redis.ClusterClient, config ServiceConfig)
 	if config.CleanupInterval == 0 {
 		config.CleanupInterval = DefaultCleanupInterval
 	}
+	if config.Logger == nil {
+		config.Logger = DefaultLogger
+	}
 
 	if config.ServiceID == "" {
 		config.ServiceID = uuid.New().String()
@@ -128,9 +134,9 @@ func (sr *ServiceRegistrar) Register() error {
 	go sr.heartbeatLoop()
 	go sr.cleanupLoop()
 
-	log.Printf("ServiceRegistrar: Registered '%s' as ID '%s' (IP: %s, Port: %d). Heartbeat every %s, TTL %s, Cleanup %s.",
-		sr.config.ServiceType, sr.config.ServiceID, sr.config.IP, sr.config.Port,
-		sr.config.HeartbeatInterval, sr.config.HeartbeatTTL, sr.config.CleanupInterval)
+	sr.config.Logger(sr.heartbeatCtx).Info("ServiceRegistrar registered instance", "service_type", sr.config.ServiceType, "service_id", sr.config.ServiceID,
+		"ip", sr.config.IP, "port", sr.config.Port, "heartbeat_interval", sr.config.HeartbeatInterval,
+		"heartbeat_ttl", sr.config.HeartbeatTTL, "cleanup_interval", sr.config.CleanupInterval)
 	return nil
 }
 
@@ -148,7 +154,6 @@ func (sr *ServiceRegistrar) sendHeartbeat(ctx context.Context) error {
 	if cmd.Err() != nil {
 		return fmt.Errorf("failed to send heartbeat for %s (%s): %w", sr.config.ServiceType, sr.config.ServiceID, cmd.Err())
 	}
-	// log.Printf("ServiceRegistrar: Sent heartbeat for %s:%s", sr.config.ServiceType, sr.config.ServiceID) // Too noisy for production
 	return nil
 }
 
@@ -164,8 +169,7 @@ func (sr *ServiceRegistrar) heartbeatLoop() {
 			return
 		case <-ticker.C:
 			if err := sr.sendHeartbeat(sr.heartbeatCtx); err != nil {
-				log.Printf("WARNING: ServiceRegistrar: Error sending heartbeat for %s (%s): %v",
-					sr.config.ServiceType, sr.config.ServiceID, err)
+				sr.config.Logger(sr.heartbeatCtx).Warn("ServiceRegistrar failed to send heartbeat", "service_type", sr.config.ServiceType, "service_id", sr.config.ServiceID, "error", err)
 			}
 		}
 	}
@@ -180,13 +184,13 @@ func (sr *ServiceRegistrar) cleanupLoop() {
 	for {
 		select {
 		case <-sr.cleanupCtx.Done():
-			log.Printf("ServiceRegistrar: Cleanup loop for '%s' (ID: %s) stopped.", sr.config.ServiceType, sr.config.ServiceID)
+			sr.config.Logger(sr.cleanupCtx).Info("ServiceRegistrar cleanup loop stopped", "service_type", sr.config.ServiceType, "service_id", sr.config.ServiceID)
 			return
 		case <-ticker.C:
 			key := sr.getRedisKey(sr.config.ServiceType)
 			results, err := sr.redisClient.HGetAll(sr.cleanupCtx, key).Result()
 			if err != nil {
-				log.Printf("WARNING: ServiceRegistrar: Error getting services for cleanup of type %s: %v", sr.config.ServiceType, err)
+				sr.config.Logger(sr.cleanupCtx).Warn("ServiceRegistrar failed to read instances for cleanup", "service_type", sr.config.ServiceType, "error", err)
 				continue
 			}
 
@@ -195,7 +199,7 @@ func (sr *ServiceRegistrar) cleanupLoop() {
 			for instanceID, infoJSON := range results {
 				var info ServiceInfo
 				if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
-					log.Printf("WARNING: ServiceRegistrar: Failed to unmarshal ServiceInfo for ID %s during cleanup: %v", instanceID, err)
+					sr.config.Logger(sr.cleanupCtx).Warn("ServiceRegistrar removing malformed instance", "service_type", sr.config.ServiceType, "instance_id", instanceID, "error", err)
 					staleIDs = append(staleIDs, instanceID) // Malformed entry, mark for deletion
 					continue
 				}
@@ -205,10 +209,10 @@ func (sr *ServiceRegistrar) cleanupLoop() {
 			}
 
 			if len(staleIDs) > 0 {
-				log.Printf("ServiceRegistrar: Cleaning up %d stale services of type '%s'.", len(staleIDs), sr.config.ServiceType)
+				sr.config.Logger(sr.cleanupCtx).Info("ServiceRegistrar removing stale instances", "service_type", sr.config.ServiceType, "count", len(staleIDs))
 				cmd := sr.redisClient.HDel(sr.cleanupCtx, key, staleIDs...)
 				if cmd.Err() != nil {
-					log.Printf("WARNING: ServiceRegistrar: Error during cleanup of stale services of type %s: %v", sr.config.ServiceType, cmd.Err())
+					sr.config.Logger(sr.cleanupCtx).Warn("ServiceRegistrar failed to remove stale instances", "service_type", sr.config.ServiceType, "error", cmd.Err())
 				}
 			}
 		}
@@ -236,7 +240,7 @@ func (sr *ServiceRegistrar) GetActiveServices(ctx context.Context, serviceType s
 	for instanceID, infoJSON := range results {
 		var info ServiceInfo
 		if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
-			log.Printf("WARNING: ServiceRegistrar: Failed to unmarshal ServiceInfo for ID %s (type %s): %v", instanceID, serviceType, err)
+			sr.config.Logger(ctx).Warn("ServiceRegistrar skipping malformed instance", "service_type", serviceType, "instance_id", instanceID, "error", err)
 			continue // Skip malformed entries, they'll be cleaned up by cleanupLoop
 		}
 
@@ -254,7 +258,7 @@ func (sr *ServiceRegistrar) Stop(ctx context.Context) {
 		return
 	}
 	sr.isStopped = true
-	log.Printf("ServiceRegistrar: Stopping '%s' (ID: %s)...", sr.config.ServiceType, sr.config.ServiceID)
+	sr.config.Logger(ctx).Info("ServiceRegistrar stopping", "service_type", sr.config.ServiceType, "service_id", sr.config.ServiceID)
 
 	sr.heartbeatCancel() // Signal heartbeat loop to stop
 	sr.cleanupCancel()   // Signal cleanup loop to stop
@@ -264,9 +268,9 @@ func (sr *ServiceRegistrar) Stop(ctx context.Context) {
 	key := sr.getRedisKey(sr.config.ServiceType)
 	cmd := sr.redisClient.HDel(ctx, key, sr.config.ServiceID)
 	if cmd.Err() != nil {
-		log.Printf("WARNING: ServiceRegistrar: Failed to deregister service %s (%s): %v", sr.config.ServiceType, sr.config.ServiceID, cmd.Err())
+		sr.config.Logger(ctx).Warn("ServiceRegistrar failed to deregister instance", "service_type", sr.config.ServiceType, "service_id", sr.config.ServiceID, "error", cmd.Err())
 	} else {
-		log.Printf("ServiceRegistrar: Successfully deregistered '%s' (ID: %s).", sr.config.ServiceType, sr.config.ServiceID)
+		sr.config.Logger(ctx).Info("ServiceRegistrar deregistered instance", "service_type", sr.config.ServiceType, "service_id", sr.config.ServiceID)
 	}
 }
 
@@ -0,0 +1,281 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Consumer defaults.
+const (
+	DefaultConsumerBatchSize = 100
+	DefaultConsumerBlock     = 5 * time.Second
+	DefaultConsumerClaimIdle = 1 * time.Minute
+)
+
+// DefaultLogger returns the default slog logger whatever the context. Services usually replace it
+// with one that tags each line with the request ID the context carries.
+func DefaultLogger(context.Context) *slog.Logger {
+	return slog.Default()
+}
+
+// Handler processes one event. Returning an error leaves the event unacknowledged, so it is
+// delivered again once it has been pending for ClaimIdle. Handlers must therefore be idempotent,
+// and should return nil for events they can never process rather than have them retried forever.
+type Handler func(ctx context.Context, event models.Event) error
+
+// ConsumerConfig holds the configuration for a Consumer.
+type ConsumerConfig struct {
+	// Stream: The stream to read. Defaults to PlayerStream.
+	Stream string
+	// Group: The consumer group; every instance of the same service uses the same group, so each
+	// event is handled by one of them. Proxies reading ProxyStream each use their own registrar ID
+	// instead, so every proxy sees every command. Required.
+	Group string
+	// Name: Unique name of this consumer within the group, usually the instance ID. Required.
+	Name string
+	// StartID: Where a newly created group starts reading: "$" for new events only (the default),
+	// "0" for everything still in the stream, or a stream ID. Ignored if the group already exists.
+	StartID string
+	// BatchSize: Most entries read per call. Defaults to DefaultConsumerBatchSize.
+	BatchSize int64
+	// Block: How long a read waits for new entries. Defaults to DefaultConsumerBlock.
+	Block time.Duration
+	// ClaimIdle: How long an entry may stay unacknowledged before any consumer in the group
+	// takes it over, covering both failed handlers and consumers that died. Defaults to DefaultConsumerClaimIdle.
+	ClaimIdle time.Duration
+	// Logger: Returns the logger for a context, so services can tag log lines with their own fields.
+	// Defaults to DefaultLogger.
+	Logger func(ctx context.Context) *slog.Logger
+}
+
+// Consumer reads a stream as part of a consumer group and acknowledges each event once its handler succeeds.
+type Consumer struct {
+	config      ConsumerConfig
+	redisClient *redis.ClusterClient
+	handler     Handler
+}
+
+// NewConsumer creates a new Consumer. Call Run to start consuming.
+func NewConsumer(redisClient *redis.ClusterClient, config ConsumerConfig, handler Handler) (*Consumer, error) {
+	if redisClient == nil {
+		return nil, fmt.Errorf("redis client cannot be nil")
+	}
+	if handler == nil {
+		return nil, fmt.Errorf("handler cannot be nil")
+	}
+	if config.Group == "" {
+		return nil, fmt.Errorf("consumer group cannot be empty")
+	}
+	if config.Name == "" {
+		return nil, fmt.Errorf("consumer name cannot be empty")
+	}
+	if config.Stream == "" {
+		config.Stream = PlayerStream
+	}
+	if config.StartID == "" {
+		config.StartID = "$"
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = DefaultConsumerBatchSize
+	}
+	if config.Block == 0 {
+		config.Block = DefaultConsumerBlock
+	}
+	if config.ClaimIdle == 0 {
+		config.ClaimIdle = DefaultConsumerClaimIdle
+	}
+	if config.Logger == nil {
+		config.Logger = DefaultLogger
+	}
+
+	return &Consumer{
+		config:      config,
+		redisClient: redisClient,
+		handler:     handler,
+	}, nil
+}
+
+// Run creates the group if needed and consumes until ctx is cancelled. It first handles entries
+// this consumer received but never acknowledged (e.g. before a crash), then new entries, and
+// periodically takes over entries left pending for ClaimIdle by any consumer in the group.
+func (c *Consumer) Run(ctx context.Context) error {
+	if err := c.ensureGroup(ctx); err != nil {
+		return err
+	}
+	c.config.Logger(ctx).Info("Events consumer reading stream", "consumer", c.config.Name, "group", c.config.Group, "stream", c.config.Stream)
+
+	if err := c.drainOwnPending(ctx); err != nil {
+		c.config.Logger(ctx).Warn("Events consumer could not read its pending entries", "consumer", c.config.Name, "error", err)
+	}
+
+	lastClaim := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(lastClaim) >= c.config.ClaimIdle {
+			if err := c.claimStale(ctx); err != nil {
+				c.config.Logger(ctx).Warn("Events consumer could not claim stale entries", "consumer", c.config.Name, "error", err)
+			}
+			lastClaim = time.Now()
+		}
+
+		streams, err := c.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.config.Group,
+			Consumer: c.config.Name,
+			Streams:  []string{c.config.Stream, ">"},
+			Count:    c.config.BatchSize,
+			Block:    c.config.Block,
+		}).Result()
+		if err == redis.Nil {
+			continue // Nothing new within Block
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.config.Logger(ctx).Error("Events consumer failed to read stream", "consumer", c.config.Name, "stream", c.config.Stream, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, stream := range streams {
+			c.handleAll(ctx, stream.Messages)
+		}
+	}
+}
+
+// ReplayFrom moves the group's position back (or forward) so every entry after id is delivered
+// to the group again. Use "0" to replay everything still in the stream.
+func (c *Consumer) ReplayFrom(ctx context.Context, id string) error {
+	if err := c.redisClient.XGroupSetID(ctx, c.config.Stream, c.config.Group, id).Err(); err != nil {
+		return fmt.Errorf("failed to move group '%s' on stream %s to %s: %w", c.config.Group, c.config.Stream, id, err)
+	}
+	c.config.Logger(ctx).Info("Events group will replay entries", "group", c.config.Group, "stream", c.config.Stream, "after", id)
+	return nil
+}
+
+// ensureGroup creates the consumer group (and the stream) unless it already exists.
+func (c *Consumer) ensureGroup(ctx context.Context) error {
+	err := c.redisClient.XGroupCreateMkStream(ctx, c.config.Stream, c.config.Group, c.config.StartID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create group '%s' on stream %s: %w", c.config.Group, c.config.Stream, err)
+	}
+	return nil
+}
+
+// drainOwnPending handles every entry already delivered to this consumer but not acknowledged.
+func (c *Consumer) drainOwnPending(ctx context.Context) error {
+	after := "0"
+	for {
+		streams, err := c.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.config.Group,
+			Consumer: c.config.Name,
+			Streams:  []string{c.config.Stream, after},
+			Count:    c.config.BatchSize,
+			Block:    -1, // Pending entries are returned immediately; never block
+		}).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			return nil
+		}
+		messages := streams[0].Messages
+		c.handleAll(ctx, messages)
+		after = messages[len(messages)-1].ID
+	}
+}
+
+// claimStale takes over entries that have been pending in the group for at least ClaimIdle and handles them.
+func (c *Consumer) claimStale(ctx context.Context) error {
+	start := "0-0"
+	for {
+		messages, next, err := c.redisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.config.Stream,
+			Group:    c.config.Group,
+			Consumer: c.config.Name,
+			MinIdle:  c.config.ClaimIdle,
+			Start:    start,
+			Count:    c.config.BatchSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) > 0 {
+			c.config.Logger(ctx).Info("Events consumer claimed stale entries", "consumer", c.config.Name, "stream", c.config.Stream, "count", len(messages))
+			c.handleAll(ctx, messages)
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// handleAll runs the handler on each entry and acknowledges the ones it succeeded on.
+// Entries that cannot be parsed are acknowledged too, since retrying them cannot help.
+func (c *Consumer) handleAll(ctx context.Context, messages []redis.XMessage) {
+	var acks []string
+	for _, msg := range messages {
+		event, err := parseEvent(msg)
+		if err != nil {
+			c.config.Logger(ctx).Warn("Events consumer dropping malformed entry", "stream", c.config.Stream, "entry_id", msg.ID, "error", err)
+			acks = append(acks, msg.ID)
+			continue
+		}
+		if err := c.handler(ctx, event); err != nil {
+			c.config.Logger(ctx).Warn("Events consumer failed to handle event; it will be retried", "consumer", c.config.Name, "event_type", event.Type, "event_id", event.ID, "error", err)
+			continue
+		}
+		acks = append(acks, msg.ID)
+	}
+	if len(acks) == 0 {
+		return
+	}
+	if err := c.redisClient.XAck(ctx, c.config.Stream, c.config.Group, acks...).Err(); err != nil {
+		c.config.Logger(ctx).Warn("Events consumer failed to acknowledge entries; they will be handled again", "consumer", c.config.Name, "count", len(acks), "error", err)
+	}
+}
+
+// Replay reads a stream from fromID (inclusive; "-" for the beginning) to its current end and
+// calls handler on each event, without a consumer group and without acknowledging anything.
+// It suits rebuilding a read model; it stops at the first handler error.
+func Replay(ctx context.Context, redisClient *redis.ClusterClient, stream, fromID string, handler Handler) error {
+	if stream == "" {
+		stream = PlayerStream
+	}
+	start := fromID
+	for {
+		messages, err := redisClient.XRangeN(ctx, stream, start, "+", DefaultConsumerBatchSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read stream %s from %s: %w", stream, start, err)
+		}
+		for _, msg := range messages {
+			event, err := parseEvent(msg)
+			if err != nil {
+				DefaultLogger(ctx).Warn("Events replay skipping malformed entry", "stream", stream, "entry_id", msg.ID, "error", err)
+				continue
+			}
+			if err := handler(ctx, event); err != nil {
+				return fmt.Errorf("replay of stream %s stopped at %s: %w", stream, msg.ID, err)
+			}
+		}
+		if len(messages) < DefaultConsumerBatchSize {
+			return nil
+		}
+		start = "(" + messages[len(messages)-1].ID // Exclusive, so the last entry is not read twice
+	}
+}
@@ -0,0 +1,251 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis starts an in-memory Redis, which answers as a single-node cluster.
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.ClusterClient) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{server.Addr()}})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+// newTestConsumer creates a consumer in group "game" that reads the player stream from its start.
+// Its handler is set by runUntil, or by the test.
+func newTestConsumer(t *testing.T, client *redis.ClusterClient, name string) *Consumer {
+	t.Helper()
+	noop := func(context.Context, models.Event) error { return nil }
+	c, err := NewConsumer(client, ConsumerConfig{Group: "game", Name: name, StartID: "0", Block: 10 * time.Millisecond}, noop)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	return c
+}
+
+// runUntil runs a consumer until it has handled want events, counting every call to handle.
+// It stops the consumer shortly after the last one, so the batch is acknowledged first.
+func runUntil(t *testing.T, c *Consumer, want int, handle func(models.Event) error) []models.Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var seen []models.Event
+	c.handler = func(_ context.Context, event models.Event) error {
+		seen = append(seen, event)
+		if len(seen) == want {
+			time.AfterFunc(50*time.Millisecond, cancel)
+		}
+		return handle(event)
+	}
+	c.Run(ctx)
+	if len(seen) < want {
+		t.Fatalf("consumer %s handled %d events before timing out, want %d", c.config.Name, len(seen), want)
+	}
+	return seen
+}
+
+func pendingCount(t *testing.T, client *redis.ClusterClient) int64 {
+	t.Helper()
+	pending, err := client.XPending(context.Background(), PlayerStream, "game").Result()
+	if err != nil {
+		t.Fatalf("XPending: %v", err)
+	}
+	return pending.Count
+}
+
+func publishAll(t *testing.T, publisher *Publisher, subjects ...string) []string {
+	t.Helper()
+	ids := make([]string, len(subjects))
+	for i, subject := range subjects {
+		id, err := publisher.Publish(context.Background(), models.EventPlayerOffline, subject, map[string]string{"n": subject})
+		if err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func TestPublisherStreams(t *testing.T) {
+	server, client := newTestRedis(t)
+	ctx := context.Background()
+
+	before := time.Now()
+	if _, err := NewPublisher(client, "game-service").Publish(ctx, models.EventPlayerOffline, "alice", map[string]string{"reason": "quit"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if _, err := NewProxyPublisher(client, "game-service").Publish(ctx, "player.kick", "bob", nil); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	if entries, _ := server.Stream(PlayerStream); len(entries) != 1 {
+		t.Errorf("%s holds %d entries, want 1", PlayerStream, len(entries))
+	}
+	if entries, _ := server.Stream(ProxyStream); len(entries) != 1 {
+		t.Errorf("%s holds %d entries, want 1", ProxyStream, len(entries))
+	}
+
+	var event models.Event
+	err := Replay(ctx, client, "", "-", func(_ context.Context, e models.Event) error {
+		event = e
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if event.Type != models.EventPlayerOffline || event.Source != "game-service" || event.Subject != "alice" || string(event.Data) != `{"reason":"quit"}` {
+		t.Errorf("event read back = %+v", event)
+	}
+	if event.OccurredAt.Before(before.Add(-time.Second)) || event.OccurredAt.After(time.Now()) {
+		t.Errorf("occurred at %v, want the time of publishing", event.OccurredAt)
+	}
+}
+
+func TestConsumerRedeliversUnackedEntries(t *testing.T) {
+	_, client := newTestRedis(t)
+	publishAll(t, NewPublisher(client, "test"), "p1", "p2")
+
+	// The first handler fails on p1, which stays pending for this consumer
+	first := newTestConsumer(t, client, "a")
+	runUntil(t, first, 2, func(e models.Event) error {
+		if e.Subject == "p1" {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if n := pendingCount(t, client); n != 1 {
+		t.Fatalf("%d entries pending after one failure, want 1", n)
+	}
+
+	// Restarting the consumer handles its own pending entry before anything new
+	restarted := newTestConsumer(t, client, "a")
+	seen := runUntil(t, restarted, 1, func(models.Event) error { return nil })
+	if seen[0].Subject != "p1" {
+		t.Errorf("restarted consumer handled %s first, want p1", seen[0].Subject)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Errorf("%d entries still pending", n)
+	}
+}
+
+func TestConsumerClaimsStaleEntries(t *testing.T) {
+	server, client := newTestRedis(t)
+	start := time.Now()
+	server.SetTime(start)
+	publishAll(t, NewPublisher(client, "test"), "p1")
+
+	failing := newTestConsumer(t, client, "a")
+	runUntil(t, failing, 1, func(models.Event) error { return errors.New("down") })
+
+	var claimed []string
+	other := newTestConsumer(t, client, "b")
+	other.handler = func(_ context.Context, e models.Event) error {
+		claimed = append(claimed, e.Subject)
+		return nil
+	}
+	if err := other.claimStale(context.Background()); err != nil {
+		t.Fatalf("claimStale: %v", err)
+	}
+	if len(claimed) != 0 {
+		t.Fatalf("claimed %v before it had been idle for ClaimIdle", claimed)
+	}
+
+	server.SetTime(start.Add(DefaultConsumerClaimIdle + time.Second))
+	if err := other.claimStale(context.Background()); err != nil {
+		t.Fatalf("claimStale: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0] != "p1" {
+		t.Errorf("claimed %v, want p1", claimed)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Errorf("%d entries still pending after the claim", n)
+	}
+}
+
+func TestConsumerAcksMalformedEntries(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+	client.XAdd(ctx, &redis.XAddArgs{Stream: PlayerStream, Values: []interface{}{"subject", "no type"}})
+	publishAll(t, NewPublisher(client, "test"), "p1")
+
+	seen := runUntil(t, newTestConsumer(t, client, "a"), 1, func(models.Event) error { return nil })
+	if seen[0].Subject != "p1" {
+		t.Errorf("handled %+v, want only the well-formed event", seen)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Errorf("%d entries pending; a malformed entry should be acknowledged", n)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	_, client := newTestRedis(t)
+	ctx := context.Background()
+
+	// More entries than one batch, so Replay has to page
+	subjects := make([]string, DefaultConsumerBatchSize+50)
+	for i := range subjects {
+		subjects[i] = fmt.Sprintf("p%d", i)
+	}
+	ids := publishAll(t, NewPublisher(client, "test"), subjects...)
+
+	var seen []models.Event
+	err := Replay(ctx, client, PlayerStream, ids[40], func(_ context.Context, e models.Event) error {
+		seen = append(seen, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(seen) != len(subjects)-40 {
+		t.Fatalf("replayed %d events, want %d", len(seen), len(subjects)-40)
+	}
+	for i, e := range seen {
+		if e.ID != ids[40+i] || e.Subject != subjects[40+i] {
+			t.Fatalf("event %d = %s %s, want %s %s", i, e.ID, e.Subject, ids[40+i], subjects[40+i])
+		}
+	}
+
+	handled := 0
+	err = Replay(ctx, client, PlayerStream, "-", func(context.Context, models.Event) error {
+		handled++
+		if handled == 3 {
+			return errors.New("read model unavailable")
+		}
+		return nil
+	})
+	if err == nil || handled != 3 {
+		t.Errorf("Replay with a failing handler = %v after %d events, want it to stop at the failure", err, handled)
+	}
+}
+
+func TestNewConsumerValidation(t *testing.T) {
+	_, client := newTestRedis(t)
+	handler := func(context.Context, models.Event) error { return nil }
+
+	c, err := NewConsumer(client, ConsumerConfig{Group: "game", Name: "a"}, handler)
+	if err != nil {
+		t.Fatalf("NewConsumer: %v", err)
+	}
+	if c.config.Stream != PlayerStream || c.config.StartID != "$" || c.config.ClaimIdle != DefaultConsumerClaimIdle {
+		t.Errorf("defaults = %+v", c.config)
+	}
+	if _, err := NewConsumer(client, ConsumerConfig{Name: "a"}, handler); err == nil {
+		t.Error("NewConsumer without a group succeeded")
+	}
+	if _, err := NewConsumer(client, ConsumerConfig{Group: "game"}, handler); err == nil {
+		t.Error("NewConsumer without a name succeeded")
+	}
+	if _, err := NewConsumer(client, ConsumerConfig{Group: "game", Name: "a"}, nil); err == nil {
+		t.Error("NewConsumer without a handler succeeded")
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/Ftotnem/Backend/go/shared/events
+
+go 1.24.2
+
+require (
+	github.com/Ftotnem/Backend/go/shared/models v0.0.0-20250527153451-3d298d427332
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/redis/go-redis/v9 v9.9.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
@@ -0,0 +1,116 @@
+// Package events publishes domain events to Redis Streams and consumes them through consumer groups,
+// so services can react to each other's changes without calling one another over HTTP.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// PlayerStream is the stream player lifecycle events are published to. The hash tag pins
+	// the whole stream to one cluster slot, which streams require anyway.
+	PlayerStream = "events:{player}:"
+	// ProxyStream is the stream commands for proxies (e.g. kicks) are published to. Every proxy reads it
+	// through a consumer group of its own, named after its registrar ID, so each proxy sees every command.
+	ProxyStream = "events:{proxy}:"
+	// DefaultStreamMaxLen is roughly how many entries a stream keeps; older ones are trimmed on publish.
+	DefaultStreamMaxLen = 100000
+	// DefaultProxyStreamMaxLen is roughly how many entries ProxyStream keeps. Commands only matter
+	// while the player is still connected, so far fewer are kept.
+	DefaultProxyStreamMaxLen = 10000
+)
+
+// Stream entry field names.
+const (
+	fieldType       = "type"
+	fieldSource     = "source"
+	fieldSubject    = "subject"
+	fieldOccurredAt = "occurred_at"
+	fieldData       = "data"
+)
+
+// Publisher appends events to a stream.
+type Publisher struct {
+	redisClient *redis.ClusterClient
+	stream      string
+	source      string
+	maxLen      int64
+}
+
+// NewPublisher creates a Publisher that stamps its events with source and appends them to PlayerStream.
+func NewPublisher(redisClient *redis.ClusterClient, source string) *Publisher {
+	return &Publisher{
+		redisClient: redisClient,
+		stream:      PlayerStream,
+		source:      source,
+		maxLen:      DefaultStreamMaxLen,
+	}
+}
+
+// NewProxyPublisher creates a Publisher that stamps its events with source and appends them to ProxyStream.
+func NewProxyPublisher(redisClient *redis.ClusterClient, source string) *Publisher {
+	return &Publisher{
+		redisClient: redisClient,
+		stream:      ProxyStream,
+		source:      source,
+		maxLen:      DefaultProxyStreamMaxLen,
+	}
+}
+
+// Publish appends an event about subject with data as its payload, and returns the event's stream ID.
+func (p *Publisher) Publish(ctx context.Context, eventType, subject string, data interface{}) (string, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s event payload: %w", eventType, err)
+	}
+
+	id, err := p.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: true, // Trimming whole macro nodes is far cheaper than an exact MAXLEN
+		Values: []interface{}{
+			fieldType, eventType,
+			fieldSource, p.source,
+			fieldSubject, subject,
+			fieldOccurredAt, time.Now().UTC().Format(time.RFC3339Nano),
+			fieldData, string(payload),
+		},
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to publish %s event for %s: %w", eventType, subject, err)
+	}
+	return id, nil
+}
+
+// parseEvent converts a stream entry back into an Event.
+func parseEvent(msg redis.XMessage) (models.Event, error) {
+	event := models.Event{ID: msg.ID}
+	str := func(field string) string {
+		s, _ := msg.Values[field].(string)
+		return s
+	}
+
+	event.Type = str(fieldType)
+	if event.Type == "" {
+		return event, fmt.Errorf("event %s has no type", msg.ID)
+	}
+	event.Source = str(fieldSource)
+	event.Subject = str(fieldSubject)
+	if data := str(fieldData); data != "" {
+		event.Data = json.RawMessage(data)
+	}
+	if occurredAt := str(fieldOccurredAt); occurredAt != "" {
+		t, err := time.Parse(time.RFC3339Nano, occurredAt)
+		if err != nil {
+			return event, fmt.Errorf("event %s has an invalid timestamp %q: %w", msg.ID, occurredAt, err)
+		}
+		event.OccurredAt = t
+	}
+	return event, nil
+}
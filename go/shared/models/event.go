@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Player lifecycle event types.
+const (
+	EventPlayerOnline   = "player.online"
+	EventPlayerOffline  = "player.offline"
+	EventPlayerBanned   = "player.banned"
+	EventPlayerUnbanned = "player.unbanned"
+	EventPlayerMuted    = "player.muted"
+	EventPlayerUnmuted  = "player.unmuted"
+	EventPlayerWarned   = "player.warned"
+	EventPlayerKicked   = "player.kicked"
+	EventProfileCreated = "profile.created"
+	EventTeamAssigned   = "team.assigned"
+)
+
+// Event is the envelope every domain event is published in. Data holds the JSON payload
+// for the event's type; use Decode to read it into the matching *Data struct.
+type Event struct {
+	ID         string          `json:"id"`          // Stream entry ID, assigned on publish; orders events
+	Type       string          `json:"type"`        // One of the Event* constants
+	Source     string          `json:"source"`      // Service that published the event (e.g., "game-service")
+	Subject    string          `json:"subject"`     // ID of the entity the event is about, usually a player UUID
+	OccurredAt time.Time       `json:"occurred_at"` // When the change happened
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals the event's payload into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// PlayerOnlineData is the payload of EventPlayerOnline.
+type PlayerOnlineData struct {
+	Team       string `json:"team,omitempty"`
+	NewSession bool   `json:"new_session"` // False if the player was already online (e.g., a proxy switch)
+	ProxyID    string `json:"proxy_id,omitempty"`
+	Server     string `json:"server,omitempty"`
+}
+
+// Reasons a player went offline.
+const (
+	OfflineReasonOffline        = "offline"         // The proxy reported the player leaving
+	OfflineReasonSessionExpired = "session_expired" // The session's presence lapsed and it was reaped
+)
+
+// PlayerOfflineData is the payload of EventPlayerOffline.
+type PlayerOfflineData struct {
+	Reason string `json:"reason"` // One of the OfflineReason* constants
+}
+
+// PlayerBannedData is the payload of EventPlayerBanned.
+type PlayerBannedData struct {
+	Reason       string     `json:"reason,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // Nil for permanent bans
+	IssuedBy     string     `json:"issued_by,omitempty"`
+	PunishmentID string     `json:"punishment_id,omitempty"` // Empty if the ban could not be recorded in the punishment history
+}
+
+// PlayerUnbannedData is the payload of EventPlayerUnbanned.
+type PlayerUnbannedData struct {
+	RevokedBy string `json:"revoked_by,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// PlayerMutedData is the payload of EventPlayerMuted.
+type PlayerMutedData struct {
+	Reason       string     `json:"reason,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // Nil for permanent mutes
+	IssuedBy     string     `json:"issued_by,omitempty"`
+	PunishmentID string     `json:"punishment_id,omitempty"`
+}
+
+// PlayerUnmutedData is the payload of EventPlayerUnmuted.
+type PlayerUnmutedData struct {
+	RevokedBy string `json:"revoked_by,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// PlayerWarnedData is the payload of EventPlayerWarned.
+type PlayerWarnedData struct {
+	Reason       string `json:"reason,omitempty"`
+	IssuedBy     string `json:"issued_by,omitempty"`
+	PunishmentID string `json:"punishment_id,omitempty"`
+	Warnings     int    `json:"warnings"` // The player's active warnings, including this one
+}
+
+// PlayerKickedData is the payload of EventPlayerKicked. It is how a kick reaches the proxy: it is
+// published to the proxy stream, which every proxy reads, and the proxy whose registrar ID is ProxyID
+// must disconnect the player with Reason. An empty ProxyID means the player's proxy is unknown, and
+// whichever proxy holds the player disconnects them. The kick is recorded once it has been delivered,
+// so PunishmentID is only set on the copy published to the player stream afterwards.
+type PlayerKickedData struct {
+	ProxyID      string `json:"proxy_id,omitempty"`
+	Server       string `json:"server,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	IssuedBy     string `json:"issued_by,omitempty"`
+	PunishmentID string `json:"punishment_id,omitempty"`
+}
+
+// ProfileCreatedData is the payload of EventProfileCreated.
+type ProfileCreatedData struct {
+	Username string `json:"username,omitempty"`
+	Team     string `json:"team"`
+}
+
+// TeamAssignedData is the payload of EventTeamAssigned.
+type TeamAssignedData struct {
+	Team         string `json:"team"`
+	PreviousTeam string `json:"previous_team,omitempty"` // Empty for a player's first team
+}
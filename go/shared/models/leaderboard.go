@@ -0,0 +1,16 @@
+package models
+
+// LeaderboardEntry is a single player's position on a playtime leaderboard.
+type LeaderboardEntry struct {
+	Rank     int64   `json:"rank"` // 1-based; rank 1 has the most playtime
+	UUID     string  `json:"uuid"`
+	Playtime float64 `json:"playtime"`
+}
+
+// LeaderboardStanding is a player's rank on a leaderboard along with how many players it holds.
+type LeaderboardStanding struct {
+	LeaderboardEntry
+	Team       string  `json:"team,omitempty"` // Set for per-team leaderboards
+	Total      int64   `json:"total"`          // Players on the leaderboard
+	Percentile float64 `json:"percentile"`     // Share of players ranked at or below this one, 0-100
+}
@@ -5,13 +5,19 @@ import (
 	"time"
 )
 
-// Booster represents and active booster a player has
+// Booster represents an active booster a player has.
+// Value is applied as a multiplier to the player's per-tick playtime increment.
 type Booster struct {
-	ID        string
-	Type      string
-	Value     float64
-	ExpiersAt time.Time
-	Source    string
+	ID        string     `bson:"id" json:"ID"`
+	Type      string     `bson:"type" json:"Type"`
+	Value     float64    `bson:"value" json:"Value"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"ExpiresAt"` // Nil for boosters that never expire
+	Source    string     `bson:"source" json:"Source"`
+}
+
+// IsExpired reports whether the booster has expired at the given time.
+func (b Booster) IsExpired(now time.Time) bool {
+	return b.ExpiresAt != nil && !now.Before(*b.ExpiresAt)
 }
 
 // Player repcrenset a player's profile data stored presistently in MongoDB
@@ -21,9 +27,13 @@ type Player struct {
 	Username           string     `bson:"username" json:"Username"`
 	Team               string     `bson:"team" json:"Team"`
 	TotalPlaytimeTicks float64    `bson:"total_playtime_ticks" json:"TotalPlaytimeTicks"`
+	SeasonEpoch        int64      `bson:"season_epoch,omitempty" json:"SeasonEpoch"` // Season epoch TotalPlaytimeTicks belongs to; see Season
 	DeltaPlaytimeTicks float64    `bson:"delta_playtime_ticks" json:"DeltaPlaytimeTicks"`
+	AFKTicks           float64    `bson:"afk_ticks" json:"AFKTicks"` // Ticks spent AFK; counted apart from playtime for moderation
 	Banned             bool       `bson:"banned" json:"Banned"`
 	BanExpiresAt       *time.Time `bson:"ban_expires_at,omitempty" json:"BanExpiresAt"`
+	BanVersion         int64      `bson:"ban_version,omitempty" json:"-"` // Bumped whenever the ban fields are derived from punishment history; 0 for bans set before it was kept
 	LastLoginAt        *time.Time `bson:"last_login_at,omitempty" json:"LastLoginAt"`
 	CreatedAt          *time.Time `bson:"created_at,omitempty" json:"CreatedAt"`
+	Boosters           []Booster  `bson:"boosters,omitempty" json:"Boosters"`
 }
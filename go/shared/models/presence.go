@@ -0,0 +1,9 @@
+package models
+
+// PlayerPresence is where an online player is connected: the proxy they joined through
+// and the backend server they are currently on. Either may be empty if not reported yet.
+type PlayerPresence struct {
+	UUID    string `json:"uuid"`
+	ProxyID string `json:"proxy_id,omitempty"` // Registrar ID of the proxy
+	Server  string `json:"server,omitempty"`   // Name of the backend server
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Punishment types. Bans and mutes last until they expire or are revoked, warnings stay active
+// (and count towards the player's warnings) until revoked, and kicks take effect immediately, so a
+// kick is recorded as already expired.
+const (
+	PunishmentTypeBan  = "ban"
+	PunishmentTypeMute = "mute"
+	PunishmentTypeWarn = "warn"
+	PunishmentTypeKick = "kick"
+)
+
+// Punishment is one entry in a player's punishment history. Entries are never deleted:
+// revoking a punishment records who revoked it and when, and expired ones simply stay on record.
+type Punishment struct {
+	ID           string     `bson:"_id" json:"ID"`
+	UUID         string     `bson:"uuid" json:"UUID"` // Punished player
+	Type         string     `bson:"type" json:"Type"` // One of the PunishmentType* constants
+	Reason       string     `bson:"reason" json:"Reason"`
+	IssuedBy     string     `bson:"issued_by" json:"IssuedBy"` // Staff member or system that issued it
+	IssuedAt     time.Time  `bson:"issued_at" json:"IssuedAt"`
+	ExpiresAt    *time.Time `bson:"expires_at,omitempty" json:"ExpiresAt"` // Nil for permanent punishments
+	RevokedAt    *time.Time `bson:"revoked_at,omitempty" json:"RevokedAt"` // Set once lifted before it expired
+	RevokedBy    string     `bson:"revoked_by,omitempty" json:"RevokedBy"`
+	RevokeReason string     `bson:"revoke_reason,omitempty" json:"RevokeReason"`
+}
+
+// IsActive reports whether the punishment is in force at the given time.
+func (p Punishment) IsActive(now time.Time) bool {
+	return p.RevokedAt == nil && (p.ExpiresAt == nil || now.Before(*p.ExpiresAt))
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Season lifecycle. A season is scheduled, becomes active, and at its end is archived:
+// live totals are copied into the season's standings (archiving) and then reset (resetting).
+const (
+	SeasonStatusScheduled = "scheduled"
+	SeasonStatusActive    = "active"
+	SeasonStatusArchiving = "archiving"
+	SeasonStatusResetting = "resetting"
+	SeasonStatusArchived  = "archived"
+)
+
+// Season is a scoped competition between the teams. Playtime accrued while it is active
+// counts towards its standings; at its end every total is archived and the live counters reset.
+//
+// Each reset moves the live totals to a new season epoch, counting the resets so far. Playtime
+// writes carry the epoch their totals belong to, and stores reject writes from an older epoch,
+// so a write still in flight when the season ended cannot bring back its totals.
+type Season struct {
+	ID         string               `bson:"_id" json:"ID"` // Short identifier chosen on creation (e.g., "s3")
+	Name       string               `bson:"name" json:"Name"`
+	StartsAt   time.Time            `bson:"starts_at" json:"StartsAt"`
+	EndsAt     time.Time            `bson:"ends_at" json:"EndsAt"`
+	Status     string               `bson:"status" json:"Status"`
+	Teams      []SeasonTeamStanding `bson:"teams,omitempty" json:"Teams"` // Final team standings, set once archived
+	CreatedAt  *time.Time           `bson:"created_at,omitempty" json:"CreatedAt"`
+	ArchivedAt *time.Time           `bson:"archived_at,omitempty" json:"ArchivedAt"`
+	ResetEpoch int64                `bson:"reset_epoch,omitempty" json:"ResetEpoch"` // Season epoch the live totals move to when reset; set once resetting
+}
+
+// SeasonTeamStanding is a team's final result in an archived season.
+type SeasonTeamStanding struct {
+	Rank               int     `bson:"rank" json:"Rank"` // 1-based; rank 1 has the most playtime
+	Team               string  `bson:"team" json:"Team"`
+	TotalPlaytimeTicks float64 `bson:"total_playtime_ticks" json:"TotalPlaytimeTicks"`
+	PlayerCount        int64   `bson:"player_count" json:"PlayerCount"`
+}
+
+// SeasonPlayerStanding is a player's archived total in a season.
+type SeasonPlayerStanding struct {
+	Rank               int64   `bson:"-" json:"Rank"` // Position in the queried standings, 1-based
+	SeasonID           string  `bson:"season_id" json:"SeasonID"`
+	UUID               string  `bson:"uuid" json:"UUID"`
+	Username           string  `bson:"username" json:"Username"`
+	Team               string  `bson:"team" json:"Team"`
+	TotalPlaytimeTicks float64 `bson:"total_playtime_ticks" json:"TotalPlaytimeTicks"`
+}
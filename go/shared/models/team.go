@@ -9,3 +9,16 @@ type Team struct {
 	CreatedAt          *time.Time `bson:"created_at"`
 	LastUpdated        *time.Time `bson:"last_updated"`
 }
+
+// TeamTotalsUpdate is one frame of the live team-total stream: every team's total playtime
+// and how many of its players are online.
+type TeamTotalsUpdate struct {
+	Teams     map[string]TeamLiveStats `json:"teams"`
+	Timestamp time.Time                `json:"timestamp"`
+}
+
+// TeamLiveStats is a single team's entry in a TeamTotalsUpdate.
+type TeamLiveStats struct {
+	TotalPlaytime float64 `json:"total_playtime"`
+	Online        int     `json:"online"`
+}
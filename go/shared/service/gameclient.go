@@ -3,10 +3,17 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/Ftotnem/Backend/go/shared/api" // Import the shared API client
-	"go.minekube.com/gate/pkg/util/uuid"       // Assuming you use google/uuid for UUIDs
+	"github.com/Ftotnem/Backend/go/shared/api"    // Import the shared API client
+	"github.com/Ftotnem/Backend/go/shared/models" // Import the shared Booster model
+	"go.minekube.com/gate/pkg/util/uuid"          // Assuming you use google/uuid for UUIDs
 )
 
 // Client is a client for the Game Service.
@@ -14,19 +21,109 @@ import (
 // although Go's scope rules generally handle this if only one 'Client' is used per file.
 // Explicitly naming helps clarity.
 type GameServiceClient struct {
-	apiClient *api.Client
+	apiClient    *api.Client
+	baseURL      string
+	streamClient *http.Client // No overall timeout, for long-lived streams
 }
 
-// NewGameClient creates a new Game Service client.
-func NewGameClient(baseURL string) *GameServiceClient {
+// NewGameClient creates a new Game Service client. Pass api.WithSigner to authenticate its requests.
+func NewGameClient(baseURL string, opts ...api.ClientOption) *GameServiceClient {
 	return &GameServiceClient{
-		apiClient: api.NewClient(baseURL, 5*time.Second), // Use the shared API client with a timeout
+		apiClient:    api.NewClient(baseURL, 5*time.Second, opts...), // Use the shared API client with a timeout
+		baseURL:      baseURL,
+		streamClient: &http.Client{},
 	}
 }
 
 // OnlineStatusRequest represents the payload for online/offline updates.
+// SessionID is the ID returned by SendPlayerOnline; it is required for offline updates.
 type OnlineStatusRequest struct {
-	UUID string `json:"uuid"`
+	UUID      string `json:"uuid"`
+	SessionID string `json:"session_id,omitempty"`
+	ProxyID   string `json:"proxy_id,omitempty"` // Registrar ID of the proxy the player joined through
+	Server    string `json:"server,omitempty"`   // Backend server the player is on
+}
+
+// OnlineResponse is the response of the /game/online endpoint.
+type OnlineResponse struct {
+	Message   string `json:"message"`
+	UUID      string `json:"uuid"`
+	SessionID string `json:"session_id"`
+}
+
+// ErrSessionSuperseded is returned (wrapped) when the game service rejects a session ID because the
+// player has logged in again since it was issued. The session must be dropped, not retried.
+var ErrSessionSuperseded = errors.New("session superseded by a newer login")
+
+// ErrSessionEnded is returned (wrapped) by SendPlayerOffline when the session has already ended,
+// e.g. a repeated offline. There is nothing left to do for it.
+var ErrSessionEnded = errors.New("session already ended")
+
+// BannedError is returned by SendPlayerOnline when the game service refuses a banned player.
+// It carries what the proxy needs to build a kick message.
+type BannedError struct {
+	UUID        string     `json:"uuid"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Nil for permanent bans
+	IsPermanent bool       `json:"is_permanent"`
+}
+
+func (e *BannedError) Error() string {
+	if e.IsPermanent || e.ExpiresAt == nil {
+		return fmt.Sprintf("player %s is permanently banned: %s", e.UUID, e.Reason)
+	}
+	return fmt.Sprintf("player %s is banned until %s: %s", e.UUID, e.ExpiresAt.Format(time.RFC3339), e.Reason)
+}
+
+// HeartbeatRequest represents the payload for presence keepalives.
+// Either UUID and SessionID (single player) or Sessions (batch) is set.
+type HeartbeatRequest struct {
+	ProxyID   string          `json:"proxy_id,omitempty"`
+	UUID      string          `json:"uuid,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Server    string          `json:"server,omitempty"`
+	Sessions  []PlayerSession `json:"sessions,omitempty"`
+}
+
+// PlayerSession identifies a player's session, as issued by SendPlayerOnline,
+// along with the backend server they are currently on.
+type PlayerSession struct {
+	UUID      string `json:"uuid"`
+	SessionID string `json:"session_id"`
+	Server    string `json:"server,omitempty"`
+}
+
+// HeartbeatResponse reports which players had their presence refreshed.
+// Players listed in Expired must be sent online again; players listed in Superseded
+// have logged in again elsewhere and must be dropped.
+type HeartbeatResponse struct {
+	Refreshed  int      `json:"refreshed"`
+	Expired    []string `json:"expired"`
+	Superseded []string `json:"superseded"`
+}
+
+// ActivityRequest represents the payload for reporting players' last input.
+// Either UUID, SessionID and LastInputAt (single player) or Players (batch) is set.
+type ActivityRequest struct {
+	UUID        string           `json:"uuid,omitempty"`
+	SessionID   string           `json:"session_id,omitempty"`
+	LastInputAt time.Time        `json:"last_input_at,omitempty"`
+	Players     []PlayerActivity `json:"players,omitempty"`
+}
+
+// PlayerActivity is the time of a player's last input in the session issued by SendPlayerOnline.
+type PlayerActivity struct {
+	UUID        string    `json:"uuid"`
+	SessionID   string    `json:"session_id"`
+	LastInputAt time.Time `json:"last_input_at"`
+}
+
+// ActivityResponse reports which players had their activity recorded.
+// Expired and Superseded mean the same as in HeartbeatResponse.
+type ActivityResponse struct {
+	Recorded   int      `json:"recorded"`
+	Expired    []string `json:"expired"`
+	Superseded []string `json:"superseded"`
 }
 
 // BanRequest is the structure for the request body for banning/unbanning.
@@ -34,42 +131,398 @@ type BanRequest struct {
 	UUID        string `json:"uuid"`
 	DurationSec int64  `json:"duration_seconds"` // Duration in seconds. 0 for permanent, -1 to unban.
 	Reason      string `json:"reason,omitempty"`
+	IssuedBy    string `json:"issued_by"` // Staff member or system issuing the ban
+}
+
+// UnbanRequest is the structure for the request body for unbanning.
+type UnbanRequest struct {
+	UUID      string `json:"uuid"`
+	RevokedBy string `json:"revoked_by"` // Staff member or system lifting the ban
+	Reason    string `json:"reason,omitempty"`
+}
+
+// MuteRequest is the structure for the request body for muting.
+type MuteRequest struct {
+	UUID        string `json:"uuid"`
+	DurationSec int64  `json:"duration_seconds"` // Duration in seconds. 0 for permanent.
+	Reason      string `json:"reason,omitempty"`
+	IssuedBy    string `json:"issued_by"` // Staff member or system issuing the mute
+}
+
+// UnmuteRequest is the structure for the request body for unmuting.
+type UnmuteRequest struct {
+	UUID      string `json:"uuid"`
+	RevokedBy string `json:"revoked_by"` // Staff member or system lifting the mute
+	Reason    string `json:"reason,omitempty"`
+}
+
+// MuteStatus reports whether a player is muted.
+type MuteStatus struct {
+	UUID        string     `json:"uuid"`
+	IsMuted     bool       `json:"is_muted"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Nil for permanent mutes
+	IsPermanent bool       `json:"is_permanent"`
 }
 
-// SendPlayerOnline sends a POST request to the /game/online endpoint.
-func (c *GameServiceClient) SendPlayerOnline(ctx context.Context, playerUUID uuid.UUID) error {
+// WarnRequest is the structure for the request body for warning a player.
+type WarnRequest struct {
+	UUID     string `json:"uuid"`
+	Reason   string `json:"reason"`
+	IssuedBy string `json:"issued_by"`
+}
+
+// WarnResponse reports a recorded warning and the player's active warnings, including it.
+type WarnResponse struct {
+	Message      string `json:"message"`
+	UUID         string `json:"uuid"`
+	PunishmentID string `json:"punishment_id"`
+	Warnings     int    `json:"warnings"`
+}
+
+// KickRequest is the structure for the request body for kicking a player.
+type KickRequest struct {
+	UUID     string `json:"uuid"`
+	Reason   string `json:"reason,omitempty"`
+	IssuedBy string `json:"issued_by"`
+}
+
+// KickResponse reports a kick that has been sent to the player's proxy.
+type KickResponse struct {
+	Message      string `json:"message"`
+	UUID         string `json:"uuid"`
+	ProxyID      string `json:"proxy_id,omitempty"` // Empty if the player's proxy was unknown
+	PunishmentID string `json:"punishment_id,omitempty"`
+}
+
+// GrantBoosterRequest is the structure for the request body for granting a booster.
+type GrantBoosterRequest struct {
+	UUID        string  `json:"uuid"`
+	Type        string  `json:"type"`
+	Value       float64 `json:"value"`            // Multiplier applied to the player's per-tick increment
+	DurationSec int64   `json:"duration_seconds"` // Duration in seconds. 0 for a booster that never expires.
+	Source      string  `json:"source,omitempty"`
+}
+
+// RevokeBoosterRequest is the structure for the request body for revoking a booster.
+type RevokeBoosterRequest struct {
+	UUID      string `json:"uuid"`
+	BoosterID string `json:"booster_id"`
+}
+
+// SendPlayerOnline sends a POST request to the /game/online endpoint and returns the player's session ID,
+// which SendPlayerOffline and SendHeartbeat must echo. Pass an empty sessionID for a new login, or the
+// current one to re-send online for a live session (e.g. after a retry or an expired heartbeat).
+// proxyID (the proxy's registrar ID) and server record where the player is; either may be empty.
+// If the player is banned, the returned error is a *BannedError (check with errors.As); if sessionID
+// has been superseded by a newer login, it wraps ErrSessionSuperseded.
+func (c *GameServiceClient) SendPlayerOnline(ctx context.Context, playerUUID uuid.UUID, sessionID, proxyID, server string) (string, error) {
 	reqData := OnlineStatusRequest{
-		UUID: playerUUID.String(),
+		UUID:      playerUUID.String(),
+		SessionID: sessionID,
+		ProxyID:   proxyID,
+		Server:    server,
 	}
-	// Use the apiClient's Post method. No response body is expected, so result is nil.
-	return c.apiClient.Post(ctx, "/game/online", reqData, nil)
+	var resp OnlineResponse
+	err := c.apiClient.Post(ctx, "/game/online", reqData, &resp)
+	var apiErr *api.HTTPError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+		bannedErr := &BannedError{UUID: playerUUID.String()}
+		// Details are best effort; the 403 alone already means the player is banned
+		_ = json.Unmarshal(apiErr.Body, bannedErr)
+		return "", bannedErr
+	}
+	if err != nil {
+		return "", wrapSessionConflict(err, playerUUID)
+	}
+	return resp.SessionID, nil
 }
 
-// SendPlayerOffline sends a POST request to the /game/offline endpoint.
-func (c *GameServiceClient) SendPlayerOffline(ctx context.Context, playerUUID uuid.UUID) error {
+// SendPlayerOffline sends a POST request to the /game/offline endpoint to end the given session.
+// The error wraps ErrSessionSuperseded if the player has logged in again since, or ErrSessionEnded
+// if the session has already ended; neither should be retried.
+func (c *GameServiceClient) SendPlayerOffline(ctx context.Context, playerUUID uuid.UUID, sessionID string) error {
 	reqData := OnlineStatusRequest{
-		UUID: playerUUID.String(),
+		UUID:      playerUUID.String(),
+		SessionID: sessionID,
 	}
 	// Use the apiClient's Post method. No response body is expected, so result is nil.
-	return c.apiClient.Post(ctx, "/game/offline", reqData, nil)
+	return wrapSessionConflict(c.apiClient.Post(ctx, "/game/offline", reqData, nil), playerUUID)
+}
+
+// wrapSessionConflict wraps the game service's responses to stale and repeated session transitions
+// with ErrSessionSuperseded (409) and ErrSessionEnded (410).
+func wrapSessionConflict(err error, playerUUID uuid.UUID) error {
+	var apiErr *api.HTTPError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.StatusCode {
+	case http.StatusConflict:
+		return fmt.Errorf("session of player %s: %w", playerUUID.String(), ErrSessionSuperseded)
+	case http.StatusGone:
+		return fmt.Errorf("session of player %s: %w", playerUUID.String(), ErrSessionEnded)
+	}
+	return err
+}
+
+// SendHeartbeat sends a POST request to the /game/heartbeat endpoint to keep players' presence alive.
+// Pass every session on the proxy to refresh them in a single batch; proxyID is the proxy's registrar ID.
+func (c *GameServiceClient) SendHeartbeat(ctx context.Context, proxyID string, sessions ...PlayerSession) (*HeartbeatResponse, error) {
+	reqData := HeartbeatRequest{ProxyID: proxyID}
+	if len(sessions) == 1 {
+		reqData.UUID = sessions[0].UUID
+		reqData.SessionID = sessions[0].SessionID
+		reqData.Server = sessions[0].Server
+	} else {
+		reqData.Sessions = sessions
+	}
+	var resp HeartbeatResponse
+	if err := c.apiClient.Post(ctx, "/game/heartbeat", reqData, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ReportActivity sends a POST request to the /game/activity endpoint with players' last input times,
+// from which the game service decides who is AFK. Report each player on join and then at least once per
+// AFK timeout while they keep giving input; players never reported are treated as active.
+func (c *GameServiceClient) ReportActivity(ctx context.Context, activity ...PlayerActivity) (*ActivityResponse, error) {
+	var reqData ActivityRequest
+	if len(activity) == 1 {
+		reqData.UUID = activity[0].UUID
+		reqData.SessionID = activity[0].SessionID
+		reqData.LastInputAt = activity[0].LastInputAt
+	} else {
+		reqData.Players = activity
+	}
+	var resp ActivityResponse
+	if err := c.apiClient.Post(ctx, "/game/activity", reqData, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetPlayersOnProxy fetches the players connected through a proxy from the /game/proxies/{proxyID}/players endpoint.
+func (c *GameServiceClient) GetPlayersOnProxy(ctx context.Context, proxyID string) ([]models.PlayerPresence, error) {
+	var players []models.PlayerPresence
+	if err := c.apiClient.Get(ctx, fmt.Sprintf("/game/proxies/%s/players", url.PathEscape(proxyID)), &players); err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+// GetPlayersOnServer fetches the players on a backend server from the /game/servers/{server}/players endpoint.
+func (c *GameServiceClient) GetPlayersOnServer(ctx context.Context, server string) ([]models.PlayerPresence, error) {
+	var players []models.PlayerPresence
+	if err := c.apiClient.Get(ctx, fmt.Sprintf("/game/servers/%s/players", url.PathEscape(server)), &players); err != nil {
+		return nil, err
+	}
+	return players, nil
 }
 
 // BanPlayer sends a POST request to the /game/ban endpoint to ban a player.
-func (c *GameServiceClient) BanPlayer(ctx context.Context, playerUUID uuid.UUID, duration time.Duration, reason string) error {
+// The ban is recorded in the player's punishment history under issuedBy.
+func (c *GameServiceClient) BanPlayer(ctx context.Context, playerUUID uuid.UUID, duration time.Duration, reason, issuedBy string) error {
 	reqData := BanRequest{
 		UUID:        playerUUID.String(),
 		DurationSec: int64(duration.Seconds()),
 		Reason:      reason,
+		IssuedBy:    issuedBy,
 	}
 	// Use the apiClient's Post method. No response body is expected, so result is nil.
 	return c.apiClient.Post(ctx, "/game/ban", reqData, nil)
 }
 
 // UnbanPlayer sends a POST request to the /game/unban endpoint to unban a player.
-func (c *GameServiceClient) UnbanPlayer(ctx context.Context, playerUUID uuid.UUID) error {
-	reqData := OnlineStatusRequest{ // Re-use OnlineStatusRequest as it only needs UUID
-		UUID: playerUUID.String(),
+// Every active ban is revoked in the player's punishment history under revokedBy.
+func (c *GameServiceClient) UnbanPlayer(ctx context.Context, playerUUID uuid.UUID, revokedBy, reason string) error {
+	reqData := UnbanRequest{
+		UUID:      playerUUID.String(),
+		RevokedBy: revokedBy,
+		Reason:    reason,
 	}
 	// Use the apiClient's Post method. No response body is expected, so result is nil.
 	return c.apiClient.Post(ctx, "/game/unban", reqData, nil)
 }
+
+// GetPunishments fetches a player's punishment history, newest first, from the /game/player/{uuid}/punishments endpoint.
+// An empty punishmentType lists every type; activeOnly leaves out expired and revoked punishments.
+func (c *GameServiceClient) GetPunishments(ctx context.Context, playerUUID uuid.UUID, punishmentType string, activeOnly bool) ([]models.Punishment, error) {
+	query := url.Values{}
+	if punishmentType != "" {
+		query.Set("type", punishmentType)
+	}
+	if activeOnly {
+		query.Set("active", "true")
+	}
+	path := fmt.Sprintf("/game/player/%s/punishments", playerUUID.String())
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var punishments []models.Punishment
+	if err := c.apiClient.Get(ctx, path, &punishments); err != nil {
+		return nil, err
+	}
+	return punishments, nil
+}
+
+// MutePlayer sends a POST request to the /game/mute endpoint to mute a player. A zero duration mutes permanently.
+// The mute is recorded in the player's punishment history under issuedBy.
+func (c *GameServiceClient) MutePlayer(ctx context.Context, playerUUID uuid.UUID, duration time.Duration, reason, issuedBy string) (*MuteStatus, error) {
+	reqData := MuteRequest{
+		UUID:        playerUUID.String(),
+		DurationSec: int64(duration.Seconds()),
+		Reason:      reason,
+		IssuedBy:    issuedBy,
+	}
+	var status MuteStatus
+	if err := c.apiClient.Post(ctx, "/game/mute", reqData, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// UnmutePlayer sends a POST request to the /game/unmute endpoint to unmute a player.
+// Every active mute is revoked in the player's punishment history under revokedBy.
+func (c *GameServiceClient) UnmutePlayer(ctx context.Context, playerUUID uuid.UUID, revokedBy, reason string) error {
+	reqData := UnmuteRequest{
+		UUID:      playerUUID.String(),
+		RevokedBy: revokedBy,
+		Reason:    reason,
+	}
+	return c.apiClient.Post(ctx, "/game/unmute", reqData, nil)
+}
+
+// GetMuteStatus fetches whether a player is muted from the /game/player/{uuid}/mute endpoint.
+// Proxies call it before relaying a player's chat.
+func (c *GameServiceClient) GetMuteStatus(ctx context.Context, playerUUID uuid.UUID) (*MuteStatus, error) {
+	var status MuteStatus
+	if err := c.apiClient.Get(ctx, fmt.Sprintf("/game/player/%s/mute", playerUUID.String()), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// WarnPlayer sends a POST request to the /game/warn endpoint to record a warning for a player.
+// The response carries the player's active warnings, so callers can escalate repeat offenders.
+func (c *GameServiceClient) WarnPlayer(ctx context.Context, playerUUID uuid.UUID, reason, issuedBy string) (*WarnResponse, error) {
+	reqData := WarnRequest{
+		UUID:     playerUUID.String(),
+		Reason:   reason,
+		IssuedBy: issuedBy,
+	}
+	var resp WarnResponse
+	if err := c.apiClient.Post(ctx, "/game/warn", reqData, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// KickPlayer sends a POST request to the /game/kick endpoint to kick an online player.
+// The game service delivers the kick to the player's proxy as a models.EventPlayerKicked event on the proxy stream.
+func (c *GameServiceClient) KickPlayer(ctx context.Context, playerUUID uuid.UUID, reason, issuedBy string) (*KickResponse, error) {
+	reqData := KickRequest{
+		UUID:     playerUUID.String(),
+		Reason:   reason,
+		IssuedBy: issuedBy,
+	}
+	var resp KickResponse
+	if err := c.apiClient.Post(ctx, "/game/kick", reqData, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GrantBooster sends a POST request to the /game/boosters/grant endpoint.
+// A zero duration grants a booster that never expires.
+func (c *GameServiceClient) GrantBooster(ctx context.Context, playerUUID uuid.UUID, boosterType string, value float64, duration time.Duration, source string) (*models.Booster, error) {
+	reqData := GrantBoosterRequest{
+		UUID:        playerUUID.String(),
+		Type:        boosterType,
+		Value:       value,
+		DurationSec: int64(duration.Seconds()),
+		Source:      source,
+	}
+	booster := &models.Booster{}
+	if err := c.apiClient.Post(ctx, "/game/boosters/grant", reqData, booster); err != nil {
+		return nil, err
+	}
+	return booster, nil
+}
+
+// RevokeBooster sends a POST request to the /game/boosters/revoke endpoint.
+func (c *GameServiceClient) RevokeBooster(ctx context.Context, playerUUID uuid.UUID, boosterID string) error {
+	reqData := RevokeBoosterRequest{
+		UUID:      playerUUID.String(),
+		BoosterID: boosterID,
+	}
+	return c.apiClient.Post(ctx, "/game/boosters/revoke", reqData, nil)
+}
+
+// GetBoosters fetches a player's active boosters from the /game/player/{uuid}/boosters endpoint.
+func (c *GameServiceClient) GetBoosters(ctx context.Context, playerUUID uuid.UUID) ([]models.Booster, error) {
+	var boosters []models.Booster
+	if err := c.apiClient.Get(ctx, fmt.Sprintf("/game/player/%s/boosters", playerUUID.String()), &boosters); err != nil {
+		return nil, err
+	}
+	return boosters, nil
+}
+
+// GetLeaderboardTop fetches the top limit players from the /game/leaderboard endpoint.
+// Pass an empty teamID for the global leaderboard.
+func (c *GameServiceClient) GetLeaderboardTop(ctx context.Context, teamID string, limit int) ([]models.LeaderboardEntry, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if teamID != "" {
+		query.Set("team", teamID)
+	}
+
+	var entries []models.LeaderboardEntry
+	if err := c.apiClient.Get(ctx, "/game/leaderboard?"+query.Encode(), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetLeaderboardStanding fetches a player's rank and percentile from the /game/leaderboard/player/{uuid} endpoint.
+// Pass an empty teamID for the global leaderboard. Returns an error wrapping api.ErrNotFound if the player is unranked.
+func (c *GameServiceClient) GetLeaderboardStanding(ctx context.Context, playerUUID uuid.UUID, teamID string) (*models.LeaderboardStanding, error) {
+	path := fmt.Sprintf("/game/leaderboard/player/%s", playerUUID.String())
+	if teamID != "" {
+		path += "?team=" + url.QueryEscape(teamID)
+	}
+
+	var standing models.LeaderboardStanding
+	if err := c.apiClient.Get(ctx, path, &standing); err != nil {
+		return nil, wrapLeaderboardNotFound(err, playerUUID)
+	}
+	return &standing, nil
+}
+
+// GetLeaderboardNeighbours fetches the players ranked up to radius places around a player from the
+// /game/leaderboard/player/{uuid}/neighbours endpoint. Pass an empty teamID for the global leaderboard.
+func (c *GameServiceClient) GetLeaderboardNeighbours(ctx context.Context, playerUUID uuid.UUID, teamID string, radius int) ([]models.LeaderboardEntry, error) {
+	query := url.Values{}
+	query.Set("radius", strconv.Itoa(radius))
+	if teamID != "" {
+		query.Set("team", teamID)
+	}
+
+	var entries []models.LeaderboardEntry
+	path := fmt.Sprintf("/game/leaderboard/player/%s/neighbours?%s", playerUUID.String(), query.Encode())
+	if err := c.apiClient.Get(ctx, path, &entries); err != nil {
+		return nil, wrapLeaderboardNotFound(err, playerUUID)
+	}
+	return entries, nil
+}
+
+// wrapLeaderboardNotFound wraps 404s from the leaderboard endpoints with api.ErrNotFound.
+func wrapLeaderboardNotFound(err error, playerUUID uuid.UUID) error {
+	var apiErr *api.HTTPError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("player %s is not on the leaderboard: %w", playerUUID.String(), api.ErrNotFound)
+	}
+	return err
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"net/http" // Required for http.StatusNotFound
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/Ftotnem/Backend/go/shared/api"    // Import the shared API client
@@ -14,26 +16,43 @@ import (
 
 // PlayerServiceClient is a client for the Player Data Service.
 type PlayerServiceClient struct {
-	apiClient *api.Client
+	apiClient     *api.Client
+	archiveClient *api.Client // Longer timeout, for season archival which copies every profile
 }
 
-// NewPlayerClient creates a new Player Data Service client.
-func NewPlayerClient(baseURL string) *PlayerServiceClient {
+// NewPlayerClient creates a new Player Data Service client. Pass api.WithSigner to authenticate its requests.
+func NewPlayerClient(baseURL string, opts ...api.ClientOption) *PlayerServiceClient {
 	return &PlayerServiceClient{
-		apiClient: api.NewClient(baseURL, 5*time.Second), // Use the shared API client with a timeout
+		apiClient:     api.NewClient(baseURL, 5*time.Second, opts...), // Use the shared API client with a timeout
+		archiveClient: api.NewClient(baseURL, 2*time.Minute, opts...),
 	}
 }
 
-// UpdateBanStatusRequest is the structure for the request body for updating ban status.
-// This mirrors the UpdateBanStatusRequest in your player-service.
-type UpdateBanStatusRequest struct {
-	Banned       bool       `json:"banned"`
-	BanExpiresAt *time.Time `json:"banExpiresAt"`
+// IssuePunishmentRequest is the structure for the request body for issuing a punishment.
+// This mirrors the IssuePunishmentRequest in your player-service.
+type IssuePunishmentRequest struct {
+	Type            string `json:"type"` // One of the models.PunishmentType* constants
+	Reason          string `json:"reason"`
+	IssuedBy        string `json:"issuedBy"`
+	DurationSeconds int64  `json:"durationSeconds"` // 0 for a permanent punishment
+}
+
+// RevokePunishmentsRequest is the structure for the request body for revoking a player's active punishments of a type.
+type RevokePunishmentsRequest struct {
+	Type      string `json:"type"`
+	RevokedBy string `json:"revokedBy"`
+	Reason    string `json:"reason"`
+}
+
+// RevokePunishmentsResponse reports how many active punishments were revoked.
+type RevokePunishmentsResponse struct {
+	Revoked int64 `json:"revoked"`
 }
 
 // UpdatePlaytimeRequest is the structure for updating playtime.
 type UpdatePlaytimeRequest struct {
-	TicksToSet float64 `json:"ticksToSet"` // Matches the server-side field name
+	TicksToSet  float64 `json:"ticksToSet"`  // Matches the server-side field name
+	SeasonEpoch int64   `json:"seasonEpoch"` // Season epoch the total belongs to; rejected if the profile has been reset since
 }
 
 // UpdateDeltaPlaytimeRequest is the structure for updating delta playtime.
@@ -41,12 +60,46 @@ type UpdateDeltaPlaytimeRequest struct {
 	TicksToSet float64 `json:"ticksToSet"` // Matches the server-side field name
 }
 
+// UpdateAFKTicksRequest is the structure for updating the total ticks a player has spent AFK.
+type UpdateAFKTicksRequest struct {
+	TicksToSet float64 `json:"ticksToSet"` // Matches the server-side field name
+}
+
+// BulkUpdatePlaytimeRequest is the structure for setting many players' total playtime at once.
+// This mirrors the BulkUpdatePlaytimeRequest in your player-service.
+type BulkUpdatePlaytimeRequest struct {
+	Updates []PlaytimeUpdate `json:"updates"`
+}
+
+// PlaytimeUpdate is a single player's total playtime, and optionally AFK time, in a bulk update.
+type PlaytimeUpdate struct {
+	UUID          string   `json:"uuid"`
+	TicksToSet    float64  `json:"ticksToSet"`
+	AFKTicksToSet *float64 `json:"afkTicksToSet,omitempty"` // Nil leaves the stored AFK time unchanged
+	SeasonEpoch   int64    `json:"seasonEpoch"`             // Season epoch the total belongs to; skipped if the profile has been reset since
+}
+
+// BulkUpdatePlaytimeResponse reports how many of the updated profiles exist.
+type BulkUpdatePlaytimeResponse struct {
+	Requested int   `json:"requested"`
+	Matched   int64 `json:"matched"`
+}
+
 // CreateProfileRequest is the structure for creating a new player profile.
 // This directly maps to the server's CreateProfileRequest.
 type CreateProfileRequest struct {
 	UUID string `json:"uuid"`
 }
 
+// GrantProfileBoosterRequest is the structure for granting a booster to a player profile.
+// This mirrors the GrantBoosterRequest in your player-service.
+type GrantProfileBoosterRequest struct {
+	Type            string  `json:"type"`
+	Value           float64 `json:"value"`
+	DurationSeconds int64   `json:"durationSeconds"` // 0 for a booster that never expires
+	Source          string  `json:"source"`
+}
+
 // SyncPlayerPlaytimeResponse defines the expected response structure from the player service's sync endpoint.
 type SyncPlayerPlaytimeResponse struct {
 	TeamTotals map[string]float64 `json:"teamTotals"` // Map of teamID to calculated total playtime
@@ -70,14 +123,54 @@ func (c *PlayerServiceClient) GetProfile(ctx context.Context, playerUUID uuid.UU
 	return profile, nil
 }
 
-// UpdateProfileBanStatus sends a PUT request to update a player profile's ban status.
-// PUT /profiles/{uuid}/ban
-func (c *PlayerServiceClient) UpdateProfileBanStatus(ctx context.Context, playerUUID uuid.UUID, banned bool, banExpiresAt *time.Time) error {
-	reqData := UpdateBanStatusRequest{
-		Banned:       banned,
-		BanExpiresAt: banExpiresAt,
+// IssuePunishment sends a POST request to record a punishment for a player and returns the stored record.
+// The player's profile ban status is derived from their active bans by the player service.
+// POST /profiles/{uuid}/punishments
+func (c *PlayerServiceClient) IssuePunishment(ctx context.Context, playerUUID uuid.UUID, reqData IssuePunishmentRequest) (*models.Punishment, error) {
+	var punishment models.Punishment
+	if err := c.apiClient.Post(ctx, fmt.Sprintf("/profiles/%s/punishments", playerUUID.String()), reqData, &punishment); err != nil {
+		return nil, err
+	}
+	return &punishment, nil
+}
+
+// RevokePunishments sends a POST request to lift a player's active punishments of a type
+// and returns how many were revoked.
+// POST /profiles/{uuid}/punishments/revoke
+func (c *PlayerServiceClient) RevokePunishments(ctx context.Context, playerUUID uuid.UUID, punishmentType, revokedBy, reason string) (int64, error) {
+	reqData := RevokePunishmentsRequest{
+		Type:      punishmentType,
+		RevokedBy: revokedBy,
+		Reason:    reason,
+	}
+	var resp RevokePunishmentsResponse
+	if err := c.apiClient.Post(ctx, fmt.Sprintf("/profiles/%s/punishments/revoke", playerUUID.String()), reqData, &resp); err != nil {
+		return 0, err
 	}
-	return c.apiClient.Put(ctx, fmt.Sprintf("/profiles/%s/ban", playerUUID.String()), reqData, nil)
+	return resp.Revoked, nil
+}
+
+// GetPunishments sends a GET request for a player's punishment history, newest first.
+// An empty punishmentType lists every type; activeOnly leaves out expired and revoked punishments.
+// GET /profiles/{uuid}/punishments
+func (c *PlayerServiceClient) GetPunishments(ctx context.Context, playerUUID uuid.UUID, punishmentType string, activeOnly bool) ([]models.Punishment, error) {
+	query := url.Values{}
+	if punishmentType != "" {
+		query.Set("type", punishmentType)
+	}
+	if activeOnly {
+		query.Set("active", "true")
+	}
+	path := fmt.Sprintf("/profiles/%s/punishments", playerUUID.String())
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var punishments []models.Punishment
+	if err := c.apiClient.Get(ctx, path, &punishments); err != nil {
+		return nil, err
+	}
+	return punishments, nil
 }
 
 // UpdateProfileLastLogin sends a PUT request to update a player profile's last login timestamp.
@@ -87,13 +180,46 @@ func (c *PlayerServiceClient) UpdateProfileLastLogin(ctx context.Context, player
 	return c.apiClient.Put(ctx, fmt.Sprintf("/profiles/%s/lastlogin", playerUUID.String()), nil, nil)
 }
 
-// UpdateProfilePlaytime sends a PUT request to update a player profile's total playtime.
+// UpdateProfilePlaytime sends a PUT request to update a player profile's total playtime, which belongs
+// to seasonEpoch. Returns an error wrapping api.ErrConflict if the profile has been reset for a later season since.
 // PUT /profiles/{uuid}/playtime
-func (c *PlayerServiceClient) UpdateProfilePlaytime(ctx context.Context, playerUUID uuid.UUID, playtimeTicks float64) error {
+func (c *PlayerServiceClient) UpdateProfilePlaytime(ctx context.Context, playerUUID uuid.UUID, playtimeTicks float64, seasonEpoch int64) error {
 	reqData := UpdatePlaytimeRequest{
-		TicksToSet: playtimeTicks,
+		TicksToSet:  playtimeTicks,
+		SeasonEpoch: seasonEpoch,
+	}
+	err := c.apiClient.Put(ctx, fmt.Sprintf("/profiles/%s/playtime", playerUUID.String()), reqData, nil)
+	if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%w: player profile %s has been reset for a later season", api.ErrConflict, playerUUID.String())
 	}
-	return c.apiClient.Put(ctx, fmt.Sprintf("/profiles/%s/playtime", playerUUID.String()), reqData, nil)
+	return err
+}
+
+// UpdateProfilesPlaytime sends a PUT request to set the total playtime of many player profiles at once.
+// PUT /profiles/playtime
+func (c *PlayerServiceClient) UpdateProfilesPlaytime(ctx context.Context, updates []PlaytimeUpdate) (*BulkUpdatePlaytimeResponse, error) {
+	var resp BulkUpdatePlaytimeResponse
+	if err := c.apiClient.Put(ctx, "/profiles/playtime", BulkUpdatePlaytimeRequest{Updates: updates}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListProfilePlaytimes fetches one page of profiles (UUID, team and total playtime only), ordered by UUID.
+// Pass the last UUID of the previous page as afterUUID, or "" for the first page.
+// GET /profiles/playtime?after={uuid}&limit={n}
+func (c *PlayerServiceClient) ListProfilePlaytimes(ctx context.Context, afterUUID string, limit int) ([]models.Player, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if afterUUID != "" {
+		query.Set("after", afterUUID)
+	}
+
+	var profiles []models.Player
+	if err := c.apiClient.Get(ctx, "/profiles/playtime?"+query.Encode(), &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
 }
 
 // UpdateProfileDeltaPlaytime sends a PUT request to update a player profile's delta playtime.
@@ -105,6 +231,15 @@ func (c *PlayerServiceClient) UpdateProfileDeltaPlaytime(ctx context.Context, pl
 	return c.apiClient.Put(ctx, fmt.Sprintf("/profiles/%s/deltaplaytime", playerUUID.String()), reqData, nil)
 }
 
+// UpdateProfileAFKTicks sends a PUT request to update a player's total AFK ticks.
+// PUT /profiles/{uuid}/afk
+func (c *PlayerServiceClient) UpdateProfileAFKTicks(ctx context.Context, playerUUID uuid.UUID, afkTicks float64) error {
+	reqData := UpdateAFKTicksRequest{
+		TicksToSet: afkTicks,
+	}
+	return c.apiClient.Put(ctx, fmt.Sprintf("/profiles/%s/afk", playerUUID.String()), reqData, nil)
+}
+
 // SyncPlayerPlaytime triggers the player service to synchronize playtime data from Redis to MongoDB
 // and also returns the aggregated team totals.
 // POST /player/sync-playtime
@@ -118,3 +253,110 @@ func (c *PlayerServiceClient) SyncPlayerPlaytime(ctx context.Context) (*SyncPlay
 	}
 	return &resp, nil
 }
+
+// GrantBooster sends a POST request to grant a booster to a player profile.
+// POST /profiles/{uuid}/boosters
+// A zero duration grants a booster that never expires.
+// Returns an error wrapping api.ErrNotFound if the profile does not exist.
+func (c *PlayerServiceClient) GrantBooster(ctx context.Context, playerUUID uuid.UUID, boosterType string, value float64, duration time.Duration, source string) (*models.Booster, error) {
+	reqData := GrantProfileBoosterRequest{
+		Type:            boosterType,
+		Value:           value,
+		DurationSeconds: int64(duration.Seconds()),
+		Source:          source,
+	}
+	booster := &models.Booster{}
+	if err := c.apiClient.Post(ctx, fmt.Sprintf("/profiles/%s/boosters", playerUUID.String()), reqData, booster); err != nil {
+		if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: player profile %s", api.ErrNotFound, playerUUID.String())
+		}
+		return nil, fmt.Errorf("failed to grant booster to player %s: %w", playerUUID.String(), err)
+	}
+	return booster, nil
+}
+
+// RevokeBooster sends a DELETE request to remove a booster from a player profile.
+// DELETE /profiles/{uuid}/boosters/{boosterID}
+func (c *PlayerServiceClient) RevokeBooster(ctx context.Context, playerUUID uuid.UUID, boosterID string) error {
+	err := c.apiClient.Delete(ctx, fmt.Sprintf("/profiles/%s/boosters/%s", playerUUID.String(), url.PathEscape(boosterID)), nil)
+	if err != nil {
+		if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: booster %s for player %s", api.ErrNotFound, boosterID, playerUUID.String())
+		}
+		return fmt.Errorf("failed to revoke booster %s for player %s: %w", boosterID, playerUUID.String(), err)
+	}
+	return nil
+}
+
+// GetBoosters fetches a player's active (unexpired) boosters.
+// GET /profiles/{uuid}/boosters
+func (c *PlayerServiceClient) GetBoosters(ctx context.Context, playerUUID uuid.UUID) ([]models.Booster, error) {
+	var boosters []models.Booster
+	err := c.apiClient.Get(ctx, fmt.Sprintf("/profiles/%s/boosters", playerUUID.String()), &boosters)
+	if err != nil {
+		if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: player profile %s", api.ErrNotFound, playerUUID.String())
+		}
+		return nil, fmt.Errorf("failed to get boosters for player %s: %w", playerUUID.String(), err)
+	}
+	return boosters, nil
+}
+
+// GetCurrentSeason fetches the season in progress: active, or ended but not yet fully archived.
+// GET /seasons/current
+// Returns an error wrapping api.ErrNotFound if no season is in progress.
+func (c *PlayerServiceClient) GetCurrentSeason(ctx context.Context) (*models.Season, error) {
+	season := &models.Season{}
+	if err := c.apiClient.Get(ctx, "/seasons/current", season); err != nil {
+		if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: no season in progress", api.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get current season: %w", err)
+	}
+	return season, nil
+}
+
+// GetSeason fetches a season by ID.
+// GET /seasons/{id}
+// Returns an error wrapping api.ErrNotFound if the season does not exist.
+func (c *PlayerServiceClient) GetSeason(ctx context.Context, seasonID string) (*models.Season, error) {
+	season := &models.Season{}
+	if err := c.apiClient.Get(ctx, "/seasons/"+url.PathEscape(seasonID), season); err != nil {
+		if apiErr, ok := err.(*api.HTTPError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: season %s", api.ErrNotFound, seasonID)
+		}
+		return nil, fmt.Errorf("failed to get season %s: %w", seasonID, err)
+	}
+	return season, nil
+}
+
+// ListSeasons fetches every season ordered by start time.
+// GET /seasons
+func (c *PlayerServiceClient) ListSeasons(ctx context.Context) ([]models.Season, error) {
+	var seasons []models.Season
+	if err := c.apiClient.Get(ctx, "/seasons", &seasons); err != nil {
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	return seasons, nil
+}
+
+// ActivateSeason starts a scheduled season.
+// POST /seasons/{id}/activate
+func (c *PlayerServiceClient) ActivateSeason(ctx context.Context, seasonID string) (*models.Season, error) {
+	season := &models.Season{}
+	if err := c.apiClient.Post(ctx, "/seasons/"+url.PathEscape(seasonID)+"/activate", nil, season); err != nil {
+		return nil, fmt.Errorf("failed to activate season %s: %w", seasonID, err)
+	}
+	return season, nil
+}
+
+// ArchiveSeason archives a season's standings and resets the live totals in MongoDB.
+// It is safe to retry; archiving an archived season returns it unchanged.
+// POST /seasons/{id}/archive
+func (c *PlayerServiceClient) ArchiveSeason(ctx context.Context, seasonID string) (*models.Season, error) {
+	season := &models.Season{}
+	if err := c.archiveClient.Post(ctx, "/seasons/"+url.PathEscape(seasonID)+"/archive", nil, season); err != nil {
+		return nil, fmt.Errorf("failed to archive season %s: %w", seasonID, err)
+	}
+	return season, nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ftotnem/Backend/go/shared/api"
+	"github.com/Ftotnem/Backend/go/shared/models"
+)
+
+// Reconnect backoff bounds for SubscribeTeamTotals.
+const (
+	teamStreamMinBackoff = 1 * time.Second
+	teamStreamMaxBackoff = 30 * time.Second
+)
+
+// SubscribeTeamTotals consumes the game service's live team totals stream (Server-Sent Events)
+// and calls handle for every update. It reconnects with backoff whenever the stream drops,
+// and only returns once ctx is cancelled. handle is called from a single goroutine.
+// GET /game/stream/teams
+func (c *GameServiceClient) SubscribeTeamTotals(ctx context.Context, handle func(models.TeamTotalsUpdate)) error {
+	backoff := teamStreamMinBackoff
+	for {
+		received, err := c.streamTeamTotals(ctx, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			backoff = teamStreamMinBackoff // The connection was healthy; reconnect promptly
+		}
+		api.Logger(ctx).Warn("Team totals stream disconnected, reconnecting", "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > teamStreamMaxBackoff {
+			backoff = teamStreamMaxBackoff
+		}
+	}
+}
+
+// streamTeamTotals reads one SSE connection until it ends. It reports whether any update was received.
+func (c *GameServiceClient) streamTeamTotals(ctx context.Context, handle func(models.TeamTotalsUpdate)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/game/stream/teams", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create team totals stream request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to team totals stream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("team totals stream returned HTTP %d", resp.StatusCode)
+	}
+
+	received := false
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line ends the event
+			if data.Len() == 0 {
+				continue
+			}
+			var update models.TeamTotalsUpdate
+			if err := json.Unmarshal([]byte(data.String()), &update); err != nil {
+				api.Logger(ctx).Warn("Skipping malformed team totals event", "error", err)
+			} else {
+				received = true
+				handle(update)
+			}
+			data.Reset()
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		default:
+			// Comments (keep-alives), event names and ids need no handling
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return received, fmt.Errorf("team totals stream read failed: %w", err)
+	}
+	return received, fmt.Errorf("team totals stream closed by server")
+}